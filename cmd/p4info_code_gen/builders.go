@@ -0,0 +1,425 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ettle/strcase"
+	p4ConfigV1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+)
+
+const (
+	builderPrefix      = "Build"
+	matchStructSuffix  = "Match"
+	paramsStructSuffix = "Params"
+	entrySuffix        = "Entry"
+
+	maxBuilderBitwidth = 64
+)
+
+// buildersPreamble holds the helpers used by the generated builders.
+// It uses raw strings to avoid issues with reuse.
+const buildersPreamble = `
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+var (
+	// ErrInvalidValue is returned if a value does not fit the bitwidth of its match field or action parameter.
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrInvalidAction is returned if an action is not allowed for the entries of a table.
+	ErrInvalidAction = errors.New("invalid action")
+)
+
+// encodeValue returns value encoded in the minimum number of bytes required by bitwidth.
+func encodeValue(value uint64, bitwidth int32) ([]byte, error) {
+	if bitwidth < 64 && value>>uint(bitwidth) != 0 {
+		return nil, fmt.Errorf("%w: %d does not fit in %d bits", ErrInvalidValue, value, bitwidth)
+	}
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, value)
+
+	return buf[8-(bitwidth+7)/8:], nil
+}
+
+// maxValue returns the maximum value of a field of bitwidth bits.
+func maxValue(bitwidth int32) uint64 {
+	if bitwidth >= 64 {
+		return ^uint64(0)
+	}
+
+	return 1<<uint(bitwidth) - 1
+}
+
+type entryBuilder struct {
+	tableName string
+	entry     *p4.TableEntry
+	err       error
+}
+
+func newEntryBuilder(tableName string, tableID uint32, priority int32, action *p4.Action) *entryBuilder {
+	return &entryBuilder{
+		tableName: tableName,
+		entry: &p4.TableEntry{
+			TableId:  tableID,
+			Priority: priority,
+			Action: &p4.TableAction{
+				Type: &p4.TableAction_Action{Action: action},
+			},
+		},
+	}
+}
+
+func (b *entryBuilder) fail(name string, err error) {
+	b.err = fmt.Errorf("match field %s of table %s: %w", name, b.tableName, err)
+}
+
+func (b *entryBuilder) exact(name string, fieldID uint32, bitwidth int32, value uint64) {
+	if b.err != nil {
+		return
+	}
+
+	v, err := encodeValue(value, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: v}},
+	})
+}
+
+// lpm omits the match field if prefixLen is 0 (wildcard), and masks value to prefixLen.
+func (b *entryBuilder) lpm(name string, fieldID uint32, bitwidth int32, value uint64, prefixLen int32) {
+	if b.err != nil || prefixLen == 0 {
+		return
+	}
+
+	if prefixLen < 0 || prefixLen > bitwidth {
+		b.fail(name, fmt.Errorf("%w: prefix length %d out of range", ErrInvalidValue, prefixLen))
+		return
+	}
+
+	v, err := encodeValue(value&^maxValue(bitwidth-prefixLen), bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Lpm{Lpm: &p4.FieldMatch_LPM{Value: v, PrefixLen: prefixLen}},
+	})
+}
+
+// ternary omits the match field if mask is 0 (wildcard), and masks value.
+func (b *entryBuilder) ternary(name string, fieldID uint32, bitwidth int32, value uint64, mask uint64) {
+	if b.err != nil || mask == 0 {
+		return
+	}
+
+	m, err := encodeValue(mask, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	v, err := encodeValue(value&mask, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Ternary_{Ternary: &p4.FieldMatch_Ternary{Value: v, Mask: m}},
+	})
+}
+
+// rangeMatch omits the match field if [low, high] covers all values (wildcard).
+func (b *entryBuilder) rangeMatch(name string, fieldID uint32, bitwidth int32, low uint64, high uint64) {
+	if b.err != nil || (low == 0 && high == maxValue(bitwidth)) {
+		return
+	}
+
+	if low > high {
+		b.fail(name, fmt.Errorf("%w: empty range [%d, %d]", ErrInvalidValue, low, high))
+		return
+	}
+
+	l, err := encodeValue(low, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	h, err := encodeValue(high, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Range_{Range: &p4.FieldMatch_Range{Low: l, High: h}},
+	})
+}
+
+func (b *entryBuilder) build() (*p4.TableEntry, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return b.entry, nil
+}
+
+type actionBuilder struct {
+	actionName string
+	action     *p4.Action
+	err        error
+}
+
+func newActionBuilder(actionName string, actionID uint32) *actionBuilder {
+	return &actionBuilder{
+		actionName: actionName,
+		action:     &p4.Action{ActionId: actionID},
+	}
+}
+
+func (b *actionBuilder) param(name string, paramID uint32, bitwidth int32, value uint64) {
+	if b.err != nil {
+		return
+	}
+
+	v, err := encodeValue(value, bitwidth)
+	if err != nil {
+		b.err = fmt.Errorf("param %s of action %s: %w", name, b.actionName, err)
+		return
+	}
+
+	b.action.Params = append(b.action.Params, &p4.Action_Param{ParamId: paramID, Value: v})
+}
+
+func (b *actionBuilder) build() (*p4.Action, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return b.action, nil
+}
+`
+
+// goTypeForBitwidth returns the smallest unsigned Go type holding bitwidth bits.
+func goTypeForBitwidth(bitwidth int32) string {
+	switch {
+	case bitwidth <= 8:
+		return "uint8"
+	case bitwidth <= 16:
+		return "uint16"
+	case bitwidth <= 32:
+		return "uint32"
+	default:
+		return "uint64"
+	}
+}
+
+func mustCheckBuilderBitwidth(entity string, name string, bitwidth int32) {
+	if bitwidth <= 0 || bitwidth > maxBuilderBitwidth {
+		panic(fmt.Sprintf("unsupported bitwidth %d of %s in %s", bitwidth, name, entity))
+	}
+}
+
+func fieldIdentifier(name string) string {
+	return strcase.ToPascal(strings.Replace(name, ".", "_", -1))
+}
+
+// tableNeedsPriority returns true if the entries of table require a priority.
+// See the P4Runtime specification, section 9.1.
+func tableNeedsPriority(table *p4ConfigV1.Table) bool {
+	for _, mf := range table.GetMatchFields() {
+		switch mf.GetMatchType() {
+		case p4ConfigV1.MatchField_TERNARY, p4ConfigV1.MatchField_RANGE, p4ConfigV1.MatchField_OPTIONAL:
+			return true
+		}
+	}
+
+	return false
+}
+
+func generateTableBuilder(info *p4ConfigV1.P4Info, table *p4ConfigV1.Table) string {
+	tableName := table.GetPreamble().GetName()
+	tableConst := entityIdentifier(tblVarPrefix, tableName)
+	matchStruct := tableConst + matchStructSuffix
+	builderName := builderPrefix + tableConst + entrySuffix
+
+	actionNames := make(map[uint32]string)
+	for _, action := range info.GetActions() {
+		actionNames[action.GetPreamble().GetId()] = action.GetPreamble().GetName()
+	}
+
+	structBuilder, bodyBuilder := strings.Builder{}, strings.Builder{}
+	hasWildcards := false
+
+	structBuilder.WriteString(fmt.Sprintf("// %s holds the match fields of table %s.\n", matchStruct, tableName))
+	structBuilder.WriteString(fmt.Sprintf("type %s struct {\n", matchStruct))
+
+	for _, mf := range table.GetMatchFields() {
+		name, bitwidth := mf.GetName(), mf.GetBitwidth()
+		mustCheckBuilderBitwidth(tableName, name, bitwidth)
+
+		field, goType := fieldIdentifier(name), goTypeForBitwidth(bitwidth)
+		hdrConst := entityIdentifier(hfVarPrefix+tableName, name)
+
+		switch mf.GetMatchType() {
+		case p4ConfigV1.MatchField_EXACT:
+			structBuilder.WriteString(fmt.Sprintf("%s %s\n", field, goType))
+			bodyBuilder.WriteString(fmt.Sprintf("b.exact(%q, %s, %d, uint64(match.%s))\n",
+				name, hdrConst, bitwidth, field))
+		case p4ConfigV1.MatchField_LPM:
+			hasWildcards = true
+
+			structBuilder.WriteString(fmt.Sprintf("%s %s\n%sPrefixLen int32\n", field, goType, field))
+			bodyBuilder.WriteString(fmt.Sprintf("b.lpm(%q, %s, %d, uint64(match.%s), match.%sPrefixLen)\n",
+				name, hdrConst, bitwidth, field, field))
+		case p4ConfigV1.MatchField_TERNARY:
+			hasWildcards = true
+
+			structBuilder.WriteString(fmt.Sprintf("%s %s\n%sMask %s\n", field, goType, field, goType))
+			bodyBuilder.WriteString(fmt.Sprintf("b.ternary(%q, %s, %d, uint64(match.%s), uint64(match.%sMask))\n",
+				name, hdrConst, bitwidth, field, field))
+		case p4ConfigV1.MatchField_RANGE:
+			hasWildcards = true
+
+			structBuilder.WriteString(fmt.Sprintf("%sLow %s\n%sHigh %s\n", field, goType, field, goType))
+			bodyBuilder.WriteString(fmt.Sprintf("b.rangeMatch(%q, %s, %d, uint64(match.%sLow), uint64(match.%sHigh))\n",
+				name, hdrConst, bitwidth, field, field))
+		default:
+			panic(fmt.Sprintf("unsupported match type %v of %s in %s", mf.GetMatchType(), name, tableName))
+		}
+	}
+
+	structBuilder.WriteString("}\n\n")
+
+	allowedActions := make([]string, 0, len(table.GetActionRefs()))
+	allowedActionNames := make([]string, 0, len(table.GetActionRefs()))
+
+	for _, ref := range table.GetActionRefs() {
+		if ref.GetScope() == p4ConfigV1.ActionRef_DEFAULT_ONLY {
+			continue
+		}
+
+		actionName, ok := actionNames[ref.GetId()]
+		if !ok {
+			panic(fmt.Sprintf("unknown action %d referenced by %s", ref.GetId(), tableName))
+		}
+
+		allowedActions = append(allowedActions, entityIdentifier(actVarPrefix, actionName))
+		allowedActionNames = append(allowedActionNames, actionName)
+	}
+
+	priorityParam, priorityValue := "", "0"
+	if tableNeedsPriority(table) {
+		priorityParam, priorityValue = ", priority int32", "priority"
+	}
+
+	fb := strings.Builder{}
+	fb.WriteString(structBuilder.String())
+	fb.WriteString(fmt.Sprintf("// %s returns an entry of table %s.\n", builderName, tableName))
+
+	if hasWildcards {
+		fb.WriteString("// Wildcard match fields are omitted.\n")
+	}
+
+	fb.WriteString(fmt.Sprintf("// action must be one of: %s.\n", strings.Join(allowedActionNames, ", ")))
+	fb.WriteString(fmt.Sprintf("func %s(match %s, action *p4.Action%s) (*p4.TableEntry, error) {\n",
+		builderName, matchStruct, priorityParam))
+	fb.WriteString("switch action.GetActionId() {\n")
+
+	if len(allowedActions) != 0 {
+		fb.WriteString(fmt.Sprintf("case %s:\n", strings.Join(allowedActions, ", ")))
+	}
+
+	fb.WriteString("default:\n")
+	fb.WriteString(fmt.Sprintf("return nil, fmt.Errorf(\"%%w: %%d for table %s\", ErrInvalidAction, action.GetActionId())\n", tableName))
+	fb.WriteString("}\n\n")
+	fb.WriteString(fmt.Sprintf("b := newEntryBuilder(%q, %s, %s, action)\n", tableName, tableConst, priorityValue))
+	fb.WriteString(bodyBuilder.String())
+	fb.WriteString("\nreturn b.build()\n}\n\n")
+
+	return fb.String()
+}
+
+func generateActionBuilder(action *p4ConfigV1.Action) string {
+	actionName := action.GetPreamble().GetName()
+	actionConst := entityIdentifier(actVarPrefix, actionName)
+	builderName := builderPrefix + actionConst
+
+	fb := strings.Builder{}
+
+	if len(action.GetParams()) == 0 {
+		fb.WriteString(fmt.Sprintf("// %s returns action %s.\n", builderName, actionName))
+		fb.WriteString(fmt.Sprintf("func %s() *p4.Action {\n", builderName))
+		fb.WriteString(fmt.Sprintf("return &p4.Action{ActionId: %s}\n}\n\n", actionConst))
+
+		return fb.String()
+	}
+
+	paramsStruct := actionConst + paramsStructSuffix
+	structBuilder, bodyBuilder := strings.Builder{}, strings.Builder{}
+
+	for _, param := range action.GetParams() {
+		name, bitwidth := param.GetName(), param.GetBitwidth()
+		mustCheckBuilderBitwidth(actionName, name, bitwidth)
+
+		field := fieldIdentifier(name)
+		structBuilder.WriteString(fmt.Sprintf("%s %s\n", field, goTypeForBitwidth(bitwidth)))
+		bodyBuilder.WriteString(fmt.Sprintf("b.param(%q, %s, %d, uint64(params.%s))\n",
+			name, entityIdentifier(actparamVarPrefix+actionName, name), bitwidth, field))
+	}
+
+	fb.WriteString(fmt.Sprintf("// %s holds the parameters of action %s.\n", paramsStruct, actionName))
+	fb.WriteString(fmt.Sprintf("type %s struct {\n", paramsStruct))
+	fb.WriteString(structBuilder.String())
+	fb.WriteString("}\n\n")
+	fb.WriteString(fmt.Sprintf("// %s returns action %s with params.\n", builderName, actionName))
+	fb.WriteString(fmt.Sprintf("func %s(params %s) (*p4.Action, error) {\n", builderName, paramsStruct))
+	fb.WriteString(fmt.Sprintf("b := newActionBuilder(%q, %s)\n", actionName, actionConst))
+	fb.WriteString(bodyBuilder.String())
+	fb.WriteString("\nreturn b.build()\n}\n\n")
+
+	return fb.String()
+}
+
+// generateBuilders returns typed builders of the entries of the non-const tables and of the actions.
+// The builders refer to the constants generated by generateConstants.
+func generateBuilders(p4info *p4ConfigV1.P4Info) string {
+	sb := strings.Builder{}
+
+	sb.WriteString(buildersPreamble + "\n")
+
+	sb.WriteString("// Tables\n")
+	for _, table := range p4info.GetTables() {
+		if table.GetIsConstTable() {
+			continue
+		}
+
+		sb.WriteString(generateTableBuilder(p4info, table))
+	}
+
+	sb.WriteString("// Actions\n")
+	for _, action := range p4info.GetActions() {
+		sb.WriteString(generateActionBuilder(action))
+	}
+
+	return sb.String()
+}
@@ -52,29 +52,25 @@ const (
 	bitwidthAPVarPrefix = "BitwidthAp_"
 )
 
-func emitEntityConstantUint32(prefix string, p4EntityName string, id uint32) string {
+// entityIdentifier returns the Go identifier of a P4 entity.
+func entityIdentifier(prefix string, p4EntityName string) string {
 	// see: https://go.dev/ref/spec#Identifiers
 	p4EntityName = prefix + "_" + p4EntityName
 	p4EntityName = strings.Replace(p4EntityName, ".", "_", -1)
-	p4EntityName = strcase.ToPascal(p4EntityName)
-	return fmt.Sprintf("%s \t %s = %v\n", p4EntityName, uint32TypeString, id)
+	return strcase.ToPascal(p4EntityName)
+}
+
+func emitEntityConstantUint32(prefix string, p4EntityName string, id uint32) string {
+	return fmt.Sprintf("%s \t %s = %v\n", entityIdentifier(prefix, p4EntityName), uint32TypeString, id)
 }
 
 func emitEntityConstantInt32(prefix string, p4EntityName string, value int32) string {
-	// see: https://go.dev/ref/spec#Identifiers
-	p4EntityName = prefix + "_" + p4EntityName
-	p4EntityName = strings.Replace(p4EntityName, ".", "_", -1)
-	p4EntityName = strcase.ToPascal(p4EntityName)
-	return fmt.Sprintf("%s \t %s = %v\n", p4EntityName, int32TypeString, value)
+	return fmt.Sprintf("%s \t %s = %v\n", entityIdentifier(prefix, p4EntityName), int32TypeString, value)
 }
 
 // TODO: collapse with emitEntityConstantUint32
 func emitEntitySizeConstant(prefix string, p4EntityName string, id int64) string {
-	// see: https://go.dev/ref/spec#Identifiers
-	p4EntityName = prefix + "_" + p4EntityName
-	p4EntityName = strings.Replace(p4EntityName, ".", "_", -1)
-	p4EntityName = strcase.ToPascal(p4EntityName)
-	return fmt.Sprintf("%s \t %s = %v\n", p4EntityName, int64TypeString, id)
+	return fmt.Sprintf("%s \t %s = %v\n", entityIdentifier(prefix, p4EntityName), int64TypeString, id)
 }
 
 func getPreambles(info *p4ConfigV1.P4Info, p4Type string) (preambles []*p4ConfigV1.Preamble) {
@@ -322,6 +318,7 @@ func getOrderedMapKeys(fieldMap map[string]int32) *[]string {
 func main() {
 	p4infoPath := flag.String("p4info", p4infoPath, "Path of the p4info file")
 	outputPath := flag.String("output", "-", "Default will print to Stdout")
+	buildersOutputPath := flag.String("builders-output", "", "Path of the table entry builders, not generated if empty")
 	packageName := flag.String("package", defaultPackageName, "Set the package name")
 
 	flag.Parse()
@@ -344,13 +341,21 @@ func main() {
 	sb.WriteString(generateP4DataFunctions(p4info, "ControllerPacketMetadata"))
 	sb.WriteString(generateP4DataFunctions(p4info, "Register"))
 
-	result := sb.String()
+	mustWriteOutput(*outputPath, sb.String())
+
+	if *buildersOutputPath != "" {
+		builders := copyrightHeader + "\n" + fmt.Sprintf("package %s\n", *packageName) + generateBuilders(p4info)
+		mustWriteOutput(*buildersOutputPath, builders)
+	}
+}
 
-	if *outputPath == "-" {
+func mustWriteOutput(path string, result string) {
+	if path == "-" {
 		fmt.Println(result)
-	} else {
-		if err := os.WriteFile(*outputPath, []byte(result), 0644); err != nil {
-			panic(fmt.Sprintf("Error while creating File: %v", err))
-		}
+		return
+	}
+
+	if err := os.WriteFile(path, []byte(result), 0644); err != nil {
+		panic(fmt.Sprintf("Error while creating File: %v", err))
 	}
 }
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"go/format"
 	"io/fs"
 	"io/ioutil"
 	"strconv"
@@ -46,6 +47,13 @@ tables {
   }
   size: 1024
 }
+actions {
+  preamble {
+    id: 21257015
+    name: "NoAction"
+    alias: "NoAction"
+  }
+}
 actions {
   preamble {
     id: 26090030
@@ -366,3 +374,27 @@ func Test_generator(t *testing.T) {
 		})
 	}
 }
+
+func Test_generateBuilders(t *testing.T) {
+	p4infoPath := t.TempDir() + "/dummy_p4info.pb.txt"
+	mustWriteStringToDisk(testP4InfoString, p4infoPath)
+
+	result := generateBuilders(mustGetP4Config(p4infoPath))
+
+	// match fields and params use the smallest Go type fitting their bitwidth
+	require.Contains(t, result, "type TablePreQosPipeMyStationMatch struct {\nDstMac uint64\n}")
+	require.Contains(t, result, "func BuildTablePreQosPipeMyStationEntry(match TablePreQosPipeMyStationMatch, action *p4.Action) (*p4.TableEntry, error)")
+	require.Contains(t, result, "type ActionPreQosPipeSetSourceIfaceParams struct {\nSrcIface uint8\nDirection uint8\nSliceId uint8\n}")
+	require.Contains(t, result, `b.param("slice_id", ActionParamPreQosPipeSetSourceIfaceSliceId, 4, uint64(params.SliceId))`)
+}
+
+func Test_generateBuilders_upToDate(t *testing.T) {
+	p4info := mustGetP4Config("../../" + p4infoPath)
+
+	generated, err := format.Source([]byte(copyrightHeader + "\n" + "package " + defaultPackageName + "\n" + generateBuilders(p4info)))
+	require.NoError(t, err)
+
+	committed, err := ioutil.ReadFile("../../internal/p4constants/p4builders.go")
+	require.NoError(t, err)
+	require.Equal(t, string(committed), string(generated), "run 'make p4-constants' to update the builders")
+}
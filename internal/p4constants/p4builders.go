@@ -0,0 +1,687 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package p4constants
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+var (
+	// ErrInvalidValue is returned if a value does not fit the bitwidth of its match field or action parameter.
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrInvalidAction is returned if an action is not allowed for the entries of a table.
+	ErrInvalidAction = errors.New("invalid action")
+)
+
+// encodeValue returns value encoded in the minimum number of bytes required by bitwidth.
+func encodeValue(value uint64, bitwidth int32) ([]byte, error) {
+	if bitwidth < 64 && value>>uint(bitwidth) != 0 {
+		return nil, fmt.Errorf("%w: %d does not fit in %d bits", ErrInvalidValue, value, bitwidth)
+	}
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, value)
+
+	return buf[8-(bitwidth+7)/8:], nil
+}
+
+// maxValue returns the maximum value of a field of bitwidth bits.
+func maxValue(bitwidth int32) uint64 {
+	if bitwidth >= 64 {
+		return ^uint64(0)
+	}
+
+	return 1<<uint(bitwidth) - 1
+}
+
+type entryBuilder struct {
+	tableName string
+	entry     *p4.TableEntry
+	err       error
+}
+
+func newEntryBuilder(tableName string, tableID uint32, priority int32, action *p4.Action) *entryBuilder {
+	return &entryBuilder{
+		tableName: tableName,
+		entry: &p4.TableEntry{
+			TableId:  tableID,
+			Priority: priority,
+			Action: &p4.TableAction{
+				Type: &p4.TableAction_Action{Action: action},
+			},
+		},
+	}
+}
+
+func (b *entryBuilder) fail(name string, err error) {
+	b.err = fmt.Errorf("match field %s of table %s: %w", name, b.tableName, err)
+}
+
+func (b *entryBuilder) exact(name string, fieldID uint32, bitwidth int32, value uint64) {
+	if b.err != nil {
+		return
+	}
+
+	v, err := encodeValue(value, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: v}},
+	})
+}
+
+// lpm omits the match field if prefixLen is 0 (wildcard), and masks value to prefixLen.
+func (b *entryBuilder) lpm(name string, fieldID uint32, bitwidth int32, value uint64, prefixLen int32) {
+	if b.err != nil || prefixLen == 0 {
+		return
+	}
+
+	if prefixLen < 0 || prefixLen > bitwidth {
+		b.fail(name, fmt.Errorf("%w: prefix length %d out of range", ErrInvalidValue, prefixLen))
+		return
+	}
+
+	v, err := encodeValue(value&^maxValue(bitwidth-prefixLen), bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Lpm{Lpm: &p4.FieldMatch_LPM{Value: v, PrefixLen: prefixLen}},
+	})
+}
+
+// ternary omits the match field if mask is 0 (wildcard), and masks value.
+func (b *entryBuilder) ternary(name string, fieldID uint32, bitwidth int32, value uint64, mask uint64) {
+	if b.err != nil || mask == 0 {
+		return
+	}
+
+	m, err := encodeValue(mask, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	v, err := encodeValue(value&mask, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Ternary_{Ternary: &p4.FieldMatch_Ternary{Value: v, Mask: m}},
+	})
+}
+
+// rangeMatch omits the match field if [low, high] covers all values (wildcard).
+func (b *entryBuilder) rangeMatch(name string, fieldID uint32, bitwidth int32, low uint64, high uint64) {
+	if b.err != nil || (low == 0 && high == maxValue(bitwidth)) {
+		return
+	}
+
+	if low > high {
+		b.fail(name, fmt.Errorf("%w: empty range [%d, %d]", ErrInvalidValue, low, high))
+		return
+	}
+
+	l, err := encodeValue(low, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	h, err := encodeValue(high, bitwidth)
+	if err != nil {
+		b.fail(name, err)
+		return
+	}
+
+	b.entry.Match = append(b.entry.Match, &p4.FieldMatch{
+		FieldId:        fieldID,
+		FieldMatchType: &p4.FieldMatch_Range_{Range: &p4.FieldMatch_Range{Low: l, High: h}},
+	})
+}
+
+func (b *entryBuilder) build() (*p4.TableEntry, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return b.entry, nil
+}
+
+type actionBuilder struct {
+	actionName string
+	action     *p4.Action
+	err        error
+}
+
+func newActionBuilder(actionName string, actionID uint32) *actionBuilder {
+	return &actionBuilder{
+		actionName: actionName,
+		action:     &p4.Action{ActionId: actionID},
+	}
+}
+
+func (b *actionBuilder) param(name string, paramID uint32, bitwidth int32, value uint64) {
+	if b.err != nil {
+		return
+	}
+
+	v, err := encodeValue(value, bitwidth)
+	if err != nil {
+		b.err = fmt.Errorf("param %s of action %s: %w", name, b.actionName, err)
+		return
+	}
+
+	b.action.Params = append(b.action.Params, &p4.Action_Param{ParamId: paramID, Value: v})
+}
+
+func (b *actionBuilder) build() (*p4.Action, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return b.action, nil
+}
+
+// Tables
+// TablePreQosPipeRoutingRoutesV4Match holds the match fields of table PreQosPipe.Routing.routes_v4.
+type TablePreQosPipeRoutingRoutesV4Match struct {
+	DstPrefix          uint32
+	DstPrefixPrefixLen int32
+}
+
+// BuildTablePreQosPipeRoutingRoutesV4Entry returns an entry of table PreQosPipe.Routing.routes_v4.
+// Wildcard match fields are omitted.
+// action must be one of: PreQosPipe.Routing.route.
+func BuildTablePreQosPipeRoutingRoutesV4Entry(match TablePreQosPipeRoutingRoutesV4Match, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeRoutingRoute:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.Routing.routes_v4", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.Routing.routes_v4", TablePreQosPipeRoutingRoutesV4, 0, action)
+	b.lpm("dst_prefix", HdrPreQosPipeRoutingRoutesV4DstPrefix, 32, uint64(match.DstPrefix), match.DstPrefixPrefixLen)
+
+	return b.build()
+}
+
+// TablePreQosPipeAclAclsMatch holds the match fields of table PreQosPipe.Acl.acls.
+type TablePreQosPipeAclAclsMatch struct {
+	Inport        uint16
+	InportMask    uint16
+	SrcIface      uint8
+	SrcIfaceMask  uint8
+	EthSrc        uint64
+	EthSrcMask    uint64
+	EthDst        uint64
+	EthDstMask    uint64
+	EthType       uint16
+	EthTypeMask   uint16
+	Ipv4Src       uint32
+	Ipv4SrcMask   uint32
+	Ipv4Dst       uint32
+	Ipv4DstMask   uint32
+	Ipv4Proto     uint8
+	Ipv4ProtoMask uint8
+	L4Sport       uint16
+	L4SportMask   uint16
+	L4Dport       uint16
+	L4DportMask   uint16
+}
+
+// BuildTablePreQosPipeAclAclsEntry returns an entry of table PreQosPipe.Acl.acls.
+// Wildcard match fields are omitted.
+// action must be one of: PreQosPipe.Acl.set_port, PreQosPipe.Acl.punt, PreQosPipe.Acl.clone_to_cpu, PreQosPipe.Acl.drop, NoAction.
+func BuildTablePreQosPipeAclAclsEntry(match TablePreQosPipeAclAclsMatch, action *p4.Action, priority int32) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeAclSetPort, ActionPreQosPipeAclPunt, ActionPreQosPipeAclCloneToCpu, ActionPreQosPipeAclDrop, ActionNoAction:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.Acl.acls", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.Acl.acls", TablePreQosPipeAclAcls, priority, action)
+	b.ternary("inport", HdrPreQosPipeAclAclsInport, 9, uint64(match.Inport), uint64(match.InportMask))
+	b.ternary("src_iface", HdrPreQosPipeAclAclsSrcIface, 8, uint64(match.SrcIface), uint64(match.SrcIfaceMask))
+	b.ternary("eth_src", HdrPreQosPipeAclAclsEthSrc, 48, uint64(match.EthSrc), uint64(match.EthSrcMask))
+	b.ternary("eth_dst", HdrPreQosPipeAclAclsEthDst, 48, uint64(match.EthDst), uint64(match.EthDstMask))
+	b.ternary("eth_type", HdrPreQosPipeAclAclsEthType, 16, uint64(match.EthType), uint64(match.EthTypeMask))
+	b.ternary("ipv4_src", HdrPreQosPipeAclAclsIpv4Src, 32, uint64(match.Ipv4Src), uint64(match.Ipv4SrcMask))
+	b.ternary("ipv4_dst", HdrPreQosPipeAclAclsIpv4Dst, 32, uint64(match.Ipv4Dst), uint64(match.Ipv4DstMask))
+	b.ternary("ipv4_proto", HdrPreQosPipeAclAclsIpv4Proto, 8, uint64(match.Ipv4Proto), uint64(match.Ipv4ProtoMask))
+	b.ternary("l4_sport", HdrPreQosPipeAclAclsL4Sport, 16, uint64(match.L4Sport), uint64(match.L4SportMask))
+	b.ternary("l4_dport", HdrPreQosPipeAclAclsL4Dport, 16, uint64(match.L4Dport), uint64(match.L4DportMask))
+
+	return b.build()
+}
+
+// TablePreQosPipeMyStationMatch holds the match fields of table PreQosPipe.my_station.
+type TablePreQosPipeMyStationMatch struct {
+	DstMac uint64
+}
+
+// BuildTablePreQosPipeMyStationEntry returns an entry of table PreQosPipe.my_station.
+// action must be one of: NoAction.
+func BuildTablePreQosPipeMyStationEntry(match TablePreQosPipeMyStationMatch, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionNoAction:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.my_station", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.my_station", TablePreQosPipeMyStation, 0, action)
+	b.exact("dst_mac", HdrPreQosPipeMyStationDstMac, 48, uint64(match.DstMac))
+
+	return b.build()
+}
+
+// TablePreQosPipeInterfacesMatch holds the match fields of table PreQosPipe.interfaces.
+type TablePreQosPipeInterfacesMatch struct {
+	Ipv4DstPrefix          uint32
+	Ipv4DstPrefixPrefixLen int32
+}
+
+// BuildTablePreQosPipeInterfacesEntry returns an entry of table PreQosPipe.interfaces.
+// Wildcard match fields are omitted.
+// action must be one of: PreQosPipe.set_source_iface.
+func BuildTablePreQosPipeInterfacesEntry(match TablePreQosPipeInterfacesMatch, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeSetSourceIface:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.interfaces", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.interfaces", TablePreQosPipeInterfaces, 0, action)
+	b.lpm("ipv4_dst_prefix", HdrPreQosPipeInterfacesIpv4DstPrefix, 32, uint64(match.Ipv4DstPrefix), match.Ipv4DstPrefixPrefixLen)
+
+	return b.build()
+}
+
+// TablePreQosPipeSessionsUplinkMatch holds the match fields of table PreQosPipe.sessions_uplink.
+type TablePreQosPipeSessionsUplinkMatch struct {
+	N3Address uint32
+	Teid      uint32
+}
+
+// BuildTablePreQosPipeSessionsUplinkEntry returns an entry of table PreQosPipe.sessions_uplink.
+// action must be one of: PreQosPipe.set_session_uplink, PreQosPipe.set_session_uplink_drop.
+func BuildTablePreQosPipeSessionsUplinkEntry(match TablePreQosPipeSessionsUplinkMatch, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeSetSessionUplink, ActionPreQosPipeSetSessionUplinkDrop:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.sessions_uplink", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.sessions_uplink", TablePreQosPipeSessionsUplink, 0, action)
+	b.exact("n3_address", HdrPreQosPipeSessionsUplinkN3Address, 32, uint64(match.N3Address))
+	b.exact("teid", HdrPreQosPipeSessionsUplinkTeid, 32, uint64(match.Teid))
+
+	return b.build()
+}
+
+// TablePreQosPipeSessionsDownlinkMatch holds the match fields of table PreQosPipe.sessions_downlink.
+type TablePreQosPipeSessionsDownlinkMatch struct {
+	UeAddress uint32
+}
+
+// BuildTablePreQosPipeSessionsDownlinkEntry returns an entry of table PreQosPipe.sessions_downlink.
+// action must be one of: PreQosPipe.set_session_downlink, PreQosPipe.set_session_downlink_drop, PreQosPipe.set_session_downlink_buff.
+func BuildTablePreQosPipeSessionsDownlinkEntry(match TablePreQosPipeSessionsDownlinkMatch, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeSetSessionDownlink, ActionPreQosPipeSetSessionDownlinkDrop, ActionPreQosPipeSetSessionDownlinkBuff:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.sessions_downlink", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.sessions_downlink", TablePreQosPipeSessionsDownlink, 0, action)
+	b.exact("ue_address", HdrPreQosPipeSessionsDownlinkUeAddress, 32, uint64(match.UeAddress))
+
+	return b.build()
+}
+
+// TablePreQosPipeTerminationsUplinkMatch holds the match fields of table PreQosPipe.terminations_uplink.
+type TablePreQosPipeTerminationsUplinkMatch struct {
+	UeAddress uint32
+	AppId     uint8
+}
+
+// BuildTablePreQosPipeTerminationsUplinkEntry returns an entry of table PreQosPipe.terminations_uplink.
+// action must be one of: PreQosPipe.uplink_term_fwd, PreQosPipe.uplink_term_drop.
+func BuildTablePreQosPipeTerminationsUplinkEntry(match TablePreQosPipeTerminationsUplinkMatch, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeUplinkTermFwd, ActionPreQosPipeUplinkTermDrop:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.terminations_uplink", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.terminations_uplink", TablePreQosPipeTerminationsUplink, 0, action)
+	b.exact("ue_address", HdrPreQosPipeTerminationsUplinkUeAddress, 32, uint64(match.UeAddress))
+	b.exact("app_id", HdrPreQosPipeTerminationsUplinkAppId, 8, uint64(match.AppId))
+
+	return b.build()
+}
+
+// TablePreQosPipeTerminationsDownlinkMatch holds the match fields of table PreQosPipe.terminations_downlink.
+type TablePreQosPipeTerminationsDownlinkMatch struct {
+	UeAddress uint32
+	AppId     uint8
+}
+
+// BuildTablePreQosPipeTerminationsDownlinkEntry returns an entry of table PreQosPipe.terminations_downlink.
+// action must be one of: PreQosPipe.downlink_term_fwd, PreQosPipe.downlink_term_drop.
+func BuildTablePreQosPipeTerminationsDownlinkEntry(match TablePreQosPipeTerminationsDownlinkMatch, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeDownlinkTermFwd, ActionPreQosPipeDownlinkTermDrop:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.terminations_downlink", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.terminations_downlink", TablePreQosPipeTerminationsDownlink, 0, action)
+	b.exact("ue_address", HdrPreQosPipeTerminationsDownlinkUeAddress, 32, uint64(match.UeAddress))
+	b.exact("app_id", HdrPreQosPipeTerminationsDownlinkAppId, 8, uint64(match.AppId))
+
+	return b.build()
+}
+
+// TablePreQosPipeApplicationsMatch holds the match fields of table PreQosPipe.applications.
+type TablePreQosPipeApplicationsMatch struct {
+	SliceId            uint8
+	AppIpAddr          uint32
+	AppIpAddrPrefixLen int32
+	AppL4PortLow       uint16
+	AppL4PortHigh      uint16
+	AppIpProto         uint8
+	AppIpProtoMask     uint8
+}
+
+// BuildTablePreQosPipeApplicationsEntry returns an entry of table PreQosPipe.applications.
+// Wildcard match fields are omitted.
+// action must be one of: PreQosPipe.set_app_id.
+func BuildTablePreQosPipeApplicationsEntry(match TablePreQosPipeApplicationsMatch, action *p4.Action, priority int32) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeSetAppId:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.applications", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.applications", TablePreQosPipeApplications, priority, action)
+	b.exact("slice_id", HdrPreQosPipeApplicationsSliceId, 4, uint64(match.SliceId))
+	b.lpm("app_ip_addr", HdrPreQosPipeApplicationsAppIpAddr, 32, uint64(match.AppIpAddr), match.AppIpAddrPrefixLen)
+	b.rangeMatch("app_l4_port", HdrPreQosPipeApplicationsAppL4Port, 16, uint64(match.AppL4PortLow), uint64(match.AppL4PortHigh))
+	b.ternary("app_ip_proto", HdrPreQosPipeApplicationsAppIpProto, 8, uint64(match.AppIpProto), uint64(match.AppIpProtoMask))
+
+	return b.build()
+}
+
+// TablePreQosPipeTunnelPeersMatch holds the match fields of table PreQosPipe.tunnel_peers.
+type TablePreQosPipeTunnelPeersMatch struct {
+	TunnelPeerId uint8
+}
+
+// BuildTablePreQosPipeTunnelPeersEntry returns an entry of table PreQosPipe.tunnel_peers.
+// action must be one of: PreQosPipe.load_tunnel_param.
+func BuildTablePreQosPipeTunnelPeersEntry(match TablePreQosPipeTunnelPeersMatch, action *p4.Action) (*p4.TableEntry, error) {
+	switch action.GetActionId() {
+	case ActionPreQosPipeLoadTunnelParam:
+	default:
+		return nil, fmt.Errorf("%w: %d for table PreQosPipe.tunnel_peers", ErrInvalidAction, action.GetActionId())
+	}
+
+	b := newEntryBuilder("PreQosPipe.tunnel_peers", TablePreQosPipeTunnelPeers, 0, action)
+	b.exact("tunnel_peer_id", HdrPreQosPipeTunnelPeersTunnelPeerId, 8, uint64(match.TunnelPeerId))
+
+	return b.build()
+}
+
+// Actions
+// BuildActionNoAction returns action NoAction.
+func BuildActionNoAction() *p4.Action {
+	return &p4.Action{ActionId: ActionNoAction}
+}
+
+// BuildActionPreQosPipeRoutingDrop returns action PreQosPipe.Routing.drop.
+func BuildActionPreQosPipeRoutingDrop() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeRoutingDrop}
+}
+
+// ActionPreQosPipeRoutingRouteParams holds the parameters of action PreQosPipe.Routing.route.
+type ActionPreQosPipeRoutingRouteParams struct {
+	SrcMac     uint64
+	DstMac     uint64
+	EgressPort uint16
+}
+
+// BuildActionPreQosPipeRoutingRoute returns action PreQosPipe.Routing.route with params.
+func BuildActionPreQosPipeRoutingRoute(params ActionPreQosPipeRoutingRouteParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.Routing.route", ActionPreQosPipeRoutingRoute)
+	b.param("src_mac", ActionParamPreQosPipeRoutingRouteSrcMac, 48, uint64(params.SrcMac))
+	b.param("dst_mac", ActionParamPreQosPipeRoutingRouteDstMac, 48, uint64(params.DstMac))
+	b.param("egress_port", ActionParamPreQosPipeRoutingRouteEgressPort, 9, uint64(params.EgressPort))
+
+	return b.build()
+}
+
+// ActionPreQosPipeAclSetPortParams holds the parameters of action PreQosPipe.Acl.set_port.
+type ActionPreQosPipeAclSetPortParams struct {
+	Port uint16
+}
+
+// BuildActionPreQosPipeAclSetPort returns action PreQosPipe.Acl.set_port with params.
+func BuildActionPreQosPipeAclSetPort(params ActionPreQosPipeAclSetPortParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.Acl.set_port", ActionPreQosPipeAclSetPort)
+	b.param("port", ActionParamPreQosPipeAclSetPortPort, 9, uint64(params.Port))
+
+	return b.build()
+}
+
+// BuildActionPreQosPipeAclPunt returns action PreQosPipe.Acl.punt.
+func BuildActionPreQosPipeAclPunt() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeAclPunt}
+}
+
+// BuildActionPreQosPipeAclCloneToCpu returns action PreQosPipe.Acl.clone_to_cpu.
+func BuildActionPreQosPipeAclCloneToCpu() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeAclCloneToCpu}
+}
+
+// BuildActionPreQosPipeAclDrop returns action PreQosPipe.Acl.drop.
+func BuildActionPreQosPipeAclDrop() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeAclDrop}
+}
+
+// BuildActionPreQosPipeInitializeMetadata returns action PreQosPipe._initialize_metadata.
+func BuildActionPreQosPipeInitializeMetadata() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeInitializeMetadata}
+}
+
+// ActionPreQosPipeSetSourceIfaceParams holds the parameters of action PreQosPipe.set_source_iface.
+type ActionPreQosPipeSetSourceIfaceParams struct {
+	SrcIface  uint8
+	Direction uint8
+	SliceId   uint8
+}
+
+// BuildActionPreQosPipeSetSourceIface returns action PreQosPipe.set_source_iface with params.
+func BuildActionPreQosPipeSetSourceIface(params ActionPreQosPipeSetSourceIfaceParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.set_source_iface", ActionPreQosPipeSetSourceIface)
+	b.param("src_iface", ActionParamPreQosPipeSetSourceIfaceSrcIface, 8, uint64(params.SrcIface))
+	b.param("direction", ActionParamPreQosPipeSetSourceIfaceDirection, 8, uint64(params.Direction))
+	b.param("slice_id", ActionParamPreQosPipeSetSourceIfaceSliceId, 4, uint64(params.SliceId))
+
+	return b.build()
+}
+
+// BuildActionPreQosPipeDoDrop returns action PreQosPipe.do_drop.
+func BuildActionPreQosPipeDoDrop() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeDoDrop}
+}
+
+// ActionPreQosPipeSetSessionUplinkParams holds the parameters of action PreQosPipe.set_session_uplink.
+type ActionPreQosPipeSetSessionUplinkParams struct {
+	SessionMeterIdx uint32
+}
+
+// BuildActionPreQosPipeSetSessionUplink returns action PreQosPipe.set_session_uplink with params.
+func BuildActionPreQosPipeSetSessionUplink(params ActionPreQosPipeSetSessionUplinkParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.set_session_uplink", ActionPreQosPipeSetSessionUplink)
+	b.param("session_meter_idx", ActionParamPreQosPipeSetSessionUplinkSessionMeterIdx, 32, uint64(params.SessionMeterIdx))
+
+	return b.build()
+}
+
+// BuildActionPreQosPipeSetSessionUplinkDrop returns action PreQosPipe.set_session_uplink_drop.
+func BuildActionPreQosPipeSetSessionUplinkDrop() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeSetSessionUplinkDrop}
+}
+
+// ActionPreQosPipeSetSessionDownlinkParams holds the parameters of action PreQosPipe.set_session_downlink.
+type ActionPreQosPipeSetSessionDownlinkParams struct {
+	TunnelPeerId    uint8
+	SessionMeterIdx uint32
+}
+
+// BuildActionPreQosPipeSetSessionDownlink returns action PreQosPipe.set_session_downlink with params.
+func BuildActionPreQosPipeSetSessionDownlink(params ActionPreQosPipeSetSessionDownlinkParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.set_session_downlink", ActionPreQosPipeSetSessionDownlink)
+	b.param("tunnel_peer_id", ActionParamPreQosPipeSetSessionDownlinkTunnelPeerId, 8, uint64(params.TunnelPeerId))
+	b.param("session_meter_idx", ActionParamPreQosPipeSetSessionDownlinkSessionMeterIdx, 32, uint64(params.SessionMeterIdx))
+
+	return b.build()
+}
+
+// BuildActionPreQosPipeSetSessionDownlinkDrop returns action PreQosPipe.set_session_downlink_drop.
+func BuildActionPreQosPipeSetSessionDownlinkDrop() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeSetSessionDownlinkDrop}
+}
+
+// ActionPreQosPipeSetSessionDownlinkBuffParams holds the parameters of action PreQosPipe.set_session_downlink_buff.
+type ActionPreQosPipeSetSessionDownlinkBuffParams struct {
+	SessionMeterIdx uint32
+}
+
+// BuildActionPreQosPipeSetSessionDownlinkBuff returns action PreQosPipe.set_session_downlink_buff with params.
+func BuildActionPreQosPipeSetSessionDownlinkBuff(params ActionPreQosPipeSetSessionDownlinkBuffParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.set_session_downlink_buff", ActionPreQosPipeSetSessionDownlinkBuff)
+	b.param("session_meter_idx", ActionParamPreQosPipeSetSessionDownlinkBuffSessionMeterIdx, 32, uint64(params.SessionMeterIdx))
+
+	return b.build()
+}
+
+// ActionPreQosPipeUplinkTermFwdParams holds the parameters of action PreQosPipe.uplink_term_fwd.
+type ActionPreQosPipeUplinkTermFwdParams struct {
+	CtrIdx      uint32
+	Tc          uint8
+	AppMeterIdx uint32
+}
+
+// BuildActionPreQosPipeUplinkTermFwd returns action PreQosPipe.uplink_term_fwd with params.
+func BuildActionPreQosPipeUplinkTermFwd(params ActionPreQosPipeUplinkTermFwdParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.uplink_term_fwd", ActionPreQosPipeUplinkTermFwd)
+	b.param("ctr_idx", ActionParamPreQosPipeUplinkTermFwdCtrIdx, 32, uint64(params.CtrIdx))
+	b.param("tc", ActionParamPreQosPipeUplinkTermFwdTc, 2, uint64(params.Tc))
+	b.param("app_meter_idx", ActionParamPreQosPipeUplinkTermFwdAppMeterIdx, 32, uint64(params.AppMeterIdx))
+
+	return b.build()
+}
+
+// ActionPreQosPipeUplinkTermDropParams holds the parameters of action PreQosPipe.uplink_term_drop.
+type ActionPreQosPipeUplinkTermDropParams struct {
+	CtrIdx uint32
+}
+
+// BuildActionPreQosPipeUplinkTermDrop returns action PreQosPipe.uplink_term_drop with params.
+func BuildActionPreQosPipeUplinkTermDrop(params ActionPreQosPipeUplinkTermDropParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.uplink_term_drop", ActionPreQosPipeUplinkTermDrop)
+	b.param("ctr_idx", ActionParamPreQosPipeUplinkTermDropCtrIdx, 32, uint64(params.CtrIdx))
+
+	return b.build()
+}
+
+// ActionPreQosPipeDownlinkTermFwdParams holds the parameters of action PreQosPipe.downlink_term_fwd.
+type ActionPreQosPipeDownlinkTermFwdParams struct {
+	CtrIdx      uint32
+	Teid        uint32
+	Qfi         uint8
+	Tc          uint8
+	AppMeterIdx uint32
+}
+
+// BuildActionPreQosPipeDownlinkTermFwd returns action PreQosPipe.downlink_term_fwd with params.
+func BuildActionPreQosPipeDownlinkTermFwd(params ActionPreQosPipeDownlinkTermFwdParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.downlink_term_fwd", ActionPreQosPipeDownlinkTermFwd)
+	b.param("ctr_idx", ActionParamPreQosPipeDownlinkTermFwdCtrIdx, 32, uint64(params.CtrIdx))
+	b.param("teid", ActionParamPreQosPipeDownlinkTermFwdTeid, 32, uint64(params.Teid))
+	b.param("qfi", ActionParamPreQosPipeDownlinkTermFwdQfi, 6, uint64(params.Qfi))
+	b.param("tc", ActionParamPreQosPipeDownlinkTermFwdTc, 2, uint64(params.Tc))
+	b.param("app_meter_idx", ActionParamPreQosPipeDownlinkTermFwdAppMeterIdx, 32, uint64(params.AppMeterIdx))
+
+	return b.build()
+}
+
+// ActionPreQosPipeDownlinkTermDropParams holds the parameters of action PreQosPipe.downlink_term_drop.
+type ActionPreQosPipeDownlinkTermDropParams struct {
+	CtrIdx uint32
+}
+
+// BuildActionPreQosPipeDownlinkTermDrop returns action PreQosPipe.downlink_term_drop with params.
+func BuildActionPreQosPipeDownlinkTermDrop(params ActionPreQosPipeDownlinkTermDropParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.downlink_term_drop", ActionPreQosPipeDownlinkTermDrop)
+	b.param("ctr_idx", ActionParamPreQosPipeDownlinkTermDropCtrIdx, 32, uint64(params.CtrIdx))
+
+	return b.build()
+}
+
+// ActionPreQosPipeSetAppIdParams holds the parameters of action PreQosPipe.set_app_id.
+type ActionPreQosPipeSetAppIdParams struct {
+	AppId uint8
+}
+
+// BuildActionPreQosPipeSetAppId returns action PreQosPipe.set_app_id with params.
+func BuildActionPreQosPipeSetAppId(params ActionPreQosPipeSetAppIdParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.set_app_id", ActionPreQosPipeSetAppId)
+	b.param("app_id", ActionParamPreQosPipeSetAppIdAppId, 8, uint64(params.AppId))
+
+	return b.build()
+}
+
+// ActionPreQosPipeLoadTunnelParamParams holds the parameters of action PreQosPipe.load_tunnel_param.
+type ActionPreQosPipeLoadTunnelParamParams struct {
+	SrcAddr uint32
+	DstAddr uint32
+	Sport   uint16
+}
+
+// BuildActionPreQosPipeLoadTunnelParam returns action PreQosPipe.load_tunnel_param with params.
+func BuildActionPreQosPipeLoadTunnelParam(params ActionPreQosPipeLoadTunnelParamParams) (*p4.Action, error) {
+	b := newActionBuilder("PreQosPipe.load_tunnel_param", ActionPreQosPipeLoadTunnelParam)
+	b.param("src_addr", ActionParamPreQosPipeLoadTunnelParamSrcAddr, 32, uint64(params.SrcAddr))
+	b.param("dst_addr", ActionParamPreQosPipeLoadTunnelParamDstAddr, 32, uint64(params.DstAddr))
+	b.param("sport", ActionParamPreQosPipeLoadTunnelParamSport, 16, uint64(params.Sport))
+
+	return b.build()
+}
+
+// BuildActionPreQosPipeDoGtpuTunnel returns action PreQosPipe.do_gtpu_tunnel.
+func BuildActionPreQosPipeDoGtpuTunnel() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeDoGtpuTunnel}
+}
+
+// BuildActionPreQosPipeDoGtpuTunnelWithPsc returns action PreQosPipe.do_gtpu_tunnel_with_psc.
+func BuildActionPreQosPipeDoGtpuTunnelWithPsc() *p4.Action {
+	return &p4.Action{ActionId: ActionPreQosPipeDoGtpuTunnelWithPsc}
+}
@@ -7,9 +7,12 @@ import (
 	"context"
 	"encoding/binary"
 	"flag"
+	"fmt"
 	"math"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc/connectivity"
@@ -21,6 +24,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/wmnsk/go-pfcp/ie"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
@@ -69,8 +74,24 @@ var intEnc = func(u uint64) *pb.FieldData {
 	return &pb.FieldData{Encoding: &pb.FieldData_ValueInt{ValueInt: u}}
 }
 
+const (
+	// bessPipelineCheckInterval is how often a reconnected BESS is polled for its pipeline.
+	bessPipelineCheckInterval = time.Second
+	// bessPipelineTimeout is how long to wait for BESS to set up its pipeline after a restart.
+	bessPipelineTimeout = 2 * time.Minute
+	// bessPipelineModule is the module that must exist before the pipeline is considered configured.
+	bessPipelineModule = "pdrLookup"
+)
+
 var bessIP = flag.String("bess", "localhost:10514", "BESS IP/port combo")
 
+var bessReconnectBackoff = backoff.Config{
+	BaseDelay:  500 * time.Millisecond,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   5 * time.Second,
+}
+
 type bess struct {
 	client           pb.BESSControlClient
 	conn             *grpc.ClientConn
@@ -78,6 +99,22 @@ type bess struct {
 	notifyBessSocket net.Conn
 	endMarkerChan    chan []byte
 	qciQosMap        map[uint8]*QosConfigVal
+
+	upf *upf
+	// connectedMu guards connected
+	connectedMu sync.RWMutex
+	// connected is false while BESS is unreachable or while the state is being replayed
+	connected bool
+	// stateMu serializes the state replay with regular rule updates.
+	// Rule updates hold a read lock, the replay and slice meter updates hold the write lock.
+	stateMu sync.RWMutex
+	// sliceMeterConfig is the last slice meter configuration applied, nil if none. Guarded by stateMu.
+	sliceMeterConfig *SliceMeterConfig
+
+	// workers sends rule updates to BESS with a bounded number of concurrent calls per module.
+	workers *bessWorkerPool
+	// msgDeadline is the time allowed to apply all rule updates of a single PFCP message.
+	msgDeadline time.Duration
 }
 
 func (b *bess) IsConnected(AccessIP *net.IP) bool {
@@ -85,7 +122,33 @@ func (b *bess) IsConnected(AccessIP *net.IP) bool {
 		return false
 	}
 
-	return true
+	b.connectedMu.RLock()
+	defer b.connectedMu.RUnlock()
+
+	return b.connected
+}
+
+func (b *bess) setConnected(connected bool) {
+	b.connectedMu.Lock()
+	defer b.connectedMu.Unlock()
+
+	b.connected = connected
+
+	value := 0.0
+	if connected {
+		value = 1
+	}
+
+	getDatapathMetrics().connected.WithLabelValues("bess").Set(value)
+}
+
+// moduleCommandError converts a failed BESS ModuleCommand into an error.
+func moduleCommandError(module string, cmd string, resp *pb.CommandResponse, err error) error {
+	if err != nil {
+		return ErrOperationFailedWithReason(module+" "+cmd, err.Error())
+	}
+
+	return ErrOperationFailedWithReason(module+" "+cmd, resp.GetError().GetErrmsg())
 }
 
 func (b *bess) SendEndMarkers(endMarkerList *[][]byte) error {
@@ -103,96 +166,157 @@ func (b *bess) AddSliceInfo(sliceInfo *SliceInfo) error {
 	sliceMeterConfig.N6BurstBytes = sliceInfo.ulBurstBytes
 	sliceMeterConfig.N3BurstBytes = sliceInfo.dlBurstBytes
 
-	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
-	defer cancel()
-
-	done := make(chan bool)
-
-	b.addSliceMeter(ctx, done, sliceMeterConfig)
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
 
-	rc := b.GRPCJoin(1, Timeout, done)
+	b.sliceMeterConfig = &sliceMeterConfig
 
-	if !rc {
+	if !b.applySliceMeter(sliceMeterConfig) {
 		log.Errorln("Unable to make GRPC calls")
 	}
 
 	return nil
 }
 
-func (b *bess) SendMsgToUPF(
-	method upfMsgType, rules PacketForwardingRules, updated PacketForwardingRules) uint8 {
-	// create context
-	var cause uint8 = ie.CauseRequestAccepted
+func (b *bess) applySliceMeter(meterConfig SliceMeterConfig) bool {
+	batch := newBESSBatch()
 
-	pdrs := rules.pdrs
-	fars := rules.fars
-	qers := rules.qers
-
-	if method == upfMsgTypeMod {
-		pdrs = updated.pdrs
-		fars = updated.fars
-		qers = updated.qers
+	if err := b.addSliceMeter(batch, meterConfig); err != nil {
+		return false
 	}
 
-	calls := len(pdrs) + len(fars) + len(qers)
-	if calls == 0 {
-		return cause
+	return b.executeBatch(batch)
+}
+
+// executeBatch sends all commands of batch to BESS and waits for their completion within the message deadline.
+func (b *bess) executeBatch(batch *bessBatch) bool {
+	if err := b.executeBatchContext(context.Background(), batch); err != nil {
+		log.Errorf("Failed to apply %d BESS commands: %v", batch.len(), err)
+		return false
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	return true
+}
+
+// executeBatchContext sends all commands of batch to BESS and waits for their completion within the message
+// deadline, or the deadline of ctx if earlier.
+func (b *bess) executeBatchContext(ctx context.Context, batch *bessBatch) error {
+	ctx, cancel := context.WithTimeout(ctx, b.msgDeadline)
 	defer cancel()
 
-	done := make(chan bool)
+	return b.workers.execute(ctx, batch)
+}
+
+func (b *bess) BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx {
+	return newChangesTx(ctx, all, b.commitRules)
+}
+
+// commitRules writes all changes in a single batch. Failures while BESS is disconnected are ignored,
+// as all rules are replayed once reconnected.
+func (b *bess) commitRules(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error {
+	b.stateMu.RLock()
+	defer b.stateMu.RUnlock()
+
+	batch := newBESSBatch()
+
+	if err := b.batchRules(batch, upfMsgTypeAdd, changes.upserted()); err != nil {
+		return err
+	}
+
+	if err := b.batchRules(batch, upfMsgTypeDel, changes.deleted); err != nil {
+		return err
+	}
+
+	err := b.executeBatchContext(ctx, batch)
+	if err != nil && !b.IsConnected(nil) {
+		log.Warnf("Failed to apply rules to disconnected BESS, they will be replayed: %v", err)
+		return nil
+	}
+
+	return err
+}
+
+// applyRules adds or deletes all rules in BESS and returns false if any of the commands failed.
+// Callers must hold stateMu.
+func (b *bess) applyRules(method upfMsgType, rules PacketForwardingRules) bool {
+	batch := newBESSBatch()
+
+	if err := b.batchRules(batch, method, rules); err != nil {
+		return false
+	}
+
+	return b.executeBatch(batch)
+}
 
-	for _, pdr := range pdrs {
+// batchRules adds the commands to add or delete all rules to batch.
+func (b *bess) batchRules(batch *bessBatch, method upfMsgType, rules PacketForwardingRules) error {
+	for _, pdr := range rules.pdrs {
 		log.Traceln(method, pdr)
 
+		var err error
+
 		switch method {
 		case upfMsgTypeAdd:
 			fallthrough
 		case upfMsgTypeMod:
-			b.addPDR(ctx, done, pdr)
+			err = b.addPDR(batch, pdr)
 		case upfMsgTypeDel:
-			b.delPDR(ctx, done, pdr)
+			err = b.delPDR(batch, pdr)
+		}
+
+		if err != nil {
+			return err
 		}
 	}
 
-	for _, far := range fars {
+	for _, far := range rules.fars {
 		log.Traceln(method, far)
 
+		var err error
+
 		switch method {
 		case upfMsgTypeAdd:
 			fallthrough
 		case upfMsgTypeMod:
-			b.addFAR(ctx, done, far)
+			err = b.addFAR(batch, far)
 		case upfMsgTypeDel:
-			b.delFAR(ctx, done, far)
+			err = b.delFAR(batch, far)
+		}
+
+		if err != nil {
+			return err
 		}
 	}
 
-	for _, qer := range qers {
+	for _, qer := range rules.qers {
 		log.Traceln(method, qer)
 
+		var err error
+
 		switch method {
 		case upfMsgTypeAdd:
 			fallthrough
 		case upfMsgTypeMod:
-			b.addQER(ctx, done, qer)
+			err = b.addQER(batch, qer)
 		case upfMsgTypeDel:
-			b.delQER(ctx, done, qer)
+			err = b.delQER(batch, qer)
 		}
-	}
 
-	rc := b.GRPCJoin(calls, Timeout, done)
-	if !rc {
-		log.Println("Unable to make GRPC calls")
+		if err != nil {
+			return err
+		}
 	}
 
-	return cause
+	return nil
 }
 
 func (b *bess) Exit() {
 	log.Println("Exit function Bess")
+
+	if b.workers != nil {
+		b.workers.Stop()
+	}
+
 	b.conn.Close()
 }
 
@@ -680,12 +804,31 @@ func (b *bess) SetUpfInfo(u *upf, conf *Conf) {
 
 	b.endMarkerChan = make(chan []byte, 1024)
 
-	b.conn, err = grpc.Dial(*bessIP, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	b.conn, err = grpc.Dial(*bessIP, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		// cap the reconnection backoff, so that a restarted BESS is detected quickly
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           bessReconnectBackoff,
+			MinConnectTimeout: 5 * time.Second,
+		}))
 	if err != nil {
 		log.Fatalln("did not connect:", err)
 	}
 
 	b.client = pb.NewBESSControlClient(b.conn)
+	b.upf = u
+
+	workers := conf.BessModuleWorkers
+	if workers == 0 {
+		workers = bessModuleWorkersDefault
+	}
+
+	b.msgDeadline = bessMsgDeadlineDefault
+	if conf.BessMsgDeadline != "" {
+		// already validated
+		b.msgDeadline, _ = time.ParseDuration(conf.BessMsgDeadline)
+	}
+
+	b.workers = newBESSWorkerPool(b.client, int(workers))
 
 	b.clearState()
 
@@ -723,26 +866,132 @@ func (b *bess) SetUpfInfo(u *upf, conf *Conf) {
 
 	if (conf.SliceMeterConfig.N6RateBps > 0) ||
 		(conf.SliceMeterConfig.N3RateBps > 0) {
+		sliceMeterConfig := conf.SliceMeterConfig
+		b.sliceMeterConfig = &sliceMeterConfig
+
+		if !b.applySliceMeter(sliceMeterConfig) {
+			log.Errorln("Unable to make GRPC calls")
+		}
+	}
+
+	go b.monitorConnection()
+}
+
+// monitorConnection watches the state of the BESS gRPC channel. Whenever the channel becomes
+// ready again after being down, e.g. because BESS restarted, the forwarding state is replayed.
+func (b *bess) monitorConnection() {
+	state := b.conn.GetState()
+	b.setConnected(state == connectivity.Ready)
+
+	for {
+		if state == connectivity.Idle {
+			b.conn.Connect()
+		}
+
+		if !b.conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+
+		newState := b.conn.GetState()
+
+		switch {
+		case newState == connectivity.Shutdown:
+			b.setConnected(false)
+			return
+		case newState == connectivity.Ready:
+			log.Info("Connection to BESS (re-)established")
+			getDatapathMetrics().reconnects.WithLabelValues("bess").Inc()
+
+			b.replayState()
+		case state == connectivity.Ready:
+			log.WithField("state", newState).Warn("Lost connection to BESS")
+			b.setConnected(false)
+		}
+
+		state = newState
+	}
+}
+
+// waitForPipeline waits until the BESS pipeline is configured, as the modules are created
+// by an external script after the BESS daemon starts.
+func (b *bess) waitForPipeline() error {
+	deadline := time.Now().Add(bessPipelineTimeout)
+
+	for {
 		ctx, cancel := context.WithTimeout(context.Background(), Timeout)
-		defer cancel()
+		resp, err := b.client.GetModuleInfo(ctx, &pb.GetModuleInfoRequest{Name: bessPipelineModule})
 
-		done := make(chan bool)
+		cancel()
 
-		b.addSliceMeter(ctx, done, conf.SliceMeterConfig)
+		if err == nil && resp.GetError() == nil {
+			return nil
+		}
 
-		rc := b.GRPCJoin(1, Timeout, done)
-		if !rc {
-			log.Errorln("Unable to make GRPC calls")
+		if time.Now().After(deadline) {
+			return ErrOperationFailedWithReason("wait for BESS pipeline",
+				"module "+bessPipelineModule+" not found")
 		}
+
+		time.Sleep(bessPipelineCheckInterval)
 	}
 }
 
-func (b *bess) processPDR(ctx context.Context, any *anypb.Any, method upfMsgType) {
-	if method != upfMsgTypeAdd && method != upfMsgTypeDel && method != upfMsgTypeClear {
-		log.Println("Invalid method name: ", method)
+// replayState clears BESS and re-installs slice meters and all PFCP sessions from the session stores.
+// Rule updates are blocked while the replay is in progress.
+func (b *bess) replayState() {
+	dpMetrics := getDatapathMetrics()
+	start := time.Now()
+
+	if err := b.waitForPipeline(); err != nil {
+		log.Errorf("Failed to replay BESS state: %v", err)
+		dpMetrics.replayFailures.WithLabelValues("bess", "pipeline").Inc()
+
 		return
 	}
 
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+
+	b.clearState()
+
+	if b.sliceMeterConfig != nil && !b.applySliceMeter(*b.sliceMeterConfig) {
+		log.Error("Failed to replay slice meter to BESS")
+		dpMetrics.replayFailures.WithLabelValues("bess", "slice_meter").Inc()
+	}
+
+	sessions := b.upf.allSessions()
+	failed := 0
+
+	for _, session := range sessions {
+		if !b.applyRules(upfMsgTypeAdd, session.PacketForwardingRules) {
+			log.WithField("F-SEID", session.localSEID).Error("Failed to replay session to BESS")
+			dpMetrics.replayFailures.WithLabelValues("bess", "session").Inc()
+
+			failed++
+
+			continue
+		}
+
+		dpMetrics.replayedEntries.WithLabelValues("bess").Inc()
+	}
+
+	duration := time.Since(start)
+	dpMetrics.replayDuration.WithLabelValues("bess").Observe(duration.Seconds())
+
+	log.WithFields(log.Fields{
+		"sessions": len(sessions),
+		"failed":   failed,
+		"duration": duration,
+	}).Info("Replayed forwarding state to BESS")
+
+	b.setConnected(true)
+}
+
+func (b *bess) processPDR(ctx context.Context, any *anypb.Any, method upfMsgType) error {
+	if method != upfMsgTypeAdd && method != upfMsgTypeDel && method != upfMsgTypeClear {
+		return ErrInvalidArgument("method name", method)
+	}
+
 	methods := [...]string{"add", "add", "delete", "clear"}
 
 	resp, err := b.client.ModuleCommand(ctx, &pb.CommandRequest{
@@ -755,212 +1004,207 @@ func (b *bess) processPDR(ctx context.Context, any *anypb.Any, method upfMsgType
 
 	if err != nil || resp.GetError() != nil {
 		log.Errorf("pdrLookup method failed with resp: %v, err: %v\n", resp, err)
+		return moduleCommandError("pdrLookup", methods[method], resp, err)
 	}
+
+	return nil
 }
 
-func (b *bess) addPDR(ctx context.Context, done chan<- bool, p pdr) {
-	go func() {
-		var (
-			any *anypb.Any
-			err error
-		)
+// pdrAddArgs returns the pdrLookup rules for p, one for each port range of its application filter.
+func pdrAddArgs(p pdr) ([]*pb.WildcardMatchCommandAddArg, error) {
+	var qerID uint32
 
-		var qerID uint32
+	for _, qer := range p.qerIDList {
+		qerID = qer
+		break
+	}
 
-		for _, qer := range p.qerIDList {
-			qerID = qer
-			break
-		}
+	// Translate port ranges into ternary rule(s).
+	portRules, err := CreatePortRangeCartesianProduct(p.appFilter.srcPortRange, p.appFilter.dstPortRange)
+	if err != nil {
+		return nil, err
+	}
 
-		// Translate port ranges into ternary rule(s) and insert them one-by-one.
-		portRules, err := CreatePortRangeCartesianProduct(p.appFilter.srcPortRange, p.appFilter.dstPortRange)
-		if err != nil {
-			log.Errorln(err)
-			return
-		}
+	log.Tracef("PDR rules %+v", portRules)
 
-		log.Tracef("PDR rules %+v", portRules)
-
-		for _, r := range portRules {
-			f := &pb.WildcardMatchCommandAddArg{
-				Gate:     uint64(p.needDecap),
-				Priority: int64(math.MaxUint32 - p.precedence),
-				Values: []*pb.FieldData{
-					intEnc(uint64(p.srcIface)),        /* src_iface */
-					intEnc(uint64(p.tunnelIP4Dst)),    /* tunnel_ipv4_dst */
-					intEnc(uint64(p.tunnelTEID)),      /* enb_teid */
-					intEnc(uint64(p.appFilter.srcIP)), /* ueaddr ip*/
-					intEnc(uint64(p.appFilter.dstIP)), /* inet ip */
-					intEnc(uint64(r.srcPort)),         /* ue port */
-					intEnc(uint64(r.dstPort)),         /* inet port */
-					intEnc(uint64(p.appFilter.proto)), /* proto id */
-				},
-				Masks: []*pb.FieldData{
-					intEnc(uint64(p.srcIfaceMask)),        /* src_iface-mask */
-					intEnc(uint64(p.tunnelIP4DstMask)),    /* tunnel_ipv4_dst-mask */
-					intEnc(uint64(p.tunnelTEIDMask)),      /* enb_teid-mask */
-					intEnc(uint64(p.appFilter.srcIPMask)), /* ueaddr ip-mask */
-					intEnc(uint64(p.appFilter.dstIPMask)), /* inet ip-mask */
-					intEnc(uint64(r.srcMask)),             /* ue port-mask */
-					intEnc(uint64(r.dstMask)),             /* inet port-mask */
-					intEnc(uint64(p.appFilter.protoMask)), /* proto id-mask */
-				},
-				Valuesv: []*pb.FieldData{
-					intEnc(uint64(p.pdrID)), /* pdr-id */
-					intEnc(p.fseID),         /* fseid */
-					intEnc(uint64(p.ctrID)), /* ctr_id */
-					intEnc(uint64(qerID)),   /* qer_id */
-					intEnc(uint64(p.farID)), /* far_id */
-				},
-			}
+	args := make([]*pb.WildcardMatchCommandAddArg, 0, len(portRules))
 
-			any, err = anypb.New(f)
-			if err != nil {
-				log.Println("Error marshalling the rule", f, err)
-				return
-			}
+	for _, r := range portRules {
+		args = append(args, &pb.WildcardMatchCommandAddArg{
+			Gate:     uint64(p.needDecap),
+			Priority: int64(math.MaxUint32 - p.precedence),
+			Values: []*pb.FieldData{
+				intEnc(uint64(p.srcIface)),        /* src_iface */
+				intEnc(uint64(p.tunnelIP4Dst)),    /* tunnel_ipv4_dst */
+				intEnc(uint64(p.tunnelTEID)),      /* enb_teid */
+				intEnc(uint64(p.appFilter.srcIP)), /* ueaddr ip*/
+				intEnc(uint64(p.appFilter.dstIP)), /* inet ip */
+				intEnc(uint64(r.srcPort)),         /* ue port */
+				intEnc(uint64(r.dstPort)),         /* inet port */
+				intEnc(uint64(p.appFilter.proto)), /* proto id */
+			},
+			Masks: []*pb.FieldData{
+				intEnc(uint64(p.srcIfaceMask)),        /* src_iface-mask */
+				intEnc(uint64(p.tunnelIP4DstMask)),    /* tunnel_ipv4_dst-mask */
+				intEnc(uint64(p.tunnelTEIDMask)),      /* enb_teid-mask */
+				intEnc(uint64(p.appFilter.srcIPMask)), /* ueaddr ip-mask */
+				intEnc(uint64(p.appFilter.dstIPMask)), /* inet ip-mask */
+				intEnc(uint64(r.srcMask)),             /* ue port-mask */
+				intEnc(uint64(r.dstMask)),             /* inet port-mask */
+				intEnc(uint64(p.appFilter.protoMask)), /* proto id-mask */
+			},
+			Valuesv: []*pb.FieldData{
+				intEnc(uint64(p.pdrID)), /* pdr-id */
+				intEnc(p.fseID),         /* fseid */
+				intEnc(uint64(p.ctrID)), /* ctr_id */
+				intEnc(uint64(qerID)),   /* qer_id */
+				intEnc(uint64(p.farID)), /* far_id */
+			},
+		})
+	}
 
-			b.processPDR(ctx, any, upfMsgTypeAdd)
-		}
-		done <- true
-	}()
+	return args, nil
 }
 
-func (b *bess) delPDR(ctx context.Context, done chan<- bool, p pdr) {
-	go func() {
-		var (
-			any *anypb.Any
-			err error
-		)
+func (b *bess) addPDR(batch *bessBatch, p pdr) error {
+	args, err := pdrAddArgs(p)
+	if err != nil {
+		log.Errorln(err)
+		return err
+	}
 
-		// Translate port ranges into ternary rule(s) and insert them one-by-one.
-		portRules, err := CreatePortRangeCartesianProduct(p.appFilter.srcPortRange, p.appFilter.dstPortRange)
-		if err != nil {
-			log.Errorln(err)
-			return
+	for _, f := range args {
+		if err := batch.add("pdrLookup", "add", f); err != nil {
+			return err
 		}
+	}
 
-		for _, r := range portRules {
-			f := &pb.WildcardMatchCommandDeleteArg{
-				Values: []*pb.FieldData{
-					intEnc(uint64(p.srcIface)),        /* src_iface */
-					intEnc(uint64(p.tunnelIP4Dst)),    /* tunnel_ipv4_dst */
-					intEnc(uint64(p.tunnelTEID)),      /* enb_teid */
-					intEnc(uint64(p.appFilter.srcIP)), /* ueaddr ip*/
-					intEnc(uint64(p.appFilter.dstIP)), /* inet ip */
-					intEnc(uint64(r.srcPort)),         /* ue port */
-					intEnc(uint64(r.dstPort)),         /* inet port */
-					intEnc(uint64(p.appFilter.proto)), /* proto id */
-				},
-				Masks: []*pb.FieldData{
-					intEnc(uint64(p.srcIfaceMask)),        /* src_iface-mask */
-					intEnc(uint64(p.tunnelIP4DstMask)),    /* tunnel_ipv4_dst-mask */
-					intEnc(uint64(p.tunnelTEIDMask)),      /* enb_teid-mask */
-					intEnc(uint64(p.appFilter.srcIPMask)), /* ueaddr ip-mask */
-					intEnc(uint64(p.appFilter.dstIPMask)), /* inet ip-mask */
-					intEnc(uint64(r.srcMask)),             /* ue port-mask */
-					intEnc(uint64(r.dstMask)),             /* inet port-mask */
-					intEnc(uint64(p.appFilter.protoMask)), /* proto id-mask */
-				},
-			}
+	return nil
+}
 
-			any, err = anypb.New(f)
-			if err != nil {
-				log.Errorln("Error marshalling the rule", f, err)
-				return
-			}
+func (b *bess) delPDR(batch *bessBatch, p pdr) error {
+	// Translate port ranges into ternary rule(s) and delete them one-by-one.
+	portRules, err := CreatePortRangeCartesianProduct(p.appFilter.srcPortRange, p.appFilter.dstPortRange)
+	if err != nil {
+		log.Errorln(err)
+		return err
+	}
 
-			b.processPDR(ctx, any, upfMsgTypeDel)
+	for _, r := range portRules {
+		f := &pb.WildcardMatchCommandDeleteArg{
+			Values: []*pb.FieldData{
+				intEnc(uint64(p.srcIface)),        /* src_iface */
+				intEnc(uint64(p.tunnelIP4Dst)),    /* tunnel_ipv4_dst */
+				intEnc(uint64(p.tunnelTEID)),      /* enb_teid */
+				intEnc(uint64(p.appFilter.srcIP)), /* ueaddr ip*/
+				intEnc(uint64(p.appFilter.dstIP)), /* inet ip */
+				intEnc(uint64(r.srcPort)),         /* ue port */
+				intEnc(uint64(r.dstPort)),         /* inet port */
+				intEnc(uint64(p.appFilter.proto)), /* proto id */
+			},
+			Masks: []*pb.FieldData{
+				intEnc(uint64(p.srcIfaceMask)),        /* src_iface-mask */
+				intEnc(uint64(p.tunnelIP4DstMask)),    /* tunnel_ipv4_dst-mask */
+				intEnc(uint64(p.tunnelTEIDMask)),      /* enb_teid-mask */
+				intEnc(uint64(p.appFilter.srcIPMask)), /* ueaddr ip-mask */
+				intEnc(uint64(p.appFilter.dstIPMask)), /* inet ip-mask */
+				intEnc(uint64(r.srcMask)),             /* ue port-mask */
+				intEnc(uint64(r.dstMask)),             /* inet port-mask */
+				intEnc(uint64(p.appFilter.protoMask)), /* proto id-mask */
+			},
 		}
-		done <- true
-	}()
+
+		if err := batch.add("pdrLookup", "delete", f); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (b *bess) addQER(ctx context.Context, done chan<- bool, qer qer) {
-	go func() {
-		var (
-			cir, pir, cbs, ebs, pbs, gate uint64
-			srcIface                      uint8
-		)
+func (b *bess) addQER(batch *bessBatch, qer qer) error {
+	var (
+		cir, pir, cbs, ebs, pbs, gate uint64
+		srcIface                      uint8
+		err                           error
+	)
 
-		// Uplink QER
-		srcIface = access
+	// Uplink QER
+	srcIface = access
 
-		// Lookup QCI from QFI, else try default QCI.
-		qosVal, ok := b.qciQosMap[qer.qfi]
-		if !ok {
-			log.Debug("No config for qfi/qci : ", qer.qfi, ". Using default burst size.")
+	// Lookup QCI from QFI, else try default QCI.
+	qosVal, ok := b.qciQosMap[qer.qfi]
+	if !ok {
+		log.Debug("No config for qfi/qci : ", qer.qfi, ". Using default burst size.")
 
-			qosVal = b.qciQosMap[0]
-		}
+		qosVal = b.qciQosMap[0]
+	}
 
-		cbs = maxUint64(calcBurstSizeFromRate(qer.ulGbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.cbs))
-		ebs = maxUint64(calcBurstSizeFromRate(qer.ulMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
-		pbs = maxUint64(calcBurstSizeFromRate(qer.ulMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
-
-		if qer.ulStatus != ie.GateStatusOpen {
-			gate = qerGateStatusDrop
-		} else if qer.ulMbr != 0 || qer.ulGbr != 0 {
-			/* MBR/GBR is received in Kilobits/sec.
-			   CIR/PIR is sent in bytes */
-			cir = maxUint64(((qer.ulGbr * 1000) / 8), 1)
-			pir = maxUint64(((qer.ulMbr * 1000) / 8), cir)
-			gate = qerGateMeter
-		} else {
-			gate = qerGateUnmeter
-		}
+	cbs = maxUint64(calcBurstSizeFromRate(qer.ulGbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.cbs))
+	ebs = maxUint64(calcBurstSizeFromRate(qer.ulMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
+	pbs = maxUint64(calcBurstSizeFromRate(qer.ulMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
 
-		if qer.qosLevel == ApplicationQos {
-			b.addApplicationQER(ctx, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
-		} else if qer.qosLevel == SessionQos {
-			b.addSessionQER(ctx, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
-		}
+	if qer.ulStatus != ie.GateStatusOpen {
+		gate = qerGateStatusDrop
+	} else if qer.ulMbr != 0 || qer.ulGbr != 0 {
+		/* MBR/GBR is received in Kilobits/sec.
+		   CIR/PIR is sent in bytes */
+		cir = maxUint64(((qer.ulGbr * 1000) / 8), 1)
+		pir = maxUint64(((qer.ulMbr * 1000) / 8), cir)
+		gate = qerGateMeter
+	} else {
+		gate = qerGateUnmeter
+	}
 
-		// Downlink QER
-		srcIface = core
+	if qer.qosLevel == ApplicationQos {
+		err = b.addApplicationQER(batch, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
+	} else if qer.qosLevel == SessionQos {
+		err = b.addSessionQER(batch, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
+	}
 
-		// Lookup QCI from QFI, else try default QCI.
-		qosVal, ok = b.qciQosMap[qer.qfi]
-		if !ok {
-			log.Debug("No config for qfi/qci : ", qer.qfi, ". Using default burst size.")
+	if err != nil {
+		return err
+	}
 
-			qosVal = b.qciQosMap[0]
-		}
+	// Downlink QER
+	srcIface = core
 
-		cbs = maxUint64(calcBurstSizeFromRate(qer.dlGbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.cbs))
-		ebs = maxUint64(calcBurstSizeFromRate(qer.dlMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
-		pbs = maxUint64(calcBurstSizeFromRate(qer.dlMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
-
-		if qer.dlStatus != ie.GateStatusOpen {
-			gate = qerGateStatusDrop
-		} else if qer.dlMbr != 0 || qer.dlGbr != 0 {
-			/* MBR/GBR is received in Kilobits/sec.
-			   CIR/PIR is sent in bytes */
-			cir = maxUint64(((qer.dlGbr * 1000) / 8), 1)
-			pir = maxUint64(((qer.dlMbr * 1000) / 8), cir)
-			gate = qerGateMeter
-		} else {
-			gate = qerGateUnmeter
-		}
+	// Lookup QCI from QFI, else try default QCI.
+	qosVal, ok = b.qciQosMap[qer.qfi]
+	if !ok {
+		log.Debug("No config for qfi/qci : ", qer.qfi, ". Using default burst size.")
 
-		if qer.qosLevel == ApplicationQos {
-			b.addApplicationQER(ctx, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
-		} else if qer.qosLevel == SessionQos {
-			b.addSessionQER(ctx, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
-		}
+		qosVal = b.qciQosMap[0]
+	}
+
+	cbs = maxUint64(calcBurstSizeFromRate(qer.dlGbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.cbs))
+	ebs = maxUint64(calcBurstSizeFromRate(qer.dlMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
+	pbs = maxUint64(calcBurstSizeFromRate(qer.dlMbr, uint64(qosVal.burstDurationMs)), uint64(qosVal.ebs))
+
+	if qer.dlStatus != ie.GateStatusOpen {
+		gate = qerGateStatusDrop
+	} else if qer.dlMbr != 0 || qer.dlGbr != 0 {
+		/* MBR/GBR is received in Kilobits/sec.
+		   CIR/PIR is sent in bytes */
+		cir = maxUint64(((qer.dlGbr * 1000) / 8), 1)
+		pir = maxUint64(((qer.dlMbr * 1000) / 8), cir)
+		gate = qerGateMeter
+	} else {
+		gate = qerGateUnmeter
+	}
 
-		done <- true
-	}()
+	if qer.qosLevel == ApplicationQos {
+		err = b.addApplicationQER(batch, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
+	} else if qer.qosLevel == SessionQos {
+		err = b.addSessionQER(batch, gate, srcIface, cir, pir, cbs, pbs, ebs, qer)
+	}
+
+	return err
 }
 
-func (b *bess) addApplicationQER(ctx context.Context, gate uint64, srcIface uint8,
+func (b *bess) addApplicationQER(batch *bessBatch, gate uint64, srcIface uint8,
 	cir uint64, pir uint64, cbs uint64, pbs uint64,
-	ebs uint64, qer qer) {
-	var (
-		any *anypb.Any
-		err error
-	)
-
+	ebs uint64, qer qer) error {
 	q := &pb.QosCommandAddArg{
 		Gate: gate,
 		Cir:  cir, /* committed info rate */
@@ -978,53 +1222,28 @@ func (b *bess) addApplicationQER(ctx context.Context, gate uint64, srcIface uint
 		},
 	}
 
-	any, err = anypb.New(q)
-	if err != nil {
-		log.Errorln("Error marshalling the rule", q, err)
-		return
-	}
-
-	qosTableName := AppQerLookup
-
-	err = b.processQER(ctx, any, upfMsgTypeAdd, qosTableName)
-	if err != nil {
-		log.Errorln("process QER failed for appQERLookup add operation")
-	}
+	return batch.add(AppQerLookup, "add", q)
 }
 
-func (b *bess) delQER(ctx context.Context, done chan<- bool, qer qer) {
-	go func() {
-		var srcIface uint8
-
-		// Uplink QER
-		srcIface = access
+func (b *bess) delQER(batch *bessBatch, qer qer) error {
+	for _, srcIface := range []uint8{access, core} {
+		var err error
 
 		if qer.qosLevel == ApplicationQos {
-			b.delApplicationQER(ctx, srcIface, qer)
+			err = b.delApplicationQER(batch, srcIface, qer)
 		} else if qer.qosLevel == SessionQos {
-			b.delSessionQER(ctx, srcIface, qer)
+			err = b.delSessionQER(batch, srcIface, qer)
 		}
 
-		// Downlink QER
-		srcIface = core
-
-		if qer.qosLevel == ApplicationQos {
-			b.delApplicationQER(ctx, srcIface, qer)
-		} else if qer.qosLevel == SessionQos {
-			b.delSessionQER(ctx, srcIface, qer)
+		if err != nil {
+			return err
 		}
+	}
 
-		done <- true
-	}()
+	return nil
 }
 
-func (b *bess) delApplicationQER(
-	ctx context.Context, srcIface uint8, qer qer) {
-	var (
-		any *anypb.Any
-		err error
-	)
-
+func (b *bess) delApplicationQER(batch *bessBatch, srcIface uint8, qer qer) error {
 	q := &pb.QosCommandDeleteArg{
 		Fields: []*pb.FieldData{
 			intEnc(uint64(srcIface)),  /* Src Intf */
@@ -1033,24 +1252,12 @@ func (b *bess) delApplicationQER(
 		},
 	}
 
-	any, err = anypb.New(q)
-	if err != nil {
-		log.Println("Error marshalling the rule", q, err)
-		return
-	}
-
-	qosTableName := AppQerLookup
-
-	err = b.processQER(ctx, any, upfMsgTypeDel, qosTableName)
-	if err != nil {
-		log.Errorln("process QER failed for appQERLookup del operation")
-	}
+	return batch.add(AppQerLookup, "delete", q)
 }
 
-func (b *bess) processFAR(ctx context.Context, any *anypb.Any, method upfMsgType) {
+func (b *bess) processFAR(ctx context.Context, any *anypb.Any, method upfMsgType) error {
 	if method != upfMsgTypeAdd && method != upfMsgTypeDel && method != upfMsgTypeClear {
-		log.Println("Invalid method name: ", method)
-		return
+		return ErrInvalidArgument("method name", method)
 	}
 
 	methods := [...]string{"add", "add", "delete", "clear"}
@@ -1065,7 +1272,10 @@ func (b *bess) processFAR(ctx context.Context, any *anypb.Any, method upfMsgType
 
 	if err != nil || resp.GetError() != nil {
 		log.Errorf("farLookup method failed with resp: %v, err: %v\n", resp, err)
+		return moduleCommandError("farLookup", methods[method], resp, err)
 	}
+
+	return nil
 }
 
 func (b *bess) setActionValue(f far) uint8 {
@@ -1087,183 +1297,122 @@ func (b *bess) setActionValue(f far) uint8 {
 	return farDrop
 }
 
-func (b *bess) addFAR(ctx context.Context, done chan<- bool, far far) {
-	go func() {
-		var (
-			any *anypb.Any
-			err error
-		)
-
-		action := b.setActionValue(far)
-		f := &pb.ExactMatchCommandAddArg{
-			Gate: uint64(far.tunnelType),
-			Fields: []*pb.FieldData{
-				intEnc(uint64(far.farID)), /* far_id */
-				intEnc(far.fseID),         /* fseid */
-			},
-			Values: []*pb.FieldData{
-				intEnc(uint64(action)),           /* action */
-				intEnc(uint64(far.tunnelType)),   /* tunnel_out_type */
-				intEnc(uint64(far.tunnelIP4Src)), /* access-ip */
-				intEnc(uint64(far.tunnelIP4Dst)), /* enb ip */
-				intEnc(uint64(far.tunnelTEID)),   /* enb teid */
-				intEnc(uint64(far.tunnelPort)),   /* udp gtpu port */
-			},
-		}
-
-		any, err = anypb.New(f)
-		if err != nil {
-			log.Println("Error marshalling the rule", f, err)
-			return
-		}
+// farAddArg returns the farLookup rule for far.
+func (b *bess) farAddArg(far far) *pb.ExactMatchCommandAddArg {
+	action := b.setActionValue(far)
 
-		b.processFAR(ctx, any, upfMsgTypeAdd)
-		done <- true
-	}()
+	return &pb.ExactMatchCommandAddArg{
+		Gate: uint64(far.tunnelType),
+		Fields: []*pb.FieldData{
+			intEnc(uint64(far.farID)), /* far_id */
+			intEnc(far.fseID),         /* fseid */
+		},
+		Values: []*pb.FieldData{
+			intEnc(uint64(action)),           /* action */
+			intEnc(uint64(far.tunnelType)),   /* tunnel_out_type */
+			intEnc(uint64(far.tunnelIP4Src)), /* access-ip */
+			intEnc(uint64(far.tunnelIP4Dst)), /* enb ip */
+			intEnc(uint64(far.tunnelTEID)),   /* enb teid */
+			intEnc(uint64(far.tunnelPort)),   /* udp gtpu port */
+		},
+	}
 }
 
-func (b *bess) delFAR(ctx context.Context, done chan<- bool, far far) {
-	go func() {
-		var (
-			any *anypb.Any
-			err error
-		)
-
-		f := &pb.ExactMatchCommandDeleteArg{
-			Fields: []*pb.FieldData{
-				intEnc(uint64(far.farID)), /* far_id */
-				intEnc(far.fseID),         /* fseid */
-			},
-		}
-
-		any, err = anypb.New(f)
-		if err != nil {
-			log.Println("Error marshalling the rule", f, err)
-			return
-		}
-
-		b.processFAR(ctx, any, upfMsgTypeDel)
-		done <- true
-	}()
+func (b *bess) addFAR(batch *bessBatch, far far) error {
+	return batch.add("farLookup", "add", b.farAddArg(far))
 }
 
-func (b *bess) processSliceMeter(ctx context.Context, any *anypb.Any, method upfMsgType) {
-	if method != upfMsgTypeAdd && method != upfMsgTypeDel && method != upfMsgTypeClear {
-		log.Errorln("Invalid method name: ", method)
-		return
-	}
-
-	methods := [...]string{"add", "add", "delete", "clear"}
-
-	_, err := b.client.ModuleCommand(
-		ctx, &pb.CommandRequest{
-			Name: "sliceMeter",
-			Cmd:  methods[method],
-			Arg:  any,
+func (b *bess) delFAR(batch *bessBatch, far far) error {
+	f := &pb.ExactMatchCommandDeleteArg{
+		Fields: []*pb.FieldData{
+			intEnc(uint64(far.farID)), /* far_id */
+			intEnc(far.fseID),         /* fseid */
 		},
-	)
-	if err != nil {
-		log.Errorln("sliceMeter method failed!:", err)
 	}
-}
 
-func (b *bess) addSliceMeter(ctx context.Context, done chan<- bool, meterConfig SliceMeterConfig) {
-	go func() {
-		var (
-			any                           *anypb.Any
-			err                           error
-			cir, pir, cbs, ebs, pbs, gate uint64
-		)
-
-		// Uplink N6 slice meter config
-		if meterConfig.N6RateBps != 0 {
-			gate = sliceMeterGateMeter
-			cir = 1                         // Mark all traffic as yellow
-			pir = meterConfig.N6RateBps / 8 // bit/s to byte/s
-		} else {
-			gate = sliceMeterGateUnmeter
-		}
+	return batch.add("farLookup", "delete", f)
+}
 
-		if meterConfig.N6BurstBytes != 0 {
-			cbs = 1 // Mark all traffic as yellow
-			pbs = meterConfig.N6BurstBytes
-			ebs = 0 // Unused
-		} else {
-			cbs = 1 // Mark all traffic as yellow
-			pbs = DefaultBurstSize
-			ebs = 0 // Unused
-		}
+func (b *bess) addSliceMeter(batch *bessBatch, meterConfig SliceMeterConfig) error {
+	var cir, pir, cbs, ebs, pbs, gate uint64
 
-		log.Traceln("uplink slice : cir: ", cir, ", pir: ", pir,
-			", cbs: ", cbs, ", pbs: ", pbs)
-
-		q := &pb.QosCommandAddArg{
-			Gate:              gate,
-			Cir:               cir,                                          /* committed info rate */
-			Pir:               pir,                                          /* peak info rate */
-			Cbs:               cbs,                                          /* committed burst size */
-			Pbs:               pbs,                                          /* Peak burst size */
-			Ebs:               ebs,                                          /* Excess burst size */
-			OptionalDeductLen: &pb.QosCommandAddArg_DeductLen{DeductLen: 0}, /* Include all headers */
-			Fields: []*pb.FieldData{
-				intEnc(uint64(farForwardU)), /* Action */
-				intEnc(uint64(0)),           /* tunnel_out_type */
-			},
-		}
-
-		any, err = anypb.New(q)
-		if err != nil {
-			log.Errorln("Error marshalling the rule", q, err)
-			return
-		}
+	// Uplink N6 slice meter config
+	if meterConfig.N6RateBps != 0 {
+		gate = sliceMeterGateMeter
+		cir = 1                         // Mark all traffic as yellow
+		pir = meterConfig.N6RateBps / 8 // bit/s to byte/s
+	} else {
+		gate = sliceMeterGateUnmeter
+	}
 
-		b.processSliceMeter(ctx, any, upfMsgTypeAdd)
+	if meterConfig.N6BurstBytes != 0 {
+		cbs = 1 // Mark all traffic as yellow
+		pbs = meterConfig.N6BurstBytes
+		ebs = 0 // Unused
+	} else {
+		cbs = 1 // Mark all traffic as yellow
+		pbs = DefaultBurstSize
+		ebs = 0 // Unused
+	}
 
-		// Downlink N3 slice meter config
-		if meterConfig.N3RateBps != 0 {
-			gate = sliceMeterGateMeter
-			cir = 1                         // Mark all traffic as yellow
-			pir = meterConfig.N3RateBps / 8 // bit/s to byte/s
-		} else {
-			gate = sliceMeterGateUnmeter
-		}
+	log.Traceln("uplink slice : cir: ", cir, ", pir: ", pir,
+		", cbs: ", cbs, ", pbs: ", pbs)
 
-		if meterConfig.N3BurstBytes != 0 {
-			cbs = 1 // Mark all traffic as yellow
-			pbs = meterConfig.N3BurstBytes
-			ebs = 0 // Unused
-		} else {
-			cbs = 1 // Mark all traffic as yellow
-			pbs = DefaultBurstSize
-			ebs = 0 // Unused
-		}
+	q := &pb.QosCommandAddArg{
+		Gate:              gate,
+		Cir:               cir,                                          /* committed info rate */
+		Pir:               pir,                                          /* peak info rate */
+		Cbs:               cbs,                                          /* committed burst size */
+		Pbs:               pbs,                                          /* Peak burst size */
+		Ebs:               ebs,                                          /* Excess burst size */
+		OptionalDeductLen: &pb.QosCommandAddArg_DeductLen{DeductLen: 0}, /* Include all headers */
+		Fields: []*pb.FieldData{
+			intEnc(uint64(farForwardU)), /* Action */
+			intEnc(uint64(0)),           /* tunnel_out_type */
+		},
+	}
 
-		log.Traceln("downlink slice : cir: ", cir, ", pir: ", pir,
-			", cbs: ", cbs, ", pbs: ", pbs)
-		// TODO: packet deduction should take GTPU extension header into account
-		q = &pb.QosCommandAddArg{
-			Gate:              gate,
-			Cir:               cir,                                           /* committed info rate */
-			Pir:               pir,                                           /* peak info rate */
-			Cbs:               cbs,                                           /* committed burst size */
-			Pbs:               pbs,                                           /* Peak burst size */
-			Ebs:               ebs,                                           /* Excess burst size */
-			OptionalDeductLen: &pb.QosCommandAddArg_DeductLen{DeductLen: 50}, /* Exclude Ethernet,IP,UDP,GTP header */
-			Fields: []*pb.FieldData{
-				intEnc(uint64(farForwardD)), /* Action */
-				intEnc(uint64(1)),           /* tunnel_out_type */
-			},
-		}
+	if err := batch.add("sliceMeter", "add", q); err != nil {
+		return err
+	}
 
-		any, err = anypb.New(q)
-		if err != nil {
-			log.Errorln("Error marshalling the rule", q, err)
-			return
-		}
+	// Downlink N3 slice meter config
+	if meterConfig.N3RateBps != 0 {
+		gate = sliceMeterGateMeter
+		cir = 1                         // Mark all traffic as yellow
+		pir = meterConfig.N3RateBps / 8 // bit/s to byte/s
+	} else {
+		gate = sliceMeterGateUnmeter
+	}
+
+	if meterConfig.N3BurstBytes != 0 {
+		cbs = 1 // Mark all traffic as yellow
+		pbs = meterConfig.N3BurstBytes
+		ebs = 0 // Unused
+	} else {
+		cbs = 1 // Mark all traffic as yellow
+		pbs = DefaultBurstSize
+		ebs = 0 // Unused
+	}
+
+	log.Traceln("downlink slice : cir: ", cir, ", pir: ", pir,
+		", cbs: ", cbs, ", pbs: ", pbs)
+	// TODO: packet deduction should take GTPU extension header into account
+	q = &pb.QosCommandAddArg{
+		Gate:              gate,
+		Cir:               cir,                                           /* committed info rate */
+		Pir:               pir,                                           /* peak info rate */
+		Cbs:               cbs,                                           /* committed burst size */
+		Pbs:               pbs,                                           /* Peak burst size */
+		Ebs:               ebs,                                           /* Excess burst size */
+		OptionalDeductLen: &pb.QosCommandAddArg_DeductLen{DeductLen: 50}, /* Exclude Ethernet,IP,UDP,GTP header */
+		Fields: []*pb.FieldData{
+			intEnc(uint64(farForwardD)), /* Action */
+			intEnc(uint64(1)),           /* tunnel_out_type */
+		},
+	}
 
-		b.processSliceMeter(ctx, any, upfMsgTypeAdd)
-		done <- true
-	}()
+	return batch.add("sliceMeter", "add", q)
 }
 
 func (b *bess) processQER(ctx context.Context, any *anypb.Any, method upfMsgType, qosTableName string) error {
@@ -1283,20 +1432,15 @@ func (b *bess) processQER(ctx context.Context, any *anypb.Any, method upfMsgType
 
 	if err != nil || resp.GetError() != nil {
 		log.Errorf("%v for qer %v failed with resp: %v, error: %v", qosTableName, methods[method], resp, err)
-		return err
+		return moduleCommandError(qosTableName, methods[method], resp, err)
 	}
 
 	return nil
 }
 
-func (b *bess) addSessionQER(ctx context.Context, gate uint64, srcIface uint8,
+func (b *bess) addSessionQER(batch *bessBatch, gate uint64, srcIface uint8,
 	cir uint64, pir uint64, cbs uint64,
-	pbs uint64, ebs uint64, qer qer) {
-	var (
-		any *anypb.Any
-		err error
-	)
-
+	pbs uint64, ebs uint64, qer qer) error {
 	q := &pb.QosCommandAddArg{
 		Gate: gate,
 		Cir:  cir, /* committed info rate */
@@ -1310,26 +1454,10 @@ func (b *bess) addSessionQER(ctx context.Context, gate uint64, srcIface uint8,
 		},
 	}
 
-	any, err = anypb.New(q)
-	if err != nil {
-		log.Errorln("Error marshalling the rule", q, err)
-		return
-	}
-
-	qosTableName := SessQerLookup
-
-	err = b.processQER(ctx, any, upfMsgTypeAdd, qosTableName)
-	if err != nil {
-		log.Errorln("process QER failed for sessionQERLookup add operation")
-	}
+	return batch.add(SessQerLookup, "add", q)
 }
 
-func (b *bess) delSessionQER(ctx context.Context, srcIface uint8, qer qer) {
-	var (
-		any *anypb.Any
-		err error
-	)
-
+func (b *bess) delSessionQER(batch *bessBatch, srcIface uint8, qer qer) error {
 	q := &pb.QosCommandDeleteArg{
 		Fields: []*pb.FieldData{
 			intEnc(uint64(srcIface)), /* Src Intf */
@@ -1337,38 +1465,167 @@ func (b *bess) delSessionQER(ctx context.Context, srcIface uint8, qer qer) {
 		},
 	}
 
-	any, err = anypb.New(q)
-	if err != nil {
-		log.Println("Error marshalling the rule", q, err)
-		return
+	return batch.add(SessQerLookup, "delete", q)
+}
+
+func fieldDataKey(fields []*pb.FieldData) string {
+	var sb strings.Builder
+
+	for _, f := range fields {
+		switch v := f.GetEncoding().(type) {
+		case *pb.FieldData_ValueInt:
+			fmt.Fprintf(&sb, "i%d,", v.ValueInt)
+		case *pb.FieldData_ValueBin:
+			fmt.Fprintf(&sb, "b%x,", v.ValueBin)
+		default:
+			sb.WriteString("-,")
+		}
 	}
 
-	qosTableName := SessQerLookup
+	return sb.String()
+}
+
+func pdrReconcileEntry(r *pb.WildcardMatchCommandAddArg) reconcileEntry {
+	return reconcileEntry{
+		key:   fieldDataKey(r.GetValues()) + "/" + fieldDataKey(r.GetMasks()),
+		value: fmt.Sprintf("%d/%d/%s", r.GetGate(), r.GetPriority(), fieldDataKey(r.GetValuesv())),
+		obj:   r,
+	}
+}
 
-	err = b.processQER(ctx, any, upfMsgTypeDel, qosTableName)
+func farReconcileEntry(r *pb.ExactMatchCommandAddArg) reconcileEntry {
+	return reconcileEntry{
+		key:   fieldDataKey(r.GetFields()),
+		value: fmt.Sprintf("%d/%s", r.GetGate(), fieldDataKey(r.GetValues())),
+		obj:   r,
+	}
+}
+
+// readModuleRules issues a read command with no arguments to module and unmarshals the response into cfg.
+func (b *bess) readModuleRules(module, cmd string, cfg proto.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	arg, err := anypb.New(&pb.EmptyArg{})
 	if err != nil {
-		log.Errorln("process QER failed for sessionQERLookup del operation")
+		return err
 	}
+
+	resp, err := b.client.ModuleCommand(ctx, &pb.CommandRequest{
+		Name: module,
+		Cmd:  cmd,
+		Arg:  arg,
+	})
+	if err != nil || resp.GetError() != nil {
+		return moduleCommandError(module, cmd, resp, err)
+	}
+
+	return resp.GetData().UnmarshalTo(cfg)
 }
 
-func (b *bess) GRPCJoin(calls int, timeout time.Duration, done chan bool) bool {
-	boom := time.After(timeout)
+// Reconcile reads back the pdrLookup and farLookup rules and repairs them against the rules of sessions.
+// QERs are not reconciled, as the Qos module does not support reading back its rules.
+func (b *bess) Reconcile(sessions []PFCPSession) (driftReport, error) {
+	report := driftReport{Datapath: "bess"}
 
-	for {
-		select {
-		case ok := <-done:
-			if !ok {
-				log.Println("Error making GRPC calls")
-				return false
+	if !b.IsConnected(nil) {
+		return report, ErrOperationFailedWithReason("reconcile BESS", "not connected")
+	}
+
+	// block rule updates, so that the read back state is consistent with the sessions
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+
+	var expectedPDRs, expectedFARs []reconcileEntry
+
+	for _, session := range sessions {
+		for _, p := range session.pdrs {
+			args, err := pdrAddArgs(p)
+			if err != nil {
+				return report, err
 			}
 
-			calls--
-			if calls == 0 {
-				return true
+			for _, arg := range args {
+				expectedPDRs = append(expectedPDRs, pdrReconcileEntry(arg))
 			}
-		case <-boom:
-			log.Println("Timed out adding entries")
-			return false
+		}
+
+		for _, f := range session.fars {
+			expectedFARs = append(expectedFARs, farReconcileEntry(b.farAddArg(f)))
 		}
 	}
+
+	pdrConfig := &pb.WildcardMatchConfig{}
+	if err := b.readModuleRules("pdrLookup", "get_rules", pdrConfig); err != nil {
+		return report, err
+	}
+
+	farConfig := &pb.ExactMatchConfig{}
+	if err := b.readModuleRules("farLookup", "get_runtime_config", farConfig); err != nil {
+		return report, err
+	}
+
+	actualPDRs := make([]reconcileEntry, 0, len(pdrConfig.GetRules()))
+	for _, r := range pdrConfig.GetRules() {
+		actualPDRs = append(actualPDRs, pdrReconcileEntry(r))
+	}
+
+	actualFARs := make([]reconcileEntry, 0, len(farConfig.GetRules()))
+	for _, r := range farConfig.GetRules() {
+		actualFARs = append(actualFARs, farReconcileEntry(r))
+	}
+
+	pdrDiff := diffEntries(expectedPDRs, actualPDRs)
+	farDiff := diffEntries(expectedFARs, actualFARs)
+
+	report.add(pdrDiff)
+	report.add(farDiff)
+
+	repair := func(err error) {
+		if err != nil {
+			report.Failed++
+			return
+		}
+
+		report.Repaired++
+	}
+
+	// adding an existing rule overwrites it in BESS
+	for _, e := range append(pdrDiff.missing, pdrDiff.different...) {
+		repair(b.repairRule(e.obj.(*pb.WildcardMatchCommandAddArg), b.processPDR, upfMsgTypeAdd))
+	}
+
+	for _, e := range pdrDiff.extra {
+		r := e.obj.(*pb.WildcardMatchCommandAddArg)
+		repair(b.repairRule(&pb.WildcardMatchCommandDeleteArg{
+			Values: r.GetValues(),
+			Masks:  r.GetMasks(),
+		}, b.processPDR, upfMsgTypeDel))
+	}
+
+	for _, e := range append(farDiff.missing, farDiff.different...) {
+		repair(b.repairRule(e.obj.(*pb.ExactMatchCommandAddArg), b.processFAR, upfMsgTypeAdd))
+	}
+
+	for _, e := range farDiff.extra {
+		r := e.obj.(*pb.ExactMatchCommandAddArg)
+		repair(b.repairRule(&pb.ExactMatchCommandDeleteArg{
+			Fields: r.GetFields(),
+		}, b.processFAR, upfMsgTypeDel))
+	}
+
+	return report, nil
+}
+
+func (b *bess) repairRule(rule proto.Message,
+	process func(ctx context.Context, any *anypb.Any, method upfMsgType) error, method upfMsgType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	any, err := anypb.New(rule)
+	if err != nil {
+		return err
+	}
+
+	return process(ctx, any, method)
 }
@@ -0,0 +1,428 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/omec-project/upf-epc/pfcpiface/bess_pb"
+	"github.com/omec-project/upf-epc/pkg/fake_bess"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func getFreeLocalAddress(t testing.TB) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func startFakeBESS(t testing.TB, address string) *fake_bess.FakeBESS {
+	fb := fake_bess.NewFakeBESS()
+
+	go func() {
+		if err := fb.Run(address); err != nil {
+			t.Logf("fake BESS stopped: %v", err)
+		}
+	}()
+
+	return fb
+}
+
+// newFakeBESSTestBESS returns a bess connected to a fake BESS. The fake BESS listens on the
+// notify and end marker sockets if they are enabled in conf.
+func newFakeBESSTestBESS(t *testing.T, u *upf, conf *Conf) (*bess, *fake_bess.FakeBESS) {
+	address := getFreeLocalAddress(t)
+
+	oldBessIP := *bessIP
+	*bessIP = address
+
+	t.Cleanup(func() { *bessIP = oldBessIP })
+
+	fb := startFakeBESS(t, address)
+	t.Cleanup(fb.Stop)
+
+	if conf.EnableNotifyBess {
+		require.NoError(t, fb.ListenNotifySocket(conf.NotifySockAddr))
+	}
+
+	if conf.EnableEndMarker {
+		require.NoError(t, fb.ListenEndMarkerSocket(conf.EndMarkerSockAddr))
+	}
+
+	u.setSessionsSource(func() []PFCPSession { return nil })
+
+	b := &bess{}
+	u.datapath = b
+	b.SetUpfInfo(u, conf)
+	t.Cleanup(b.Exit)
+
+	require.Eventually(t, func() bool { return b.IsConnected(nil) }, 10*time.Second, 50*time.Millisecond)
+
+	return b, fb
+}
+
+// sessionStatsCollector collects the session statistics once per scrape. Unlike PfcpNodeCollector,
+// it does not read the statistics (which clears them) to describe the metrics.
+type sessionStatsCollector struct {
+	b  *bess
+	pc *PfcpNodeCollector
+}
+
+func (c sessionStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pc.sessionTxPackets
+	ch <- c.pc.sessionRxPackets
+	ch <- c.pc.sessionTxBytes
+	ch <- c.pc.sessionLatency
+	ch <- c.pc.sessionJitter
+}
+
+func (c sessionStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	if err := c.b.SessionStats(c.pc, ch); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func newTestBESSSession(fseid uint64) PFCPSession {
+	return PFCPSession{
+		localSEID: fseid,
+		PacketForwardingRules: PacketForwardingRules{
+			pdrs: []pdr{
+				{
+					srcIface: access, srcIfaceMask: 0xff, tunnelTEID: uint32(fseid), tunnelTEIDMask: 0xffffffff,
+					pdrID: 1, fseID: fseid, farID: 1,
+				},
+				{
+					srcIface: core, srcIfaceMask: 0xff, ueAddress: 0x0a000000 + uint32(fseid),
+					appFilter: applicationFilter{srcIP: 0x0a000000 + uint32(fseid), srcIPMask: 0xffffffff},
+					pdrID:     2, fseID: fseid, farID: 2,
+				},
+			},
+			fars: []far{
+				{farID: 1, fseID: fseid, applyAction: ActionForward, dstIntf: 1},
+				{farID: 2, fseID: fseid, applyAction: ActionForward, dstIntf: 0},
+			},
+			qers: []qer{
+				{qerID: 1, fseID: fseid, qosLevel: SessionQos, ulStatus: 0, dlStatus: 0},
+			},
+		},
+	}
+}
+
+func Test_bessReplaysStateOnReconnect(t *testing.T) {
+	address := getFreeLocalAddress(t)
+
+	oldBessIP := *bessIP
+	*bessIP = address
+
+	defer func() { *bessIP = oldBessIP }()
+
+	fb := startFakeBESS(t, address)
+
+	sessions := []PFCPSession{newTestBESSSession(1), newTestBESSSession(2)}
+
+	u := &upf{reportNotifyChan: make(chan uint64, 1)}
+	u.setSessionsSource(func() []PFCPSession { return sessions })
+
+	b := &bess{}
+	b.SetUpfInfo(u, &Conf{
+		SliceMeterConfig: SliceMeterConfig{N6RateBps: 1000000, N3RateBps: 1000000},
+	})
+
+	defer b.Exit()
+
+	require.Eventually(t, func() bool { return b.IsConnected(nil) }, 10*time.Second, 50*time.Millisecond)
+
+	for _, s := range sessions {
+		tx := b.BeginRules(context.Background(), s.PacketForwardingRules)
+		createRules(tx, s.PacketForwardingRules)
+		require.NoError(t, tx.Commit())
+	}
+
+	require.Len(t, fb.GetFarTableEntries(), 2)
+
+	// simulate a BESS restart, the new instance has no state
+	fb.Stop()
+
+	require.Eventually(t, func() bool { return !b.IsConnected(nil) }, 10*time.Second, 50*time.Millisecond)
+
+	fb = startFakeBESS(t, address)
+	defer fb.Stop()
+
+	require.Eventually(t, func() bool { return b.IsConnected(nil) }, 20*time.Second, 50*time.Millisecond)
+
+	pdrs := fb.GetPdrTableEntries()
+	require.Len(t, pdrs[1], 2, "uplink PDRs of both sessions should be replayed")
+	require.Len(t, pdrs[2], 2, "downlink PDRs of both sessions should be replayed")
+	require.Len(t, fb.GetSessionQerTableEntries(), 4, "session QERs should be replayed for both directions")
+	require.Len(t, fb.GetSliceMeterEntries(), 2, "slice meters should be replayed")
+}
+
+func Test_bessAddSliceInfoConcurrently(t *testing.T) {
+	b, fb := newFakeBESSTestBESS(t, &upf{}, &Conf{})
+
+	var wg sync.WaitGroup
+
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+
+		go func(rate uint64) {
+			defer wg.Done()
+
+			require.NoError(t, b.AddSliceInfo(&SliceInfo{uplinkMbr: rate, downlinkMbr: rate}))
+		}(uint64(i) * 1000000)
+	}
+
+	// the replay reads the last slice meter configuration concurrently
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		b.replayState()
+	}()
+
+	wg.Wait()
+
+	require.Len(t, fb.GetSliceMeterEntries(), 2)
+}
+
+func Test_bessReconcileRepairsDrift(t *testing.T) {
+	address := getFreeLocalAddress(t)
+
+	oldBessIP := *bessIP
+	*bessIP = address
+
+	defer func() { *bessIP = oldBessIP }()
+
+	fb := startFakeBESS(t, address)
+	defer fb.Stop()
+
+	sessions := []PFCPSession{newTestBESSSession(1), newTestBESSSession(2)}
+
+	u := &upf{reportNotifyChan: make(chan uint64, 1)}
+	u.setSessionsSource(func() []PFCPSession { return sessions })
+
+	b := &bess{}
+	b.SetUpfInfo(u, &Conf{})
+
+	defer b.Exit()
+
+	require.Eventually(t, func() bool { return b.IsConnected(nil) }, 10*time.Second, 50*time.Millisecond)
+
+	for _, s := range sessions {
+		tx := b.BeginRules(context.Background(), s.PacketForwardingRules)
+		createRules(tx, s.PacketForwardingRules)
+		require.NoError(t, tx.Commit())
+	}
+
+	report, err := b.Reconcile(sessions)
+	require.NoError(t, err)
+	require.False(t, report.hasDrift())
+
+	batch := newBESSBatch()
+
+	// missing FAR
+	require.NoError(t, b.delFAR(batch, sessions[0].fars[0]))
+
+	// different FAR
+	modifiedFAR := sessions[1].fars[1]
+	modifiedFAR.applyAction = ActionDrop
+	require.NoError(t, b.addFAR(batch, modifiedFAR))
+
+	// extra PDR
+	staleSession := newTestBESSSession(3)
+	require.NoError(t, b.addPDR(batch, staleSession.pdrs[0]))
+
+	require.True(t, b.executeBatch(batch))
+
+	report, err = b.Reconcile(sessions)
+	require.NoError(t, err)
+	require.Equal(t, driftReport{Datapath: "bess", Missing: 1, Extra: 1, Different: 1, Repaired: 3}, report)
+
+	pdrs := fb.GetPdrTableEntries()
+	require.Len(t, pdrs[1], 2, "stale uplink PDR should be removed")
+	require.Len(t, pdrs[2], 2)
+
+	report, err = b.Reconcile(sessions)
+	require.NoError(t, err)
+	require.False(t, report.hasDrift(), "no drift expected after repair")
+}
+
+func Test_bessPortStats(t *testing.T) {
+	u := &upf{reportNotifyChan: make(chan uint64, 1), accessIface: "access", coreIface: "core"}
+	_, fb := newFakeBESSTestBESS(t, u, &Conf{})
+
+	fb.SetPortStats("accessFast",
+		&pb.GetPortStatsResponse_Stat{Packets: 10, Bytes: 1000, Dropped: 1},
+		&pb.GetPortStatsResponse_Stat{Packets: 20, Bytes: 2000, Dropped: 2})
+	fb.SetPortStats("coreFast",
+		&pb.GetPortStatsResponse_Stat{Packets: 30, Bytes: 3000},
+		&pb.GetPortStatsResponse_Stat{Packets: 40, Bytes: 4000})
+
+	expected := `
+# HELP upf_packets_count Shows the number of packets received by the UPF port
+# TYPE upf_packets_count counter
+upf_packets_count{dir="rx",iface="Access"} 10
+upf_packets_count{dir="rx",iface="Core"} 30
+upf_packets_count{dir="tx",iface="Access"} 20
+upf_packets_count{dir="tx",iface="Core"} 40
+# HELP upf_dropped_count Shows the number of packets dropped on receive by the UPF port
+# TYPE upf_dropped_count counter
+upf_dropped_count{dir="rx",iface="Access"} 1
+upf_dropped_count{dir="rx",iface="Core"} 0
+upf_dropped_count{dir="tx",iface="Access"} 2
+upf_dropped_count{dir="tx",iface="Core"} 0
+`
+	require.NoError(t, testutil.CollectAndCompare(newUpfCollector(u), strings.NewReader(expected),
+		"upf_packets_count", "upf_dropped_count"))
+}
+
+func Test_bessSessionStats(t *testing.T) {
+	u := &upf{reportNotifyChan: make(chan uint64, 1)}
+	b, fb := newFakeBESSTestBESS(t, u, &Conf{})
+
+	fb.SetFlowMeasureStatistics(PreQosFlowMeasure, []*pb.FlowMeasureReadResponse_Statistic{
+		{Fseid: 1, Pdr: 2, TotalPackets: 10, TotalBytes: 1000},
+	})
+	fb.SetFlowMeasureStatistics(PostDlQosFlowMeasure, []*pb.FlowMeasureReadResponse_Statistic{
+		{Fseid: 1, Pdr: 2, TotalPackets: 8, TotalBytes: 800},
+	})
+
+	c := sessionStatsCollector{b: b, pc: NewPFCPNodeCollector(&PFCPNode{})}
+
+	expected := `
+# HELP upf_session_rx_packets Shows the total number of packets received for a given session in UPF
+# TYPE upf_session_rx_packets gauge
+upf_session_rx_packets{fseid="1",pdr="2",ue_ip="unknown"} 10
+# HELP upf_session_tx_packets Shows the total number of packets sent for a given session in UPF
+# TYPE upf_session_tx_packets gauge
+upf_session_tx_packets{fseid="1",pdr="2",ue_ip="unknown"} 8
+# HELP upf_session_tx_bytes Shows the total number of bytes for a given session in UPF
+# TYPE upf_session_tx_bytes gauge
+upf_session_tx_bytes{fseid="1",pdr="2",ue_ip="unknown"} 800
+`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"upf_session_rx_packets", "upf_session_tx_packets", "upf_session_tx_bytes"))
+
+	// the statistics are cleared once read
+	require.Zero(t, testutil.CollectAndCount(c))
+}
+
+func Test_bessDownlinkDataNotification(t *testing.T) {
+	u := &upf{reportNotifyChan: make(chan uint64, 1)}
+	_, fb := newFakeBESSTestBESS(t, u, &Conf{
+		EnableNotifyBess: true,
+		NotifySockAddr:   filepath.Join(t.TempDir(), "notifycp"),
+	})
+
+	// the notify socket is connected asynchronously by the fake BESS
+	require.Eventually(t, func() bool { return fb.NotifyDownlinkData(5) == nil }, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case fseid := <-u.reportNotifyChan:
+		require.Equal(t, uint64(5), fseid)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no Downlink Data Notification for the F-SEID")
+	}
+}
+
+func Test_bessSendEndMarkers(t *testing.T) {
+	u := &upf{reportNotifyChan: make(chan uint64, 1)}
+	b, fb := newFakeBESSTestBESS(t, u, &Conf{
+		EnableEndMarker:   true,
+		EndMarkerSockAddr: filepath.Join(t.TempDir(), "pfcpport"),
+	})
+
+	endMarkers := [][]byte{{0x01}, {0x02}}
+	require.NoError(t, b.SendEndMarkers(&endMarkers))
+
+	require.Eventually(t, func() bool { return len(fb.GetEndMarkers()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, endMarkers, fb.GetEndMarkers())
+}
+
+// Benchmark_bessSessionEstablishment measures how many sessions per second can be established and
+// removed against fake BESS, with an increasing number of concurrent PFCP messages. The messages
+// beyond the pending bound of the workers are rejected rather than failed.
+func Benchmark_bessSessionEstablishment(b *testing.B) {
+	log.SetLevel(log.ErrorLevel)
+	defer log.SetLevel(log.InfoLevel)
+
+	address := getFreeLocalAddress(b)
+
+	oldBessIP := *bessIP
+	*bessIP = address
+
+	defer func() { *bessIP = oldBessIP }()
+
+	fb := startFakeBESS(b, address)
+	defer fb.Stop()
+
+	u := &upf{reportNotifyChan: make(chan uint64, 1)}
+	u.setSessionsSource(func() []PFCPSession { return nil })
+
+	dp := &bess{}
+	dp.SetUpfInfo(u, &Conf{})
+
+	defer dp.Exit()
+
+	require.Eventually(b, func() bool { return dp.IsConnected(nil) }, 10*time.Second, 10*time.Millisecond)
+
+	var fseid uint64
+
+	for _, concurrency := range []int{1, 16, 256, 1024} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			var failed, rejected int64
+
+			b.SetParallelism(concurrency)
+			b.ResetTimer()
+
+			start := time.Now()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := newTestBESSSession(atomic.AddUint64(&fseid, 1))
+
+					tx := dp.BeginRules(context.Background(), s.PacketForwardingRules)
+					createRules(tx, s.PacketForwardingRules)
+
+					err := tx.Commit()
+					if err == nil {
+						tx = dp.BeginRules(context.Background(), PacketForwardingRules{})
+						deleteRules(tx, s.PacketForwardingRules)
+						err = tx.Commit()
+					}
+
+					switch {
+					case errors.Is(err, errNoResources):
+						atomic.AddInt64(&rejected, 1)
+						// like a SMF, retry later rather than right away
+						time.Sleep(100 * time.Millisecond)
+					case err != nil:
+						atomic.AddInt64(&failed, 1)
+					}
+				}
+			})
+
+			established := int64(b.N) - failed - rejected
+			b.ReportMetric(float64(established)/time.Since(start).Seconds(), "establishments/s")
+			b.ReportMetric(float64(failed), "failed")
+			b.ReportMetric(float64(rejected), "rejected")
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	pb "github.com/omec-project/upf-epc/pfcpiface/bess_pb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// bessBatch collects the BESS module commands required by a single PFCP message, grouped by module.
+type bessBatch struct {
+	modules  []string
+	commands map[string][]*pb.CommandRequest
+}
+
+func newBESSBatch() *bessBatch {
+	return &bessBatch{
+		commands: make(map[string][]*pb.CommandRequest),
+	}
+}
+
+// add appends the command cmd with argument arg for module to the batch.
+func (b *bessBatch) add(module string, cmd string, arg proto.Message) error {
+	any, err := anypb.New(arg)
+	if err != nil {
+		log.Errorln("Error marshalling the rule", arg, err)
+		return err
+	}
+
+	if _, ok := b.commands[module]; !ok {
+		b.modules = append(b.modules, module)
+	}
+
+	b.commands[module] = append(b.commands[module], &pb.CommandRequest{
+		Name: module,
+		Cmd:  cmd,
+		Arg:  any,
+	})
+
+	return nil
+}
+
+func (b *bessBatch) len() int {
+	n := 0
+
+	for _, cmds := range b.commands {
+		n += len(cmds)
+	}
+
+	return n
+}
+
+// bessJob is a sequence of commands for a single BESS module.
+type bessJob struct {
+	ctx      context.Context
+	commands []*pb.CommandRequest
+	result   chan<- error
+}
+
+// bessPendingMsgsPerWorker bounds the messages waiting for the workers. The messages beyond it are
+// rejected right away, rather than partially programmed once their deadline expires in the queue.
+const bessPendingMsgsPerWorker = 32
+
+// bessWorkerPool executes BESS module commands with a bounded number of concurrent calls per module.
+type bessWorkerPool struct {
+	client  pb.BESSControlClient
+	workers int
+	// pending holds a token per message being executed
+	pending chan struct{}
+
+	mu     sync.Mutex
+	queues map[string]chan bessJob
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newBESSWorkerPool(client pb.BESSControlClient, workers int) *bessWorkerPool {
+	return &bessWorkerPool{
+		client:  client,
+		workers: workers,
+		pending: make(chan struct{}, workers*bessPendingMsgsPerWorker),
+		queues:  make(map[string]chan bessJob),
+		stop:    make(chan struct{}),
+	}
+}
+
+// queue returns the job queue of module, starting its workers on first use.
+func (p *bessWorkerPool) queue(module string) chan bessJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	q, ok := p.queues[module]
+	if ok {
+		return q
+	}
+
+	q = make(chan bessJob)
+	p.queues[module] = q
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+
+		go p.work(q)
+	}
+
+	return q
+}
+
+func (p *bessWorkerPool) work(q <-chan bessJob) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case job := <-q:
+			job.result <- p.run(job)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// run executes all commands of job, even if some of them fail, and returns the first error.
+func (p *bessWorkerPool) run(job bessJob) error {
+	var firstErr error
+
+	for _, req := range job.commands {
+		resp, err := p.client.ModuleCommand(job.ctx, req)
+
+		log.Traceln(req.Name, " resp : ", resp)
+
+		if err != nil || resp.GetError() != nil {
+			log.Errorf("%v %v failed with resp: %v, err: %v", req.Name, req.Cmd, resp, err)
+
+			if firstErr == nil {
+				firstErr = moduleCommandError(req.Name, req.Cmd, resp, err)
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// execute runs all commands of batch, one job per module, and waits for their completion until ctx is done.
+// Commands for the same module are sent in order. Fails with errNoResources if too many messages are pending.
+func (p *bessWorkerPool) execute(ctx context.Context, batch *bessBatch) error {
+	if batch.len() == 0 {
+		return nil
+	}
+
+	select {
+	case p.pending <- struct{}{}:
+		defer func() { <-p.pending }()
+	default:
+		return ErrNoResourcesAvailable("BESS command queue")
+	}
+
+	// buffered so that no worker is left blocked if we stop waiting early
+	results := make(chan error, len(batch.modules))
+	submitted := 0
+
+	for _, module := range batch.modules {
+		job := bessJob{ctx: ctx, commands: batch.commands[module], result: results}
+
+		select {
+		case p.queue(module) <- job:
+			submitted++
+		case <-ctx.Done():
+			return fmt.Errorf("submit BESS commands: %w", ctx.Err())
+		}
+	}
+
+	var firstErr error
+
+	for i := 0; i < submitted; i++ {
+		select {
+		case err := <-results:
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("wait for BESS commands: %w", ctx.Err())
+		}
+	}
+
+	return firstErr
+}
+
+// Stop stops all workers once they complete their current job.
+func (p *bessWorkerPool) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
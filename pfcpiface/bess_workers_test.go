@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/omec-project/upf-epc/pfcpiface/bess_pb"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+	"google.golang.org/grpc"
+)
+
+// moduleCommandRecorder is a BESS client recording the module commands and the maximum number of concurrent calls.
+type moduleCommandRecorder struct {
+	pb.BESSControlClient
+
+	mu            sync.Mutex
+	inFlight      int
+	maxInFlight   int
+	commandsOrder map[string][]string
+}
+
+func (r *moduleCommandRecorder) ModuleCommand(ctx context.Context, req *pb.CommandRequest, opts ...grpc.CallOption) (*pb.CommandResponse, error) {
+	r.mu.Lock()
+	r.inFlight++
+
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+
+	r.commandsOrder[req.Name] = append(r.commandsOrder[req.Name], req.Cmd)
+	r.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+
+	return &pb.CommandResponse{}, nil
+}
+
+func Test_bessWorkerPool_execute(t *testing.T) {
+	const workers = 2
+
+	recorder := &moduleCommandRecorder{commandsOrder: make(map[string][]string)}
+	pool := newBESSWorkerPool(recorder, workers)
+
+	defer pool.Stop()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			batch := newBESSBatch()
+			require.NoError(t, batch.add("pdrLookup", "add", &pb.EmptyArg{}))
+			require.NoError(t, batch.add("pdrLookup", "delete", &pb.EmptyArg{}))
+
+			require.NoError(t, pool.execute(context.Background(), batch))
+		}()
+	}
+
+	wg.Wait()
+
+	require.LessOrEqual(t, recorder.maxInFlight, workers, "concurrent calls should be bounded by the number of workers")
+
+	order := recorder.commandsOrder["pdrLookup"]
+	require.Len(t, order, 20)
+
+	// commands of a batch are sent in order by the same worker, but batches can interleave
+	adds := 0
+
+	for _, cmd := range order {
+		if cmd == "add" {
+			adds++
+		} else {
+			adds--
+		}
+
+		require.GreaterOrEqual(t, adds, 0, "delete sent before the corresponding add")
+	}
+}
+
+func Test_bessWorkerPool_executeDeadline(t *testing.T) {
+	recorder := &moduleCommandRecorder{commandsOrder: make(map[string][]string)}
+	pool := newBESSWorkerPool(recorder, 1)
+
+	defer pool.Stop()
+
+	batch := newBESSBatch()
+	require.NoError(t, batch.add("farLookup", "add", &pb.EmptyArg{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.Error(t, pool.execute(ctx, batch))
+}
+
+// blockingModuleCommandClient is a BESS client whose module commands wait until released.
+type blockingModuleCommandClient struct {
+	pb.BESSControlClient
+
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingModuleCommandClient) ModuleCommand(ctx context.Context, req *pb.CommandRequest, opts ...grpc.CallOption) (*pb.CommandResponse, error) {
+	c.started <- struct{}{}
+
+	select {
+	case <-c.release:
+		return &pb.CommandResponse{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func Test_bessWorkerPool_executeRejectsWhenFull(t *testing.T) {
+	client := &blockingModuleCommandClient{
+		started: make(chan struct{}, bessPendingMsgsPerWorker),
+		release: make(chan struct{}),
+	}
+	pool := newBESSWorkerPool(client, 1)
+
+	defer pool.Stop()
+
+	newBatch := func() *bessBatch {
+		batch := newBESSBatch()
+		require.NoError(t, batch.add("farLookup", "add", &pb.EmptyArg{}))
+
+		return batch
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < bessPendingMsgsPerWorker; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			require.NoError(t, pool.execute(context.Background(), newBatch()))
+		}()
+	}
+
+	// wait for the single worker to be busy and the other messages to be pending
+	<-client.started
+	require.Eventually(t, func() bool { return len(pool.pending) == bessPendingMsgsPerWorker },
+		5*time.Second, time.Millisecond)
+
+	err := pool.execute(context.Background(), newBatch())
+	require.ErrorIs(t, err, errNoResources)
+	require.Equal(t, ie.CauseNoResourcesAvailable, datapathErrorCause(err))
+
+	close(client.release)
+	wg.Wait()
+
+	require.NoError(t, pool.execute(context.Background(), newBatch()))
+}
@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"net"
+	"net/url"
 	"time"
 
 	"encoding/json"
@@ -21,6 +22,16 @@ const (
 	respTimeoutDefault   = 2 * time.Second
 	hbIntervalDefault    = 5 * time.Second
 	readTimeoutDefault   = 15 * time.Second
+
+	bessModuleWorkersDefault = 8
+	bessMsgDeadlineDefault   = time.Second
+
+	lbKeepaliveIntervalDefault  = 30 * time.Second
+	lbLoadReportIntervalDefault = 10 * time.Second
+	overloadValidityDefault     = 30 * time.Second
+	enterLBURLDefault           = "http://enterlb:8080"
+	exitLBURLDefault            = "http://exitlb:8080"
+	pfcpLBURLDefault            = "http://upf-http:8081/"
 )
 
 // Conf : Json conf struct.
@@ -47,6 +58,15 @@ type Conf struct {
 	EnableHBTimer     bool             `json:"enable_hbTimer"`
 	HeartBeatInterval string           `json:"heart_beat_interval"`
 	Ueransim          bool             `json:"ueransim"`
+	ReconcileInterval string           `json:"reconcile_interval"`
+	BessModuleWorkers uint32           `json:"bess_module_workers"`
+	BessMsgDeadline   string           `json:"bess_msg_deadline"`
+	Mirror            MirrorConf       `json:"mirror"`
+	LoadBalancers     LBConf           `json:"load_balancers"`
+	LoadControl       LoadControlConf  `json:"load_control"`
+	SessionHooks      SessionHooksConf `json:"session_hooks"`
+	HTTP              HTTPConf         `json:"http"`
+	Drain             DrainConf        `json:"drain"`
 }
 
 // QciQosConfig : Qos configured attributes.
@@ -94,6 +114,102 @@ type IfaceType struct {
 	IfName string `json:"ifname"`
 }
 
+// MirrorConf : Mirroring of the rules to a secondary datapath.
+type MirrorConf struct {
+	// Secondary is the datapath mirroring the primary one, mirroring is disabled if empty.
+	Secondary string `json:"secondary"`
+}
+
+// LBConf : URLs of the load balancers the UPF registers to. Defaults are used if empty.
+type LBConf struct {
+	// EnterLBURL is the base URL of the load balancer in front of the access interface.
+	EnterLBURL string `json:"enter_lb_url"`
+	// ExitLBURL is the base URL of the load balancer in front of the core interface.
+	ExitLBURL string `json:"exit_lb_url"`
+	// PFCPLBURL is the URL the PFCP load balancer accepts the UPF info on.
+	PFCPLBURL string `json:"pfcp_lb_url"`
+	// KeepaliveInterval is the interval at which the registration to the Enter-LB and Exit-LB
+	// is renewed, to register again to a restarted LB. "0s" disables the renewal.
+	KeepaliveInterval string `json:"keepalive_interval"`
+	// LoadReportInterval is the interval at which the load of the UPF is reported to the
+	// PFCP-LB. "0s" disables the reports.
+	LoadReportInterval string `json:"load_report_interval"`
+	// API is the API of the Enter-LB and Exit-LB: "http" (default) for the JSON API served on
+	// EnterLBURL and ExitLBURL, or "grpc" for the lb_pb service served on EnterLBGRPCAddr and
+	// ExitLBGRPCAddr.
+	API string `json:"api"`
+	// EnterLBGRPCAddr is the host:port of the gRPC API of the load balancer in front of the
+	// access interface.
+	EnterLBGRPCAddr string `json:"enter_lb_grpc_addr"`
+	// ExitLBGRPCAddr is the host:port of the gRPC API of the load balancer in front of the core
+	// interface.
+	ExitLBGRPCAddr string `json:"exit_lb_grpc_addr"`
+	// GRPCListenAddr is the address the UPF serves its gRPC API to the load balancers on,
+	// disabled if empty. The HTTP API is served in any case.
+	GRPCListenAddr string `json:"grpc_listen_addr"`
+	// AllowedSources are the IP addresses or CIDR networks the Enter-LB and Exit-LB announce
+	// their gateway from. Any source is accepted if empty.
+	AllowedSources []string `json:"allowed_sources"`
+}
+
+// LoadControlConf configures the load of the UPF, as advertised to the SMF in the PFCP Load
+// Control Information and Overload Control Information IEs. The load is the highest of the
+// session and UE IP pool utilisations, in percent.
+type LoadControlConf struct {
+	// MaxSessions is the session capacity of the UPF. 0 only uses the UE IP pool for the load.
+	MaxSessions uint32 `json:"max_sessions"`
+	// LoadThreshold is the load from which the Load Control Information is sent, 0 never sends it.
+	LoadThreshold uint8 `json:"load_threshold"`
+	// OverloadThreshold is the load from which the Overload Control Information is sent, asking
+	// the SMF to reduce the new sessions. 0 never sends it.
+	OverloadThreshold uint8 `json:"overload_threshold"`
+	// OverloadValidity is the period of validity of the Overload Control Information.
+	OverloadValidity string `json:"overload_validity"`
+}
+
+// SessionHooksConf configures the sinks notified of the creation, modification and deletion of
+// the PFCP sessions, in addition to the load balancers.
+type SessionHooksConf struct {
+	// WebhookURL receives each event as a JSON POST request.
+	WebhookURL string `json:"webhook_url"`
+	// LogFile has each event appended as a line of JSON.
+	LogFile string `json:"log_file"`
+}
+
+// DrainConf configures the drain of the UPF before scale-in, see drainer.
+type DrainConf struct {
+	// ExportURLs are the URLs a drain can export the remaining sessions to. Exports are
+	// rejected if empty.
+	ExportURLs []string `json:"export_urls"`
+}
+
+// HTTPConf secures the HTTP API of the UPF, served in clear and without authentication by default.
+type HTTPConf struct {
+	TLS TLSConf `json:"tls"`
+	// Auth is the authentication of each group of routes: "config" for the configuration of the
+	// UPF, "lb" for /registergw and /v1/lb/, and "metrics" for /metrics.
+	Auth map[string]HTTPAuthConf `json:"auth"`
+}
+
+// TLSConf : certificates of the UPF, also presented to the load balancers.
+type TLSConf struct {
+	// CertFile and KeyFile are the PEM certificate and key of the UPF, TLS is disabled if empty.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// CAFile is the PEM bundle verifying the certificates of the clients with mutual TLS, and of
+	// the load balancers. The system roots verify the load balancers if empty.
+	CAFile string `json:"ca_file"`
+}
+
+// HTTPAuthConf : authentication of a group of routes of the HTTP API.
+type HTTPAuthConf struct {
+	// Mode is "none" (default), "mtls" for a client certificate verified by the CA, or "bearer"
+	// for one of the tokens of TokenFile.
+	Mode string `json:"mode"`
+	// TokenFile holds the accepted bearer tokens, one per line.
+	TokenFile string `json:"token_file"`
+}
+
 // P4rtcInfo : P4 runtime interface settings.
 type P4rtcInfo struct {
 	SliceID             uint8           `json:"slice_id"`
@@ -103,11 +219,21 @@ type P4rtcInfo struct {
 	QFIToTC             map[uint8]uint8 `json:"qfi_tc_mapping"`
 	DefaultTC           uint8           `json:"default_tc"`
 	ClearStateOnRestart bool            `json:"clear_state_on_restart"`
+	EnableHA            bool            `json:"enable_ha"`
+}
+
+// bessModes are the valid modes of the BESS datapath.
+var bessModes = map[string]struct{}{
+	"af_xdp":    {},
+	"af_packet": {},
+	"cndp":      {},
+	"dpdk":      {},
+	"sim":       {},
 }
 
 // validateConf checks that the given config reaches a baseline of correctness.
 func validateConf(conf Conf) error {
-	if conf.EnableP4rt {
+	if conf.EnableP4rt || conf.Mirror.Secondary == datapathUP4 {
 		_, _, err := net.ParseCIDR(conf.P4rtcIface.AccessIP)
 		if err != nil {
 			return ErrInvalidArgumentWithReason("conf.P4rtcIface.AccessIP", conf.P4rtcIface.AccessIP, err.Error())
@@ -117,24 +243,38 @@ func validateConf(conf Conf) error {
 		if err != nil {
 			return ErrInvalidArgumentWithReason("conf.UEIPPool", conf.CPIface.UEIPPool, err.Error())
 		}
+	}
 
-		if conf.Mode != "" {
+	if conf.EnableP4rt {
+		// mode is only used by a BESS secondary datapath
+		if conf.Mode != "" && conf.Mirror.Secondary != datapathBESS {
 			return ErrInvalidArgumentWithReason("conf.Mode", conf.Mode, "mode must not be set for UP4")
 		}
 	} else {
-		// Mode is only relevant in a BESS deployment.
-		validModes := map[string]struct{}{
-			"af_xdp":    {},
-			"af_packet": {},
-			"cndp":      {},
-			"dpdk":      {},
-			"sim":       {},
-		}
-		if _, ok := validModes[conf.Mode]; !ok {
+		// Mode selects the BESS mode, or the software or shadow datapath.
+		_, ok := bessModes[conf.Mode]
+		if !ok && conf.Mode != modeSoftware && conf.Mode != modeShadow {
 			return ErrInvalidArgumentWithReason("conf.Mode", conf.Mode, "invalid mode")
 		}
 	}
 
+	if !conf.EnableP4rt || conf.Mirror.Secondary == datapathBESS {
+		if conf.BessModuleWorkers == 0 {
+			return ErrInvalidArgumentWithReason("conf.BessModuleWorkers", conf.BessModuleWorkers, "invalid number of workers")
+		}
+
+		deadline, err := time.ParseDuration(conf.BessMsgDeadline)
+		if err != nil || deadline <= 0 {
+			return ErrInvalidArgumentWithReason("conf.BessMsgDeadline", conf.BessMsgDeadline, "invalid duration")
+		}
+	}
+
+	if conf.Mirror.Secondary != "" {
+		if err := validateMirrorConf(conf); err != nil {
+			return err
+		}
+	}
+
 	if conf.CPIface.EnableUeIPAlloc {
 		_, _, err := net.ParseCIDR(conf.CPIface.UEIPPool)
 		if err != nil {
@@ -142,6 +282,40 @@ func validateConf(conf Conf) error {
 		}
 	}
 
+	for _, lbURL := range []string{conf.LoadBalancers.EnterLBURL, conf.LoadBalancers.ExitLBURL, conf.LoadBalancers.PFCPLBURL} {
+		if lbURL == "" {
+			continue
+		}
+
+		if u, err := url.Parse(lbURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return ErrInvalidArgumentWithReason("conf.LoadBalancers", lbURL, "invalid URL")
+		}
+	}
+
+	if err := validateLBAPI(conf.LoadBalancers); err != nil {
+		return err
+	}
+
+	if _, err := parseSources(conf.LoadBalancers.AllowedSources); err != nil {
+		return err
+	}
+
+	if err := validateHTTPConf(conf.HTTP); err != nil {
+		return err
+	}
+
+	for _, exportURL := range conf.Drain.ExportURLs {
+		if u, err := url.Parse(exportURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return ErrInvalidArgumentWithReason("conf.Drain.ExportURLs", exportURL, "invalid URL")
+		}
+	}
+
+	if conf.SessionHooks.WebhookURL != "" {
+		if u, err := url.Parse(conf.SessionHooks.WebhookURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return ErrInvalidArgumentWithReason("conf.SessionHooks.WebhookURL", conf.SessionHooks.WebhookURL, "invalid URL")
+		}
+	}
+
 	for _, peer := range conf.CPIface.Peers {
 		ip := net.ParseIP(peer)
 		if ip == nil {
@@ -167,6 +341,135 @@ func validateConf(conf Conf) error {
 		}
 	}
 
+	if conf.LoadBalancers.KeepaliveInterval != "" {
+		interval, err := time.ParseDuration(conf.LoadBalancers.KeepaliveInterval)
+		if err != nil || interval < 0 {
+			return ErrInvalidArgumentWithReason("conf.LoadBalancers.KeepaliveInterval",
+				conf.LoadBalancers.KeepaliveInterval, "invalid duration")
+		}
+	}
+
+	if conf.LoadBalancers.LoadReportInterval != "" {
+		interval, err := time.ParseDuration(conf.LoadBalancers.LoadReportInterval)
+		if err != nil || interval < 0 {
+			return ErrInvalidArgumentWithReason("conf.LoadBalancers.LoadReportInterval",
+				conf.LoadBalancers.LoadReportInterval, "invalid duration")
+		}
+	}
+
+	if err := validateLoadControlConf(conf.LoadControl); err != nil {
+		return err
+	}
+
+	if conf.ReconcileInterval != "" {
+		interval, err := time.ParseDuration(conf.ReconcileInterval)
+		if err != nil || interval <= 0 {
+			return ErrInvalidArgumentWithReason("conf.ReconcileInterval", conf.ReconcileInterval, "invalid duration")
+		}
+	}
+
+	return nil
+}
+
+// validateMirrorConf checks that the secondary datapath can run next to the primary one.
+func validateMirrorConf(conf Conf) error {
+	secondary := conf.Mirror.Secondary
+
+	switch secondary {
+	case datapathUP4, modeShadow:
+	case datapathBESS:
+		// BESS only mirrors UP4, as its mode is otherwise used by the primary datapath
+		if _, ok := bessModes[conf.Mode]; !ok || !conf.EnableP4rt {
+			return ErrInvalidArgumentWithReason("conf.Mirror.Secondary", secondary,
+				"BESS can only mirror UP4, with mode set to a BESS mode")
+		}
+	default:
+		return ErrInvalidArgumentWithReason("conf.Mirror.Secondary", secondary, "invalid datapath")
+	}
+
+	if secondary == datapathName(conf) {
+		return ErrInvalidArgumentWithReason("conf.Mirror.Secondary", secondary, "same as the primary datapath")
+	}
+
+	return nil
+}
+
+// validateLoadControlConf checks that the thresholds are percentages.
+// validateLBAPI checks that the addresses of the gRPC API of the LBs are set when used.
+func validateLBAPI(conf LBConf) error {
+	switch conf.API {
+	case "", lbAPIHTTP:
+	case lbAPIGRPC:
+		for _, addr := range []string{conf.EnterLBGRPCAddr, conf.ExitLBGRPCAddr} {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return ErrInvalidArgumentWithReason("conf.LoadBalancers", addr, "invalid gRPC address")
+			}
+		}
+	default:
+		return ErrInvalidArgumentWithReason("conf.LoadBalancers.API", conf.API, "must be http or grpc")
+	}
+
+	if conf.GRPCListenAddr != "" {
+		if _, _, err := net.SplitHostPort(conf.GRPCListenAddr); err != nil {
+			return ErrInvalidArgumentWithReason("conf.LoadBalancers.GRPCListenAddr", conf.GRPCListenAddr, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validateHTTPConf checks that TLS is fully configured, and enabled for mutual TLS.
+func validateHTTPConf(conf HTTPConf) error {
+	tlsConf := conf.TLS
+	if (tlsConf.CertFile == "") != (tlsConf.KeyFile == "") {
+		return ErrInvalidArgumentWithReason("conf.HTTP.TLS", tlsConf.CertFile, "cert_file and key_file must be set together")
+	}
+
+	for group, auth := range conf.Auth {
+		if _, ok := httpRouteGroups[group]; !ok {
+			return ErrInvalidArgumentWithReason("conf.HTTP.Auth", group, "unknown route group")
+		}
+
+		switch auth.Mode {
+		case "", httpAuthNone:
+		case httpAuthMTLS:
+			if tlsConf.CertFile == "" || tlsConf.CAFile == "" {
+				return ErrInvalidArgumentWithReason("conf.HTTP.Auth."+group, auth.Mode, "needs cert_file, key_file and ca_file")
+			}
+		case httpAuthBearer:
+			if auth.TokenFile == "" {
+				return ErrInvalidArgumentWithReason("conf.HTTP.Auth."+group, auth.Mode, "needs token_file")
+			}
+
+			if tlsConf.CertFile == "" {
+				return ErrInvalidArgumentWithReason("conf.HTTP.Auth."+group, auth.Mode,
+					"needs cert_file and key_file, the tokens would be sent in clear")
+			}
+		default:
+			return ErrInvalidArgumentWithReason("conf.HTTP.Auth."+group, auth.Mode, "must be none, mtls or bearer")
+		}
+	}
+
+	return nil
+}
+
+func validateLoadControlConf(conf LoadControlConf) error {
+	for name, threshold := range map[string]uint8{
+		"conf.LoadControl.LoadThreshold":     conf.LoadThreshold,
+		"conf.LoadControl.OverloadThreshold": conf.OverloadThreshold,
+	} {
+		if threshold > 100 {
+			return ErrInvalidArgumentWithReason(name, threshold, "not a percentage")
+		}
+	}
+
+	if conf.OverloadValidity != "" {
+		validity, err := time.ParseDuration(conf.OverloadValidity)
+		if err != nil || validity <= 0 {
+			return ErrInvalidArgumentWithReason("conf.LoadControl.OverloadValidity", conf.OverloadValidity, "invalid duration")
+		}
+	}
+
 	return nil
 }
 
@@ -207,6 +510,14 @@ func LoadConfigFile(filepath string) (Conf, error) {
 		conf.MaxReqRetries = maxReqRetriesDefault
 	}
 
+	if conf.BessModuleWorkers == 0 {
+		conf.BessModuleWorkers = bessModuleWorkersDefault
+	}
+
+	if conf.BessMsgDeadline == "" {
+		conf.BessMsgDeadline = bessMsgDeadlineDefault.String()
+	}
+
 	if conf.EnableHBTimer {
 		if conf.HeartBeatInterval == "" {
 			conf.HeartBeatInterval = hbIntervalDefault.String()
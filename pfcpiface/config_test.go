@@ -81,6 +81,232 @@ func TestLoadConfigFile(t *testing.T) {
 		require.Equal(t, conf.LogLevel, log.InfoLevel)
 	})
 
+	t.Run("software mode is valid", func(t *testing.T) {
+		s := `{
+			"mode": "software",
+			"access": {
+				"ifname": "access"
+			},
+			"core": {
+				"ifname": "core"
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.NoError(t, err)
+	})
+
+	t.Run("mirroring to UP4 is valid", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"mirror": {
+				"secondary": "up4"
+			},
+			"cpiface": {
+				"ue_ip_pool": "10.250.0.0/16"
+			},
+			"p4rtciface": {
+				"access_ip": "198.18.0.1/32"
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.NoError(t, err)
+	})
+
+	t.Run("mirroring to the primary datapath is invalid", func(t *testing.T) {
+		s := `{
+			"enable_p4rt": true,
+			"mirror": {
+				"secondary": "up4"
+			},
+			"cpiface": {
+				"ue_ip_pool": "10.250.0.0/16"
+			},
+			"p4rtciface": {
+				"access_ip": "198.18.0.1/32"
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("load balancer URLs must be absolute", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"load_balancers": {
+				"enter_lb_url": "http://127.0.0.1:8081",
+				"exit_lb_url": "exitlb:8080"
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("load balancer keepalive interval must be a duration", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"load_balancers": {
+				"keepalive_interval": "30"
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("gRPC API of the load balancers needs their addresses", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"load_balancers": {
+				"api": "grpc",
+				"enter_lb_grpc_addr": "enterlb:9090"
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("load balancer sources must be addresses or networks", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"load_balancers": {
+				"allowed_sources": ["192.168.252.0/24", "enterlb"]
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("session hooks webhook must be a URL", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"session_hooks": {
+				"webhook_url": "collector:9000"
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("load control thresholds must be percentages", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"load_control": {
+				"load_threshold": 50,
+				"overload_threshold": 120
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("drain export URLs must be URLs", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"drain": {
+				"export_urls": ["orchestrator:8080/sessions"]
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("http mutual TLS needs a CA", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"http": {
+				"tls": {
+					"cert_file": "/etc/upf/tls.crt",
+					"key_file": "/etc/upf/tls.key"
+				},
+				"auth": {
+					"config": {"mode": "mtls"}
+				}
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("http bearer auth needs a token file", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"http": {
+				"auth": {
+					"lb": {"mode": "bearer"}
+				}
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("http bearer auth needs TLS", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"http": {
+				"auth": {
+					"lb": {"mode": "bearer", "token_file": "/etc/upf/lb-tokens"}
+				}
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
+	t.Run("http auth of an unknown route group is invalid", func(t *testing.T) {
+		s := `{
+			"mode": "dpdk",
+			"http": {
+				"auth": {
+					"admin": {"mode": "none"}
+				}
+			}
+		}`
+		confPath := t.TempDir() + "/conf.json"
+		mustWriteStringToDisk(s, confPath)
+
+		_, err := LoadConfigFile(confPath)
+		require.Error(t, err)
+	})
+
 	t.Run("all sample configs must be valid", func(t *testing.T) {
 		paths := []string{
 			"../conf/upf.json",
@@ -42,6 +42,24 @@ type nodeID struct {
 	remote  string
 }
 
+// cpFeatures are the features advertised by the CP function in the association setup.
+type cpFeatures struct {
+	// load and overload: the CP function supports the Load and Overload Control Information
+	load     bool
+	overload bool
+}
+
+func newCPFeatures(features *ie.IE) cpFeatures {
+	if features == nil {
+		return cpFeatures{}
+	}
+
+	return cpFeatures{
+		load:     features.HasLOAD(),
+		overload: features.HasOVRL(),
+	}
+}
+
 // PFCPConn represents a PFCP connection with a unique PFCP peer.
 type PFCPConn struct {
 	ctx context.Context
@@ -58,12 +76,13 @@ type PFCPConn struct {
 	nodeID nodeID
 	upf    *upf
 	// channel to signal PFCPNode on exit
-	done             chan<- string
-	shutdown         chan struct{}
-	gwIp             string
-	sentIpsToRouters map[uint32]struct{}
+	done     chan<- string
+	shutdown chan struct{}
 	metrics.InstrumentPFCP
 
+	// cpFeatures are the features of the CP function relevant to the UPF
+	cpFeatures cpFeatures
+
 	hbReset     chan struct{}
 	hbCtxCancel context.CancelFunc
 
@@ -126,20 +145,18 @@ func (node *PFCPNode) NewPFCPConn(lAddr, rAddr string, buf []byte) *PFCPConn {
 	rng := rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec G404
 
 	var p = &PFCPConn{
-		ctx:              node.ctx,
-		Conn:             conn,
-		ts:               ts,
-		rng:              rng,
-		maxRetries:       100,
-		store:            NewInMemoryStore(),
-		upf:              node.upf,
-		done:             node.pConnDone,
-		shutdown:         make(chan struct{}),
-		InstrumentPFCP:   node.metrics,
-		hbReset:          make(chan struct{}, 100),
-		hbCtxCancel:      nil,
-		gwIp:             node.gwIP,
-		sentIpsToRouters: make(map[uint32]struct{}),
+		ctx:            node.ctx,
+		Conn:           conn,
+		ts:             ts,
+		rng:            rng,
+		maxRetries:     100,
+		store:          NewInMemoryStore(),
+		upf:            node.upf,
+		done:           node.pConnDone,
+		shutdown:       make(chan struct{}),
+		InstrumentPFCP: node.metrics,
+		hbReset:        make(chan struct{}, 100),
+		hbCtxCancel:    nil,
 	}
 
 	p.setLocalNodeID(node.upf.NodeID)
@@ -245,8 +262,17 @@ func (pConn *PFCPConn) Shutdown() {
 
 	// Cleanup all sessions in this conn
 	for _, sess := range pConn.store.GetAllSessions() {
-		pConn.upf.SendMsgToUPF(upfMsgTypeDel, sess.PacketForwardingRules, PacketForwardingRules{})
+		tx := pConn.upf.BeginRules(context.Background(), PacketForwardingRules{})
+		deleteRules(tx, sess.PacketForwardingRules)
+
+		pConn.upf.rulesMu.RLock()
+
+		if err := tx.Commit(); err != nil {
+			log.Errorf("Failed to delete session %v from datapath: %v", sess.localSEID, err)
+		}
+
 		pConn.RemoveSession(sess)
+		pConn.upf.rulesMu.RUnlock()
 	}
 
 	rAddr := pConn.RemoteAddr().String()
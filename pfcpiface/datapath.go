@@ -4,9 +4,19 @@
 package pfcpiface
 
 import (
+	"context"
+	"errors"
 	"net"
+	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Names of the datapaths not selected by a mode.
+const (
+	datapathBESS = "bess"
+	datapathUP4  = "up4"
 )
 
 type upfMsgType int
@@ -42,13 +52,77 @@ type datapath interface {
 	/* write endMarker to datapath */
 	SendEndMarkers(endMarkerList *[][]byte) error
 	/* write pdr/far/qer to datapath */
-	// "master" function to send create/update/delete messages to UPF.
-	// "new" PacketForwardingRules are only used for update messages to UPF.
-	// TODO: we should have better CRUD API, with a single function per message type.
-	SendMsgToUPF(method upfMsgType, all PacketForwardingRules, new PacketForwardingRules) uint8
+	// BeginRules starts a set of changes to the rules of a PFCP session, written to the
+	// datapath by Commit within the deadline of ctx. all are the rules of the session once
+	// the changes are applied.
+	BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx
 	/* check of communication channel to datapath is setup */
 	IsConnected(AccessIP *net.IP) bool
 	SummaryLatencyJitter(uc *upfCollector, ch chan<- prometheus.Metric)
 	PortStats(uc *upfCollector, ch chan<- prometheus.Metric)
 	SessionStats(pc *PfcpNodeCollector, ch chan<- prometheus.Metric) error
 }
+
+// rulesTx is a set of changes to the rules of a PFCP session.
+// TODO: add URRs once supported by the datapaths.
+type rulesTx interface {
+	CreatePDR(p pdr)
+	UpdatePDR(p pdr)
+	DeletePDR(p pdr)
+	CreateFAR(f far)
+	UpdateFAR(f far)
+	DeleteFAR(f far)
+	CreateQER(q qer)
+	UpdateQER(q qer)
+	DeleteQER(q qer)
+	// Commit writes all changes to the datapath, atomically if supported by the datapath.
+	// The returned error can be converted to a PFCP cause with datapathErrorCause.
+	Commit() error
+}
+
+// datapathErrorCause returns the PFCP cause to reply with for an error returned by the datapath.
+func datapathErrorCause(err error) uint8 {
+	switch {
+	case err == nil:
+		return ie.CauseRequestAccepted
+	case errors.Is(err, errInvalidArgument), errors.Is(err, errNotFound), errors.Is(err, errUnsupported):
+		return ie.CauseRuleCreationModificationFailure
+	case errors.Is(err, errNoResources):
+		return ie.CauseNoResourcesAvailable
+	case errors.Is(err, errDatapathUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return ie.CauseSystemFailure
+	default:
+		return ie.CauseRequestRejected
+	}
+}
+
+// httpServingDatapath is implemented by datapaths exposing their own HTTP API.
+type httpServingDatapath interface {
+	setupHandlers(mux *http.ServeMux)
+}
+
+// datapathName returns the name of the (primary) datapath selected by conf.
+func datapathName(conf Conf) string {
+	switch {
+	case conf.EnableP4rt:
+		return datapathUP4
+	case conf.Mode == modeSoftware, conf.Mode == modeShadow:
+		return conf.Mode
+	default:
+		return datapathBESS
+	}
+}
+
+// newDatapath returns an uninitialized datapath of the given name.
+func newDatapath(name string) datapath {
+	switch name {
+	case datapathUP4:
+		return &UP4{}
+	case modeSoftware:
+		return &software{}
+	case modeShadow:
+		return &shadow{}
+	default:
+		return &bess{}
+	}
+}
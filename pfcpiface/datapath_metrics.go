@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// datapathMetrics holds metrics describing the health of the datapath control channel,
+// as opposed to the traffic metrics exported by the datapath itself.
+type datapathMetrics struct {
+	connected       *prometheus.GaugeVec
+	reconnects      *prometheus.CounterVec
+	replayDuration  *prometheus.HistogramVec
+	replayFailures  *prometheus.CounterVec
+	replayedEntries *prometheus.CounterVec
+
+	reconcileRuns       *prometheus.CounterVec
+	driftEntries        *prometheus.CounterVec
+	driftRepairFailures *prometheus.CounterVec
+
+	mirrorPrimary     *prometheus.GaugeVec
+	mirrorDivergences *prometheus.CounterVec
+
+	up4Primary   prometheus.Gauge
+	up4Takeovers prometheus.Counter
+
+	up4IDPoolSize           *prometheus.GaugeVec
+	up4IDPoolFree           *prometheus.GaugeVec
+	up4IDPoolExhausted      *prometheus.CounterVec
+	up4TunnelPeersReclaimed prometheus.Counter
+}
+
+var (
+	dpMetrics     *datapathMetrics
+	dpMetricsOnce sync.Once
+)
+
+// getDatapathMetrics returns the process-wide datapath metrics, registering them on first use.
+func getDatapathMetrics() *datapathMetrics {
+	dpMetricsOnce.Do(func() {
+		dpMetrics = &datapathMetrics{
+			connected: mustRegisterOrExisting(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "upf_datapath_connected",
+				Help: "Shows whether the control channel to the datapath is up (1) or down (0)",
+			}, []string{"datapath"})).(*prometheus.GaugeVec),
+			reconnects: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_reconnects_total",
+				Help: "Number of times the control channel to the datapath was re-established",
+			}, []string{"datapath"})).(*prometheus.CounterVec),
+			replayDuration: mustRegisterOrExisting(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "upf_datapath_replay_duration_seconds",
+				Help:    "Time spent replaying the forwarding state to the datapath after a reconnection",
+				Buckets: []float64{1e-2, 1e-1, 1, 5, 10, 30, 60, 300},
+			}, []string{"datapath"})).(*prometheus.HistogramVec),
+			replayFailures: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_replay_failures_total",
+				Help: "Number of sessions or slice meters that failed to be replayed to the datapath",
+			}, []string{"datapath", "kind"})).(*prometheus.CounterVec),
+			replayedEntries: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_replayed_sessions_total",
+				Help: "Number of sessions replayed to the datapath after a reconnection",
+			}, []string{"datapath"})).(*prometheus.CounterVec),
+			reconcileRuns: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_reconcile_runs_total",
+				Help: "Number of datapath reconciliation runs",
+			}, []string{"datapath", "result"})).(*prometheus.CounterVec),
+			driftEntries: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_drift_entries_total",
+				Help: "Number of datapath entries found missing, extra or different during reconciliation",
+			}, []string{"datapath", "kind"})).(*prometheus.CounterVec),
+			driftRepairFailures: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_drift_repair_failures_total",
+				Help: "Number of drifted datapath entries that could not be repaired",
+			}, []string{"datapath"})).(*prometheus.CounterVec),
+			mirrorPrimary: mustRegisterOrExisting(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "upf_datapath_mirror_primary",
+				Help: "Shows whether a mirrored datapath is the primary (1) or the secondary (0)",
+			}, []string{"datapath"})).(*prometheus.GaugeVec),
+			mirrorDivergences: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_mirror_divergences_total",
+				Help: "Number of operations for which the secondary datapath returned a different result than the primary",
+			}, []string{"operation"})).(*prometheus.CounterVec),
+			up4Primary: mustRegisterOrExisting(prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "upf_datapath_up4_primary",
+				Help: "Shows whether pfcpiface is the P4Runtime primary (1) or a standby (0) of UP4",
+			})).(prometheus.Gauge),
+			up4Takeovers: mustRegisterOrExisting(prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "upf_datapath_up4_takeovers_total",
+				Help: "Number of times pfcpiface became the P4Runtime primary of UP4 and restored its state from the switch",
+			})).(prometheus.Counter),
+			up4IDPoolSize: mustRegisterOrExisting(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "upf_datapath_up4_id_pool_size",
+				Help: "Number of IDs of a UP4 ID pool, derived from the p4info",
+			}, []string{"pool"})).(*prometheus.GaugeVec),
+			up4IDPoolFree: mustRegisterOrExisting(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "upf_datapath_up4_id_pool_free",
+				Help: "Number of free IDs of a UP4 ID pool",
+			}, []string{"pool"})).(*prometheus.GaugeVec),
+			up4IDPoolExhausted: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_datapath_up4_id_pool_exhausted_total",
+				Help: "Number of ID allocations that failed because a UP4 ID pool was exhausted",
+			}, []string{"pool"})).(*prometheus.CounterVec),
+			up4TunnelPeersReclaimed: mustRegisterOrExisting(prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "upf_datapath_up4_tunnel_peers_reclaimed_total",
+				Help: "Number of idle tunnel peers whose ID was reclaimed for a new tunnel peer",
+			})).(prometheus.Counter),
+		}
+	})
+
+	return dpMetrics
+}
+
+// mustRegisterOrExisting registers c or returns the already registered equivalent collector.
+func mustRegisterOrExisting(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector
+		}
+
+		log.Fatalln("failed to register datapath metrics:", err)
+	}
+
+	return c
+}
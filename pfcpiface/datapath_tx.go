@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+)
+
+// ruleChanges are the changes to the rules of a PFCP session, by operation.
+type ruleChanges struct {
+	created PacketForwardingRules
+	updated PacketForwardingRules
+	deleted PacketForwardingRules
+}
+
+// upserted returns the created and updated rules, for datapaths treating them alike.
+func (c ruleChanges) upserted() PacketForwardingRules {
+	return PacketForwardingRules{
+		pdrs: append(append([]pdr{}, c.created.pdrs...), c.updated.pdrs...),
+		fars: append(append([]far{}, c.created.fars...), c.updated.fars...),
+		qers: append(append([]qer{}, c.created.qers...), c.updated.qers...),
+	}
+}
+
+// isEmpty returns true if there are no changes.
+func (c ruleChanges) isEmpty() bool {
+	return c.created.IsEmpty() && c.updated.IsEmpty() && c.deleted.IsEmpty()
+}
+
+// replay adds the changes to tx.
+func (c ruleChanges) replay(tx rulesTx) {
+	createRules(tx, c.created)
+	updateRules(tx, c.updated)
+	deleteRules(tx, c.deleted)
+}
+
+// rulesCommitFunc writes the changes to the rules of a PFCP session to a datapath.
+type rulesCommitFunc func(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error
+
+// changesTx is a rulesTx collecting all changes, for datapaths writing them at once on Commit.
+type changesTx struct {
+	ctx     context.Context
+	all     PacketForwardingRules
+	changes ruleChanges
+	commit  rulesCommitFunc
+	done    bool
+}
+
+func newChangesTx(ctx context.Context, all PacketForwardingRules, commit rulesCommitFunc) *changesTx {
+	return &changesTx{ctx: ctx, all: all, commit: commit}
+}
+
+func (tx *changesTx) CreatePDR(p pdr) { tx.changes.created.pdrs = append(tx.changes.created.pdrs, p) }
+func (tx *changesTx) UpdatePDR(p pdr) { tx.changes.updated.pdrs = append(tx.changes.updated.pdrs, p) }
+func (tx *changesTx) DeletePDR(p pdr) { tx.changes.deleted.pdrs = append(tx.changes.deleted.pdrs, p) }
+func (tx *changesTx) CreateFAR(f far) { tx.changes.created.fars = append(tx.changes.created.fars, f) }
+func (tx *changesTx) UpdateFAR(f far) { tx.changes.updated.fars = append(tx.changes.updated.fars, f) }
+func (tx *changesTx) DeleteFAR(f far) { tx.changes.deleted.fars = append(tx.changes.deleted.fars, f) }
+func (tx *changesTx) CreateQER(q qer) { tx.changes.created.qers = append(tx.changes.created.qers, q) }
+func (tx *changesTx) UpdateQER(q qer) { tx.changes.updated.qers = append(tx.changes.updated.qers, q) }
+func (tx *changesTx) DeleteQER(q qer) { tx.changes.deleted.qers = append(tx.changes.deleted.qers, q) }
+
+func (tx *changesTx) Commit() error {
+	if tx.done {
+		return ErrInvalidOperation("commit of already committed rules")
+	}
+
+	tx.done = true
+
+	if tx.changes.isEmpty() {
+		return nil
+	}
+
+	if err := tx.ctx.Err(); err != nil {
+		return err
+	}
+
+	return tx.commit(tx.ctx, tx.all, tx.changes)
+}
+
+// createRules adds the creation of all rules to tx.
+func createRules(tx rulesTx, rules PacketForwardingRules) {
+	for _, p := range rules.pdrs {
+		tx.CreatePDR(p)
+	}
+
+	for _, f := range rules.fars {
+		tx.CreateFAR(f)
+	}
+
+	for _, q := range rules.qers {
+		tx.CreateQER(q)
+	}
+}
+
+// updateRules adds the update of all rules to tx.
+func updateRules(tx rulesTx, rules PacketForwardingRules) {
+	for _, p := range rules.pdrs {
+		tx.UpdatePDR(p)
+	}
+
+	for _, f := range rules.fars {
+		tx.UpdateFAR(f)
+	}
+
+	for _, q := range rules.qers {
+		tx.UpdateQER(q)
+	}
+}
+
+// deleteRules adds the deletion of all rules to tx.
+func deleteRules(tx rulesTx, rules PacketForwardingRules) {
+	for _, p := range rules.pdrs {
+		tx.DeletePDR(p)
+	}
+
+	for _, f := range rules.fars {
+		tx.DeleteFAR(f)
+	}
+
+	for _, q := range rules.qers {
+		tx.DeleteQER(q)
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func Test_changesTx(t *testing.T) {
+	var (
+		committed ruleChanges
+		commits   int
+	)
+
+	commit := func(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error {
+		committed = changes
+		commits++
+
+		return nil
+	}
+
+	rules := newSWTestRules()
+
+	tx := newChangesTx(context.Background(), rules, commit)
+	tx.CreatePDR(rules.pdrs[0])
+	tx.UpdatePDR(rules.pdrs[1])
+	tx.CreateFAR(rules.fars[0])
+	tx.DeleteFAR(rules.fars[1])
+	tx.UpdateQER(rules.qers[0])
+
+	require.NoError(t, tx.Commit())
+	require.Equal(t, 1, commits)
+	require.Equal(t, []pdr{rules.pdrs[0]}, committed.created.pdrs)
+	require.Equal(t, []pdr{rules.pdrs[1]}, committed.updated.pdrs)
+	require.Equal(t, []far{rules.fars[0]}, committed.created.fars)
+	require.Equal(t, []far{rules.fars[1]}, committed.deleted.fars)
+	require.Equal(t, []qer{rules.qers[0]}, committed.updated.qers)
+	require.Len(t, committed.upserted().pdrs, 2)
+
+	require.Error(t, tx.Commit(), "rules must not be committed twice")
+	require.Equal(t, 1, commits)
+
+	// no changes, nothing to commit
+	require.NoError(t, newChangesTx(context.Background(), rules, commit).Commit())
+	require.Equal(t, 1, commits)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tx = newChangesTx(ctx, rules, commit)
+	createRules(tx, rules)
+	require.ErrorIs(t, tx.Commit(), context.Canceled)
+	require.Equal(t, 1, commits)
+}
+
+func Test_datapathErrorCause(t *testing.T) {
+	tests := []struct {
+		err  error
+		want uint8
+	}{
+		{nil, ie.CauseRequestAccepted},
+		{ErrInvalidArgument("pdr", 1), ie.CauseRuleCreationModificationFailure},
+		{ErrNotFoundWithParam("FAR", "farID", 1), ie.CauseRuleCreationModificationFailure},
+		{ErrDatapathUnavailable(datapathUP4), ie.CauseSystemFailure},
+		{fmt.Errorf("Counter ID allocation: %w", ErrNoResourcesAvailable("Counter IDs")), ie.CauseNoResourcesAvailable},
+		{fmt.Errorf("write: %w", context.DeadlineExceeded), ie.CauseSystemFailure},
+		{ErrOperationFailedWithReason("write", "boom"), ie.CauseRequestRejected},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, datapathErrorCause(tt.err), "error: %v", tt.err)
+	}
+}
+
+// deadlineRecordingDatapath records whether the rules are written with a deadline.
+type deadlineRecordingDatapath struct {
+	*mirrorTestDatapath
+	deadlines []bool
+}
+
+func (d *deadlineRecordingDatapath) BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx {
+	_, ok := ctx.Deadline()
+	d.deadlines = append(d.deadlines, ok)
+
+	return d.mirrorTestDatapath.BeginRules(ctx, all)
+}
+
+func Test_handleSessionDeletionRequest_datapathFailure(t *testing.T) {
+	u := &upf{}
+	pConn := newLBTestPFCPConn(t, u)
+
+	dp := &deadlineRecordingDatapath{mirrorTestDatapath: newMirrorTestDatapath(nil)}
+	u.datapath = dp
+
+	_, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, 0))
+	require.NoError(t, err)
+
+	dp.err = ErrDatapathUnavailable(datapathBESS)
+
+	reply, err := pConn.handleSessionDeletionRequest(message.NewSessionDeletionRequest(0, 0, 1, 2, 0))
+	require.Error(t, err)
+
+	cause, err := reply.(*message.SessionDeletionResponse).Cause.Cause()
+	require.NoError(t, err)
+	require.Equal(t, ie.CauseSystemFailure, cause)
+
+	require.Equal(t, []bool{true, true}, dp.deadlines, "the rules must be written with a deadline")
+}
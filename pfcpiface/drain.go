@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// drainTimeoutDefault bounds the wait for the sessions to be removed, before stopping the UPF.
+	drainTimeoutDefault = 10 * time.Minute
+	// drainPollInterval is the interval between two counts of the remaining sessions.
+	drainPollInterval = time.Second
+)
+
+// ErrDraining is returned when a session is established while the UPF is drained.
+var ErrDraining = errors.New("UPF is draining")
+
+// drainRequest is the optional body of the requests to drain the UPF.
+type drainRequest struct {
+	// Timeout bounds the wait for the sessions to be removed, e.g. "5m".
+	Timeout string `json:"timeout"`
+	// ExportURL receives the remaining sessions with a POST, e.g. for an orchestrator to hand
+	// them to a target UPF. It must be one of DrainConf.ExportURLs.
+	ExportURL string `json:"export_url"`
+}
+
+// drainStatus is the drain state returned by the HTTP API.
+type drainStatus struct {
+	Draining bool   `json:"draining"`
+	Sessions int    `json:"sessions"`
+	Deadline string `json:"deadline,omitempty"`
+}
+
+// exportedSession is a session sent to the export URL of a drain.
+type exportedSession struct {
+	LocalSEID   uint64   `json:"local_seid"`
+	RemoteSEID  uint64   `json:"remote_seid"`
+	UEAddresses []string `json:"ue_addresses"`
+}
+
+// drainer empties the UPF before scale-in: once started, new sessions are rejected and the
+// UPF deregisters from the PFCP-LB, while the existing sessions are served until removed by
+// the SMF. The UPF is stopped once no session remains, or on the deadline.
+type drainer struct {
+	upf          *upf
+	registrar    *lbRegistrar
+	stop         func()
+	pollInterval time.Duration
+	client       http.Client
+	// exportURLs are the URLs the sessions can be exported to, as they leave the UPF
+	exportURLs map[string]struct{}
+
+	// mu guards deadline, zero until the drain starts
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// newDrainer creates a drainer calling stop once upf is drained.
+func newDrainer(upf *upf, registrar *lbRegistrar, conf DrainConf, stop func()) *drainer {
+	d := &drainer{
+		upf:          upf,
+		registrar:    registrar,
+		stop:         stop,
+		pollInterval: drainPollInterval,
+		client:       http.Client{Timeout: lbRequestTimeout},
+		exportURLs:   make(map[string]struct{}),
+	}
+
+	for _, u := range conf.ExportURLs {
+		d.exportURLs[u] = struct{}{}
+	}
+
+	return d
+}
+
+// start drains the UPF in the background. It returns false if the drain is already started.
+func (d *drainer) start(timeout time.Duration, exportURL string) bool {
+	d.mu.Lock()
+
+	if !d.deadline.IsZero() {
+		d.mu.Unlock()
+		return false
+	}
+
+	d.deadline = time.Now().Add(timeout)
+	deadline := d.deadline
+
+	d.mu.Unlock()
+
+	log.Infof("Draining the UPF, stopping at the latest at %v", deadline.Format(time.RFC3339))
+
+	d.upf.setDraining()
+	d.registrar.drain()
+
+	go d.run(deadline, exportURL)
+
+	return true
+}
+
+func (d *drainer) run(deadline time.Time, exportURL string) {
+	if exportURL != "" {
+		if err := d.export(exportURL); err != nil {
+			log.Warnln("Failed to export the sessions:", err)
+		}
+	}
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		remaining := len(d.upf.allSessions())
+		if remaining == 0 {
+			log.Infoln("UPF drained, stopping")
+			break
+		}
+
+		if time.Now().After(deadline) {
+			log.Warnf("Drain deadline reached with %d sessions left, stopping", remaining)
+			break
+		}
+
+		<-ticker.C
+	}
+
+	d.stop()
+}
+
+// export posts the sessions of the UPF to url.
+func (d *drainer) export(url string) error {
+	var sessions []exportedSession
+
+	for _, s := range d.upf.allSessions() {
+		exported := exportedSession{
+			LocalSEID:   s.localSEID,
+			RemoteSEID:  s.remoteSEID,
+			UEAddresses: []string{},
+		}
+
+		for _, p := range s.pdrs {
+			if p.ueAddress != 0 {
+				exported.UEAddresses = append(exported.UEAddresses, int2ip(p.ueAddress).String())
+			}
+		}
+
+		sessions = append(sessions, exported)
+	}
+
+	body, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s replied %s", url, resp.Status)
+	}
+
+	log.Infof("Exported %d sessions to %s", len(sessions), url)
+
+	return nil
+}
+
+func (d *drainer) status() drainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := drainStatus{
+		Draining: !d.deadline.IsZero(),
+		Sessions: len(d.upf.allSessions()),
+	}
+
+	if status.Draining {
+		status.Deadline = d.deadline.Format(time.RFC3339)
+	}
+
+	return status
+}
+
+// ServeHTTP starts the drain on POST, and returns its status on GET.
+func (d *drainer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var drainReq drainRequest
+		if err := json.NewDecoder(req.Body).Decode(&drainReq); err != nil && !errors.Is(err, io.EOF) {
+			log.Errorln("Failed to decode the drain request:", err)
+			sendHTTPResp(http.StatusBadRequest, w)
+
+			return
+		}
+
+		timeout := drainTimeoutDefault
+
+		if drainReq.Timeout != "" {
+			var err error
+
+			timeout, err = time.ParseDuration(drainReq.Timeout)
+			if err != nil || timeout < 0 {
+				log.Errorln("Invalid drain timeout:", drainReq.Timeout)
+				sendHTTPResp(http.StatusBadRequest, w)
+
+				return
+			}
+		}
+
+		// the sessions are only sent to the configured URLs, not to any host given by the client
+		if _, ok := d.exportURLs[drainReq.ExportURL]; drainReq.ExportURL != "" && !ok {
+			err := ErrForbidden("export_url", drainReq.ExportURL)
+			log.Errorln("Invalid drain request:", err)
+			sendHTTPError(w, err)
+
+			return
+		}
+
+		if !d.start(timeout, drainReq.ExportURL) {
+			sendHTTPResp(http.StatusConflict, w)
+			return
+		}
+	default:
+		sendHTTPResp(http.StatusMethodNotAllowed, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(d.status()); err != nil {
+		log.Errorln("Failed to encode the drain status:", err)
+	}
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// newTestDrainer returns a drainer polling every 10ms, and a channel closed once it stops the UPF.
+func newTestDrainer(t *testing.T, exportURLs ...string) (*drainer, chan struct{}) {
+	r, _ := newTestLBRegistrar(t, 0)
+	stopped := make(chan struct{})
+
+	d := newDrainer(r.upf, r, DrainConf{ExportURLs: exportURLs}, func() { close(stopped) })
+	d.pollInterval = 10 * time.Millisecond
+
+	return d, stopped
+}
+
+func postDrain(d *drainer, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/drain", strings.NewReader(body)))
+
+	return rec
+}
+
+func Test_drainer_stopsOnceDrained(t *testing.T) {
+	d, stopped := newTestDrainer(t)
+
+	var (
+		mu       sync.Mutex
+		sessions = []PFCPSession{{localSEID: 1}}
+	)
+
+	d.upf.setSessionsSource(func() []PFCPSession {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return sessions
+	})
+
+	rec := postDrain(d, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var status drainStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	require.True(t, status.Draining)
+	require.Equal(t, 1, status.Sessions)
+	require.True(t, d.upf.isDraining())
+	require.True(t, d.registrar.isDraining())
+
+	// a single drain at a time
+	require.Equal(t, http.StatusConflict, postDrain(d, "").Code)
+
+	select {
+	case <-stopped:
+		t.Fatal("stopped with sessions left")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mu.Lock()
+	sessions = nil
+	mu.Unlock()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not stopped once drained")
+	}
+}
+
+func Test_drainer_deadline(t *testing.T) {
+	d, stopped := newTestDrainer(t)
+	d.upf.setSessionsSource(func() []PFCPSession { return []PFCPSession{{localSEID: 1}} })
+
+	require.Equal(t, http.StatusOK, postDrain(d, `{"timeout": "50ms"}`).Code)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not stopped on the deadline")
+	}
+}
+
+func Test_drainer_export(t *testing.T) {
+	exported := make(chan []exportedSession, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sessions []exportedSession
+		if err := json.NewDecoder(r.Body).Decode(&sessions); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		exported <- sessions
+	}))
+	defer target.Close()
+
+	d, stopped := newTestDrainer(t, target.URL)
+	setTestUEs(d.registrar, lbTestUEAddress)
+
+	require.Equal(t, http.StatusOK, postDrain(d, `{"timeout": "0s", "export_url": "`+target.URL+`"}`).Code)
+
+	select {
+	case sessions := <-exported:
+		require.Equal(t, []exportedSession{{LocalSEID: 1, UEAddresses: []string{lbTestUEAddress}}}, sessions)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sessions not exported")
+	}
+
+	<-stopped
+}
+
+func Test_drainer_ServeHTTP(t *testing.T) {
+	d, _ := newTestDrainer(t)
+
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/drain", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"draining": false, "sessions": 0}`, rec.Body.String())
+
+	require.Equal(t, http.StatusBadRequest, postDrain(d, `{"timeout": "soon"}`).Code)
+	require.Equal(t, http.StatusBadRequest, postDrain(d, `{"timeout": "-1m"}`).Code)
+	require.Equal(t, http.StatusBadRequest, postDrain(d, `{`).Code)
+	require.False(t, d.upf.isDraining())
+
+	// the sessions are only exported to the configured URLs
+	rec = postDrain(d, `{"export_url": "http://169.254.169.254/latest"}`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "export_url")
+	require.False(t, d.upf.isDraining())
+
+	rec = httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/drain", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func Test_handleSessionEstablishmentRequest_draining(t *testing.T) {
+	u := &upf{}
+	pConn := newLBTestPFCPConn(t, u)
+
+	u.setDraining()
+
+	resp, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, 0))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrDraining.Error())
+
+	seres, ok := resp.(*message.SessionEstablishmentResponse)
+	require.True(t, ok)
+
+	cause, err := seres.Cause.Cause()
+	require.NoError(t, err)
+	require.Equal(t, ie.CauseNoResourcesAvailable, cause)
+	require.Empty(t, pConn.store.GetAllSessions())
+}
@@ -13,12 +13,25 @@ var (
 	errInvalidOperation = errors.New("invalid operation")
 	errFailed           = errors.New("failed")
 	errUnsupported      = errors.New("unsupported")
+	errForbidden        = errors.New("forbidden")
+	errUnauthenticated  = errors.New("unauthenticated")
+
+	errDatapathUnavailable = errors.New("unavailable")
+	errNoResources         = errors.New("no resources available")
 )
 
 func ErrUnsupported(what string, value interface{}) error {
 	return fmt.Errorf("%s=%v %w", what, value, errUnsupported)
 }
 
+func ErrDatapathUnavailable(datapath string) error {
+	return fmt.Errorf("datapath %s %w", datapath, errDatapathUnavailable)
+}
+
+func ErrNoResourcesAvailable(what string) error {
+	return fmt.Errorf("%s: %w", what, errNoResources)
+}
+
 func ErrNotFound(what string) error {
 	return fmt.Errorf("%s %w", what, errNotFound)
 }
@@ -27,6 +40,14 @@ func ErrNotFoundWithParam(what string, paramName string, paramValue interface{})
 	return fmt.Errorf("%s %w with %s=%v", what, errNotFound, paramName, paramValue)
 }
 
+func ErrForbidden(what string, value interface{}) error {
+	return fmt.Errorf("%s=%v %w", what, value, errForbidden)
+}
+
+func ErrUnauthenticated(what string) error {
+	return fmt.Errorf("%w: missing or invalid %s", errUnauthenticated, what)
+}
+
 func ErrInvalidOperation(operation interface{}) error {
 	return fmt.Errorf("%w: %v", errInvalidOperation, operation)
 }
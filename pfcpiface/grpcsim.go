@@ -4,6 +4,7 @@
 package pfcpiface
 
 import (
+	"context"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -259,13 +260,21 @@ func (u *upf) sim(mode simMode, s *SimModeInfo) {
 			qers: qers,
 		}
 
+		var tx rulesTx
+
 		if mode.create() {
-			u.SendMsgToUPF(upfMsgTypeAdd, allRules, PacketForwardingRules{})
+			tx = u.BeginRules(context.Background(), allRules)
+			createRules(tx, allRules)
 		} else if mode.delete() {
-			u.SendMsgToUPF(upfMsgTypeDel, allRules, PacketForwardingRules{})
+			tx = u.BeginRules(context.Background(), PacketForwardingRules{})
+			deleteRules(tx, allRules)
 		} else {
 			log.Fatalln("Unsupported method", mode)
 		}
+
+		if err := tx.Commit(); err != nil {
+			log.Errorln("Failed to write simulated session to datapath:", err)
+		}
 	}
 
 	log.Infoln("Sessions/s:", float64(s.MaxSessions)/time.Since(start).Seconds())
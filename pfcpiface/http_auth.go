@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	httpAuthNone   = "none"
+	httpAuthMTLS   = "mtls"
+	httpAuthBearer = "bearer"
+)
+
+const (
+	// httpRouteGroupConfig is the configuration of the UPF, e.g. the network slices.
+	httpRouteGroupConfig = "config"
+	// httpRouteGroupLB are the routes used by the load balancers.
+	httpRouteGroupLB = "lb"
+	// httpRouteGroupMetrics is the Prometheus endpoint.
+	httpRouteGroupMetrics = "metrics"
+)
+
+var httpRouteGroups = map[string]struct{}{
+	httpRouteGroupConfig:  {},
+	httpRouteGroupLB:      {},
+	httpRouteGroupMetrics: {},
+}
+
+// httpRouteGroup returns the route group of path.
+func httpRouteGroup(path string) string {
+	switch {
+	case path == "/metrics":
+		return httpRouteGroupMetrics
+	case path == "/registergw" || strings.HasPrefix(path, "/v1/lb/"):
+		return httpRouteGroupLB
+	default:
+		return httpRouteGroupConfig
+	}
+}
+
+type httpAuth struct {
+	mode   string
+	tokens [][]byte
+}
+
+// httpAuthenticator authenticates the requests to handler, with the authentication of the
+// route group of each request.
+type httpAuthenticator struct {
+	handler http.Handler
+	auth    map[string]httpAuth
+}
+
+// newHTTPAuthenticator returns handler authenticating the requests as configured in conf.
+func newHTTPAuthenticator(conf HTTPConf, handler http.Handler) (*httpAuthenticator, error) {
+	a := &httpAuthenticator{
+		handler: handler,
+		auth:    make(map[string]httpAuth),
+	}
+
+	for group, authConf := range conf.Auth {
+		auth, err := newHTTPAuth(authConf)
+		if err != nil {
+			return nil, err
+		}
+
+		a.auth[group] = auth
+	}
+
+	return a, nil
+}
+
+// newHTTPAuth returns the authentication of a route group configured by conf.
+func newHTTPAuth(conf HTTPAuthConf) (httpAuth, error) {
+	auth := httpAuth{mode: conf.Mode}
+
+	if auth.mode == httpAuthBearer {
+		var err error
+
+		auth.tokens, err = readTokens(conf.TokenFile)
+		if err != nil {
+			return httpAuth{}, err
+		}
+	}
+
+	return auth, nil
+}
+
+// readTokens returns the non-empty lines of path.
+func readTokens(path string) ([][]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens [][]byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		if token := bytes.TrimSpace(scanner.Bytes()); len(token) > 0 {
+			tokens = append(tokens, append([]byte(nil), token...))
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, ErrNotFoundWithParam("bearer token", "file", path)
+	}
+
+	return tokens, scanner.Err()
+}
+
+func (a *httpAuthenticator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	group := httpRouteGroup(r.URL.Path)
+	auth := a.auth[group]
+
+	if err := auth.authenticate(r); err != nil {
+		log.Warnf("Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+
+		if auth.mode == httpAuthBearer {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="upf"`)
+		}
+
+		sendHTTPError(w, err)
+
+		return
+	}
+
+	a.handler.ServeHTTP(w, r)
+}
+
+func (auth httpAuth) authenticate(r *http.Request) error {
+	return auth.check(r.TLS, r.Header.Get("Authorization"))
+}
+
+// check authenticates a client by the state of its TLS connection, nil in clear, and the value
+// of its Authorization header.
+func (auth httpAuth) check(state *tls.ConnectionState, header string) error {
+	switch auth.mode {
+	case httpAuthMTLS:
+		// the certificates are verified by the TLS handshake
+		if state == nil || len(state.VerifiedChains) == 0 {
+			return ErrUnauthenticated("client certificate")
+		}
+	case httpAuthBearer:
+		if !strings.HasPrefix(header, "Bearer ") {
+			return ErrUnauthenticated("bearer token")
+		}
+
+		token := []byte(strings.TrimPrefix(header, "Bearer "))
+
+		for _, t := range auth.tokens {
+			if subtle.ConstantTimeCompare(token, t) == 1 {
+				return nil
+			}
+		}
+
+		return ErrUnauthenticated("bearer token")
+	}
+
+	return nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, ErrInvalidArgumentWithReason("ca_file", path, "no PEM certificate")
+	}
+
+	return pool, nil
+}
+
+// serverTLSConfig returns the TLS config of the HTTP server, nil if TLS is disabled. The clients
+// are asked for a certificate verified by the CA, which the routes with mutual TLS require.
+func serverTLSConfig(conf TLSConf) (*tls.Config, error) {
+	if conf.CertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if conf.CAFile != "" {
+		tlsConfig.ClientCAs, err = loadCertPool(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// clientTLSConfig returns the TLS config of the clients of the load balancers, presenting the
+// certificate of the UPF to the LBs asking for it. nil if TLS is not configured.
+func clientTLSConfig(conf TLSConf) (*tls.Config, error) {
+	if conf.CertFile == "" && conf.CAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if conf.CAFile != "" {
+		var err error
+
+		tlsConfig.RootCAs, err = loadCertPool(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tlsConfig, nil
+}
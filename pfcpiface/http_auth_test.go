@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a certificate of name signed by parent, self-signed if nil, and its key
+// to dir, as <name>.crt and <name>.key.
+func writeTestCert(t *testing.T, dir, name string, template *x509.Certificate, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+// writeTestPKI writes a CA, the certificate of a server on 127.0.0.1 and the one of a client
+// signed by the CA, and returns the TLS configs of the server and the client.
+func writeTestPKI(t *testing.T) (server, client TLSConf) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+
+	ca, caKey := writeTestCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+
+	writeTestCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "upf"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+
+	writeTestCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "enterlb"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	server = TLSConf{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key"), CAFile: caFile}
+	client = TLSConf{CertFile: filepath.Join(dir, "client.crt"), KeyFile: filepath.Join(dir, "client.key"), CAFile: caFile}
+
+	return server, client
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func Test_httpRouteGroup(t *testing.T) {
+	require.Equal(t, httpRouteGroupMetrics, httpRouteGroup("/metrics"))
+	require.Equal(t, httpRouteGroupLB, httpRouteGroup("/registergw"))
+	require.Equal(t, httpRouteGroupLB, httpRouteGroup("/v1/lb/registration"))
+	require.Equal(t, httpRouteGroupConfig, httpRouteGroup("/v1/config/network-slices"))
+	require.Equal(t, httpRouteGroupConfig, httpRouteGroup("/v1/lb"))
+}
+
+func Test_httpAuthenticator_bearer(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("\nsecret\n  other \n"), 0o600))
+
+	handler, err := newHTTPAuthenticator(HTTPConf{
+		Auth: map[string]HTTPAuthConf{
+			httpRouteGroupMetrics: {Mode: httpAuthBearer, TokenFile: tokenFile},
+		},
+	}, okHandler())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		status        int
+	}{
+		{name: "missing token", path: "/metrics", status: http.StatusUnauthorized},
+		{name: "wrong token", path: "/metrics", authorization: "Bearer secre", status: http.StatusUnauthorized},
+		{name: "not a bearer token", path: "/metrics", authorization: "Basic secret", status: http.StatusUnauthorized},
+		{name: "token", path: "/metrics", authorization: "Bearer secret", status: http.StatusOK},
+		{name: "second token", path: "/metrics", authorization: "Bearer other", status: http.StatusOK},
+		{name: "other route group", path: "/v1/config/network-slices", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+
+			if tt.status == http.StatusUnauthorized {
+				require.Equal(t, `Bearer realm="upf"`, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	t.Run("empty token file", func(t *testing.T) {
+		emptyFile := filepath.Join(t.TempDir(), "tokens")
+		require.NoError(t, os.WriteFile(emptyFile, []byte("\n"), 0o600))
+
+		_, err := newHTTPAuthenticator(HTTPConf{
+			Auth: map[string]HTTPAuthConf{
+				httpRouteGroupLB: {Mode: httpAuthBearer, TokenFile: emptyFile},
+			},
+		}, okHandler())
+		require.Error(t, err)
+	})
+}
+
+func Test_httpAuthenticator_mTLS(t *testing.T) {
+	serverConf, clientConf := writeTestPKI(t)
+
+	conf := HTTPConf{
+		TLS: serverConf,
+		Auth: map[string]HTTPAuthConf{
+			httpRouteGroupLB: {Mode: httpAuthMTLS},
+		},
+	}
+	require.NoError(t, validateHTTPConf(conf))
+
+	handler, err := newHTTPAuthenticator(conf, okHandler())
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS, err = serverTLSConfig(conf.TLS)
+	require.NoError(t, err)
+	srv.StartTLS()
+
+	defer srv.Close()
+
+	get := func(t *testing.T, tlsConf TLSConf, path string) int {
+		tlsConfig, err := clientTLSConfig(tlsConf)
+		require.NoError(t, err)
+
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+		resp, err := client.Get(srv.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	// without a client certificate, the server is only verified
+	anonymous := TLSConf{CAFile: clientConf.CAFile}
+
+	require.Equal(t, http.StatusUnauthorized, get(t, anonymous, "/registergw"))
+	require.Equal(t, http.StatusOK, get(t, anonymous, "/metrics"))
+	require.Equal(t, http.StatusOK, get(t, clientConf, "/registergw"))
+}
+
+func Test_httpLBClient_TLS(t *testing.T) {
+	serverConf, clientConf := writeTestPKI(t)
+
+	lb := fake_lb.NewFakeLB()
+	srv := httptest.NewUnstartedServer(lb)
+
+	var err error
+
+	srv.TLS, err = serverTLSConfig(serverConf)
+	require.NoError(t, err)
+
+	srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	srv.StartTLS()
+
+	defer srv.Close()
+
+	req := RegisterReq{GwIP: lbTestGwIP, CoreMac: "00:00:00:00:00:01", Hostname: "upf-0"}
+
+	// the LB rejects the UPF without a certificate
+	u := &upf{enterLBURL: srv.URL}
+	u.lbTLS, err = clientTLSConfig(TLSConf{CAFile: clientConf.CAFile})
+	require.NoError(t, err)
+	require.Error(t, newHTTPLBClient(u).Register(context.Background(), enterlb, req))
+
+	u.lbTLS, err = clientTLSConfig(clientConf)
+	require.NoError(t, err)
+	require.NoError(t, newHTTPLBClient(u).Register(context.Background(), enterlb, req))
+	require.Len(t, lb.GetRegistrations(), 1)
+}
@@ -91,6 +91,14 @@ func (i *IPPool) DeallocIP(seid uint64) error {
 	return nil
 }
 
+// Usage returns the number of allocated IP addresses and the size of the pool.
+func (i *IPPool) Usage() (allocated int, size int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return len(i.inventory), len(i.inventory) + len(i.freePool)
+}
+
 func (i *IPPool) String() string {
 	i.mu.Lock()
 	defer i.mu.Unlock()
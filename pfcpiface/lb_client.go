@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/omec-project/upf-epc/pfcpiface/lb_pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// lbAPIHTTP is the JSON API of the load balancers.
+	lbAPIHTTP = "http"
+	// lbAPIGRPC is the gRPC API of the load balancers, defined in lb_pb.
+	lbAPIGRPC = "grpc"
+)
+
+// lbClient sends the requests of the UPF to the Enter-LB and Exit-LB, whatever their API.
+type lbClient interface {
+	// Register registers the UPF to lb, or renews its registration.
+	Register(ctx context.Context, lb lbtype, req RegisterReq) error
+	// Deregister removes the UPF from lb.
+	Deregister(ctx context.Context, lb lbtype, req RegisterReq) error
+	// AddRules routes the traffic of the UEs of req to the UPF.
+	AddRules(ctx context.Context, lb lbtype, req RuleReq) error
+	// DeleteRules stops routing the traffic of the UEs of req to the UPF.
+	DeleteRules(ctx context.Context, lb lbtype, req RuleReq) error
+	Close() error
+}
+
+// newLBClient returns the client of the API of the LBs configured in conf.
+func newLBClient(upf *upf, conf LBConf) (lbClient, error) {
+	if conf.API == lbAPIGRPC {
+		return newGRPCLBClient(conf.EnterLBGRPCAddr, conf.ExitLBGRPCAddr, upf.lbTLS)
+	}
+
+	return newHTTPLBClient(upf), nil
+}
+
+// lbs returns the client of the Enter-LB and Exit-LB of u, the HTTP one if none is configured.
+func (u *upf) lbs() lbClient {
+	if u.lbClient == nil {
+		return newHTTPLBClient(u)
+	}
+
+	return u.lbClient
+}
+
+// httpLBClient is the adapter of the JSON API of the LBs, which reply 201 Created to the
+// registrations and rules. It also sends the requests to the PFCP-LB.
+type httpLBClient struct {
+	upf    *upf
+	client http.Client
+}
+
+func newHTTPLBClient(upf *upf) *httpLBClient {
+	c := &httpLBClient{
+		upf:    upf,
+		client: http.Client{Timeout: lbRequestTimeout},
+	}
+
+	// the LBs serving HTTPS can authenticate the UPF by its certificate
+	if upf.lbTLS != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = upf.lbTLS
+		c.client.Transport = transport
+	}
+
+	return c
+}
+
+func (c *httpLBClient) Register(ctx context.Context, lb lbtype, req RegisterReq) error {
+	return c.send(ctx, http.MethodPost, lb, "/register", req)
+}
+
+func (c *httpLBClient) Deregister(ctx context.Context, lb lbtype, req RegisterReq) error {
+	return c.send(ctx, http.MethodDelete, lb, "/register", req)
+}
+
+func (c *httpLBClient) AddRules(ctx context.Context, lb lbtype, req RuleReq) error {
+	return c.send(ctx, http.MethodPost, lb, "/addrule", req)
+}
+
+// DeleteRules does nothing: the JSON API has no way to delete the rules, which the LBs drop
+// along with the UPF.
+func (c *httpLBClient) DeleteRules(ctx context.Context, lb lbtype, req RuleReq) error {
+	return nil
+}
+
+func (c *httpLBClient) Close() error {
+	return nil
+}
+
+// lbURL returns the URL of path on lb. The UPF registers on the configured URL of the PFCP-LB,
+// the other paths are relative to it.
+func (c *httpLBClient) lbURL(lb lbtype, path string) string {
+	switch lb {
+	case enterlb:
+		return c.upf.enterLBURL + path
+	case exitlb:
+		return c.upf.exitLBURL + path
+	default:
+		if path == "" {
+			return c.upf.pfcpLBURL
+		}
+
+		return strings.TrimSuffix(c.upf.pfcpLBURL, "/") + path
+	}
+}
+
+// send sends body to path of lb with method, e.g. POST to register and DELETE to deregister.
+func (c *httpLBClient) send(ctx context.Context, method string, lb lbtype, path string, body interface{}) error {
+	url := c.lbURL(lb, path)
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 300
+	// the Enter-LB and Exit-LB reply to registrations and rules with 201 Created
+	if method == http.MethodPost && lb != pfcplb {
+		ok = resp.StatusCode == http.StatusCreated
+	}
+
+	if !ok {
+		return fmt.Errorf("%v LB replied %s to %s %s", lb, resp.Status, method, url)
+	}
+
+	return nil
+}
+
+// grpcLBClient is the client of the gRPC API of the Enter-LB and Exit-LB.
+type grpcLBClient struct {
+	conns   []*grpc.ClientConn
+	clients map[lbtype]lb_pb.LoadBalancerClient
+}
+
+// newGRPCLBClient connects to the gRPC API of the LBs, in the background, over TLS if tlsConfig
+// is set.
+func newGRPCLBClient(enterAddr, exitAddr string, tlsConfig *tls.Config) (*grpcLBClient, error) {
+	c := &grpcLBClient{
+		clients: make(map[lbtype]lb_pb.LoadBalancerClient),
+	}
+
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	for lb, addr := range map[lbtype]string{enterlb: enterAddr, exitlb: exitAddr} {
+		conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%v LB: %w", lb, err)
+		}
+
+		c.conns = append(c.conns, conn)
+		c.clients[lb] = lb_pb.NewLoadBalancerClient(conn)
+	}
+
+	return c, nil
+}
+
+func newRegisterRequest(req RegisterReq) *lb_pb.RegisterRequest {
+	return &lb_pb.RegisterRequest{
+		GatewayIp: req.GwIP,
+		CoreMac:   req.CoreMac,
+		AccessMac: req.AccessMac,
+		Hostname:  req.Hostname,
+	}
+}
+
+func newRulesRequest(req RuleReq) *lb_pb.RulesRequest {
+	return &lb_pb.RulesRequest{
+		GatewayIp:   req.GwIP,
+		UeAddresses: req.Ip,
+	}
+}
+
+func (c *grpcLBClient) Register(ctx context.Context, lb lbtype, req RegisterReq) error {
+	ctx, cancel := context.WithTimeout(ctx, lbRequestTimeout)
+	defer cancel()
+
+	_, err := c.clients[lb].Register(ctx, newRegisterRequest(req))
+
+	return err
+}
+
+func (c *grpcLBClient) Deregister(ctx context.Context, lb lbtype, req RegisterReq) error {
+	ctx, cancel := context.WithTimeout(ctx, lbRequestTimeout)
+	defer cancel()
+
+	_, err := c.clients[lb].Deregister(ctx, newRegisterRequest(req))
+
+	return err
+}
+
+func (c *grpcLBClient) AddRules(ctx context.Context, lb lbtype, req RuleReq) error {
+	ctx, cancel := context.WithTimeout(ctx, lbRequestTimeout)
+	defer cancel()
+
+	_, err := c.clients[lb].AddRules(ctx, newRulesRequest(req))
+
+	return err
+}
+
+func (c *grpcLBClient) DeleteRules(ctx context.Context, lb lbtype, req RuleReq) error {
+	ctx, cancel := context.WithTimeout(ctx, lbRequestTimeout)
+	defer cancel()
+
+	_, err := c.clients[lb].DeleteRules(ctx, newRulesRequest(req))
+
+	return err
+}
+
+func (c *grpcLBClient) Close() error {
+	var err error
+
+	for _, conn := range c.conns {
+		if e := conn.Close(); e != nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+
+	"github.com/omec-project/upf-epc/pfcpiface/lb_pb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// lbGRPCService serves the UPF service of lb_pb to the load balancers. The /registergw and
+// /v1/lb/ues endpoints of the HTTP API serve the same requests in JSON.
+type lbGRPCService struct {
+	lb_pb.UnimplementedUPFServer
+
+	registerGw *RegisterGw
+	registrar  *lbRegistrar
+}
+
+// newLBGRPCServer returns a gRPC server serving the UPF service of upf and registrar, with the
+// TLS of the HTTP API and the authentication of its lb route group.
+func newLBGRPCServer(upf *upf, registrar *lbRegistrar, conf HTTPConf) (*grpc.Server, error) {
+	auth, err := newHTTPAuth(conf.Auth[httpRouteGroupLB])
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(auth.unaryInterceptor)}
+
+	tlsConfig, err := serverTLSConfig(conf.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	lb_pb.RegisterUPFServer(server, &lbGRPCService{
+		registerGw: &RegisterGw{upf: upf, registrar: registrar},
+		registrar:  registrar,
+	})
+
+	return server, nil
+}
+
+// unaryInterceptor rejects the gRPC requests failing auth. The bearer tokens are sent in the
+// authorization metadata, as in HTTP.
+func (auth httpAuth) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	var (
+		state  *tls.ConnectionState
+		source string
+		header string
+	)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		source = p.Addr.String()
+
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &tlsInfo.State
+		}
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+
+	if err := auth.check(state, header); err != nil {
+		log.Warnf("Rejected %s from %s: %v", info.FullMethod, source, err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return handler(ctx, req)
+}
+
+var lbInterfaces = map[string]lb_pb.Interface{
+	"access": lb_pb.Interface_INTERFACE_ACCESS,
+	"core":   lb_pb.Interface_INTERFACE_CORE,
+}
+
+func (s *lbGRPCService) AnnounceGateway(ctx context.Context, req *lb_pb.AnnounceGatewayRequest) (*lb_pb.AnnounceGatewayResponse, error) {
+	log.Infoln("handle gRPC request to announce gateway", req.GetGatewayIp())
+
+	var source net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		source = remoteIP(p.Addr.String())
+	}
+
+	iface, err := s.registerGw.announceGateway(GWRegisterReq{
+		GwIP:  req.GetGatewayIp(),
+		GwMac: req.GetGatewayMac(),
+	}, source)
+
+	switch {
+	case errors.Is(err, errInvalidArgument):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errForbidden):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &lb_pb.AnnounceGatewayResponse{Interface: lbInterfaces[iface]}, nil
+}
+
+func (s *lbGRPCService) ListUEs(ctx context.Context, req *lb_pb.ListUEsRequest) (*lb_pb.ListUEsResponse, error) {
+	return &lb_pb.ListUEsResponse{
+		GatewayIp:   s.registrar.registerReq.GwIP,
+		UeAddresses: s.registrar.ueAddresses(),
+	}, nil
+}
+
+func (s *lbGRPCService) Resync(ctx context.Context, req *lb_pb.ResyncRequest) (*lb_pb.ResyncResponse, error) {
+	switch req.GetInterface() {
+	case lb_pb.Interface_INTERFACE_ACCESS:
+		s.registrar.requestResync(enterlb)
+	case lb_pb.Interface_INTERFACE_CORE:
+		s.registrar.requestResync(exitlb)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid interface %v", req.GetInterface())
+	}
+
+	s.registrar.notify()
+
+	return &lb_pb.ResyncResponse{}, nil
+}
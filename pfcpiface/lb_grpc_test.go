@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pfcpiface/lb_pb"
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/message"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// serveTestGRPC serves the gRPC API of lbs and points u to it, the PFCP-LB is still sent JSON.
+func serveTestGRPC(t *testing.T, u *upf, lbs lbTestFakes) {
+	var addrs []string
+
+	for _, lb := range []*fake_lb.FakeLB{lbs.enter, lbs.exit} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		go func(lb *fake_lb.FakeLB) {
+			if err := lb.ServeGRPC(listener); err != nil {
+				t.Logf("fake LB stopped: %v", err)
+			}
+		}(lb)
+
+		t.Cleanup(lb.Stop)
+
+		addrs = append(addrs, listener.Addr().String())
+	}
+
+	client, err := newGRPCLBClient(addrs[0], addrs[1], nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	u.lbClient = client
+}
+
+// startTestUPFGRPC serves the gRPC API of the UPF of r configured by conf, and returns its address.
+func startTestUPFGRPC(t *testing.T, r *lbRegistrar, conf HTTPConf) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server, err := newLBGRPCServer(r.upf, r, conf)
+	require.NoError(t, err)
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			t.Logf("UPF gRPC server stopped: %v", err)
+		}
+	}()
+
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+func Test_lbGRPC_handshake(t *testing.T) {
+	commands := recordCommands(t)
+
+	u := &upf{
+		AccessIP:      net.ParseIP("192.168.252.3"),
+		CoreIP:        net.ParseIP("192.168.250.3"),
+		NodeID:        "upf-0",
+		gwIP:          lbTestGwIP,
+		maxReqRetries: 1,
+	}
+	lbs := startTestLBs(t, u)
+	serveTestGRPC(t, u, lbs)
+
+	registerReq := RegisterReq{GwIP: u.gwIP, CoreMac: "00:00:00:00:00:01", Hostname: u.NodeID}
+	r := newLBRegistrar(u, registerReq, 0)
+	r.retryInterval = 10 * time.Millisecond
+
+	upfAddr := startTestUPFGRPC(t, r, HTTPConf{})
+
+	// the LBs announce their gateway on the gRPC API of the UPF
+	lbs.enter.SetGateway(upfAddr, &fake_lb.GatewayRegistration{GwIP: "192.168.252.1", GwMac: "00:00:00:00:00:02"})
+	lbs.exit.SetGateway(upfAddr, &fake_lb.GatewayRegistration{GwIP: lbTestGwIP, GwMac: "00:00:00:00:00:03"})
+
+	r.Start()
+	r.setServing()
+	requireLBState(t, r, lbReady)
+
+	expected := []fake_lb.RegisterRequest{{GwIP: lbTestGwIP, CoreMac: "00:00:00:00:00:01", Hostname: "upf-0"}}
+	require.Equal(t, expected, lbs.enter.GetRegistrations())
+	require.Equal(t, expected, lbs.exit.GetRegistrations())
+	require.Len(t, lbs.pfcp.GetRegistrations(), 1)
+	require.ElementsMatch(t, []string{
+		"arp -s 192.168.252.1 00:00:00:00:00:02 -i access",
+		"arp -s 192.168.250.1 00:00:00:00:00:03 -i core",
+	}, commands())
+
+	// the rules of the sessions are added and deleted on the gRPC API
+	pConn := newLBTestPFCPConn(t, u)
+	u.sessionHooks = sessionHooks{newLBSessionHook(u)}
+
+	_, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, 0))
+	require.NoError(t, err)
+	requireRules(t, lbs.exit, []string{lbTestUEAddress})
+
+	_, err = pConn.handleSessionDeletionRequest(message.NewSessionDeletionRequest(0, 0, 1, 2, 0))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(lbs.enter.GetDeletedRules()) == 1 && len(lbs.exit.GetDeletedRules()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []fake_lb.RuleRequest{{GwIP: lbTestGwIP, IPs: []string{lbTestUEAddress}}},
+		lbs.exit.GetDeletedRules())
+
+	r.Stop()
+	require.Equal(t, expected, lbs.enter.GetDeregistrations())
+	require.Equal(t, expected, lbs.exit.GetDeregistrations())
+}
+
+func Test_lbGRPCService(t *testing.T) {
+	recordCommands(t)
+
+	r, lbs := newTestLBRegistrar(t, 0)
+	r.upf.AccessIP = net.ParseIP("192.168.252.3")
+	r.upf.CoreIP = net.ParseIP("192.168.250.3")
+	setTestUEs(r, "10.250.0.2", "10.250.0.1")
+
+	conn, err := grpc.Dial(startTestUPFGRPC(t, r, HTTPConf{}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	client := lb_pb.NewUPFClient(conn)
+	ctx := context.Background()
+
+	t.Run("AnnounceGateway", func(t *testing.T) {
+		resp, err := client.AnnounceGateway(ctx, &lb_pb.AnnounceGatewayRequest{
+			GatewayIp:  lbTestGwIP,
+			GatewayMac: "00:00:00:00:00:03",
+		})
+		require.NoError(t, err)
+		require.Equal(t, lb_pb.Interface_INTERFACE_CORE, resp.GetInterface())
+		require.Equal(t, lbTestGwIP, r.status().CoreGateway)
+
+		_, err = client.AnnounceGateway(ctx, &lb_pb.AnnounceGatewayRequest{GatewayIp: "192.168.250"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("ListUEs", func(t *testing.T) {
+		resp, err := client.ListUEs(ctx, &lb_pb.ListUEsRequest{})
+		require.NoError(t, err)
+		require.Equal(t, lbTestGwIP, resp.GetGatewayIp())
+		require.Equal(t, []string{"10.250.0.1", "10.250.0.2"}, resp.GetUeAddresses())
+	})
+
+	t.Run("Resync", func(t *testing.T) {
+		r.Start()
+		defer r.Stop()
+
+		requireLBState(t, r, lbRegistered)
+
+		// the registration already pushed the UEs
+		requireRules(t, lbs.enter, []string{"10.250.0.1", "10.250.0.2"})
+
+		_, err := client.Resync(ctx, &lb_pb.ResyncRequest{Interface: lb_pb.Interface_INTERFACE_ACCESS})
+		require.NoError(t, err)
+		requireRules(t, lbs.enter, []string{"10.250.0.1", "10.250.0.2"}, []string{"10.250.0.1", "10.250.0.2"})
+		require.Len(t, lbs.exit.GetRules(), 1)
+
+		_, err = client.Resync(ctx, &lb_pb.ResyncRequest{})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func Test_lbGRPCService_auth(t *testing.T) {
+	serverConf, clientConf := writeTestPKI(t)
+
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0o600))
+
+	r, _ := newTestLBRegistrar(t, 0)
+
+	// listUEs calls ListUEs over TLS, with the client certificate of tlsConf and the bearer token
+	listUEs := func(addr string, tlsConf TLSConf, token string) error {
+		tlsConfig, err := clientTLSConfig(tlsConf)
+		require.NoError(t, err)
+
+		conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		}
+
+		_, err = lb_pb.NewUPFClient(conn).ListUEs(ctx, &lb_pb.ListUEsRequest{})
+
+		return err
+	}
+
+	t.Run("mtls", func(t *testing.T) {
+		addr := startTestUPFGRPC(t, r, HTTPConf{
+			TLS:  serverConf,
+			Auth: map[string]HTTPAuthConf{httpRouteGroupLB: {Mode: httpAuthMTLS}},
+		})
+
+		err := listUEs(addr, TLSConf{CAFile: clientConf.CAFile}, "")
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		require.NoError(t, listUEs(addr, clientConf, ""))
+	})
+
+	t.Run("bearer", func(t *testing.T) {
+		addr := startTestUPFGRPC(t, r, HTTPConf{
+			TLS:  serverConf,
+			Auth: map[string]HTTPAuthConf{httpRouteGroupLB: {Mode: httpAuthBearer, TokenFile: tokenFile}},
+		})
+		caOnly := TLSConf{CAFile: clientConf.CAFile}
+
+		err := listUEs(addr, caOnly, "")
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		err = listUEs(addr, caOnly, "wrong")
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		require.NoError(t, listUEs(addr, caOnly, "secret"))
+	})
+
+	t.Run("clients in clear are rejected", func(t *testing.T) {
+		addr := startTestUPFGRPC(t, r, HTTPConf{TLS: serverConf})
+
+		conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err = lb_pb.NewUPFClient(conn).ListUEs(ctx, &lb_pb.ListUEsRequest{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,1203 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: lb.proto
+
+// API between the UPF and its load balancers: the Enter-LB (West-LB), in front of the access
+// interface, and the Exit-LB (East-LB), in front of the core interface.
+
+package lb_pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Interface of the UPF a load balancer is the gateway of.
+type Interface int32
+
+const (
+	Interface_INTERFACE_UNSPECIFIED Interface = 0
+	// The Enter-LB is the gateway of the access interface.
+	Interface_INTERFACE_ACCESS Interface = 1
+	// The Exit-LB is the gateway of the core interface.
+	Interface_INTERFACE_CORE Interface = 2
+)
+
+// Enum value maps for Interface.
+var (
+	Interface_name = map[int32]string{
+		0: "INTERFACE_UNSPECIFIED",
+		1: "INTERFACE_ACCESS",
+		2: "INTERFACE_CORE",
+	}
+	Interface_value = map[string]int32{
+		"INTERFACE_UNSPECIFIED": 0,
+		"INTERFACE_ACCESS":      1,
+		"INTERFACE_CORE":        2,
+	}
+)
+
+func (x Interface) Enum() *Interface {
+	p := new(Interface)
+	*p = x
+	return p
+}
+
+func (x Interface) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Interface) Descriptor() protoreflect.EnumDescriptor {
+	return file_lb_proto_enumTypes[0].Descriptor()
+}
+
+func (Interface) Type() protoreflect.EnumType {
+	return &file_lb_proto_enumTypes[0]
+}
+
+func (x Interface) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Interface.Descriptor instead.
+func (Interface) EnumDescriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{0}
+}
+
+type RegisterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// IP of the gateway of the core interface of the UPF, identifying the UPF.
+	GatewayIp string `protobuf:"bytes,1,opt,name=gateway_ip,json=gatewayIp,proto3" json:"gateway_ip,omitempty"`
+	CoreMac   string `protobuf:"bytes,2,opt,name=core_mac,json=coreMac,proto3" json:"core_mac,omitempty"`
+	AccessMac string `protobuf:"bytes,3,opt,name=access_mac,json=accessMac,proto3" json:"access_mac,omitempty"`
+	Hostname  string `protobuf:"bytes,4,opt,name=hostname,proto3" json:"hostname,omitempty"`
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RegisterRequest) GetGatewayIp() string {
+	if x != nil {
+		return x.GatewayIp
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetCoreMac() string {
+	if x != nil {
+		return x.CoreMac
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetAccessMac() string {
+	if x != nil {
+		return x.AccessMac
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+type RegisterResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *RegisterResponse) Reset() {
+	*x = RegisterResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterResponse) ProtoMessage() {}
+
+func (x *RegisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterResponse.ProtoReflect.Descriptor instead.
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{1}
+}
+
+type RulesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	GatewayIp   string   `protobuf:"bytes,1,opt,name=gateway_ip,json=gatewayIp,proto3" json:"gateway_ip,omitempty"`
+	UeAddresses []string `protobuf:"bytes,2,rep,name=ue_addresses,json=ueAddresses,proto3" json:"ue_addresses,omitempty"`
+}
+
+func (x *RulesRequest) Reset() {
+	*x = RulesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RulesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RulesRequest) ProtoMessage() {}
+
+func (x *RulesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RulesRequest.ProtoReflect.Descriptor instead.
+func (*RulesRequest) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RulesRequest) GetGatewayIp() string {
+	if x != nil {
+		return x.GatewayIp
+	}
+	return ""
+}
+
+func (x *RulesRequest) GetUeAddresses() []string {
+	if x != nil {
+		return x.UeAddresses
+	}
+	return nil
+}
+
+type RulesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *RulesResponse) Reset() {
+	*x = RulesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RulesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RulesResponse) ProtoMessage() {}
+
+func (x *RulesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RulesResponse.ProtoReflect.Descriptor instead.
+func (*RulesResponse) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{3}
+}
+
+type AnnounceGatewayRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	GatewayIp  string `protobuf:"bytes,1,opt,name=gateway_ip,json=gatewayIp,proto3" json:"gateway_ip,omitempty"`
+	GatewayMac string `protobuf:"bytes,2,opt,name=gateway_mac,json=gatewayMac,proto3" json:"gateway_mac,omitempty"`
+}
+
+func (x *AnnounceGatewayRequest) Reset() {
+	*x = AnnounceGatewayRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AnnounceGatewayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnounceGatewayRequest) ProtoMessage() {}
+
+func (x *AnnounceGatewayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnounceGatewayRequest.ProtoReflect.Descriptor instead.
+func (*AnnounceGatewayRequest) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AnnounceGatewayRequest) GetGatewayIp() string {
+	if x != nil {
+		return x.GatewayIp
+	}
+	return ""
+}
+
+func (x *AnnounceGatewayRequest) GetGatewayMac() string {
+	if x != nil {
+		return x.GatewayMac
+	}
+	return ""
+}
+
+type AnnounceGatewayResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Interface the gateway was configured on.
+	Interface Interface `protobuf:"varint,1,opt,name=interface,proto3,enum=upf.lb.v1.Interface" json:"interface,omitempty"`
+}
+
+func (x *AnnounceGatewayResponse) Reset() {
+	*x = AnnounceGatewayResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AnnounceGatewayResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnounceGatewayResponse) ProtoMessage() {}
+
+func (x *AnnounceGatewayResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnounceGatewayResponse.ProtoReflect.Descriptor instead.
+func (*AnnounceGatewayResponse) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AnnounceGatewayResponse) GetInterface() Interface {
+	if x != nil {
+		return x.Interface
+	}
+	return Interface_INTERFACE_UNSPECIFIED
+}
+
+type ListUEsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListUEsRequest) Reset() {
+	*x = ListUEsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListUEsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUEsRequest) ProtoMessage() {}
+
+func (x *ListUEsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUEsRequest.ProtoReflect.Descriptor instead.
+func (*ListUEsRequest) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{6}
+}
+
+type ListUEsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	GatewayIp   string   `protobuf:"bytes,1,opt,name=gateway_ip,json=gatewayIp,proto3" json:"gateway_ip,omitempty"`
+	UeAddresses []string `protobuf:"bytes,2,rep,name=ue_addresses,json=ueAddresses,proto3" json:"ue_addresses,omitempty"`
+}
+
+func (x *ListUEsResponse) Reset() {
+	*x = ListUEsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListUEsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUEsResponse) ProtoMessage() {}
+
+func (x *ListUEsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUEsResponse.ProtoReflect.Descriptor instead.
+func (*ListUEsResponse) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListUEsResponse) GetGatewayIp() string {
+	if x != nil {
+		return x.GatewayIp
+	}
+	return ""
+}
+
+func (x *ListUEsResponse) GetUeAddresses() []string {
+	if x != nil {
+		return x.UeAddresses
+	}
+	return nil
+}
+
+type ResyncRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Interface of the UPF the calling load balancer is the gateway of.
+	Interface Interface `protobuf:"varint,1,opt,name=interface,proto3,enum=upf.lb.v1.Interface" json:"interface,omitempty"`
+}
+
+func (x *ResyncRequest) Reset() {
+	*x = ResyncRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResyncRequest) ProtoMessage() {}
+
+func (x *ResyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResyncRequest.ProtoReflect.Descriptor instead.
+func (*ResyncRequest) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ResyncRequest) GetInterface() Interface {
+	if x != nil {
+		return x.Interface
+	}
+	return Interface_INTERFACE_UNSPECIFIED
+}
+
+type ResyncResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ResyncResponse) Reset() {
+	*x = ResyncResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_lb_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResyncResponse) ProtoMessage() {}
+
+func (x *ResyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lb_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResyncResponse.ProtoReflect.Descriptor instead.
+func (*ResyncResponse) Descriptor() ([]byte, []int) {
+	return file_lb_proto_rawDescGZIP(), []int{9}
+}
+
+var File_lb_proto protoreflect.FileDescriptor
+
+var file_lb_proto_rawDesc = []byte{
+	0x0a, 0x08, 0x6c, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x75, 0x70, 0x66, 0x2e,
+	0x6c, 0x62, 0x2e, 0x76, 0x31, 0x22, 0x86, 0x01, 0x0a, 0x0f, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74,
+	0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x67, 0x61, 0x74,
+	0x65, 0x77, 0x61, 0x79, 0x5f, 0x69, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x67,
+	0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x49, 0x70, 0x12, 0x19, 0x0a, 0x08, 0x63, 0x6f, 0x72, 0x65,
+	0x5f, 0x6d, 0x61, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x72, 0x65,
+	0x4d, 0x61, 0x63, 0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x6d, 0x61,
+	0x63, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x4d,
+	0x61, 0x63, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x12,
+	0x0a, 0x10, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x50, 0x0a, 0x0c, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x5f, 0x69, 0x70,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x49,
+	0x70, 0x12, 0x21, 0x0a, 0x0c, 0x75, 0x65, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x75, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x65, 0x73, 0x22, 0x0f, 0x0a, 0x0d, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x58, 0x0a, 0x16, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63,
+	0x65, 0x47, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x1d, 0x0a, 0x0a, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x5f, 0x69, 0x70, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x49, 0x70, 0x12, 0x1f,
+	0x0a, 0x0b, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x5f, 0x6d, 0x61, 0x63, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0a, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x4d, 0x61, 0x63, 0x22,
+	0x4d, 0x0a, 0x17, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x47, 0x61, 0x74, 0x65, 0x77,
+	0x61, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x32, 0x0a, 0x09, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e,
+	0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x66,
+	0x61, 0x63, 0x65, 0x52, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x22, 0x10,
+	0x0a, 0x0e, 0x4c, 0x69, 0x73, 0x74, 0x55, 0x45, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x22, 0x53, 0x0a, 0x0f, 0x4c, 0x69, 0x73, 0x74, 0x55, 0x45, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x5f, 0x69,
+	0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79,
+	0x49, 0x70, 0x12, 0x21, 0x0a, 0x0c, 0x75, 0x65, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x75, 0x65, 0x41, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x65, 0x73, 0x22, 0x43, 0x0a, 0x0d, 0x52, 0x65, 0x73, 0x79, 0x6e, 0x63, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x32, 0x0a, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66,
+	0x61, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x75, 0x70, 0x66, 0x2e,
+	0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x52,
+	0x09, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x22, 0x10, 0x0a, 0x0e, 0x52, 0x65,
+	0x73, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2a, 0x50, 0x0a, 0x09,
+	0x49, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x12, 0x19, 0x0a, 0x15, 0x49, 0x4e, 0x54,
+	0x45, 0x52, 0x46, 0x41, 0x43, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49,
+	0x45, 0x44, 0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x49, 0x4e, 0x54, 0x45, 0x52, 0x46, 0x41, 0x43,
+	0x45, 0x5f, 0x41, 0x43, 0x43, 0x45, 0x53, 0x53, 0x10, 0x01, 0x12, 0x12, 0x0a, 0x0e, 0x49, 0x4e,
+	0x54, 0x45, 0x52, 0x46, 0x41, 0x43, 0x45, 0x5f, 0x43, 0x4f, 0x52, 0x45, 0x10, 0x02, 0x32, 0x9b,
+	0x02, 0x0a, 0x0c, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72, 0x12,
+	0x43, 0x0a, 0x08, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x12, 0x1a, 0x2e, 0x75, 0x70,
+	0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62,
+	0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x45, 0x0a, 0x0a, 0x44, 0x65, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74,
+	0x65, 0x72, 0x12, 0x1a, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x52,
+	0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b,
+	0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x67, 0x69, 0x73,
+	0x74, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3d, 0x0a, 0x08, 0x41,
+	0x64, 0x64, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x17, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62,
+	0x2e, 0x76, 0x31, 0x2e, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x18, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x75, 0x6c,
+	0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x40, 0x0a, 0x0b, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x17, 0x2e, 0x75, 0x70, 0x66, 0x2e,
+	0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x18, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x52,
+	0x75, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0xe0, 0x01, 0x0a,
+	0x03, 0x55, 0x50, 0x46, 0x12, 0x58, 0x0a, 0x0f, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x65,
+	0x47, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x12, 0x21, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62,
+	0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x47, 0x61, 0x74, 0x65,
+	0x77, 0x61, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x75, 0x70, 0x66,
+	0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x47,
+	0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x40,
+	0x0a, 0x07, 0x4c, 0x69, 0x73, 0x74, 0x55, 0x45, 0x73, 0x12, 0x19, 0x2e, 0x75, 0x70, 0x66, 0x2e,
+	0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x55, 0x45, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31,
+	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x55, 0x45, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x3d, 0x0a, 0x06, 0x52, 0x65, 0x73, 0x79, 0x6e, 0x63, 0x12, 0x18, 0x2e, 0x75, 0x70, 0x66,
+	0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x75, 0x70, 0x66, 0x2e, 0x6c, 0x62, 0x2e, 0x76, 0x31,
+	0x2e, 0x52, 0x65, 0x73, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42,
+	0x31, 0x5a, 0x2f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6f, 0x6d,
+	0x65, 0x63, 0x2d, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2f, 0x75, 0x70, 0x66, 0x2d, 0x65,
+	0x70, 0x63, 0x2f, 0x70, 0x66, 0x63, 0x70, 0x69, 0x66, 0x61, 0x63, 0x65, 0x2f, 0x6c, 0x62, 0x5f,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_lb_proto_rawDescOnce sync.Once
+	file_lb_proto_rawDescData = file_lb_proto_rawDesc
+)
+
+func file_lb_proto_rawDescGZIP() []byte {
+	file_lb_proto_rawDescOnce.Do(func() {
+		file_lb_proto_rawDescData = protoimpl.X.CompressGZIP(file_lb_proto_rawDescData)
+	})
+	return file_lb_proto_rawDescData
+}
+
+var file_lb_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_lb_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_lb_proto_goTypes = []interface{}{
+	(Interface)(0),                  // 0: upf.lb.v1.Interface
+	(*RegisterRequest)(nil),         // 1: upf.lb.v1.RegisterRequest
+	(*RegisterResponse)(nil),        // 2: upf.lb.v1.RegisterResponse
+	(*RulesRequest)(nil),            // 3: upf.lb.v1.RulesRequest
+	(*RulesResponse)(nil),           // 4: upf.lb.v1.RulesResponse
+	(*AnnounceGatewayRequest)(nil),  // 5: upf.lb.v1.AnnounceGatewayRequest
+	(*AnnounceGatewayResponse)(nil), // 6: upf.lb.v1.AnnounceGatewayResponse
+	(*ListUEsRequest)(nil),          // 7: upf.lb.v1.ListUEsRequest
+	(*ListUEsResponse)(nil),         // 8: upf.lb.v1.ListUEsResponse
+	(*ResyncRequest)(nil),           // 9: upf.lb.v1.ResyncRequest
+	(*ResyncResponse)(nil),          // 10: upf.lb.v1.ResyncResponse
+}
+var file_lb_proto_depIdxs = []int32{
+	0,  // 0: upf.lb.v1.AnnounceGatewayResponse.interface:type_name -> upf.lb.v1.Interface
+	0,  // 1: upf.lb.v1.ResyncRequest.interface:type_name -> upf.lb.v1.Interface
+	1,  // 2: upf.lb.v1.LoadBalancer.Register:input_type -> upf.lb.v1.RegisterRequest
+	1,  // 3: upf.lb.v1.LoadBalancer.Deregister:input_type -> upf.lb.v1.RegisterRequest
+	3,  // 4: upf.lb.v1.LoadBalancer.AddRules:input_type -> upf.lb.v1.RulesRequest
+	3,  // 5: upf.lb.v1.LoadBalancer.DeleteRules:input_type -> upf.lb.v1.RulesRequest
+	5,  // 6: upf.lb.v1.UPF.AnnounceGateway:input_type -> upf.lb.v1.AnnounceGatewayRequest
+	7,  // 7: upf.lb.v1.UPF.ListUEs:input_type -> upf.lb.v1.ListUEsRequest
+	9,  // 8: upf.lb.v1.UPF.Resync:input_type -> upf.lb.v1.ResyncRequest
+	2,  // 9: upf.lb.v1.LoadBalancer.Register:output_type -> upf.lb.v1.RegisterResponse
+	2,  // 10: upf.lb.v1.LoadBalancer.Deregister:output_type -> upf.lb.v1.RegisterResponse
+	4,  // 11: upf.lb.v1.LoadBalancer.AddRules:output_type -> upf.lb.v1.RulesResponse
+	4,  // 12: upf.lb.v1.LoadBalancer.DeleteRules:output_type -> upf.lb.v1.RulesResponse
+	6,  // 13: upf.lb.v1.UPF.AnnounceGateway:output_type -> upf.lb.v1.AnnounceGatewayResponse
+	8,  // 14: upf.lb.v1.UPF.ListUEs:output_type -> upf.lb.v1.ListUEsResponse
+	10, // 15: upf.lb.v1.UPF.Resync:output_type -> upf.lb.v1.ResyncResponse
+	9,  // [9:16] is the sub-list for method output_type
+	2,  // [2:9] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_lb_proto_init() }
+func file_lb_proto_init() {
+	if File_lb_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_lb_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegisterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegisterResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RulesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RulesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AnnounceGatewayRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AnnounceGatewayResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListUEsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListUEsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResyncRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_lb_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResyncResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_lb_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_lb_proto_goTypes,
+		DependencyIndexes: file_lb_proto_depIdxs,
+		EnumInfos:         file_lb_proto_enumTypes,
+		MessageInfos:      file_lb_proto_msgTypes,
+	}.Build()
+	File_lb_proto = out.File
+	file_lb_proto_rawDesc = nil
+	file_lb_proto_goTypes = nil
+	file_lb_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// LoadBalancerClient is the client API for LoadBalancer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type LoadBalancerClient interface {
+	// Register adds the UPF behind gateway_ip to the load balancer, or renews its registration.
+	// The load balancer then announces its gateway to the UPF.
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// Deregister removes the UPF and the routes of its UEs from the load balancer.
+	Deregister(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// AddRules routes the traffic of the UEs to the UPF behind gateway_ip.
+	AddRules(ctx context.Context, in *RulesRequest, opts ...grpc.CallOption) (*RulesResponse, error)
+	// DeleteRules stops routing the traffic of the UEs to the UPF behind gateway_ip.
+	DeleteRules(ctx context.Context, in *RulesRequest, opts ...grpc.CallOption) (*RulesResponse, error)
+}
+
+type loadBalancerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLoadBalancerClient(cc grpc.ClientConnInterface) LoadBalancerClient {
+	return &loadBalancerClient{cc}
+}
+
+func (c *loadBalancerClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, "/upf.lb.v1.LoadBalancer/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loadBalancerClient) Deregister(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, "/upf.lb.v1.LoadBalancer/Deregister", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loadBalancerClient) AddRules(ctx context.Context, in *RulesRequest, opts ...grpc.CallOption) (*RulesResponse, error) {
+	out := new(RulesResponse)
+	err := c.cc.Invoke(ctx, "/upf.lb.v1.LoadBalancer/AddRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loadBalancerClient) DeleteRules(ctx context.Context, in *RulesRequest, opts ...grpc.CallOption) (*RulesResponse, error) {
+	out := new(RulesResponse)
+	err := c.cc.Invoke(ctx, "/upf.lb.v1.LoadBalancer/DeleteRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LoadBalancerServer is the server API for LoadBalancer service.
+type LoadBalancerServer interface {
+	// Register adds the UPF behind gateway_ip to the load balancer, or renews its registration.
+	// The load balancer then announces its gateway to the UPF.
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// Deregister removes the UPF and the routes of its UEs from the load balancer.
+	Deregister(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// AddRules routes the traffic of the UEs to the UPF behind gateway_ip.
+	AddRules(context.Context, *RulesRequest) (*RulesResponse, error)
+	// DeleteRules stops routing the traffic of the UEs to the UPF behind gateway_ip.
+	DeleteRules(context.Context, *RulesRequest) (*RulesResponse, error)
+}
+
+// UnimplementedLoadBalancerServer can be embedded to have forward compatible implementations.
+type UnimplementedLoadBalancerServer struct {
+}
+
+func (*UnimplementedLoadBalancerServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (*UnimplementedLoadBalancerServer) Deregister(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Deregister not implemented")
+}
+func (*UnimplementedLoadBalancerServer) AddRules(context.Context, *RulesRequest) (*RulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRules not implemented")
+}
+func (*UnimplementedLoadBalancerServer) DeleteRules(context.Context, *RulesRequest) (*RulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRules not implemented")
+}
+
+func RegisterLoadBalancerServer(s *grpc.Server, srv LoadBalancerServer) {
+	s.RegisterService(&_LoadBalancer_serviceDesc, srv)
+}
+
+func _LoadBalancer_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoadBalancerServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/upf.lb.v1.LoadBalancer/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoadBalancerServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoadBalancer_Deregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoadBalancerServer).Deregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/upf.lb.v1.LoadBalancer/Deregister",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoadBalancerServer).Deregister(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoadBalancer_AddRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoadBalancerServer).AddRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/upf.lb.v1.LoadBalancer/AddRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoadBalancerServer).AddRules(ctx, req.(*RulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoadBalancer_DeleteRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoadBalancerServer).DeleteRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/upf.lb.v1.LoadBalancer/DeleteRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoadBalancerServer).DeleteRules(ctx, req.(*RulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _LoadBalancer_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "upf.lb.v1.LoadBalancer",
+	HandlerType: (*LoadBalancerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _LoadBalancer_Register_Handler,
+		},
+		{
+			MethodName: "Deregister",
+			Handler:    _LoadBalancer_Deregister_Handler,
+		},
+		{
+			MethodName: "AddRules",
+			Handler:    _LoadBalancer_AddRules_Handler,
+		},
+		{
+			MethodName: "DeleteRules",
+			Handler:    _LoadBalancer_DeleteRules_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "lb.proto",
+}
+
+// UPFClient is the client API for UPF service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type UPFClient interface {
+	// AnnounceGateway configures the load balancer as the gateway of the access or core
+	// interface of the UPF, once the UPF is registered.
+	AnnounceGateway(ctx context.Context, in *AnnounceGatewayRequest, opts ...grpc.CallOption) (*AnnounceGatewayResponse, error)
+	// ListUEs returns the UE addresses routed to the UPF.
+	ListUEs(ctx context.Context, in *ListUEsRequest, opts ...grpc.CallOption) (*ListUEsResponse, error)
+	// Resync makes the UPF push the addresses of all its UEs to the load balancer again.
+	Resync(ctx context.Context, in *ResyncRequest, opts ...grpc.CallOption) (*ResyncResponse, error)
+}
+
+type uPFClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUPFClient(cc grpc.ClientConnInterface) UPFClient {
+	return &uPFClient{cc}
+}
+
+func (c *uPFClient) AnnounceGateway(ctx context.Context, in *AnnounceGatewayRequest, opts ...grpc.CallOption) (*AnnounceGatewayResponse, error) {
+	out := new(AnnounceGatewayResponse)
+	err := c.cc.Invoke(ctx, "/upf.lb.v1.UPF/AnnounceGateway", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uPFClient) ListUEs(ctx context.Context, in *ListUEsRequest, opts ...grpc.CallOption) (*ListUEsResponse, error) {
+	out := new(ListUEsResponse)
+	err := c.cc.Invoke(ctx, "/upf.lb.v1.UPF/ListUEs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uPFClient) Resync(ctx context.Context, in *ResyncRequest, opts ...grpc.CallOption) (*ResyncResponse, error) {
+	out := new(ResyncResponse)
+	err := c.cc.Invoke(ctx, "/upf.lb.v1.UPF/Resync", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UPFServer is the server API for UPF service.
+type UPFServer interface {
+	// AnnounceGateway configures the load balancer as the gateway of the access or core
+	// interface of the UPF, once the UPF is registered.
+	AnnounceGateway(context.Context, *AnnounceGatewayRequest) (*AnnounceGatewayResponse, error)
+	// ListUEs returns the UE addresses routed to the UPF.
+	ListUEs(context.Context, *ListUEsRequest) (*ListUEsResponse, error)
+	// Resync makes the UPF push the addresses of all its UEs to the load balancer again.
+	Resync(context.Context, *ResyncRequest) (*ResyncResponse, error)
+}
+
+// UnimplementedUPFServer can be embedded to have forward compatible implementations.
+type UnimplementedUPFServer struct {
+}
+
+func (*UnimplementedUPFServer) AnnounceGateway(context.Context, *AnnounceGatewayRequest) (*AnnounceGatewayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnnounceGateway not implemented")
+}
+func (*UnimplementedUPFServer) ListUEs(context.Context, *ListUEsRequest) (*ListUEsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUEs not implemented")
+}
+func (*UnimplementedUPFServer) Resync(context.Context, *ResyncRequest) (*ResyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resync not implemented")
+}
+
+func RegisterUPFServer(s *grpc.Server, srv UPFServer) {
+	s.RegisterService(&_UPF_serviceDesc, srv)
+}
+
+func _UPF_AnnounceGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnnounceGatewayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UPFServer).AnnounceGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/upf.lb.v1.UPF/AnnounceGateway",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UPFServer).AnnounceGateway(ctx, req.(*AnnounceGatewayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UPF_ListUEs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUEsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UPFServer).ListUEs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/upf.lb.v1.UPF/ListUEs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UPFServer).ListUEs(ctx, req.(*ListUEsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UPF_Resync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UPFServer).Resync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/upf.lb.v1.UPF/Resync",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UPFServer).Resync(ctx, req.(*ResyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _UPF_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "upf.lb.v1.UPF",
+	HandlerType: (*UPFServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AnnounceGateway",
+			Handler:    _UPF_AnnounceGateway_Handler,
+		},
+		{
+			MethodName: "ListUEs",
+			Handler:    _UPF_ListUEs_Handler,
+		},
+		{
+			MethodName: "Resync",
+			Handler:    _UPF_Resync_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "lb.proto",
+}
@@ -0,0 +1,547 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// lbRetryInterval is the interval between two attempts to register to the load balancers.
+	lbRetryInterval = time.Second
+	// lbRequestTimeout is the timeout of the requests sent to the load balancers.
+	lbRequestTimeout = 10 * time.Second
+	// lbDeregisterTimeout bounds the deregistration from all the load balancers on Stop.
+	lbDeregisterTimeout = 5 * time.Second
+	// lbMaxRenewalFailures is the number of consecutive failed renewals after which the
+	// registration to the Enter-LB and Exit-LB is considered lost.
+	lbMaxRenewalFailures = 3
+)
+
+// lbRegistrationState is the state of the registration of the UPF to the load balancers.
+// The states are reached in order, an LB lost brings the registration back to lbUnregistered.
+type lbRegistrationState int
+
+const (
+	// lbUnregistered: the UPF is not registered to the Enter-LB and Exit-LB.
+	lbUnregistered lbRegistrationState = iota
+	// lbRegistered: the UPF is registered to both LBs, which must send their gateway.
+	lbRegistered
+	// lbGatewaysLearned: the gateways of the access and core interfaces are configured.
+	lbGatewaysLearned
+	// lbPFCPLBRegistered: the PFCP-LB knows the UPF and can send it PFCP sessions.
+	lbPFCPLBRegistered
+	// lbReady: the PFCP node serves the sessions sent by the PFCP-LB.
+	lbReady
+)
+
+var lbRegistrationStateNames = []string{
+	"unregistered",
+	"lbs_registered",
+	"gateways_learned",
+	"pfcp_lb_registered",
+	"ready",
+}
+
+func (s lbRegistrationState) String() string {
+	if int(s) < len(lbRegistrationStateNames) {
+		return lbRegistrationStateNames[s]
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+func (lb lbtype) String() string {
+	switch lb {
+	case enterlb:
+		return "enter"
+	case exitlb:
+		return "exit"
+	case pfcplb:
+		return "pfcp"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(lb))
+	}
+}
+
+// lbRegistrationStatus is the registration state returned by the HTTP API.
+type lbRegistrationStatus struct {
+	State         string `json:"state"`
+	AccessGateway string `json:"access_gateway,omitempty"`
+	CoreGateway   string `json:"core_gateway,omitempty"`
+	Draining      bool   `json:"draining,omitempty"`
+}
+
+type lbMetrics struct {
+	state         *prometheus.GaugeVec
+	registrations *prometheus.CounterVec
+	failures      *prometheus.CounterVec
+	resyncs       *prometheus.CounterVec
+	loadReports   *prometheus.CounterVec
+}
+
+var (
+	lbMets     *lbMetrics
+	lbMetsOnce sync.Once
+)
+
+// getLBMetrics returns the process-wide load balancer metrics, registering them on first use.
+func getLBMetrics() *lbMetrics {
+	lbMetsOnce.Do(func() {
+		lbMets = &lbMetrics{
+			state: mustRegisterOrExisting(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "upf_lb_registration_state",
+				Help: "Shows the state of the registration to the load balancers, 1 for the current state",
+			}, []string{"state"})).(*prometheus.GaugeVec),
+			registrations: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_lb_registrations_total",
+				Help: "Number of successful registrations, or renewals, to a load balancer",
+			}, []string{"lb"})).(*prometheus.CounterVec),
+			failures: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_lb_registration_failures_total",
+				Help: "Number of failed registrations, or renewals, to a load balancer",
+			}, []string{"lb"})).(*prometheus.CounterVec),
+			resyncs: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_lb_ue_resyncs_total",
+				Help: "Number of times all the UE addresses were pushed to a load balancer",
+			}, []string{"lb", "result"})).(*prometheus.CounterVec),
+			loadReports: mustRegisterOrExisting(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "upf_lb_load_reports_total",
+				Help: "Number of load reports sent to the PFCP-LB",
+			}, []string{"result"})).(*prometheus.CounterVec),
+		}
+	})
+
+	return lbMets
+}
+
+// lbRegistrar registers the UPF to the Enter-LB, the Exit-LB and the PFCP-LB. It moves through
+// the lbRegistrationState in the background, on the gateways sent by the LBs to /registergw
+// and once the PFCP node serves, retrying the failed registrations.
+//
+// The registration to the Enter-LB and Exit-LB is renewed every keepaliveInterval, and retried
+// every retryInterval if one of them doesn't accept it. After lbMaxRenewalFailures consecutive
+// failures the registration starts over, so that a restarted LB learns the UPF again and sends
+// its gateway.
+type lbRegistrar struct {
+	upf               *upf
+	registerReq       RegisterReq
+	retryInterval     time.Duration
+	keepaliveInterval time.Duration
+	resyncBatchSize   int
+	// lbs is the client of the Enter-LB and Exit-LB, http of the PFCP-LB
+	lbs  lbClient
+	http *httpLBClient
+	// renewalFailures counts the consecutive failed renewals, only used by run
+	renewalFailures int
+
+	// mu guards the fields below
+	mu            sync.Mutex
+	state         lbRegistrationState
+	accessGateway string
+	coreGateway   string
+	serving       bool
+	// draining keeps the UPF deregistered from the PFCP-LB
+	draining bool
+	// resyncPending are the LBs to push all the UE addresses to
+	resyncPending map[lbtype]bool
+
+	// wake triggers a state transition after an event
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newLBRegistrar creates a registrar sending registerReq to the Enter-LB and Exit-LB of upf.
+// A zero keepaliveInterval disables the renewal of the registration.
+func newLBRegistrar(upf *upf, registerReq RegisterReq, keepaliveInterval time.Duration) *lbRegistrar {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &lbRegistrar{
+		upf:               upf,
+		registerReq:       registerReq,
+		retryInterval:     lbRetryInterval,
+		keepaliveInterval: keepaliveInterval,
+		resyncBatchSize:   lbResyncBatchSize,
+		lbs:               upf.lbs(),
+		http:              newHTTPLBClient(upf),
+		resyncPending:     make(map[lbtype]bool),
+		wake:              make(chan struct{}, 1),
+		ctx:               ctx,
+		cancel:            cancel,
+		done:              make(chan struct{}),
+	}
+	r.setState(lbUnregistered)
+
+	return r
+}
+
+// Start runs the registration in the background, unless the load balancers are disabled.
+func (r *lbRegistrar) Start() {
+	if r.upf.lbDisabled {
+		log.Infoln("Not registering to the load balancers, the shadow datapath forwards no traffic")
+		close(r.done)
+
+		return
+	}
+
+	log.Infoln("Registering to the load balancers")
+
+	go r.run()
+}
+
+// Stop the registration and deregister the UPF from the load balancers it is registered to,
+// starting with the PFCP-LB so that no new session is sent to the UPF.
+func (r *lbRegistrar) Stop() {
+	r.cancel()
+	<-r.done
+
+	state := r.getState()
+	if state == lbUnregistered {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lbDeregisterTimeout)
+	defer cancel()
+
+	if state >= lbPFCPLBRegistered {
+		if err := r.http.send(ctx, http.MethodDelete, pfcplb, "", r.pfcpInfo()); err != nil {
+			log.Warnln("Failed to deregister from the PFCP-LB:", err)
+		}
+	}
+
+	for _, lb := range []lbtype{enterlb, exitlb} {
+		if err := r.lbs.Deregister(ctx, lb, r.registerReq); err != nil {
+			log.Warnf("Failed to deregister from the %v LB: %v", lb, err)
+		}
+	}
+
+	r.setState(lbUnregistered)
+}
+
+// gatewayLearned records the gateway of the access or core interface sent by an LB. The
+// Enter-LB is the gateway of the access interface, the Exit-LB of the core interface.
+//
+// The LBs send their gateway again after each renewal of the registration, so only a new
+// gateway means the LB lost its rules. An LB restarted without missing any renewal asks for
+// the UEs with Resync, or pulls them from /v1/lb/ues.
+func (r *lbRegistrar) gatewayLearned(iface string, gwIP string) {
+	r.mu.Lock()
+
+	switch iface {
+	case "access":
+		if r.accessGateway != "" && r.accessGateway != gwIP {
+			r.resyncPending[enterlb] = true
+		}
+
+		r.accessGateway = gwIP
+	case "core":
+		if r.coreGateway != "" && r.coreGateway != gwIP {
+			r.resyncPending[exitlb] = true
+		}
+
+		r.coreGateway = gwIP
+	}
+
+	r.mu.Unlock()
+
+	r.notify()
+}
+
+// setServing records that the PFCP node serves, and can be sent sessions by the PFCP-LB.
+func (r *lbRegistrar) setServing() {
+	r.mu.Lock()
+	r.serving = true
+	r.mu.Unlock()
+
+	r.notify()
+}
+
+// drain deregisters the UPF from the PFCP-LB, so that it isn't sent new sessions, and keeps
+// it deregistered. The registration to the Enter-LB and Exit-LB is kept to serve the remaining
+// sessions.
+func (r *lbRegistrar) drain() {
+	r.mu.Lock()
+	r.draining = true
+	r.mu.Unlock()
+
+	r.notify()
+}
+
+func (r *lbRegistrar) isDraining() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.draining
+}
+
+func (r *lbRegistrar) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *lbRegistrar) getState() lbRegistrationState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.state
+}
+
+func (r *lbRegistrar) setState(state lbRegistrationState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if state != r.state {
+		log.Infof("LB registration state changed from %v to %v", r.state, state)
+	}
+
+	r.state = state
+
+	for i, name := range lbRegistrationStateNames {
+		value := 0.0
+		if lbRegistrationState(i) == state {
+			value = 1
+		}
+
+		getLBMetrics().state.WithLabelValues(name).Set(value)
+	}
+}
+
+func (r *lbRegistrar) status() lbRegistrationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return lbRegistrationStatus{
+		State:         r.state.String(),
+		AccessGateway: r.accessGateway,
+		CoreGateway:   r.coreGateway,
+		Draining:      r.draining,
+	}
+}
+
+func (r *lbRegistrar) run() {
+	defer close(r.done)
+
+	retry := time.NewTicker(r.retryInterval)
+	defer retry.Stop()
+
+	var keepalive, loadReport <-chan time.Time
+
+	if r.keepaliveInterval > 0 {
+		ticker := time.NewTicker(r.keepaliveInterval)
+		defer ticker.Stop()
+
+		keepalive = ticker.C
+	}
+
+	if r.upf.load != nil && r.upf.load.reportInterval > 0 {
+		ticker := time.NewTicker(r.upf.load.reportInterval)
+		defer ticker.Stop()
+
+		loadReport = ticker.C
+	}
+
+	for {
+		r.advance()
+		r.resync()
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.wake:
+		case <-retry.C:
+			if r.renewalFailures > 0 {
+				r.renew()
+			}
+		case <-keepalive:
+			r.renew()
+		case <-loadReport:
+			r.reportLoad()
+		}
+	}
+}
+
+// advance moves to the next states as long as their conditions are met. The failed
+// registrations are retried on the next call. A drained UPF goes back to lbGatewaysLearned.
+func (r *lbRegistrar) advance() {
+	for {
+		state := r.getState()
+
+		if state >= lbPFCPLBRegistered && r.isDraining() {
+			if err := r.http.send(r.ctx, http.MethodDelete, pfcplb, "", r.pfcpInfo()); err != nil {
+				log.Warnln("Failed to deregister from the PFCP-LB:", err)
+				return
+			}
+
+			r.setState(lbGatewaysLearned)
+
+			continue
+		}
+
+		switch state {
+		case lbUnregistered:
+			if err := r.registerToLBs(); err != nil {
+				log.Warnln("Failed to register to the load balancers:", err)
+				return
+			}
+
+			// the LBs may have lost the UEs if the registration was lost
+			r.requestResync(enterlb, exitlb)
+			r.setState(lbRegistered)
+		case lbRegistered:
+			r.mu.Lock()
+			learned := r.accessGateway != "" && r.coreGateway != ""
+			r.mu.Unlock()
+
+			if !learned {
+				return
+			}
+
+			r.setState(lbGatewaysLearned)
+		case lbGatewaysLearned:
+			if r.isDraining() {
+				return
+			}
+
+			if err := r.register(pfcplb); err != nil {
+				log.Warnln("Failed to register to the PFCP-LB:", err)
+				return
+			}
+
+			r.setState(lbPFCPLBRegistered)
+		case lbPFCPLBRegistered:
+			r.mu.Lock()
+			serving := r.serving
+			r.mu.Unlock()
+
+			if !serving {
+				return
+			}
+
+			r.setState(lbReady)
+		default:
+			return
+		}
+	}
+}
+
+// renew sends the registration to the Enter-LB and Exit-LB again. A failed renewal is retried,
+// and the registration kept, until lbMaxRenewalFailures consecutive failures. The registration
+// then starts over: the UPF deregisters from the PFCP-LB and forgets the gateways, as the LBs
+// send them again once the UPF is registered.
+func (r *lbRegistrar) renew() {
+	state := r.getState()
+	if state == lbUnregistered {
+		r.renewalFailures = 0
+		return
+	}
+
+	err := r.registerToLBs()
+	if err == nil {
+		r.renewalFailures = 0
+		return
+	}
+
+	r.renewalFailures++
+	if r.renewalFailures < lbMaxRenewalFailures {
+		log.Warnf("Failed to renew the registration to the load balancers (%d/%d): %v",
+			r.renewalFailures, lbMaxRenewalFailures, err)
+
+		return
+	}
+
+	log.Warnln("Lost the registration to the load balancers, registering again:", err)
+
+	if state >= lbPFCPLBRegistered {
+		if err := r.http.send(r.ctx, http.MethodDelete, pfcplb, "", r.pfcpInfo()); err != nil {
+			log.Warnln("Failed to deregister from the PFCP-LB:", err)
+			return
+		}
+	}
+
+	r.renewalFailures = 0
+
+	r.mu.Lock()
+	r.accessGateway = ""
+	r.coreGateway = ""
+	r.mu.Unlock()
+
+	r.setState(lbUnregistered)
+}
+
+// reportLoad sends the load of the UPF to the PFCP-LB, once registered to it.
+func (r *lbRegistrar) reportLoad() {
+	if r.getState() < lbPFCPLBRegistered {
+		return
+	}
+
+	if err := r.http.send(r.ctx, http.MethodPost, pfcplb, "/load", r.upf.load.report()); err != nil {
+		log.Warnln("Failed to report the load to the PFCP-LB:", err)
+		getLBMetrics().loadReports.WithLabelValues("failure").Inc()
+
+		return
+	}
+
+	getLBMetrics().loadReports.WithLabelValues("success").Inc()
+}
+
+func (r *lbRegistrar) registerToLBs() error {
+	for _, lb := range []lbtype{enterlb, exitlb} {
+		if err := r.register(lb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// register registers the UPF to lb. The PFCP-LB is sent the UPF info on its JSON API.
+func (r *lbRegistrar) register(lb lbtype) error {
+	var err error
+	if lb == pfcplb {
+		err = r.http.send(r.ctx, http.MethodPost, pfcplb, "/register", r.pfcpInfo())
+	} else {
+		err = r.lbs.Register(r.ctx, lb, r.registerReq)
+	}
+
+	if err != nil {
+		getLBMetrics().failures.WithLabelValues(lb.String()).Inc()
+		return err
+	}
+
+	getLBMetrics().registrations.WithLabelValues(lb.String()).Inc()
+
+	return nil
+}
+
+func (r *lbRegistrar) pfcpInfo() PfcpInfo {
+	return PfcpInfo{
+		Ip:  GetLocalIP(),
+		Upf: r.upf,
+	}
+}
+
+func (r *lbRegistrar) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		sendHTTPResp(http.StatusMethodNotAllowed, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(r.status()); err != nil {
+		log.Errorln("Failed to encode the LB registration status:", err)
+	}
+}
@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLBRegistrar returns a registrar retrying every 10ms, and the fakes of the LBs it registers to.
+func newTestLBRegistrar(t *testing.T, keepaliveInterval time.Duration) (*lbRegistrar, lbTestFakes) {
+	u := &upf{NodeID: "upf-0"}
+	lbs := startTestLBs(t, u)
+
+	r := newLBRegistrar(u, RegisterReq{GwIP: lbTestGwIP, CoreMac: "00:00:00:00:00:01", Hostname: "upf-0"},
+		keepaliveInterval)
+	r.retryInterval = 10 * time.Millisecond
+
+	return r, lbs
+}
+
+func requireLBState(t *testing.T, r *lbRegistrar, state lbRegistrationState) {
+	require.Eventually(t, func() bool { return r.getState() == state }, 5*time.Second, 10*time.Millisecond,
+		"expected state %v, got %v", state, r.getState())
+}
+
+func Test_lbRegistrar_states(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 0)
+
+	// the registration is retried until accepted
+	lbs.enter.FailNextRequests(2, http.StatusServiceUnavailable)
+	r.Start()
+
+	defer r.Stop()
+
+	requireLBState(t, r, lbRegistered)
+	require.Equal(t, float64(1), testutil.ToFloat64(getLBMetrics().state.WithLabelValues("lbs_registered")))
+	require.Equal(t, float64(0), testutil.ToFloat64(getLBMetrics().state.WithLabelValues("unregistered")))
+
+	expected := []fake_lb.RegisterRequest{{GwIP: lbTestGwIP, CoreMac: "00:00:00:00:00:01", Hostname: "upf-0"}}
+	require.Equal(t, expected, lbs.enter.GetRegistrations())
+	require.Equal(t, expected, lbs.exit.GetRegistrations())
+
+	// both gateways are needed to register to the PFCP-LB
+	r.gatewayLearned("access", "192.168.252.1")
+	require.Never(t, func() bool { return r.getState() != lbRegistered }, 100*time.Millisecond, 10*time.Millisecond)
+
+	lbs.pfcp.FailNextRequests(1, http.StatusInternalServerError)
+	r.gatewayLearned("core", lbTestGwIP)
+	requireLBState(t, r, lbPFCPLBRegistered)
+	require.Len(t, lbs.pfcp.GetRegistrations(), 1)
+	require.Equal(t, "upf-0", lbs.pfcp.GetRegistrations()[0].UPF["nodeid"])
+
+	r.setServing()
+	requireLBState(t, r, lbReady)
+}
+
+func Test_lbRegistrar_renew(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 50*time.Millisecond)
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	r.setServing()
+	r.Start()
+
+	defer r.Stop()
+
+	requireLBState(t, r, lbReady)
+
+	// the registration is renewed
+	require.Eventually(t, func() bool { return len(lbs.exit.GetRegistrations()) > 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, lbReady, r.getState())
+
+	// the Exit-LB restarts, the UPF deregisters from the PFCP-LB, registers again and waits
+	// for the gateways
+	lbs.exit.FailNextRequests(lbMaxRenewalFailures, http.StatusServiceUnavailable)
+	requireLBState(t, r, lbRegistered)
+	require.Equal(t, lbRegistrationStatus{State: "lbs_registered"}, r.status())
+	require.Len(t, lbs.pfcp.GetDeregistrations(), 1)
+
+	pfcpRegistrations := len(lbs.pfcp.GetRegistrations())
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	requireLBState(t, r, lbReady)
+	require.Len(t, lbs.pfcp.GetRegistrations(), pfcpRegistrations+1)
+}
+
+func Test_lbRegistrar_renewFailure(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 300*time.Millisecond)
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	r.setServing()
+	r.Start()
+
+	defer r.Stop()
+
+	requireLBState(t, r, lbReady)
+
+	failures := testutil.ToFloat64(getLBMetrics().failures.WithLabelValues("enter"))
+	registrations := len(lbs.enter.GetRegistrations())
+
+	lbs.enter.FailNextRequests(1, http.StatusServiceUnavailable)
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(getLBMetrics().failures.WithLabelValues("enter")) == failures+1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// the failed renewal is retried before the next keepalive, and the registration is kept
+	require.Eventually(t, func() bool { return len(lbs.enter.GetRegistrations()) > registrations },
+		150*time.Millisecond, 5*time.Millisecond)
+	require.Never(t, func() bool { return r.getState() != lbReady }, 300*time.Millisecond, 10*time.Millisecond)
+	require.Len(t, lbs.pfcp.GetRegistrations(), 1)
+	require.Empty(t, lbs.pfcp.GetDeregistrations())
+	require.Equal(t, lbRegistrationStatus{
+		State:         "ready",
+		AccessGateway: "192.168.252.1",
+		CoreGateway:   lbTestGwIP,
+	}, r.status())
+}
+
+func Test_lbRegistrar_Stop(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 0)
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	r.setServing()
+	r.Start()
+
+	requireLBState(t, r, lbReady)
+	r.Stop()
+
+	require.Equal(t, lbUnregistered, r.getState())
+	require.Len(t, lbs.pfcp.GetDeregistrations(), 1)
+	require.Equal(t, lbs.enter.GetRegistrations(), lbs.enter.GetDeregistrations())
+	require.Equal(t, lbs.exit.GetRegistrations(), lbs.exit.GetDeregistrations())
+
+	// nothing to deregister from
+	r, lbs = newTestLBRegistrar(t, 0)
+	lbs.enter.FailNextRequests(1000, http.StatusServiceUnavailable)
+	r.Start()
+	r.Stop()
+
+	require.Empty(t, lbs.enter.GetDeregistrations())
+	require.Empty(t, lbs.exit.GetDeregistrations())
+}
+
+func Test_lbRegistrar_ServeHTTP(t *testing.T) {
+	r, _ := newTestLBRegistrar(t, 0)
+	r.gatewayLearned("core", lbTestGwIP)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/lb/registration", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var status lbRegistrationStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	require.Equal(t, lbRegistrationStatus{State: "unregistered", CoreGateway: lbTestGwIP}, status)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/lb/registration", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func Test_lbRegistrar_drain(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 50*time.Millisecond)
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	r.setServing()
+	r.Start()
+
+	defer r.Stop()
+
+	requireLBState(t, r, lbReady)
+
+	// the UPF stays registered to the Enter-LB and Exit-LB only
+	lbs.pfcp.FailNextRequests(1, http.StatusServiceUnavailable)
+	r.drain()
+
+	requireLBState(t, r, lbGatewaysLearned)
+	require.Len(t, lbs.pfcp.GetDeregistrations(), 1)
+	require.True(t, r.status().Draining)
+
+	// including after losing the registration
+	lbs.exit.FailNextRequests(lbMaxRenewalFailures, http.StatusServiceUnavailable)
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	require.Never(t, func() bool { return r.getState() > lbGatewaysLearned }, 200*time.Millisecond, 10*time.Millisecond)
+	require.Len(t, lbs.pfcp.GetRegistrations(), 1)
+	require.Empty(t, lbs.enter.GetDeregistrations())
+}
+
+func Test_lbRegistrar_shadow(t *testing.T) {
+	u := &upf{NodeID: "upf-0", maxReqRetries: 1, lbDisabled: true}
+	lbs := startTestLBs(t, u)
+
+	pConn := newLBTestPFCPConn(t, u)
+	u.sessionHooks = newSessionHooks(u, SessionHooksConf{})
+	require.Empty(t, u.sessionHooks)
+
+	r := newLBRegistrar(u, RegisterReq{GwIP: lbTestGwIP, Hostname: "upf-0"}, 10*time.Millisecond)
+	r.retryInterval = 10 * time.Millisecond
+	r.Start()
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	r.setServing()
+
+	_, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, 0))
+	require.NoError(t, err)
+
+	// the load balancers must not send any traffic to the shadow UPF
+	require.Never(t, func() bool {
+		return len(lbs.enter.GetRegistrations())+len(lbs.exit.GetRegistrations())+
+			len(lbs.enter.GetRules())+len(lbs.exit.GetRules())+
+			len(lbs.pfcp.GetRegistrations())+len(lbs.pfcp.GetLoadReports()) > 0
+	}, 300*time.Millisecond, 10*time.Millisecond)
+	require.Equal(t, lbUnregistered, r.getState())
+
+	r.Stop()
+	require.Empty(t, lbs.enter.GetDeregistrations())
+	require.Empty(t, lbs.pfcp.GetDeregistrations())
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// lbResyncBatchSize is the maximum number of UE addresses pushed in a single request to /addrule.
+const lbResyncBatchSize = 256
+
+// requestResync schedules pushing the UE addresses of all the PFCP sessions to lbs.
+func (r *lbRegistrar) requestResync(lbs ...lbtype) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, lb := range lbs {
+		r.resyncPending[lb] = true
+	}
+}
+
+// resync pushes the UE addresses of all the PFCP sessions to the LBs that lost them, once the
+// UPF is registered. A failed resync is retried on the next call.
+func (r *lbRegistrar) resync() {
+	if r.getState() == lbUnregistered {
+		return
+	}
+
+	for _, lb := range []lbtype{enterlb, exitlb} {
+		r.mu.Lock()
+		pending := r.resyncPending[lb]
+		r.mu.Unlock()
+
+		if !pending {
+			continue
+		}
+
+		addresses := r.ueAddresses()
+
+		if err := r.pushUEAddresses(lb, addresses); err != nil {
+			log.Warnf("Failed to push the UE addresses to the %v LB: %v", lb, err)
+			getLBMetrics().resyncs.WithLabelValues(lb.String(), "failure").Inc()
+
+			continue
+		}
+
+		log.Infof("Pushed %d UE addresses to the %v LB", len(addresses), lb)
+		getLBMetrics().resyncs.WithLabelValues(lb.String(), "success").Inc()
+
+		r.mu.Lock()
+		delete(r.resyncPending, lb)
+		r.mu.Unlock()
+	}
+}
+
+// pushUEAddresses sends addresses to lb, in batches of resyncBatchSize.
+func (r *lbRegistrar) pushUEAddresses(lb lbtype, addresses []string) error {
+	for start := 0; start < len(addresses); start += r.resyncBatchSize {
+		end := start + r.resyncBatchSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		ruleReq := RuleReq{
+			GwIP: r.registerReq.GwIP,
+			Ip:   addresses[start:end],
+		}
+
+		if err := r.lbs.AddRules(r.ctx, lb, ruleReq); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ueAddresses returns the UE addresses of all the PFCP sessions, sorted.
+func (r *lbRegistrar) ueAddresses() []string {
+	unique := make(map[uint32]struct{})
+
+	for _, session := range r.upf.allSessions() {
+		for _, p := range session.pdrs {
+			// uplink PDRs don't match on the UE address
+			if p.ueAddress != 0 {
+				unique[p.ueAddress] = struct{}{}
+			}
+		}
+	}
+
+	sorted := make([]uint32, 0, len(unique))
+	for a := range unique {
+		sorted = append(sorted, a)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	addresses := make([]string, 0, len(sorted))
+	for _, a := range sorted {
+		addresses = append(addresses, int2ip(a).String())
+	}
+
+	return addresses
+}
+
+// serveUEs returns the UE addresses routed to the UPF, in the format of the rules pushed to
+// /addrule, so that the LBs can pull the complete list after a restart.
+func (r *lbRegistrar) serveUEs(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		sendHTTPResp(http.StatusMethodNotAllowed, w)
+		return
+	}
+
+	ruleReq := RuleReq{
+		GwIP: r.registerReq.GwIP,
+		Ip:   r.ueAddresses(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(ruleReq); err != nil {
+		log.Errorln("Failed to encode the UE addresses:", err)
+	}
+}
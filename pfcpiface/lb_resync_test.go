@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// setTestUEs makes the sessions of the UPF of r hold a downlink PDR for each of ueAddresses,
+// and an uplink PDR.
+func setTestUEs(r *lbRegistrar, ueAddresses ...string) {
+	var sessions []PFCPSession
+
+	for i, a := range ueAddresses {
+		sessions = append(sessions, PFCPSession{
+			localSEID: uint64(i + 1),
+			PacketForwardingRules: PacketForwardingRules{
+				pdrs: []pdr{
+					{pdrID: 1, srcIface: access},
+					{pdrID: 2, srcIface: core, ueAddress: ip2int(net.ParseIP(a))},
+				},
+			},
+		})
+	}
+
+	r.upf.setSessionsSource(func() []PFCPSession { return sessions })
+}
+
+func requireRules(t *testing.T, lb *fake_lb.FakeLB, expected ...[]string) {
+	var rules []fake_lb.RuleRequest
+	for _, ips := range expected {
+		rules = append(rules, fake_lb.RuleRequest{GwIP: lbTestGwIP, IPs: ips})
+	}
+
+	require.Eventually(t, func() bool { return len(lb.GetRules()) >= len(rules) }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, rules, lb.GetRules())
+}
+
+func Test_lbRegistrar_resyncGatewayLearned(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 0)
+	r.resyncBatchSize = 2
+
+	// the same UE may have several sessions, e.g. one per slice
+	setTestUEs(r, "10.250.0.10", "10.250.0.2", "10.250.0.1", "10.250.0.2")
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+	r.setServing()
+	r.Start()
+
+	defer r.Stop()
+
+	requireLBState(t, r, lbReady)
+
+	// the UEs are pushed once registered, in batches
+	requireRules(t, lbs.enter, []string{"10.250.0.1", "10.250.0.2"}, []string{"10.250.0.10"})
+	requireRules(t, lbs.exit, []string{"10.250.0.1", "10.250.0.2"}, []string{"10.250.0.10"})
+
+	// the Enter-LB is replaced by one with another gateway, the failed push is retried
+	lbs.enter.Reset()
+	lbs.exit.Reset()
+	lbs.enter.FailNextRequests(1, http.StatusServiceUnavailable)
+
+	failures := testutil.ToFloat64(getLBMetrics().resyncs.WithLabelValues("enter", "failure"))
+
+	setTestUEs(r, "10.250.0.3")
+	r.gatewayLearned("access", "192.168.252.2")
+	r.notify()
+
+	requireRules(t, lbs.enter, []string{"10.250.0.3"})
+	require.Equal(t, failures+1, testutil.ToFloat64(getLBMetrics().resyncs.WithLabelValues("enter", "failure")))
+	require.Empty(t, lbs.exit.GetRules())
+}
+
+func Test_lbRegistrar_resyncRenewal(t *testing.T) {
+	recordCommands(t)
+
+	r, lbs := newTestLBRegistrar(t, 20*time.Millisecond)
+	r.upf.AccessIP = net.ParseIP("192.168.252.3")
+	r.upf.CoreIP = net.ParseIP("192.168.250.3")
+	setTestUEs(r, "10.250.0.1")
+
+	mux := http.NewServeMux()
+	setupConfigHandler(mux, r.upf, r)
+
+	upfServer := httptest.NewServer(mux)
+	defer upfServer.Close()
+
+	// the LBs announce their gateway after each registration, including the renewals
+	lbs.enter.SetGateway(upfServer.URL, &fake_lb.GatewayRegistration{GwIP: "192.168.252.1", GwMac: "00:00:00:00:00:02"})
+	lbs.exit.SetGateway(upfServer.URL, &fake_lb.GatewayRegistration{GwIP: lbTestGwIP, GwMac: "00:00:00:00:00:03"})
+
+	r.setServing()
+	r.Start()
+
+	defer r.Stop()
+
+	requireLBState(t, r, lbReady)
+	requireRules(t, lbs.enter, []string{"10.250.0.1"})
+	requireRules(t, lbs.exit, []string{"10.250.0.1"})
+
+	// the LBs stay up: the renewals don't push the UEs again
+	registrations := len(lbs.exit.GetRegistrations())
+
+	require.Never(t, func() bool {
+		return len(lbs.enter.GetRules())+len(lbs.exit.GetRules()) > 2
+	}, 300*time.Millisecond, 10*time.Millisecond)
+	require.Greater(t, len(lbs.exit.GetRegistrations()), registrations+5)
+	require.Equal(t, lbReady, r.getState())
+}
+
+func Test_lbRegistrar_resyncRegistrationLost(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 50*time.Millisecond)
+
+	r.Start()
+
+	defer r.Stop()
+
+	requireLBState(t, r, lbRegistered)
+
+	// no UEs to push
+	require.Never(t, func() bool { return len(lbs.enter.GetRules()) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	// the Enter-LB restarts, both LBs get the UEs once registered again
+	setTestUEs(r, "10.250.0.1")
+
+	lbs.enter.FailNextRequests(lbMaxRenewalFailures, http.StatusServiceUnavailable)
+
+	requireRules(t, lbs.enter, []string{"10.250.0.1"})
+	requireRules(t, lbs.exit, []string{"10.250.0.1"})
+}
+
+func Test_lbRegistrar_serveUEs(t *testing.T) {
+	r, _ := newTestLBRegistrar(t, 0)
+
+	rec := httptest.NewRecorder()
+	r.serveUEs(rec, httptest.NewRequest(http.MethodGet, "/v1/lb/ues", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"gwip": "192.168.250.1", "ip": []}`, rec.Body.String())
+
+	setTestUEs(r, "10.250.0.2", "10.250.0.1")
+
+	rec = httptest.NewRecorder()
+	r.serveUEs(rec, httptest.NewRequest(http.MethodGet, "/v1/lb/ues", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var rules fake_lb.RuleRequest
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&rules))
+	require.Equal(t, fake_lb.RuleRequest{GwIP: lbTestGwIP, IPs: []string{"10.250.0.1", "10.250.0.2"}}, rules)
+
+	rec = httptest.NewRecorder()
+	r.serveUEs(rec, httptest.NewRequest(http.MethodPost, "/v1/lb/ues", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
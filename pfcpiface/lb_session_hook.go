@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// lbMigrationPriority is the message priority set by the PFCP-LB on the messages moving a
+	// session to another UPF. The UE address is then pushed on modification, once the UPF can
+	// serve the UE, instead of on establishment.
+	lbMigrationPriority = 123
+	// lbMigrationPushDelay delays pushing the UE address of a migrated session.
+	lbMigrationPushDelay = 2 * time.Second
+)
+
+// lbSessionHook pushes the UE addresses of the sessions to the Enter-LB and Exit-LB, so that
+// they route the traffic of the UEs to the UPF. An address is pushed once, until a session of
+// the UE is deleted.
+type lbSessionHook struct {
+	upf    *upf
+	client lbClient
+
+	// mu guards sent
+	mu   sync.Mutex
+	sent map[uint32]struct{}
+}
+
+func newLBSessionHook(upf *upf) *lbSessionHook {
+	return &lbSessionHook{
+		upf:    upf,
+		client: upf.lbs(),
+		sent:   make(map[uint32]struct{}),
+	}
+}
+
+func (h *lbSessionHook) OnSessionCreated(pConn *PFCPConn, session PFCPSession, priority uint8) {
+	if priority != lbMigrationPriority {
+		h.push(session, 0)
+	}
+}
+
+func (h *lbSessionHook) OnSessionModified(pConn *PFCPConn, old, new PFCPSession, priority uint8) {
+	if priority == lbMigrationPriority {
+		h.push(new, lbMigrationPushDelay)
+	}
+}
+
+// OnSessionDeleted stops routing the UE addresses of session to the UPF, where the API of the LBs
+// allows it.
+func (h *lbSessionHook) OnSessionDeleted(pConn *PFCPConn, session PFCPSession) {
+	var addresses []uint32
+
+	h.mu.Lock()
+
+	for _, p := range session.pdrs {
+		if _, ok := h.sent[p.ueAddress]; ok {
+			addresses = append(addresses, p.ueAddress)
+			delete(h.sent, p.ueAddress)
+		}
+	}
+
+	h.mu.Unlock()
+
+	if len(addresses) == 0 {
+		return
+	}
+
+	go func() {
+		ruleReq := h.ruleReq(addresses)
+
+		for _, lb := range []lbtype{enterlb, exitlb} {
+			if err := h.client.DeleteRules(context.Background(), lb, ruleReq); err != nil {
+				log.Warnf("Failed to delete the UE addresses from the %v LB: %v", lb, err)
+			}
+		}
+	}()
+}
+
+// push sends the UE addresses of session not pushed yet to the LBs after delay, in the background.
+func (h *lbSessionHook) push(session PFCPSession, delay time.Duration) {
+	var addresses []uint32
+
+	h.mu.Lock()
+
+	for _, p := range session.pdrs {
+		// uplink PDRs don't match on the UE address
+		if p.ueAddress == 0 {
+			continue
+		}
+
+		if _, ok := h.sent[p.ueAddress]; !ok {
+			addresses = append(addresses, p.ueAddress)
+			h.sent[p.ueAddress] = struct{}{}
+		}
+	}
+
+	h.mu.Unlock()
+
+	if len(addresses) == 0 {
+		return
+	}
+
+	go func() {
+		time.Sleep(delay)
+		h.PushPDRInfo(addresses)
+	}()
+}
+
+// PushPDRInfo sends addresses to the Enter-LB and Exit-LB.
+func (h *lbSessionHook) PushPDRInfo(addresses []uint32) {
+	ruleReq := h.ruleReq(addresses)
+
+	for _, lb := range []lbtype{enterlb, exitlb} {
+		h.sendToLBer(lb, ruleReq)
+	}
+}
+
+func (h *lbSessionHook) ruleReq(addresses []uint32) RuleReq {
+	addrStr := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		addrStr = append(addrStr, int2ip(a).String())
+	}
+
+	return RuleReq{
+		GwIP: h.upf.gwIP,
+		Ip:   addrStr,
+	}
+}
+
+// sendToLBer sends ruleReq to lb until it is accepted, at most maxReqRetries times.
+func (h *lbSessionHook) sendToLBer(lb lbtype, ruleReq RuleReq) {
+	for retries := uint8(0); retries < h.upf.maxReqRetries; retries++ {
+		err := h.client.AddRules(context.Background(), lb, ruleReq)
+		if err == nil {
+			return
+		}
+
+		log.Errorf("Failed to push the UE addresses to the %v LB: %v", lb, err)
+
+		time.Sleep(1 * time.Second)
+	}
+
+	log.Warnf("Gave up pushing the UE addresses to the %v LB", lb)
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func Test_lbSessionHook_PushPDRInfo(t *testing.T) {
+	u := &upf{maxReqRetries: 2}
+	lbs := startTestLBs(t, u)
+
+	u.gwIP = lbTestGwIP
+	h := newLBSessionHook(u)
+
+	lbs.exit.FailNextRequests(1, http.StatusInternalServerError)
+	h.PushPDRInfo([]uint32{ip2int(net.ParseIP(lbTestUEAddress)), ip2int(net.ParseIP("10.250.0.2"))})
+
+	expected := []string{lbTestUEAddress, "10.250.0.2"}
+	require.Equal(t, expected, lbs.enter.GetUEAddresses(lbTestGwIP))
+	require.Equal(t, expected, lbs.exit.GetUEAddresses(lbTestGwIP))
+
+	// gives up after maxReqRetries attempts
+	lbs.enter.FailNextRequests(2, http.StatusInternalServerError)
+	h.PushPDRInfo([]uint32{ip2int(net.ParseIP("10.250.0.3"))})
+	require.Len(t, lbs.enter.GetRules(), 1)
+	require.Len(t, lbs.exit.GetRules(), 2)
+}
+
+func Test_lbSessionHook_created(t *testing.T) {
+	u := &upf{maxReqRetries: 1}
+	lbs := startTestLBs(t, u)
+
+	pConn := newLBTestPFCPConn(t, u)
+	u.sessionHooks = sessionHooks{newLBSessionHook(u)}
+
+	_, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, 0))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(lbs.enter.GetRules()) == 1 && len(lbs.exit.GetRules()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{lbTestUEAddress}, lbs.exit.GetUEAddresses(lbTestGwIP))
+
+	// a UE address is only pushed once
+	_, err = pConn.handleSessionModificationRequest(
+		message.NewSessionModificationRequest(0, 0, 1, 2, migrationPriority))
+	require.NoError(t, err)
+
+	time.Sleep(3 * time.Second)
+	require.Len(t, lbs.enter.GetRules(), 1)
+
+	// until its session is deleted
+	_, err = pConn.handleSessionDeletionRequest(message.NewSessionDeletionRequest(0, 0, 1, 3, 0))
+	require.NoError(t, err)
+
+	_, err = pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(2, 0))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(lbs.enter.GetRules()) == 2 && len(lbs.exit.GetRules()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_lbSessionHook_migrationPriority(t *testing.T) {
+	u := &upf{maxReqRetries: 1}
+	lbs := startTestLBs(t, u)
+
+	pConn := newLBTestPFCPConn(t, u)
+	u.sessionHooks = sessionHooks{newLBSessionHook(u)}
+
+	// the UE is still served by the previous UPF until the session is modified
+	_, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, migrationPriority))
+	require.NoError(t, err)
+
+	_, err = pConn.handleSessionModificationRequest(message.NewSessionModificationRequest(0, 0, 1, 2, 0))
+	require.NoError(t, err)
+
+	time.Sleep(3 * time.Second)
+	require.Empty(t, lbs.enter.GetRules())
+	require.Empty(t, lbs.exit.GetRules())
+
+	// the UE address is pushed 2 seconds after the modification with the migration priority
+	_, err = pConn.handleSessionModificationRequest(
+		message.NewSessionModificationRequest(0, 0, 1, 3, migrationPriority))
+	require.NoError(t, err)
+
+	require.Never(t, func() bool { return len(lbs.enter.GetRules()) > 0 }, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return len(lbs.enter.GetRules()) == 1 && len(lbs.exit.GetRules()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{lbTestUEAddress}, lbs.enter.GetUEAddresses(lbTestGwIP))
+}
@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// loadCacheDuration bounds how often the load is computed, as it goes through all the sessions.
+const loadCacheDuration = time.Second
+
+// ifaceThroughput is the throughput of a datapath interface, in bits per second.
+type ifaceThroughput struct {
+	RxBps float64 `json:"rx_bps"`
+	TxBps float64 `json:"tx_bps"`
+}
+
+// loadReport is the load of the UPF, periodically sent to the PFCP-LB.
+type loadReport struct {
+	NodeID      string `json:"nodeid"`
+	Hostname    string `json:"hostname"`
+	IP          string `json:"ip"`
+	Load        uint8  `json:"load"`
+	Sessions    int    `json:"sessions"`
+	MaxSessions uint32 `json:"max_sessions,omitempty"`
+	UEPoolUsed  int    `json:"ue_pool_used"`
+	UEPoolSize  int    `json:"ue_pool_size"`
+	// Throughput is indexed by interface, e.g. access and core.
+	Throughput map[string]ifaceThroughput `json:"throughput"`
+	// PFCPLatencyMs is the average time to handle a PFCP message since the previous report.
+	PFCPLatencyMs float64 `json:"pfcp_latency_ms"`
+}
+
+// controlInformation is the last Load or Overload Control Information advertised. The
+// sequence number is incremented whenever the metric changes, as the SMF ignores the IEs
+// with a sequence number it already received.
+type controlInformation struct {
+	sequence uint32
+	metric   uint8
+}
+
+func (c *controlInformation) update(metric uint8) uint32 {
+	if c.sequence == 0 || c.metric != metric {
+		c.sequence++
+		c.metric = metric
+	}
+
+	return c.sequence
+}
+
+// portStatsCollector collects the port stats of the datapath only.
+type portStatsCollector struct {
+	uc *upfCollector
+}
+
+func (c portStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.uc.packets
+	ch <- c.uc.bytes
+	ch <- c.uc.dropped
+}
+
+func (c portStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.uc.portStats(ch)
+}
+
+// loadTracker computes the load of the UPF, for the reports to the PFCP-LB and the Load and
+// Overload Control Information IEs of the session responses. A nil loadTracker reports no
+// load, e.g. in tests.
+type loadTracker struct {
+	upf            *upf
+	conf           LoadControlConf
+	validity       time.Duration
+	reportInterval time.Duration
+	portStats      *prometheus.Registry
+
+	// mu guards the fields below
+	mu           sync.Mutex
+	load         uint8
+	loadAt       time.Time
+	latencySum   time.Duration
+	latencyCount int
+	bytes        map[string]map[string]float64
+	bytesAt      time.Time
+	lci          controlInformation
+	oci          controlInformation
+}
+
+func newLoadTracker(upf *upf, conf *Conf) *loadTracker {
+	t := &loadTracker{
+		upf:            upf,
+		conf:           conf.LoadControl,
+		validity:       overloadValidityDefault,
+		reportInterval: lbLoadReportIntervalDefault,
+		portStats:      prometheus.NewRegistry(),
+	}
+
+	if conf.LoadControl.OverloadValidity != "" {
+		t.validity, _ = time.ParseDuration(conf.LoadControl.OverloadValidity)
+	}
+
+	if conf.LoadBalancers.LoadReportInterval != "" {
+		t.reportInterval, _ = time.ParseDuration(conf.LoadBalancers.LoadReportInterval)
+	}
+
+	t.portStats.MustRegister(portStatsCollector{uc: newUpfCollector(upf)})
+
+	return t
+}
+
+// observeLatency records the time taken to handle a PFCP message.
+func (t *loadTracker) observeLatency(latency time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.latencySum += latency
+	t.latencyCount++
+}
+
+// usage returns the number of sessions and the usage of the UE IP pool.
+func (t *loadTracker) usage() (sessions int, poolUsed int, poolSize int) {
+	sessions = len(t.upf.allSessions())
+
+	if t.upf.ippool != nil {
+		poolUsed, poolSize = t.upf.ippool.Usage()
+	}
+
+	return sessions, poolUsed, poolSize
+}
+
+func (t *loadTracker) computeLoad(sessions, poolUsed, poolSize int) uint8 {
+	var load float64
+
+	if t.conf.MaxSessions > 0 {
+		load = float64(sessions) * 100 / float64(t.conf.MaxSessions)
+	}
+
+	if poolSize > 0 {
+		if poolLoad := float64(poolUsed) * 100 / float64(poolSize); poolLoad > load {
+			load = poolLoad
+		}
+	}
+
+	if load > 100 {
+		return 100
+	}
+
+	return uint8(load)
+}
+
+// getLoad returns the load of the UPF in percent, computed at most every loadCacheDuration.
+func (t *loadTracker) getLoad() uint8 {
+	t.mu.Lock()
+	fresh := time.Since(t.loadAt) < loadCacheDuration
+	load := t.load
+	t.mu.Unlock()
+
+	if fresh {
+		return load
+	}
+
+	load = t.computeLoad(t.usage())
+
+	t.mu.Lock()
+	t.load, t.loadAt = load, time.Now()
+	t.mu.Unlock()
+
+	return load
+}
+
+// controlInformation returns the Load and Overload Control Information IEs to add to the
+// session responses, nil below their thresholds.
+func (t *loadTracker) controlInformation() (lci *ie.IE, oci *ie.IE) {
+	if t == nil || (t.conf.LoadThreshold == 0 && t.conf.OverloadThreshold == 0) {
+		return nil, nil
+	}
+
+	load := t.getLoad()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conf.LoadThreshold > 0 && load >= t.conf.LoadThreshold {
+		lci = ie.NewLoadControlInformation(
+			ie.NewSequenceNumber(t.lci.update(load)),
+			ie.NewMetric(load),
+		)
+	}
+
+	if t.conf.OverloadThreshold > 0 && load >= t.conf.OverloadThreshold {
+		// the share of the new sessions the SMF should send to other UPFs
+		reduction := uint8(100)
+		if t.conf.OverloadThreshold < 100 {
+			reduction = uint8(1 + uint(load-t.conf.OverloadThreshold)*99/uint(100-t.conf.OverloadThreshold))
+		}
+
+		oci = ie.NewOverloadControlInformation(
+			ie.NewSequenceNumber(t.oci.update(reduction)),
+			ie.NewMetric(reduction),
+			ie.NewTimer(t.validity),
+		)
+	}
+
+	return lci, oci
+}
+
+// report returns the load of the UPF. The throughput and the PFCP latency are averaged since
+// the previous report.
+func (t *loadTracker) report() loadReport {
+	sessions, poolUsed, poolSize := t.usage()
+	load := t.computeLoad(sessions, poolUsed, poolSize)
+
+	r := loadReport{
+		NodeID:      t.upf.NodeID,
+		Hostname:    t.upf.Hostname,
+		IP:          GetLocalIP(),
+		Load:        load,
+		Sessions:    sessions,
+		MaxSessions: t.conf.MaxSessions,
+		UEPoolUsed:  poolUsed,
+		UEPoolSize:  poolSize,
+		Throughput:  make(map[string]ifaceThroughput),
+	}
+
+	bytes := t.portBytes()
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.load, t.loadAt = load, now
+
+	if t.latencyCount > 0 {
+		r.PFCPLatencyMs = float64(t.latencySum) / float64(t.latencyCount) / float64(time.Millisecond)
+	}
+
+	t.latencySum, t.latencyCount = 0, 0
+
+	if elapsed := now.Sub(t.bytesAt).Seconds(); !t.bytesAt.IsZero() && elapsed > 0 {
+		for iface, dirs := range bytes {
+			previous := t.bytes[iface]
+			r.Throughput[iface] = ifaceThroughput{
+				RxBps: bitRate(dirs["rx"], previous["rx"], elapsed),
+				TxBps: bitRate(dirs["tx"], previous["tx"], elapsed),
+			}
+		}
+	}
+
+	t.bytes, t.bytesAt = bytes, now
+
+	return r
+}
+
+// bitRate returns the rate between two byte counters. A counter going back, e.g. after a
+// datapath restart, gives no rate.
+func bitRate(bytes, previous float64, elapsed float64) float64 {
+	if bytes < previous {
+		return 0
+	}
+
+	return (bytes - previous) * 8 / elapsed
+}
+
+// portBytes returns the byte counters of the datapath ports, by interface and direction.
+func (t *loadTracker) portBytes() map[string]map[string]float64 {
+	bytes := make(map[string]map[string]float64)
+
+	families, err := t.portStats.Gather()
+	if err != nil {
+		log.Warnln("Failed to gather the port stats:", err)
+		return bytes
+	}
+
+	for _, family := range families {
+		if family.GetName() != "upf_bytes_count" {
+			continue
+		}
+
+		for _, m := range family.GetMetric() {
+			var iface, dir string
+
+			for _, label := range m.GetLabel() {
+				switch label.GetName() {
+				case "iface":
+					iface = label.GetValue()
+				case "dir":
+					dir = label.GetValue()
+				}
+			}
+
+			if bytes[iface] == nil {
+				bytes[iface] = make(map[string]float64)
+			}
+
+			bytes[iface][dir] += m.GetCounter().GetValue()
+		}
+	}
+
+	return bytes
+}
+
+// controlInformation returns the Load and Overload Control Information IEs to add to the
+// session responses sent on pConn, if supported by its CP function.
+func (pConn *PFCPConn) controlInformation() (lci *ie.IE, oci *ie.IE) {
+	lci, oci = pConn.upf.load.controlInformation()
+
+	if !pConn.cpFeatures.load {
+		lci = nil
+	}
+
+	if !pConn.cpFeatures.overload {
+		oci = nil
+	}
+
+	return lci, oci
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// portStatsDatapath is a shadow datapath exporting the given byte counters.
+type portStatsDatapath struct {
+	*shadow
+
+	mu    sync.Mutex
+	bytes map[string]uint64
+}
+
+func (d *portStatsDatapath) setBytes(iface, dir string, bytes uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.bytes[iface+"/"+dir] = bytes
+}
+
+func (d *portStatsDatapath) PortStats(uc *upfCollector, ch chan<- prometheus.Metric) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, iface := range []string{"Access", "Core"} {
+		for _, dir := range []string{"rx", "tx"} {
+			ch <- prometheus.MustNewConstMetric(uc.bytes, prometheus.CounterValue,
+				float64(d.bytes[iface+"/"+dir]), iface, dir)
+		}
+	}
+}
+
+func newTestSessions(n int) []PFCPSession {
+	sessions := make([]PFCPSession, n)
+	for i := range sessions {
+		sessions[i].localSEID = uint64(i + 1)
+	}
+
+	return sessions
+}
+
+func requireControlInformation(t *testing.T, info *ie.IE, sequence uint32, metric uint8) {
+	require.NotNil(t, info)
+
+	s, err := info.SequenceNumber()
+	require.NoError(t, err)
+	require.Equal(t, sequence, s)
+
+	m, err := info.Metric()
+	require.NoError(t, err)
+	require.Equal(t, metric, m)
+}
+
+func Test_loadTracker_controlInformation(t *testing.T) {
+	u := &upf{}
+	sessions := newTestSessions(0)
+	u.setSessionsSource(func() []PFCPSession { return sessions })
+
+	var err error
+	u.ippool, err = NewIPPool("10.250.0.0/29")
+	require.NoError(t, err)
+
+	l := newLoadTracker(u, &Conf{LoadControl: LoadControlConf{
+		MaxSessions:       10,
+		LoadThreshold:     20,
+		OverloadThreshold: 80,
+		OverloadValidity:  "1m",
+	}})
+
+	lci, oci := l.controlInformation()
+	require.Nil(t, lci)
+	require.Nil(t, oci)
+
+	// the UE IP pool holds 6 addresses: 1 used is a load of 16%, 2 used 33%
+	_, err = u.ippool.LookupOrAllocIP(1)
+	require.NoError(t, err)
+
+	l.loadAt = time.Time{}
+	lci, oci = l.controlInformation()
+	require.Nil(t, lci)
+	require.Nil(t, oci)
+
+	_, err = u.ippool.LookupOrAllocIP(2)
+	require.NoError(t, err)
+
+	l.loadAt = time.Time{}
+	lci, oci = l.controlInformation()
+	requireControlInformation(t, lci, 1, 33)
+	require.Nil(t, oci)
+
+	// the sequence number only changes with the load
+	lci, _ = l.controlInformation()
+	requireControlInformation(t, lci, 1, 33)
+
+	// 9 sessions out of 10
+	sessions = newTestSessions(9)
+	l.loadAt = time.Time{}
+
+	lci, oci = l.controlInformation()
+	requireControlInformation(t, lci, 2, 90)
+	requireControlInformation(t, oci, 1, 50)
+
+	validity, err := oci.Timer()
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, validity)
+
+	// the load is cached
+	sessions = newTestSessions(20)
+	lci, _ = l.controlInformation()
+	requireControlInformation(t, lci, 2, 90)
+
+	l.loadAt = time.Time{}
+	lci, oci = l.controlInformation()
+	requireControlInformation(t, lci, 3, 100)
+	requireControlInformation(t, oci, 2, 100)
+}
+
+func Test_loadTracker_report(t *testing.T) {
+	s := &shadow{}
+	s.SetUpfInfo(nil, nil)
+	dp := &portStatsDatapath{shadow: s, bytes: make(map[string]uint64)}
+
+	u := &upf{NodeID: "upf-0", datapath: dp}
+	u.setSessionsSource(func() []PFCPSession { return newTestSessions(5) })
+
+	l := newLoadTracker(u, &Conf{LoadControl: LoadControlConf{MaxSessions: 20}})
+
+	l.observeLatency(2 * time.Millisecond)
+	l.observeLatency(4 * time.Millisecond)
+
+	report := l.report()
+	require.Equal(t, "upf-0", report.NodeID)
+	require.Equal(t, uint8(25), report.Load)
+	require.Equal(t, 5, report.Sessions)
+	require.Equal(t, uint32(20), report.MaxSessions)
+	require.InDelta(t, 3, report.PFCPLatencyMs, 0.001)
+	// the throughput is known from the second report
+	require.Empty(t, report.Throughput)
+
+	dp.setBytes("Access", "rx", 1000000)
+	dp.setBytes("Core", "tx", 2000000)
+	time.Sleep(100 * time.Millisecond)
+
+	report = l.report()
+	require.Zero(t, report.PFCPLatencyMs)
+	require.Len(t, report.Throughput, 2)
+	require.Greater(t, report.Throughput["Access"].RxBps, float64(0))
+	require.Zero(t, report.Throughput["Access"].TxBps)
+	require.InDelta(t, 2*report.Throughput["Access"].RxBps, report.Throughput["Core"].TxBps, 1)
+
+	// the counters were reset
+	dp.setBytes("Access", "rx", 0)
+
+	report = l.report()
+	require.Zero(t, report.Throughput["Access"].RxBps)
+}
+
+func Test_lbRegistrar_reportLoad(t *testing.T) {
+	r, lbs := newTestLBRegistrar(t, 0)
+	r.upf.datapath = &shadow{}
+	r.upf.setSessionsSource(func() []PFCPSession { return newTestSessions(3) })
+	r.upf.load = newLoadTracker(r.upf, &Conf{
+		LoadBalancers: LBConf{LoadReportInterval: "20ms"},
+		LoadControl:   LoadControlConf{MaxSessions: 4},
+	})
+
+	r.Start()
+
+	defer r.Stop()
+
+	// the load is only reported once registered to the PFCP-LB
+	requireLBState(t, r, lbRegistered)
+	require.Never(t, func() bool { return len(lbs.pfcp.GetLoadReports()) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	r.gatewayLearned("access", "192.168.252.1")
+	r.gatewayLearned("core", lbTestGwIP)
+
+	require.Eventually(t, func() bool { return len(lbs.pfcp.GetLoadReports()) > 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, lbs.pfcp.GetRegistrations(), 1)
+
+	report := lbs.pfcp.GetLoadReports()[0]
+	require.Equal(t, "upf-0", report.NodeID)
+	require.Equal(t, uint8(75), report.Load)
+	require.Equal(t, 3, report.Sessions)
+}
+
+func Test_handleSessionEstablishmentRequest_loadControl(t *testing.T) {
+	u := &upf{}
+	pConn := newLBTestPFCPConn(t, u)
+	u.setSessionsSource(pConn.store.GetAllSessions)
+	u.load = newLoadTracker(u, &Conf{LoadControl: LoadControlConf{
+		MaxSessions:       2,
+		LoadThreshold:     1,
+		OverloadThreshold: 50,
+	}})
+
+	// not supported by the SMF. The migration priority avoids pushing the UE address.
+	resp, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, migrationPriority))
+	require.NoError(t, err)
+
+	seres, ok := resp.(*message.SessionEstablishmentResponse)
+	require.True(t, ok)
+	require.Nil(t, seres.LoadControlInformation)
+	require.Nil(t, seres.OverloadControlInformation)
+
+	pConn.cpFeatures = newCPFeatures(ie.NewCPFunctionFeatures(0x03))
+	u.load.loadAt = time.Time{}
+
+	resp, err = pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(2, migrationPriority))
+	require.NoError(t, err)
+
+	seres, ok = resp.(*message.SessionEstablishmentResponse)
+	require.True(t, ok)
+	requireControlInformation(t, seres.LoadControlInformation, 2, 100)
+	requireControlInformation(t, seres.OverloadControlInformation, 2, 100)
+}
@@ -4,17 +4,12 @@
 package pfcpiface
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"os/exec"
 	"strings"
 	"sync"
-	"time"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/wmnsk/go-pfcp/message"
 )
 
 type InMemoryStore struct {
@@ -61,6 +56,7 @@ type lbtype int
 const (
 	enterlb lbtype = 0
 	exitlb  lbtype = 1
+	pfcplb  lbtype = 2
 )
 
 func getExitLbInt() string {
@@ -89,129 +85,17 @@ func parseGatewayIP(output string) string {
 	return ""
 }
 
-func (pConn *PFCPConn) PushPDRInfo(addresses []uint32) {
-	if len(addresses) == 0 {
-		return
-	}
-	gatewayIP := pConn.gwIp
-	addrStr := make([]string, 0)
-	//teidStr := make([]string, 0)
-	for _, i := range addresses {
-		ipStr := int2ip(i)
-		addrStr = append(addrStr, ipStr.String())
-	}
-
-	rulereq := RuleReq{
-		GwIP: gatewayIP,
-		Ip:   addrStr,
-	}
-	ruleReqJson, err := json.Marshal(rulereq)
-	if err != nil {
-		log.Errorf("err while trying to marshal ruleReq: %s\n", err)
-	}
-
-	enterRequestURL := "http://enterlb:8080/addrule"
-
-	exitRequestURL := "http://exitlb:8080/addrule"
-
-	enterJsonBody := []byte(ruleReqJson)
-	exitJsonBody := []byte(ruleReqJson)
-
-	enterBodyReader := bytes.NewReader(enterJsonBody)
-	exitBodyReader := bytes.NewReader(exitJsonBody)
-
-	enterReq, err := http.NewRequest(http.MethodPost, enterRequestURL, enterBodyReader)
-	if err != nil {
-		log.Errorf("client: could not create request: %s\n", err)
-	}
-	enterReq.Header.Set("Content-Type", "application/json")
-
-	exitReq, err := http.NewRequest(http.MethodPost, exitRequestURL, exitBodyReader)
-	if err != nil {
-		log.Errorf("client: could not create request: %s\n", err)
-	}
-	exitReq.Header.Set("Content-Type", "application/json")
-
-	pConn.sendToLBer(enterReq)
-	pConn.sendToLBer(exitReq)
-
-}
-
-func (pConn *PFCPConn) sendToLBer(req *http.Request) {
-	done := false
-	retries := uint8(0)
-	client := http.Client{
-		Timeout: 10 * time.Second,
-	}
-	for !done && retries < pConn.upf.maxReqRetries {
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Errorf("client: error making http request: %s\n", err)
-		} else if resp.StatusCode == http.StatusCreated {
-			done = true
-			fmt.Println("parham log : resp header = ", resp.Header)
-			fmt.Println("parham log : resp status = ", resp.Status)
-			return
-		}
-		retries++
-		time.Sleep(1 * time.Second)
-	}
-}
-
-func (node *PFCPNode) RegisterTolb(lb lbtype) {
-	gatewayIP := node.gwIP
-	coreMac := node.coreMac
-
-	registerReq := RegisterReq{
-		GwIP:      gatewayIP,
-		CoreMac:   coreMac,
+// registerReq returns the registration of the UPF to the Enter-LB and Exit-LB.
+func (node *PFCPNode) registerReq() RegisterReq {
+	return RegisterReq{
+		GwIP:      node.gwIP,
+		CoreMac:   node.coreMac,
 		AccessMac: node.accessMac,
 		Hostname:  node.hostname,
 	}
-
-	registerReqJson, _ := json.Marshal(registerReq)
-
-	fmt.Printf("parham log : json encoded pfcpInfo [%s] ", registerReqJson)
-
-	// change the IP here
-	var requestURL string
-	switch lb {
-	case enterlb:
-		requestURL = "http://enterlb:8080/register"
-	case exitlb:
-		requestURL = "http://exitlb:8080/register"
-	}
-
-	jsonBody := []byte(registerReqJson)
-
-	bodyReader := bytes.NewReader(jsonBody)
-	req, err := http.NewRequest(http.MethodPost, requestURL, bodyReader)
-	if err != nil {
-		log.Errorf("client: could not create request: %s\n", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	client := http.Client{
-		Timeout: 10 * time.Second,
-	}
-	done := false
-	for !done {
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Errorf("client: error making http request: %s\n", err)
-		} else if resp.StatusCode == http.StatusCreated {
-			done = true
-			fmt.Println("parham log : resp header = ", resp.Header)
-			fmt.Println("parham log : resp status = ", resp.Status)
-			return
-		}
-		time.Sleep(1 * time.Second)
-	}
-
 }
 
-func (i *InMemoryStore) PutSession(session PFCPSession, pConn *PFCPConn, pushPDR bool, msgType uint8) error {
+func (i *InMemoryStore) PutSession(session PFCPSession) error {
 	if session.localSEID == 0 {
 		return ErrInvalidArgument("session.localSEID", session.localSEID)
 	}
@@ -221,35 +105,11 @@ func (i *InMemoryStore) PutSession(session PFCPSession, pConn *PFCPConn, pushPDR
 	log.WithFields(log.Fields{
 		"session": session,
 	}).Trace("Saved PFCP sessions to local store")
-	if pushPDR {
-		go func(session *PFCPSession, pConn *PFCPConn) {
-			uEAddresses := make([]uint32, 0)
-			//teids := make([]uint32, 0)
-			for _, p := range session.pdrs {
-				exists := false
-				for _, u := range uEAddresses {
-					if u == p.ueAddress {
-						exists = true
-						break
-					}
-				}
-				if _, ok := pConn.sentIpsToRouters[p.ueAddress]; !ok && !exists {
-					uEAddresses = append(uEAddresses, p.ueAddress)
-					pConn.sentIpsToRouters[p.ueAddress] = struct{}{}
 
-				}
-			}
-			if msgType == message.MsgTypeSessionModificationRequest {
-				time.Sleep(2 * time.Second)
-			}
-			pConn.PushPDRInfo(uEAddresses)
-		}(&session, pConn)
-	}
 	return nil
 }
 
-func (i *InMemoryStore) DeleteSession(fseid uint64, pConn *PFCPConn) error {
-
+func (i *InMemoryStore) DeleteSession(fseid uint64) error {
 	i.sessions.Delete(fseid)
 
 	log.WithFields(log.Fields{
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/omec-project/upf-epc/pfcpiface/metrics"
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+const (
+	lbTestGwIP      = "192.168.250.1"
+	lbTestUEAddress = "10.250.0.1"
+	// migrationPriority is the PFCP message priority set by the SMF for sessions migrated between UPFs.
+	migrationPriority = 123
+)
+
+type lbTestFakes struct {
+	enter, exit *fake_lb.FakeLB
+	pfcp        *fake_lb.FakePFCPLB
+}
+
+// startTestLBs starts fake Enter-LB, Exit-LB and PFCP-LB servers and points u to them.
+func startTestLBs(t *testing.T, u *upf) lbTestFakes {
+	fakes := lbTestFakes{
+		enter: fake_lb.NewFakeLB(),
+		exit:  fake_lb.NewFakeLB(),
+		pfcp:  fake_lb.NewFakePFCPLB(),
+	}
+
+	enter := httptest.NewServer(fakes.enter)
+	exit := httptest.NewServer(fakes.exit)
+	pfcp := httptest.NewServer(fakes.pfcp)
+
+	t.Cleanup(func() {
+		enter.Close()
+		exit.Close()
+		pfcp.Close()
+	})
+
+	u.enterLBURL = enter.URL
+	u.exitLBURL = exit.URL
+	u.pfcpLBURL = pfcp.URL + "/"
+
+	return fakes
+}
+
+// noopInstrumentPFCP discards the PFCP metrics.
+type noopInstrumentPFCP struct{}
+
+func (noopInstrumentPFCP) SaveMessages(m *metrics.Message) {}
+func (noopInstrumentPFCP) SaveSessions(s *metrics.Session) {}
+func (noopInstrumentPFCP) Stop() error                     { return nil }
+
+func newLBTestPFCPConn(t *testing.T, u *upf) *PFCPConn {
+	// the responses to the SMF are not sent
+	conn, err := net.Dial("udp", "127.0.0.1:"+PFCPPort)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	s := &shadow{}
+	s.SetUpfInfo(nil, nil)
+	u.datapath = s
+	u.AccessIP = net.ParseIP("192.168.252.3")
+	u.CoreIP = net.ParseIP("192.168.250.3")
+	u.gwIP = lbTestGwIP
+
+	pConn := &PFCPConn{
+		Conn:           conn,
+		store:          NewInMemoryStore(),
+		upf:            u,
+		InstrumentPFCP: noopInstrumentPFCP{},
+	}
+	pConn.setLocalNodeID("upf")
+	pConn.nodeID.remote = "smf"
+
+	return pConn
+}
+
+func newLBTestEstablishmentRequest(seid uint64, priority uint8) *message.SessionEstablishmentRequest {
+	return message.NewSessionEstablishmentRequest(1, 0, 0, 1, priority,
+		ie.NewNodeID("", "", "smf"),
+		ie.NewFSEID(seid, net.ParseIP("10.0.0.1"), nil),
+		ie.NewCreatePDR(
+			ie.NewPDRID(1),
+			ie.NewPrecedence(100),
+			ie.NewPDI(
+				ie.NewSourceInterface(ie.SrcInterfaceAccess),
+				ie.NewFTEID(0x01, 15, net.ParseIP("192.168.252.3"), nil, 0),
+			),
+			ie.NewOuterHeaderRemoval(0, 0),
+			ie.NewFARID(1),
+		),
+		ie.NewCreatePDR(
+			ie.NewPDRID(2),
+			ie.NewPrecedence(100),
+			ie.NewPDI(
+				ie.NewSourceInterface(ie.SrcInterfaceCore),
+				ie.NewUEIPAddress(0x2, lbTestUEAddress, "", 0, 0),
+			),
+			ie.NewFARID(2),
+		),
+		ie.NewCreateFAR(
+			ie.NewFARID(1),
+			ie.NewApplyAction(ActionForward),
+			ie.NewForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceCore)),
+		),
+		ie.NewCreateFAR(
+			ie.NewFARID(2),
+			ie.NewApplyAction(ActionForward),
+			ie.NewForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceAccess)),
+		),
+	)
+}
@@ -119,6 +119,7 @@ func (pConn *PFCPConn) HandlePFCPMsg(buf []byte) {
 	}
 
 	pConn.SaveMessages(m)
+	pConn.upf.load.observeLatency(time.Since(m.StartedAt))
 
 	if reply != nil {
 		pConn.SendPFCPMsg(reply)
@@ -164,6 +164,7 @@ func (pConn *PFCPConn) handleAssociationSetupRequest(msg message.Message) (messa
 	}
 
 	pConn.nodeID.remote = nodeID
+	pConn.cpFeatures = newCPFeatures(asreq.CPFunctionFeatures)
 	asres.Cause = ie.NewCause(ie.CauseRequestAccepted)
 
 	log.Infoln("Association setup done between nodes",
@@ -213,6 +214,7 @@ func (pConn *PFCPConn) handleAssociationSetupResponse(msg message.Message) error
 	}
 
 	pConn.nodeID.remote = nodeID
+	pConn.cpFeatures = newCPFeatures(asres.CPFunctionFeatures)
 	log.Infoln("Association setup done between nodes",
 		"local:", pConn.nodeID.local, "remote:", pConn.nodeID.remote)
 
@@ -4,9 +4,12 @@
 package pfcpiface
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/wmnsk/go-pfcp/ie"
@@ -20,6 +23,22 @@ var (
 	ErrAllocateSession = errors.New("unable to allocate new PFCP session")
 )
 
+// datapathWriteTimeout bounds the time to write the rules of a PFCP message to the datapath,
+// so that the SMF gets a reply before retransmitting its request.
+const datapathWriteTimeout = 2 * time.Second
+
+// errWriteToDatapath wraps an error returned by the datapath.
+func errWriteToDatapath(err error) error {
+	return fmt.Errorf("%w: %v", ErrWriteToDatapath, err)
+}
+
+// beginRules starts changes to the rules of a session, to be committed within datapathWriteTimeout.
+func (pConn *PFCPConn) beginRules(all PacketForwardingRules) (rulesTx, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), datapathWriteTimeout)
+
+	return pConn.upf.BeginRules(ctx, all), cancel
+}
+
 func (pConn *PFCPConn) handleSessionEstablishmentRequest(msg message.Message) (message.Message, error) {
 	upf := pConn.upf
 
@@ -76,6 +95,11 @@ func (pConn *PFCPConn) handleSessionEstablishmentRequest(msg message.Message) (m
 		return errProcessReply(ErrAssocNotFound, ie.CauseNoEstablishedPFCPAssociation)
 	}
 
+	// the SMF selects another UPF
+	if upf.isDraining() {
+		return errProcessReply(ErrDraining, ie.CauseNoResourcesAvailable)
+	}
+
 	session, ok := pConn.NewPFCPSession(remoteSEID)
 	if !ok {
 		return errProcessReply(ErrAllocateSession,
@@ -133,21 +157,30 @@ func (pConn *PFCPConn) handleSessionEstablishmentRequest(msg message.Message) (m
 		qers: addQERs,
 	}
 
-	cause := upf.SendMsgToUPF(upfMsgTypeAdd, session.PacketForwardingRules, updated)
-	if cause == ie.CauseRequestRejected {
+	tx, cancel := pConn.beginRules(session.PacketForwardingRules)
+	defer cancel()
+
+	createRules(tx, updated)
+
+	upf.rulesMu.RLock()
+
+	if err := tx.Commit(); err != nil {
+		upf.rulesMu.RUnlock()
 		pConn.RemoveSession(session)
-		return errProcessReply(ErrWriteToDatapath,
-			ie.CauseRequestRejected)
-	}
-	var pushPDR bool
-	if sereq.Header.MessagePriority != 123 {
-		pushPDR = true
+
+		return errProcessReply(errWriteToDatapath(err), datapathErrorCause(err))
 	}
-	err = pConn.store.PutSession(session, pConn, pushPDR, sereq.Header.Type)
+
+	err = pConn.store.PutSession(session)
+
+	upf.rulesMu.RUnlock()
+
 	if err != nil {
 		log.Errorf("Failed to put PFCP session to store: %v", err)
 	}
 
+	upf.sessionHooks.created(pConn, session, sereq.Header.MessagePriority)
+
 	var localFSEID *ie.IE
 
 	localIP := pConn.LocalAddr().(*net.UDPAddr).IP
@@ -170,6 +203,8 @@ func (pConn *PFCPConn) handleSessionEstablishmentRequest(msg message.Message) (m
 
 	addPdrInfo(seres, &session)
 
+	seres.LoadControlInformation, seres.OverloadControlInformation = pConn.controlInformation()
+
 	return seres, nil
 }
 
@@ -183,20 +218,24 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 
 	var remoteSEID uint64
 
-	sendError := func(err error) (message.Message, error) {
+	sendErrorWithCause := func(err error, cause uint8) (message.Message, error) {
 		log.Errorln(err)
 
 		smres := message.NewSessionModificationResponse(0, /* MO?? <-- what's this */
-			0,                                    /* FO <-- what's this? */
-			remoteSEID,                           /* seid */
-			smreq.SequenceNumber,                 /* seq # */
-			smreq.Header.MessagePriority,         /* priority */
-			ie.NewCause(ie.CauseRequestRejected), /* accept it blindly for the time being */
+			0,                            /* FO <-- what's this? */
+			remoteSEID,                   /* seid */
+			smreq.SequenceNumber,         /* seq # */
+			smreq.Header.MessagePriority, /* priority */
+			ie.NewCause(cause),
 		)
 
 		return smres, err
 	}
 
+	sendError := func(err error) (message.Message, error) {
+		return sendErrorWithCause(err, ie.CauseRequestRejected)
+	}
+
 	localSEID := smreq.SEID()
 
 	session, ok := pConn.store.GetSession(localSEID)
@@ -204,6 +243,10 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 		return sendError(ErrNotFoundWithParam("PFCP session", "localSEID", localSEID))
 	}
 
+	// the rules of the stored session are modified in place
+	old := session
+	old.PacketForwardingRules = session.PacketForwardingRules.clone()
+
 	var fseidIP uint32
 
 	if smreq.CPFSEID != nil {
@@ -221,6 +264,9 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 	addPDRs := make([]pdr, 0, MaxItems)
 	addFARs := make([]far, 0, MaxItems)
 	addQERs := make([]qer, 0, MaxItems)
+	updPDRs := make([]pdr, 0, MaxItems)
+	updFARs := make([]far, 0, MaxItems)
+	updQERs := make([]qer, 0, MaxItems)
 	endMarkerList := make([][]byte, 0, MaxItems)
 
 	for _, cPDR := range smreq.CreatePDR {
@@ -277,7 +323,7 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 			continue
 		}
 
-		addPDRs = append(addPDRs, p)
+		updPDRs = append(updPDRs, p)
 	}
 
 	for _, uFAR := range smreq.UpdateFAR {
@@ -298,7 +344,7 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 			continue
 		}
 
-		addFARs = append(addFARs, f)
+		updFARs = append(updFARs, f)
 	}
 
 	for _, uQER := range smreq.UpdateQER {
@@ -319,32 +365,15 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 			continue
 		}
 
-		addQERs = append(addQERs, q)
+		updQERs = append(updQERs, q)
 	}
 
 	session.MarkSessionQer(session.qers)
 	// FIXME: since PacketForwardingRules doesn't store pointers,
-	//  we must also mark session QERs in addQERs.
+	//  we must also mark session QERs in addQERs and updQERs.
 	//  We need a kind of refactoring to clean it up.
 	session.MarkSessionQer(addQERs)
-
-	updated := PacketForwardingRules{
-		pdrs: addPDRs,
-		fars: addFARs,
-		qers: addQERs,
-	}
-
-	cause := upf.SendMsgToUPF(upfMsgTypeMod, session.PacketForwardingRules, updated)
-	if cause == ie.CauseRequestRejected {
-		return sendError(ErrWriteToDatapath)
-	}
-
-	if upf.EnableEndMarker {
-		err := upf.SendEndMarkers(&endMarkerList)
-		if err != nil {
-			log.Errorln("Sending End Markers Failed : ", err)
-		}
-	}
+	session.MarkSessionQer(updQERs)
 
 	delPDRs := make([]pdr, 0, MaxItems)
 	delFARs := make([]far, 0, MaxItems)
@@ -398,21 +427,37 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 		qers: delQERs,
 	}
 
-	cause = upf.SendMsgToUPF(upfMsgTypeDel, deleted, PacketForwardingRules{})
-	if cause == ie.CauseRequestRejected {
-		return sendError(ErrWriteToDatapath)
-	}
+	tx, cancel := pConn.beginRules(session.PacketForwardingRules)
+	defer cancel()
+
+	createRules(tx, PacketForwardingRules{pdrs: addPDRs, fars: addFARs, qers: addQERs})
+	updateRules(tx, PacketForwardingRules{pdrs: updPDRs, fars: updFARs, qers: updQERs})
+	deleteRules(tx, deleted)
+
+	upf.rulesMu.RLock()
 
-	var pushPDR bool
-	if smreq.Header.MessagePriority == 123 {
-		pushPDR = true
+	if err := tx.Commit(); err != nil {
+		upf.rulesMu.RUnlock()
+		return sendErrorWithCause(errWriteToDatapath(err), datapathErrorCause(err))
 	}
 
-	err := pConn.store.PutSession(session, pConn, pushPDR, smreq.Header.Type)
+	err := pConn.store.PutSession(session)
+
+	upf.rulesMu.RUnlock()
+
 	if err != nil {
 		log.Errorf("Failed to put PFCP session to store: %v", err)
 	}
 
+	if upf.EnableEndMarker {
+		err := upf.SendEndMarkers(&endMarkerList)
+		if err != nil {
+			log.Errorln("Sending End Markers Failed : ", err)
+		}
+	}
+
+	upf.sessionHooks.modified(pConn, old, session, smreq.Header.MessagePriority)
+
 	// Build response message
 	smres := message.NewSessionModificationResponse(0, /* MO?? <-- what's this */
 		0,                                    /* FO <-- what's this? */
@@ -421,6 +466,7 @@ func (pConn *PFCPConn) handleSessionModificationRequest(msg message.Message) (me
 		smreq.Header.MessagePriority,         /* priority */
 		ie.NewCause(ie.CauseRequestAccepted), /* accept it blindly for the time being */
 	)
+	smres.LoadControlInformation, smres.OverloadControlInformation = pConn.controlInformation()
 
 	return smres, nil
 }
@@ -433,18 +479,22 @@ func (pConn *PFCPConn) handleSessionDeletionRequest(msg message.Message) (messag
 		return nil, errUnmarshal(errMsgUnexpectedType)
 	}
 
-	sendError := func(err error) (message.Message, error) {
+	sendErrorWithCause := func(err error, cause uint8) (message.Message, error) {
 		smres := message.NewSessionDeletionResponse(0, /* MO?? <-- what's this */
-			0,                                    /* FO <-- what's this? */
-			0,                                    /* seid */
-			sdreq.SequenceNumber,                 /* seq # */
-			sdreq.Header.MessagePriority,         /* priority */
-			ie.NewCause(ie.CauseRequestRejected), /* accept it blindly for the time being */
+			0,                            /* FO <-- what's this? */
+			0,                            /* seid */
+			sdreq.SequenceNumber,         /* seq # */
+			sdreq.Header.MessagePriority, /* priority */
+			ie.NewCause(cause),
 		)
 
 		return smres, err
 	}
 
+	sendError := func(err error) (message.Message, error) {
+		return sendErrorWithCause(err, ie.CauseRequestRejected)
+	}
+
 	/* retrieve sessionRecord */
 	localSEID := sdreq.SEID()
 
@@ -453,18 +503,28 @@ func (pConn *PFCPConn) handleSessionDeletionRequest(msg message.Message) (messag
 		return sendError(ErrNotFoundWithParam("PFCP session", "localSEID", localSEID))
 	}
 
-	cause := upf.SendMsgToUPF(upfMsgTypeDel, session.PacketForwardingRules, PacketForwardingRules{})
-	if cause == ie.CauseRequestRejected {
-		return sendError(ErrWriteToDatapath)
+	tx, cancel := pConn.beginRules(PacketForwardingRules{})
+	defer cancel()
+
+	deleteRules(tx, session.PacketForwardingRules)
+
+	upf.rulesMu.RLock()
+
+	if err := tx.Commit(); err != nil {
+		upf.rulesMu.RUnlock()
+		return sendErrorWithCause(errWriteToDatapath(err), datapathErrorCause(err))
 	}
 
 	if err := releaseAllocatedIPs(upf.ippool, &session); err != nil {
+		upf.rulesMu.RUnlock()
 		return sendError(ErrOperationFailedWithReason("session IP dealloc", err.Error()))
 	}
 
 	/* delete sessionRecord */
 	pConn.RemoveSession(session)
 
+	upf.rulesMu.RUnlock()
+
 	// Build response message
 	smres := message.NewSessionDeletionResponse(0, /* MO?? <-- what's this */
 		0,                                    /* FO <-- what's this? */
@@ -473,6 +533,7 @@ func (pConn *PFCPConn) handleSessionDeletionRequest(msg message.Message) (messag
 		sdreq.Header.MessagePriority,         /* priority */
 		ie.NewCause(ie.CauseRequestAccepted), /* accept it blindly for the time being */
 	)
+	smres.LoadControlInformation, smres.OverloadControlInformation = pConn.controlInformation()
 
 	return smres, nil
 }
@@ -559,11 +620,17 @@ func (pConn *PFCPConn) handleSessionReportResponse(msg message.Message) error {
 
 		log.Warnln("context not found, deleting session locally")
 
+		tx, cancel := pConn.beginRules(PacketForwardingRules{})
+		defer cancel()
+
+		deleteRules(tx, sessItem.PacketForwardingRules)
+
+		upf.rulesMu.RLock()
+		err := tx.Commit()
 		pConn.RemoveSession(sessItem)
+		upf.rulesMu.RUnlock()
 
-		cause := upf.SendMsgToUPF(
-			upfMsgTypeDel, sessItem.PacketForwardingRules, PacketForwardingRules{})
-		if cause == ie.CauseRequestRejected {
+		if err != nil {
 			return errProcess(
 				ErrOperationFailedWithParam("delete session from datapath", "seid", seid))
 		}
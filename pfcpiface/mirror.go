@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// mirrorBackend is a datapath mirrored by the mirror datapath.
+type mirrorBackend struct {
+	name string
+	dp   datapath
+}
+
+// mirror is a datapath writing the rules to a primary and a secondary datapath, to migrate
+// from one to the other without a flag day. The result of the primary is authoritative,
+// divergences of the secondary are logged and counted. Traffic related operations (end
+// markers and statistics) only use the primary. The primary and secondary can be flipped
+// at runtime through the HTTP API.
+type mirror struct {
+	// mu guards primary and secondary, calls to the backends hold it in read mode so that
+	// a flip waits for in-flight calls to complete.
+	mu        sync.RWMutex
+	primary   mirrorBackend
+	secondary mirrorBackend
+}
+
+func newMirror(primaryName string, primary datapath, secondaryName string, secondary datapath) *mirror {
+	m := &mirror{
+		primary:   mirrorBackend{name: primaryName, dp: primary},
+		secondary: mirrorBackend{name: secondaryName, dp: secondary},
+	}
+	m.updatePrimaryMetric()
+
+	return m
+}
+
+func (m *mirror) backends() (mirrorBackend, mirrorBackend) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.primary, m.secondary
+}
+
+func (m *mirror) updatePrimaryMetric() {
+	getDatapathMetrics().mirrorPrimary.WithLabelValues(m.primary.name).Set(1)
+	getDatapathMetrics().mirrorPrimary.WithLabelValues(m.secondary.name).Set(0)
+}
+
+// diverged records that the secondary returned a different result than the primary.
+func (m *mirror) diverged(op string, primary, secondary interface{}) {
+	getDatapathMetrics().mirrorDivergences.WithLabelValues(op).Inc()
+
+	log.WithFields(log.Fields{
+		"operation":        op,
+		"primary":          m.primary.name,
+		"primary result":   primary,
+		"secondary":        m.secondary.name,
+		"secondary result": secondary,
+	}).Warn("Mirrored datapaths diverged")
+}
+
+// SetUpfInfo initializes both datapaths. The UPF addresses are the ones of the primary,
+// both datapaths are expected to serve the same N3 address.
+func (m *mirror) SetUpfInfo(u *upf, conf *Conf) {
+	primary, secondary := m.backends()
+
+	log.Printf("SetUpfInfo mirror, primary %v, secondary %v", primary.name, secondary.name)
+
+	primary.dp.SetUpfInfo(u, conf)
+
+	accessIP, coreIP := u.AccessIP, u.CoreIP
+
+	secondary.dp.SetUpfInfo(u, conf)
+
+	if !u.AccessIP.Equal(accessIP) {
+		log.Warnf("Access IP of the secondary datapath %v differs from %v, ignoring it", u.AccessIP, accessIP)
+	}
+
+	u.AccessIP, u.CoreIP = accessIP, coreIP
+}
+
+func (m *mirror) BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx {
+	return newChangesTx(ctx, all, m.commitRules)
+}
+
+// commitRules writes the changes to both datapaths, as set when committing. The changes
+// rejected by the primary are not written to the secondary: the session is dropped, and
+// nothing would ever delete them from the secondary.
+func (m *mirror) commitRules(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tx := m.primary.dp.BeginRules(ctx, all)
+	changes.replay(tx)
+
+	err := tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	secondaryTx := m.secondary.dp.BeginRules(ctx, all)
+	changes.replay(secondaryTx)
+	secondaryErr := secondaryTx.Commit()
+
+	if secondaryErr != nil {
+		m.diverged("rules", nil, secondaryErr)
+	}
+
+	return nil
+}
+
+func (m *mirror) AddSliceInfo(sliceInfo *SliceInfo) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := m.primary.dp.AddSliceInfo(sliceInfo)
+
+	secondaryErr := m.secondary.dp.AddSliceInfo(sliceInfo)
+	if (err == nil) != (secondaryErr == nil) {
+		m.diverged("slice", err, secondaryErr)
+	}
+
+	return err
+}
+
+// IsConnected returns whether the primary datapath is connected.
+func (m *mirror) IsConnected(accessIP *net.IP) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.primary.dp.IsConnected(accessIP)
+}
+
+func (m *mirror) Exit() {
+	log.Println("Exit function mirror")
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	m.primary.dp.Exit()
+	m.secondary.dp.Exit()
+}
+
+// SendEndMarkers sends the end markers through the primary datapath only, to not
+// duplicate them towards the gNB.
+func (m *mirror) SendEndMarkers(endMarkerList *[][]byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.primary.dp.SendEndMarkers(endMarkerList)
+}
+
+func (m *mirror) SummaryLatencyJitter(uc *upfCollector, ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	m.primary.dp.SummaryLatencyJitter(uc, ch)
+}
+
+func (m *mirror) PortStats(uc *upfCollector, ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	m.primary.dp.PortStats(uc, ch)
+}
+
+func (m *mirror) SessionStats(pc *PfcpNodeCollector, ch chan<- prometheus.Metric) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.primary.dp.SessionStats(pc, ch)
+}
+
+// Reconcile reconciles both datapaths, if supported, and returns the report of the primary.
+func (m *mirror) Reconcile(sessions []PFCPSession) (driftReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if dp, ok := m.secondary.dp.(reconcilableDatapath); ok {
+		report, err := dp.Reconcile(sessions)
+		if err != nil {
+			log.Errorf("Reconciliation of the secondary datapath %v failed: %v", m.secondary.name, err)
+		} else if report.hasDrift() {
+			log.WithField("report", report).Warn("Secondary datapath drift detected")
+		}
+	}
+
+	dp, ok := m.primary.dp.(reconcilableDatapath)
+	if !ok {
+		return driftReport{}, ErrUnsupported("reconciliation for datapath", m.primary.name)
+	}
+
+	return dp.Reconcile(sessions)
+}
+
+// flip swaps the primary and secondary datapaths.
+func (m *mirror) flip() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.primary, m.secondary = m.secondary, m.primary
+	m.updatePrimaryMetric()
+
+	log.Infof("Flipped mirrored datapaths, primary is now %v", m.primary.name)
+}
+
+func (m *mirror) setupHandlers(mux *http.ServeMux) {
+	mux.Handle("/v1/datapath/mirror", m)
+
+	primary, secondary := m.backends()
+	for _, b := range []mirrorBackend{primary, secondary} {
+		if dp, ok := b.dp.(httpServingDatapath); ok {
+			dp.setupHandlers(mux)
+		}
+	}
+}
+
+// mirrorStatus is the JSON representation of the mirror datapath.
+type mirrorStatus struct {
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary"`
+}
+
+// ServeHTTP returns the mirrored datapaths on GET, and flips them on POST with ?flip=true.
+func (m *mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if r.URL.Query().Get("flip") != "true" {
+			sendHTTPResp(http.StatusBadRequest, w)
+			return
+		}
+
+		m.flip()
+	default:
+		sendHTTPResp(http.StatusMethodNotAllowed, w)
+		return
+	}
+
+	primary, secondary := m.backends()
+	status := mirrorStatus{Primary: primary.name, Secondary: secondary.name}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Errorln("http response write failed : ", err)
+	}
+}
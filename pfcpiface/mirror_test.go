@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// mirrorTestDatapath records the rules like the shadow datapath, but fails with a fixed error.
+type mirrorTestDatapath struct {
+	shadow
+	err error
+}
+
+func (d *mirrorTestDatapath) BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx {
+	return newChangesTx(ctx, all, func(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error {
+		if err := d.commitRules(ctx, all, changes); err != nil {
+			return err
+		}
+
+		return d.err
+	})
+}
+
+func newMirrorTestDatapath(err error) *mirrorTestDatapath {
+	d := &mirrorTestDatapath{err: err}
+	d.SetUpfInfo(nil, nil)
+
+	return d
+}
+
+func Test_mirror_BeginRules(t *testing.T) {
+	primary := newMirrorTestDatapath(nil)
+	secondary := newMirrorTestDatapath(ErrInvalidArgument("pdr", 1))
+	m := newMirror("primary", primary, "secondary", secondary)
+
+	divergences := getDatapathMetrics().mirrorDivergences.WithLabelValues("rules")
+	before := testutil.ToFloat64(divergences)
+
+	tx := m.BeginRules(context.Background(), newSWTestRules())
+	createRules(tx, newSWTestRules())
+	require.NoError(t, tx.Commit())
+	require.Equal(t, before+1, testutil.ToFloat64(divergences))
+
+	// both datapaths got the rules
+	require.Len(t, primary.sessions, 1)
+	require.Len(t, secondary.sessions, 1)
+
+	m.flip()
+
+	tx = m.BeginRules(context.Background(), PacketForwardingRules{})
+	deleteRules(tx, newSWTestRules())
+
+	err := tx.Commit()
+	require.Equal(t, uint8(ie.CauseRuleCreationModificationFailure), datapathErrorCause(err))
+	// the changes rejected by the new primary are not written to the new secondary
+	require.Len(t, primary.sessions, 1)
+	require.Empty(t, secondary.sessions)
+}
+
+func Test_mirror_primaryFailure(t *testing.T) {
+	primary := newMirrorTestDatapath(ErrNoResourcesAvailable("pdr"))
+	secondary := newMirrorTestDatapath(nil)
+	m := newMirror("primary", primary, "secondary", secondary)
+
+	divergences := getDatapathMetrics().mirrorDivergences.WithLabelValues("rules")
+	before := testutil.ToFloat64(divergences)
+
+	tx := m.BeginRules(context.Background(), newSWTestRules())
+	createRules(tx, newSWTestRules())
+
+	err := tx.Commit()
+	require.Equal(t, uint8(ie.CauseNoResourcesAvailable), datapathErrorCause(err))
+
+	// the session is dropped, the secondary must not keep its rules
+	require.Empty(t, secondary.sessions)
+	require.Equal(t, before, testutil.ToFloat64(divergences))
+}
+
+func Test_mirror_ServeHTTP(t *testing.T) {
+	m := newMirror("primary", newMirrorTestDatapath(nil),
+		"secondary", newMirrorTestDatapath(nil))
+
+	serve := func(method, query string) (int, mirrorStatus) {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(method, "/v1/datapath/mirror"+query, nil))
+
+		var status mirrorStatus
+
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+		}
+
+		return rec.Code, status
+	}
+
+	code, status := serve(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, mirrorStatus{Primary: "primary", Secondary: "secondary"}, status)
+
+	code, _ = serve(http.MethodPost, "")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, status = serve(http.MethodPost, "?flip=true")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, mirrorStatus{Primary: "secondary", Secondary: "primary"}, status)
+
+	code, _ = serve(http.MethodDelete, "")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+}
@@ -54,7 +54,7 @@ func NewPFCPNode(upf *upf, conf *Conf) *PFCPNode {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &PFCPNode{
+	node := &PFCPNode{
 		ctx:        ctx,
 		cancel:     cancel,
 		PacketConn: conn,
@@ -67,6 +67,36 @@ func NewPFCPNode(upf *upf, conf *Conf) *PFCPNode {
 		accessMac:  GetMac("access"),
 		hostname:   conf.CPIface.NodeID,
 	}
+
+	upf.setSessionsSource(node.allSessions)
+
+	return node
+}
+
+// hasPConns returns true if the node has at least one PFCP connection.
+func (node *PFCPNode) hasPConns() bool {
+	found := false
+
+	node.pConns.Range(func(key, value interface{}) bool {
+		found = true
+		return false
+	})
+
+	return found
+}
+
+// allSessions returns the sessions of all PFCP connections of the node.
+func (node *PFCPNode) allSessions() []PFCPSession {
+	var sessions []PFCPSession
+
+	node.pConns.Range(func(key, value interface{}) bool {
+		pConn := value.(*PFCPConn)
+		sessions = append(sessions, pConn.store.GetAllSessions()...)
+
+		return true
+	})
+
+	return sessions
 }
 
 func (node *PFCPNode) tryConnectToN4Peers(lAddrStr string) {
@@ -150,30 +180,12 @@ func (node *PFCPNode) Serve() {
 				log.Errorln("Error closing PFCPNode conn", err)
 			}
 
-			// Clear out the remaining pconn completions
-		clearLoop:
-			for {
-				select {
-				case rAddr, ok := <-node.pConnDone:
-					{
-						if !ok {
-							// channel is closed, break
-							break clearLoop
-						}
-						node.pConns.Delete(rAddr)
-						log.Infoln("Removed connection to", rAddr)
-					}
-				default:
-					// nothing to read from channel
-					break clearLoop
-				}
-			}
-
-			if len(node.pConnDone) > 0 {
-				for rAddr := range node.pConnDone {
-					node.pConns.Delete(rAddr)
-					log.Infoln("Removed connection to", rAddr)
-				}
+			// Wait for the remaining PFCP connections to shut down, as they report their completion
+			// once their sessions are removed from the datapath
+			for node.hasPConns() {
+				rAddr := <-node.pConnDone
+				node.pConns.Delete(rAddr)
+				log.Infoln("Removed connection to", rAddr)
 			}
 
 			close(node.pConnDone)
@@ -4,7 +4,6 @@
 package pfcpiface
 
 import (
-	"encoding/binary"
 	"fmt"
 	"math"
 	"math/bits"
@@ -63,53 +62,6 @@ func newP4RtTranslator(p4info *p4ConfigV1.P4Info) *P4rtTranslator {
 	}
 }
 
-func convertValueToBinary(value interface{}) ([]byte, error) {
-	switch t := value.(type) {
-	case []byte:
-		return value.([]byte), nil
-	case bool:
-		uintFlag := uint8(0)
-
-		flag := value.(bool)
-		if flag {
-			uintFlag = 1
-		}
-
-		b := make([]byte, 1)
-		b[0] = uintFlag
-
-		return b, nil
-	case uint8:
-		b := make([]byte, 1)
-		b[0] = value.(uint8)
-
-		return b, nil
-	case uint16:
-		b := make([]byte, 2)
-		binary.BigEndian.PutUint16(b, value.(uint16))
-
-		return b, nil
-	case uint32:
-		b := make([]byte, 4)
-		binary.BigEndian.PutUint32(b, value.(uint32))
-
-		return b, nil
-	case uint64:
-		b := make([]byte, 8)
-		binary.BigEndian.PutUint64(b, value.(uint64))
-
-		return b, nil
-	case int:
-		b := make([]byte, 4)
-		binary.BigEndian.PutUint32(b, uint32(value.(int)))
-
-		return b, nil
-	default:
-		log.Debugf("Type %T", t)
-		return nil, ErrOperationFailedWithParam("convert type to byte array", "type", t)
-	}
-}
-
 func (t *P4rtTranslator) getActionByID(actionID uint32) (*p4ConfigV1.Action, error) {
 	for _, action := range t.p4Info.Actions {
 		if action.Preamble.Id == actionID {
@@ -140,6 +92,59 @@ func (t *P4rtTranslator) getCounterSizeByID(counterID uint32) (int64, error) {
 	return 0, ErrNotFoundWithParam("counter", "ID", counterID)
 }
 
+func (t *P4rtTranslator) getTableSizeByID(tableID uint32) (int64, error) {
+	table, err := t.getTableByID(tableID)
+	if err != nil {
+		return 0, err
+	}
+
+	return table.GetSize(), nil
+}
+
+func (t *P4rtTranslator) getMatchFieldBitwidth(tableID uint32, fieldName string) (int32, error) {
+	table, err := t.getTableByID(tableID)
+	if err != nil {
+		return 0, err
+	}
+
+	field := t.getMatchFieldByName(table, fieldName)
+	if field == nil {
+		return 0, ErrNotFoundWithParam("match field", "name", fieldName)
+	}
+
+	return field.GetBitwidth(), nil
+}
+
+// getIDPoolSize returns the number of IDs starting from firstID that can be stored both in the entries
+// of a table, minus the reserved ones, and in a field of another table.
+func (t *P4rtTranslator) getIDPoolSize(tableID uint32, reservedEntries int64, fieldTableID uint32, fieldName string, firstID int64) (int64, error) {
+	tableSize, err := t.getTableSizeByID(tableID)
+	if err != nil {
+		return 0, err
+	}
+
+	bitwidth, err := t.getMatchFieldBitwidth(fieldTableID, fieldName)
+	if err != nil {
+		return 0, err
+	}
+
+	maxID := int64(math.MaxUint16)
+	if bitwidth < 16 {
+		maxID = 1<<uint(bitwidth) - 1
+	}
+
+	size := maxID - firstID + 1
+	if tableSize-reservedEntries < size {
+		size = tableSize - reservedEntries
+	}
+
+	if size < 0 {
+		return 0, nil
+	}
+
+	return size, nil
+}
+
 func (t *P4rtTranslator) getTableByID(tableID uint32) (*p4ConfigV1.Table, error) {
 	for _, table := range t.p4Info.Tables {
 		if table.Preamble.Id == tableID {
@@ -150,7 +155,6 @@ func (t *P4rtTranslator) getTableByID(tableID uint32) (*p4ConfigV1.Table, error)
 	return nil, ErrNotFoundWithParam("table", "ID", tableID)
 }
 
-//nolint:unused
 func (t *P4rtTranslator) getMatchFieldIDByName(table *p4ConfigV1.Table, fieldName string) uint32 {
 	for _, field := range table.MatchFields {
 		if field.Name == fieldName {
@@ -181,222 +185,60 @@ func (t *P4rtTranslator) getActionParamByName(action *p4ConfigV1.Action, paramNa
 	return nil
 }
 
-// TODO: find a way to use *p4.TableEntry as receiver
-func (t *P4rtTranslator) withExactMatchField(entry *p4.TableEntry, name string, value interface{}) error {
-	if entry.TableId == 0 {
-		return ErrInvalidArgumentWithReason("entry.TableId", entry.TableId, "no table name for entry defined, set table name before adding match fields")
-	}
-
-	p4Table, err := t.getTableByID(entry.TableId)
-	if err != nil {
-		return err
-	}
-
-	p4MatchField := t.getMatchFieldByName(p4Table, name)
-	if p4MatchField == nil {
-		return ErrOperationFailedWithParam("find match field", "name", name)
-	}
-
-	matchField := &p4.FieldMatch{
-		FieldId: p4MatchField.Id,
-	}
-
-	byteVal, err := convertValueToBinary(value)
-	if err != nil {
-		return err
-	}
-
-	exactMatch := &p4.FieldMatch_Exact{
-		Value: byteVal,
-	}
-	matchField.FieldMatchType = &p4.FieldMatch_Exact_{Exact: exactMatch}
-
-	entry.Match = append(entry.Match, matchField)
-
-	return nil
-}
-
-func (t *P4rtTranslator) withLPMField(entry *p4.TableEntry, name string, value uint32, prefixLen uint8) error {
-	lpmFieldLog := log.WithFields(log.Fields{
-		"entry":      entry.String(),
-		"field name": name,
-	})
-	lpmFieldLog.Trace("Adding LPM match field to the entry")
-
-	if entry.TableId == 0 {
-		return ErrInvalidArgumentWithReason("entry.TableId", entry.TableId, "no table ID for entry defined, set table ID before adding match fields")
-	}
-
-	p4Table, err := t.getTableByID(entry.TableId)
-	if err != nil {
-		return err
-	}
-
-	p4MatchField := t.getMatchFieldByName(p4Table, name)
-	if p4MatchField == nil {
-		return ErrOperationFailedWithParam("find match field", "name", name)
-	}
-
-	byteVal, err := convertValueToBinary(value)
-	if err != nil {
-		return err
-	}
-
-	matchField := &p4.FieldMatch{
-		FieldId: p4MatchField.Id,
-	}
-
-	lpmMatch := &p4.FieldMatch_LPM{
-		Value:     byteVal,
-		PrefixLen: int32(prefixLen),
-	}
-
-	matchField.FieldMatchType = &p4.FieldMatch_Lpm{Lpm: lpmMatch}
-
-	entry.Match = append(entry.Match, matchField)
-
-	return nil
-}
-
-func (t *P4rtTranslator) withRangeMatchField(entry *p4.TableEntry, name string, low interface{}, high interface{}) error {
-	rangeFieldLog := log.WithFields(log.Fields{
-		"entry":      entry.String(),
-		"field name": name,
-	})
-	rangeFieldLog.Trace("Adding range match field to the entry")
-
-	if entry.TableId == 0 {
-		return ErrInvalidArgumentWithReason("entry.TableId", entry.TableId, "no table ID for entry defined, set table ID before adding match fields")
-	}
-
-	lowByteVal, err := convertValueToBinary(low)
-	if err != nil {
-		return err
-	}
-
-	highByteVal, err := convertValueToBinary(high)
-	if err != nil {
-		return err
-	}
-
-	p4Table, err := t.getTableByID(entry.TableId)
-	if err != nil {
-		return err
-	}
-
-	p4MatchField := t.getMatchFieldByName(p4Table, name)
-	if p4MatchField == nil {
-		return ErrOperationFailedWithParam("find match field", "name", name)
-	}
-
-	matchField := &p4.FieldMatch{
-		FieldId: p4MatchField.Id,
-	}
-
-	rangeMatch := &p4.FieldMatch_Range{
-		Low:  lowByteVal,
-		High: highByteVal,
+func (t *P4rtTranslator) getActionParamValue(tableEntry *p4.TableEntry, id uint32) ([]byte, error) {
+	for _, param := range tableEntry.Action.GetAction().Params {
+		if param.ParamId == id {
+			return param.Value, nil
+		}
 	}
 
-	matchField.FieldMatchType = &p4.FieldMatch_Range_{Range: rangeMatch}
-
-	entry.Match = append(entry.Match, matchField)
-
-	return nil
+	return nil, ErrNotFoundWithParam("action param", "id", id)
 }
 
-func (t *P4rtTranslator) withTernaryMatchField(entry *p4.TableEntry, name string, value interface{}, mask interface{}) error {
-	ternaryFieldLog := log.WithFields(log.Fields{
-		"entry":      entry.String(),
-		"field name": name,
-	})
-	ternaryFieldLog.Trace("Adding ternary match field to the entry")
-
-	if entry.TableId == 0 {
-		return ErrInvalidArgumentWithReason("entry.TableId", entry.TableId, "no table name for entry defined, set table name before adding match fields")
-	}
-
-	byteVal, err := convertValueToBinary(value)
-	if err != nil {
-		return err
+// getActionParamValueByName returns the value of an action param of a table entry as an unsigned integer.
+func (t *P4rtTranslator) getActionParamValueByName(tableEntry *p4.TableEntry, name string) (uint64, error) {
+	action := tableEntry.GetAction().GetAction()
+	if action == nil {
+		return 0, ErrNotFoundWithParam("action", "table", tableEntry.TableId)
 	}
 
-	byteMask, err := convertValueToBinary(mask)
+	p4Action, err := t.getActionByID(action.ActionId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if len(byteVal) != len(byteMask) {
-		ternaryFieldLog.Trace("value and mask length mismatch")
-		return ErrOperationFailedWithParam("value and mask length mismatch for ternary field", "field", name)
+	p4Param := t.getActionParamByName(p4Action, name)
+	if p4Param == nil {
+		return 0, ErrNotFoundWithParam("action param", "name", name)
 	}
 
-	p4Table, err := t.getTableByID(entry.TableId)
+	value, err := t.getActionParamValue(tableEntry, p4Param.Id)
 	if err != nil {
-		return err
-	}
-
-	p4MatchField := t.getMatchFieldByName(p4Table, name)
-	if p4MatchField == nil {
-		return ErrOperationFailedWithParam("find match field", "name", name)
-	}
-
-	matchField := &p4.FieldMatch{
-		FieldId: p4MatchField.Id,
-	}
-
-	ternaryMatch := &p4.FieldMatch_Ternary{
-		Value: byteVal,
-		Mask:  byteMask,
+		return 0, err
 	}
 
-	matchField.FieldMatchType = &p4.FieldMatch_Ternary_{Ternary: ternaryMatch}
-
-	entry.Match = append(entry.Match, matchField)
-
-	return nil
+	return bytesToUint64(value), nil
 }
 
-func (t *P4rtTranslator) withActionParam(action *p4.Action, name string, value interface{}) error {
-	if action.ActionId == 0 {
-		return ErrInvalidArgumentWithReason("entry.ActionId", action.ActionId,
-			"invalid action ID defined, set action ID before adding action parameters")
-	}
-
-	byteVal, err := convertValueToBinary(value)
+// getFieldMatch returns the match field of a table entry, without a match value if the field is a wildcard.
+func (t *P4rtTranslator) getFieldMatch(tableEntry *p4.TableEntry, name string) (*p4.FieldMatch, error) {
+	p4Table, err := t.getTableByID(tableEntry.TableId)
 	if err != nil {
-		return err
-	}
-
-	p4Action, err := t.getActionByID(action.ActionId)
-	if err != nil {
-		return err
-	}
-
-	p4ActionParam := t.getActionParamByName(p4Action, name)
-	if p4ActionParam == nil {
-		return ErrOperationFailedWithParam("find action param", "action param name", name)
+		return nil, err
 	}
 
-	param := &p4.Action_Param{
-		ParamId: p4ActionParam.Id,
-		Value:   byteVal,
+	p4MatchFieldID := t.getMatchFieldIDByName(p4Table, name)
+	if p4MatchFieldID == invalidID {
+		return nil, ErrNotFoundWithParam("match field", "name", name)
 	}
 
-	action.Params = append(action.Params, param)
-
-	return nil
-}
-
-//nolint:unused
-func (t *P4rtTranslator) getActionParamValue(tableEntry *p4.TableEntry, id uint32) ([]byte, error) {
-	for _, param := range tableEntry.Action.GetAction().Params {
-		if param.ParamId == id {
-			return param.Value, nil
+	for _, mf := range tableEntry.Match {
+		if mf.FieldId == p4MatchFieldID {
+			return mf, nil
 		}
 	}
 
-	return nil, ErrNotFoundWithParam("action param", "id", id)
+	return &p4.FieldMatch{FieldId: p4MatchFieldID}, nil
 }
 
 //nolint:unused
@@ -449,54 +291,41 @@ func (t *P4rtTranslator) BuildInterfaceTableEntry(ipNet *net.IPNet, sliceID uint
 		direction = DirectionDownlink
 	}
 
-	entry := &p4.TableEntry{
-		TableId:  p4constants.TablePreQosPipeInterfaces,
-		Priority: DefaultPriority,
-	}
-
-	maskLength, _ := ipNet.Mask.Size()
-	if err := t.withLPMField(entry, FieldIPv4DstPrefix, ip2int(ipNet.IP.To4()), uint8(maskLength)); err != nil {
-		return nil, err
-	}
-
-	action := &p4.Action{
-		ActionId: p4constants.ActionPreQosPipeSetSourceIface,
-	}
-
-	if err := t.withActionParam(action, FieldSrcIface, srcIface); err != nil {
+	action, err := p4constants.BuildActionPreQosPipeSetSourceIface(p4constants.ActionPreQosPipeSetSourceIfaceParams{
+		SrcIface:  uint8(srcIface),
+		Direction: uint8(direction),
+		SliceId:   sliceID,
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if err := t.withActionParam(action, FieldDirection, direction); err != nil {
-		return nil, err
-	}
+	maskLength, _ := ipNet.Mask.Size()
 
-	if err := t.withActionParam(action, FieldSliceID, sliceID); err != nil {
-		return nil, err
-	}
+	return p4constants.BuildTablePreQosPipeInterfacesEntry(p4constants.TablePreQosPipeInterfacesMatch{
+		Ipv4DstPrefix:          ip2int(ipNet.IP.To4()),
+		Ipv4DstPrefixPrefixLen: int32(maskLength),
+	}, action)
+}
 
-	entry.Action = &p4.TableAction{
-		Type: &p4.TableAction_Action{Action: action},
+// toUint8 returns value as uint8, for UP4 fields narrower than their in-memory representation.
+func toUint8(name string, value uint16) (uint8, error) {
+	if value > math.MaxUint8 {
+		return 0, ErrInvalidArgumentWithReason(name, value, "does not fit in 8 bits")
 	}
 
-	return entry, nil
+	return uint8(value), nil
 }
 
-func (t *P4rtTranslator) BuildApplicationsTableEntry(pdr pdr, sliceID uint8, internalAppID uint8) (*p4.TableEntry, error) {
+func (t *P4rtTranslator) BuildApplicationsTableEntry(pdr pdr, sliceID uint8, internalAppID uint16) (*p4.TableEntry, error) {
 	applicationsBuilderLog := log.WithFields(log.Fields{
 		"pdr": pdr,
 	})
 	applicationsBuilderLog.Trace("Building P4rt table entry for applications table")
 
-	entry := &p4.TableEntry{
-		TableId: p4constants.TablePreQosPipeApplications,
-		// priority for UP4 cannot be greater than 65535
-		Priority: int32(math.MaxUint16 - pdr.precedence),
-	}
-
 	var (
 		appIP, appIPMask uint32 = 0, 0
-		appPort          portRange
+		appPort                 = newWildcardPortRange()
 	)
 
 	if pdr.srcIface == access {
@@ -508,40 +337,34 @@ func (t *P4rtTranslator) BuildApplicationsTableEntry(pdr pdr, sliceID uint8, int
 	}
 
 	appProto, appProtoMask := pdr.appFilter.proto, pdr.appFilter.protoMask
-
-	if err := t.withExactMatchField(entry, FieldSliceID, sliceID); err != nil {
-		return nil, err
-	}
-
-	appIPPrefixLen := 32 - bits.TrailingZeros32(appIPMask)
-	if appIPPrefixLen > 0 {
-		if err := t.withLPMField(entry, FieldAppIPAddress, appIP, uint8(appIPPrefixLen)); err != nil {
-			return nil, err
-		}
+	if appProto == 0 {
+		// wildcard, omitted from the entry
+		appProtoMask = 0
 	}
 
-	if !appPort.isWildcardMatch() {
-		if err := t.withRangeMatchField(entry, FieldAppL4Port, appPort.low, appPort.high); err != nil {
-			return nil, err
-		}
-	}
-
-	if appProto != 0 && appProtoMask != 0 {
-		if err := t.withTernaryMatchField(entry, FieldAppIPProto, appProto, appProtoMask); err != nil {
-			return nil, err
-		}
-	}
-
-	action := &p4.Action{
-		ActionId: p4constants.ActionPreQosPipeSetAppId,
+	appID, err := toUint8("internalAppID", internalAppID)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := t.withActionParam(action, FieldApplicationID, internalAppID); err != nil {
+	action, err := p4constants.BuildActionPreQosPipeSetAppId(p4constants.ActionPreQosPipeSetAppIdParams{
+		AppId: appID,
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	entry.Action = &p4.TableAction{
-		Type: &p4.TableAction_Action{Action: action},
+	entry, err := p4constants.BuildTablePreQosPipeApplicationsEntry(p4constants.TablePreQosPipeApplicationsMatch{
+		SliceId:            sliceID,
+		AppIpAddr:          appIP,
+		AppIpAddrPrefixLen: int32(32 - bits.TrailingZeros32(appIPMask)),
+		AppL4PortLow:       appPort.low,
+		AppL4PortHigh:      appPort.high,
+		AppIpProto:         appProto,
+		AppIpProtoMask:     appProtoMask,
+	}, action, int32(math.MaxUint16-pdr.precedence)) // priority for UP4 cannot be greater than 65535
+	if err != nil {
+		return nil, err
 	}
 
 	applicationsBuilderLog = applicationsBuilderLog.WithField("entry", entry)
@@ -557,37 +380,27 @@ func (t *P4rtTranslator) buildUplinkSessionsEntry(pdr pdr, sessMeterIdx uint32)
 	})
 	uplinkBuilderLog.Trace("Building P4rt table entry for sessions_uplink table")
 
-	entry := &p4.TableEntry{
-		TableId:  p4constants.TablePreQosPipeSessionsUplink,
-		Priority: DefaultPriority,
-	}
-
-	if err := t.withExactMatchField(entry, FieldN3Address, pdr.tunnelIP4Dst); err != nil {
-		return nil, err
-	}
-
-	if err := t.withExactMatchField(entry, FieldTEID, pdr.tunnelTEID); err != nil {
+	action, err := p4constants.BuildActionPreQosPipeSetSessionUplink(p4constants.ActionPreQosPipeSetSessionUplinkParams{
+		SessionMeterIdx: sessMeterIdx,
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	action := &p4.Action{
-		ActionId: p4constants.ActionPreQosPipeSetSessionUplink,
-	}
-
-	if err := t.withActionParam(action, FieldSessionMeterIndex, sessMeterIdx); err != nil {
+	entry, err := p4constants.BuildTablePreQosPipeSessionsUplinkEntry(p4constants.TablePreQosPipeSessionsUplinkMatch{
+		N3Address: pdr.tunnelIP4Dst,
+		Teid:      pdr.tunnelTEID,
+	}, action)
+	if err != nil {
 		return nil, err
 	}
 
-	entry.Action = &p4.TableAction{
-		Type: &p4.TableAction_Action{Action: action},
-	}
-
 	uplinkBuilderLog.WithField("entry", entry).Trace("Built P4rt table entry for sessions_uplink table")
 
 	return entry, nil
 }
 
-func (t *P4rtTranslator) buildDownlinkSessionsEntry(pdr pdr, sessMeterIdx uint32, tunnelPeerID uint8, needsBuffering bool) (*p4.TableEntry, error) {
+func (t *P4rtTranslator) buildDownlinkSessionsEntry(pdr pdr, sessMeterIdx uint32, tunnelPeerID uint16, needsBuffering bool) (*p4.TableEntry, error) {
 	builderLog := log.WithFields(log.Fields{
 		"pdr":               pdr,
 		"sessionMeterIndex": sessMeterIdx,
@@ -596,35 +409,38 @@ func (t *P4rtTranslator) buildDownlinkSessionsEntry(pdr pdr, sessMeterIdx uint32
 	})
 	builderLog.Trace("Building P4rt table entry for sessions_downlink table")
 
-	entry := &p4.TableEntry{
-		TableId:  p4constants.TablePreQosPipeSessionsDownlink,
-		Priority: DefaultPriority,
-	}
-
-	if err := t.withExactMatchField(entry, FieldUEAddress, pdr.ueAddress); err != nil {
-		return nil, err
-	}
+	var (
+		action *p4.Action
+		err    error
+	)
 
-	var action *p4.Action
 	if needsBuffering {
-		action = &p4.Action{
-			ActionId: p4constants.ActionPreQosPipeSetSessionDownlinkBuff,
-		}
+		action, err = p4constants.BuildActionPreQosPipeSetSessionDownlinkBuff(p4constants.ActionPreQosPipeSetSessionDownlinkBuffParams{
+			SessionMeterIdx: sessMeterIdx,
+		})
 	} else {
-		action = &p4.Action{
-			ActionId: p4constants.ActionPreQosPipeSetSessionDownlink,
-		}
-		if err := t.withActionParam(action, FieldTunnelPeerID, tunnelPeerID); err != nil {
+		var peerID uint8
+
+		peerID, err = toUint8("tunnelPeerID", tunnelPeerID)
+		if err != nil {
 			return nil, err
 		}
+
+		action, err = p4constants.BuildActionPreQosPipeSetSessionDownlink(p4constants.ActionPreQosPipeSetSessionDownlinkParams{
+			TunnelPeerId:    peerID,
+			SessionMeterIdx: sessMeterIdx,
+		})
 	}
 
-	if err := t.withActionParam(action, FieldSessionMeterIndex, sessMeterIdx); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	entry.Action = &p4.TableAction{
-		Type: &p4.TableAction_Action{Action: action},
+	entry, err := p4constants.BuildTablePreQosPipeSessionsDownlinkEntry(p4constants.TablePreQosPipeSessionsDownlinkMatch{
+		UeAddress: pdr.ueAddress,
+	}, action)
+	if err != nil {
+		return nil, err
 	}
 
 	builderLog.WithField("entry", entry).Trace("Built P4rt table entry for sessions_downlink table")
@@ -632,7 +448,7 @@ func (t *P4rtTranslator) buildDownlinkSessionsEntry(pdr pdr, sessMeterIdx uint32
 	return entry, nil
 }
 
-func (t *P4rtTranslator) BuildSessionsTableEntry(pdr pdr, sessionMeter meter, tunnelPeerID uint8, needsBuffering bool) (*p4.TableEntry, error) {
+func (t *P4rtTranslator) BuildSessionsTableEntry(pdr pdr, sessionMeter meter, tunnelPeerID uint16, needsBuffering bool) (*p4.TableEntry, error) {
 	switch pdr.srcIface {
 	case access:
 		return t.buildUplinkSessionsEntry(pdr, sessionMeter.uplinkCellID)
@@ -643,7 +459,7 @@ func (t *P4rtTranslator) BuildSessionsTableEntry(pdr pdr, sessionMeter meter, tu
 	}
 }
 
-func (t *P4rtTranslator) buildUplinkTerminationsEntry(pdr pdr, appMeterIdx uint32, shouldDrop bool, internalAppID uint8, tc uint8, relatedQER qer) (*p4.TableEntry, error) {
+func (t *P4rtTranslator) buildUplinkTerminationsEntry(pdr pdr, appMeterIdx uint32, shouldDrop bool, internalAppID uint16, tc uint8, relatedQER qer) (*p4.TableEntry, error) {
 	builderLog := log.WithFields(log.Fields{
 		"pdr":           pdr,
 		"appMeterIndex": appMeterIdx,
@@ -651,48 +467,39 @@ func (t *P4rtTranslator) buildUplinkTerminationsEntry(pdr pdr, appMeterIdx uint3
 	})
 	builderLog.Debug("Building P4rt table entry for UP4 terminations_uplink table")
 
-	entry := &p4.TableEntry{
-		TableId:  p4constants.TablePreQosPipeTerminationsUplink,
-		Priority: DefaultPriority,
-	}
-
 	// QER gating
 	if relatedQER.ulStatus == ie.GateStatusClosed {
 		shouldDrop = true
 	}
 
-	if err := t.withExactMatchField(entry, FieldUEAddress, pdr.ueAddress); err != nil {
-		return nil, err
-	}
-
-	if err := t.withExactMatchField(entry, FieldApplicationID, internalAppID); err != nil {
+	appID, err := toUint8("internalAppID", internalAppID)
+	if err != nil {
 		return nil, err
 	}
 
 	var action *p4.Action
 	if shouldDrop {
-		action = &p4.Action{
-			ActionId: p4constants.ActionPreQosPipeUplinkTermDrop,
-		}
+		action, err = p4constants.BuildActionPreQosPipeUplinkTermDrop(p4constants.ActionPreQosPipeUplinkTermDropParams{
+			CtrIdx: pdr.ctrID,
+		})
 	} else {
-		action = &p4.Action{
-			ActionId: p4constants.ActionPreQosPipeUplinkTermFwd,
-		}
-
-		if err := t.withActionParam(action, FieldTrafficClass, tc); err != nil {
-			return nil, err
-		}
-		if err := t.withActionParam(action, FieldAppMeterIndex, appMeterIdx); err != nil {
-			return nil, err
-		}
+		action, err = p4constants.BuildActionPreQosPipeUplinkTermFwd(p4constants.ActionPreQosPipeUplinkTermFwdParams{
+			CtrIdx:      pdr.ctrID,
+			Tc:          tc,
+			AppMeterIdx: appMeterIdx,
+		})
 	}
 
-	if err := t.withActionParam(action, FieldCounterIndex, pdr.ctrID); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	entry.Action = &p4.TableAction{
-		Type: &p4.TableAction_Action{Action: action},
+	entry, err := p4constants.BuildTablePreQosPipeTerminationsUplinkEntry(p4constants.TablePreQosPipeTerminationsUplinkMatch{
+		UeAddress: pdr.ueAddress,
+		AppId:     appID,
+	}, action)
+	if err != nil {
+		return nil, err
 	}
 
 	builderLog.WithField("entry", entry).Debug("Built P4rt table entry for terminations_uplink table")
@@ -701,7 +508,7 @@ func (t *P4rtTranslator) buildUplinkTerminationsEntry(pdr pdr, appMeterIdx uint3
 }
 
 func (t *P4rtTranslator) buildDownlinkTerminationsEntry(pdr pdr, appMeterIdx uint32, relatedFAR far,
-	internalAppID uint8, qfi uint8, tc uint8, relatedQER qer) (*p4.TableEntry, error) {
+	internalAppID uint16, qfi uint8, tc uint8, relatedQER qer) (*p4.TableEntry, error) {
 	builderLog := log.WithFields(log.Fields{
 		"pdr":           pdr,
 		"appMeterIndex": appMeterIdx,
@@ -710,57 +517,41 @@ func (t *P4rtTranslator) buildDownlinkTerminationsEntry(pdr pdr, appMeterIdx uin
 	})
 	builderLog.Debug("Building P4rt table entry for UP4 terminations_downlink table")
 
-	entry := &p4.TableEntry{
-		TableId:  p4constants.TablePreQosPipeTerminationsDownlink,
-		Priority: DefaultPriority,
-	}
-
 	shouldDrop := false
 	if relatedFAR.Drops() || relatedQER.dlStatus == ie.GateStatusClosed {
 		shouldDrop = true
 	}
 
-	if err := t.withExactMatchField(entry, FieldUEAddress, pdr.ueAddress); err != nil {
-		return nil, err
-	}
-
-	if err := t.withExactMatchField(entry, FieldApplicationID, internalAppID); err != nil {
+	appID, err := toUint8("internalAppID", internalAppID)
+	if err != nil {
 		return nil, err
 	}
 
 	var action *p4.Action
 	if shouldDrop {
-		action = &p4.Action{
-			ActionId: p4constants.ActionPreQosPipeDownlinkTermDrop,
-		}
+		action, err = p4constants.BuildActionPreQosPipeDownlinkTermDrop(p4constants.ActionPreQosPipeDownlinkTermDropParams{
+			CtrIdx: pdr.ctrID,
+		})
 	} else {
-		action = &p4.Action{
-			ActionId: p4constants.ActionPreQosPipeDownlinkTermFwd,
-		}
-
-		if err := t.withActionParam(action, FieldTEID, relatedFAR.tunnelTEID); err != nil {
-			return nil, err
-		}
-
-		if err := t.withActionParam(action, FieldQFI, qfi); err != nil {
-			return nil, err
-		}
-
-		if err := t.withActionParam(action, FieldTrafficClass, tc); err != nil {
-			return nil, err
-		}
-
-		if err := t.withActionParam(action, FieldAppMeterIndex, appMeterIdx); err != nil {
-			return nil, err
-		}
+		action, err = p4constants.BuildActionPreQosPipeDownlinkTermFwd(p4constants.ActionPreQosPipeDownlinkTermFwdParams{
+			CtrIdx:      pdr.ctrID,
+			Teid:        relatedFAR.tunnelTEID,
+			Qfi:         qfi,
+			Tc:          tc,
+			AppMeterIdx: appMeterIdx,
+		})
 	}
 
-	if err := t.withActionParam(action, FieldCounterIndex, pdr.ctrID); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	entry.Action = &p4.TableAction{
-		Type: &p4.TableAction_Action{Action: action},
+	entry, err := p4constants.BuildTablePreQosPipeTerminationsDownlinkEntry(p4constants.TablePreQosPipeTerminationsDownlinkMatch{
+		UeAddress: pdr.ueAddress,
+		AppId:     appID,
+	}, action)
+	if err != nil {
+		return nil, err
 	}
 
 	builderLog.WithField("entry", entry).Debug("Built P4rt table entry for terminations_downlink table")
@@ -768,7 +559,7 @@ func (t *P4rtTranslator) buildDownlinkTerminationsEntry(pdr pdr, appMeterIdx uin
 	return entry, nil
 }
 
-func (t *P4rtTranslator) BuildTerminationsTableEntry(pdr pdr, appMeter meter, relatedFAR far, internalAppID uint8, qfi uint8, tc uint8, relatedQER qer) (*p4.TableEntry, error) {
+func (t *P4rtTranslator) BuildTerminationsTableEntry(pdr pdr, appMeter meter, relatedFAR far, internalAppID uint16, qfi uint8, tc uint8, relatedQER qer) (*p4.TableEntry, error) {
 	switch pdr.srcIface {
 	case access:
 		return t.buildUplinkTerminationsEntry(pdr, appMeter.uplinkCellID, relatedFAR.Drops(), internalAppID, tc, relatedQER)
@@ -779,44 +570,112 @@ func (t *P4rtTranslator) BuildTerminationsTableEntry(pdr pdr, appMeter meter, re
 	}
 }
 
-func (t *P4rtTranslator) BuildGTPTunnelPeerTableEntry(tunnelPeerID uint8, tunnelParams tunnelParams) (*p4.TableEntry, error) {
+func (t *P4rtTranslator) BuildGTPTunnelPeerTableEntry(tunnelPeerID uint16, tunnelParams tunnelParams) (*p4.TableEntry, error) {
 	builderLog := log.WithFields(log.Fields{
 		"tunnelPeerID":  tunnelPeerID,
 		"tunnel-params": tunnelParams,
 	})
 	builderLog.Trace("Building P4rt table entry for GTP Tunnel Peers table")
 
-	entry := &p4.TableEntry{
-		TableId:  p4constants.TablePreQosPipeTunnelPeers,
-		Priority: DefaultPriority,
-		Action: &p4.TableAction{
-			Type: &p4.TableAction_Action{
-				Action: &p4.Action{
-					ActionId: p4constants.ActionPreQosPipeLoadTunnelParam,
-				},
-			},
-		},
+	peerID, err := toUint8("tunnelPeerID", tunnelPeerID)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := t.withExactMatchField(entry, FieldTunnelPeerID, tunnelPeerID); err != nil {
+	action, err := p4constants.BuildActionPreQosPipeLoadTunnelParam(p4constants.ActionPreQosPipeLoadTunnelParamParams{
+		SrcAddr: tunnelParams.tunnelIP4Src,
+		DstAddr: tunnelParams.tunnelIP4Dst,
+		Sport:   tunnelParams.tunnelPort,
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if err := t.withActionParam(entry.GetAction().GetAction(), FieldTunnelSrcAddress, tunnelParams.tunnelIP4Src); err != nil {
+	entry, err := p4constants.BuildTablePreQosPipeTunnelPeersEntry(p4constants.TablePreQosPipeTunnelPeersMatch{
+		TunnelPeerId: peerID,
+	}, action)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := t.withActionParam(entry.GetAction().GetAction(), FieldTunnelDstAddress, tunnelParams.tunnelIP4Dst); err != nil {
-		return nil, err
+	builderLog.WithField("entry", entry).Debug("Built P4rt table entry for GTP Tunnel Peers table")
+
+	return entry, nil
+}
+
+// ParseGTPTunnelPeerTableEntry returns the tunnel peer ID and the tunnel params of a GTP Tunnel Peers table entry.
+func (t *P4rtTranslator) ParseGTPTunnelPeerTableEntry(entry *p4.TableEntry) (uint16, tunnelParams, error) {
+	var params tunnelParams
+
+	mf, err := t.getFieldMatch(entry, FieldTunnelPeerID)
+	if err != nil {
+		return 0, params, err
 	}
 
-	if err := t.withActionParam(entry.GetAction().GetAction(), FieldTunnelSrcPort, tunnelParams.tunnelPort); err != nil {
-		return nil, err
+	if mf.GetExact() == nil {
+		return 0, params, ErrInvalidArgumentWithReason("entry", entry, "no exact match on tunnel peer ID")
 	}
 
-	builderLog.WithField("entry", entry).Debug("Built P4rt table entry for GTP Tunnel Peers table")
+	values := make(map[string]uint64)
 
-	return entry, nil
+	for _, name := range []string{FieldTunnelSrcAddress, FieldTunnelDstAddress, FieldTunnelSrcPort} {
+		values[name], err = t.getActionParamValueByName(entry, name)
+		if err != nil {
+			return 0, params, err
+		}
+	}
+
+	params = tunnelParams{
+		tunnelIP4Src: uint32(values[FieldTunnelSrcAddress]),
+		tunnelIP4Dst: uint32(values[FieldTunnelDstAddress]),
+		tunnelPort:   uint16(values[FieldTunnelSrcPort]),
+	}
+
+	return uint16(bytesToUint64(mf.GetExact().GetValue())), params, nil
+}
+
+// ParseApplicationsTableEntry returns the application filter and the internal application ID of an Applications table entry.
+func (t *P4rtTranslator) ParseApplicationsTableEntry(entry *p4.TableEntry) (up4ApplicationFilter, uint16, error) {
+	appFilter := up4ApplicationFilter{
+		appL4Port: newWildcardPortRange(),
+	}
+
+	appID, err := t.getActionParamValueByName(entry, FieldApplicationID)
+	if err != nil {
+		return appFilter, 0, err
+	}
+
+	mf, err := t.getFieldMatch(entry, FieldAppIPAddress)
+	if err != nil {
+		return appFilter, 0, err
+	}
+
+	if lpm := mf.GetLpm(); lpm != nil {
+		appFilter.appIP = uint32(bytesToUint64(lpm.GetValue()))
+	}
+
+	mf, err = t.getFieldMatch(entry, FieldAppL4Port)
+	if err != nil {
+		return appFilter, 0, err
+	}
+
+	if r := mf.GetRange(); r != nil {
+		appFilter.appL4Port = portRange{
+			low:  uint16(bytesToUint64(r.GetLow())),
+			high: uint16(bytesToUint64(r.GetHigh())),
+		}
+	}
+
+	mf, err = t.getFieldMatch(entry, FieldAppIPProto)
+	if err != nil {
+		return appFilter, 0, err
+	}
+
+	if ternary := mf.GetTernary(); ternary != nil {
+		appFilter.appProto = uint8(bytesToUint64(ternary.GetValue()))
+	}
+
+	return appFilter, uint16(appID), nil
 }
 
 func (t *P4rtTranslator) BuildMeterEntry(meterID uint32, cellID uint32, config *p4.MeterConfig) *p4.MeterEntry {
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"testing"
+
+	"github.com/omec-project/upf-epc/internal/p4constants"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_P4rtTranslator_BuildGTPTunnelPeerTableEntry(t *testing.T) {
+	tr := newMastershipTestTranslator(t)
+
+	entry, err := tr.BuildGTPTunnelPeerTableEntry(2, tunnelParams{
+		tunnelIP4Src: 0x0a000001,
+		tunnelIP4Dst: 0x0a000002,
+		tunnelPort:   2152,
+	})
+	require.NoError(t, err)
+	require.Equal(t, p4constants.TablePreQosPipeTunnelPeers, entry.GetTableId())
+	// values are encoded in ceil(bitwidth/8) bytes
+	require.Equal(t, []byte{0x02}, entry.GetMatch()[0].GetExact().GetValue())
+
+	params := entry.GetAction().GetAction().GetParams()
+	require.Len(t, params, 3)
+	require.Equal(t, []byte{0x0a, 0x00, 0x00, 0x01}, params[0].GetValue())
+	require.Equal(t, []byte{0x08, 0x68}, params[2].GetValue())
+
+	peerID, parsedParams, err := tr.ParseGTPTunnelPeerTableEntry(entry)
+	require.NoError(t, err)
+	require.Equal(t, uint16(2), peerID)
+	require.Equal(t, uint16(2152), parsedParams.tunnelPort)
+
+	// tunnel peer IDs are 8-bit wide
+	_, err = tr.BuildGTPTunnelPeerTableEntry(256, tunnelParams{})
+	require.ErrorIs(t, err, errInvalidArgument)
+}
+
+func Test_P4rtTranslator_BuildApplicationsTableEntry(t *testing.T) {
+	tr := newMastershipTestTranslator(t)
+
+	p := pdr{srcIface: access, precedence: 10}
+	p.appFilter.dstIP = 0x0a0000ff
+	p.appFilter.dstIPMask = 0xffffff00
+	p.appFilter.dstPortRange = newWildcardPortRange()
+
+	entry, err := tr.BuildApplicationsTableEntry(p, 1, 3)
+	require.NoError(t, err)
+	require.Equal(t, int32(65525), entry.GetPriority())
+	// wildcard port range and protocol are omitted
+	require.Len(t, entry.GetMatch(), 2)
+	// LPM values are masked to the prefix length
+	require.Equal(t, []byte{0x0a, 0x00, 0x00, 0x00}, entry.GetMatch()[1].GetLpm().GetValue())
+	require.Equal(t, int32(24), entry.GetMatch()[1].GetLpm().GetPrefixLen())
+
+	// slice IDs are 4-bit wide
+	_, err = tr.BuildApplicationsTableEntry(p, 16, 3)
+	require.ErrorIs(t, err, p4constants.ErrInvalidValue)
+}
+
+func Test_P4rtTranslator_getIDPoolSize(t *testing.T) {
+	tr := newMastershipTestTranslator(t)
+
+	// 8-bit IDs, 0 and 1 (dbuf) being reserved
+	size, err := tr.getIDPoolSize(p4constants.TablePreQosPipeTunnelPeers, reservedGTPTunnelPeerEntries,
+		p4constants.TablePreQosPipeTunnelPeers, FieldTunnelPeerID, firstGTPTunnelPeerID)
+	require.NoError(t, err)
+	require.Equal(t, int64(254), size)
+
+	size, err = tr.getIDPoolSize(p4constants.TablePreQosPipeApplications, 0,
+		p4constants.TablePreQosPipeTerminationsUplink, FieldApplicationID, firstApplicationID)
+	require.NoError(t, err)
+	require.Equal(t, int64(255), size)
+
+	// the table size is the limit when smaller than the ID space
+	size, err = tr.getIDPoolSize(p4constants.TablePreQosPipeTunnelPeers, 1020,
+		p4constants.TablePreQosPipeTunnelPeers, FieldTunnelPeerID, firstGTPTunnelPeerID)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), size)
+}
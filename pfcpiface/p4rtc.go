@@ -6,7 +6,10 @@ package pfcpiface
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc/connectivity"
@@ -40,14 +43,65 @@ const (
 	FunctionTypeDelete uint8 = 3 // Delete table Entry Function
 )
 
+// MastershipRole is the role of a client in the P4Runtime mastership arbitration of a device.
+type MastershipRole int32
+
+const (
+	// MastershipUnknown is the role until the first arbitration update, or after the stream failed.
+	MastershipUnknown MastershipRole = iota
+	// MastershipPrimary is the role of the client allowed to write to the device.
+	MastershipPrimary
+	// MastershipBackup is the role of a client while another client is primary.
+	MastershipBackup
+	// MastershipNoPrimary is the role of a client while no client is primary.
+	MastershipNoPrimary
+)
+
+func (r MastershipRole) String() string {
+	switch r {
+	case MastershipPrimary:
+		return "primary"
+	case MastershipBackup:
+		return "backup"
+	case MastershipNoPrimary:
+		return "no primary"
+	default:
+		return "unknown"
+	}
+}
+
+// arbitrationRole returns the role of a client from the status of an arbitration update.
+// See https://p4.org/p4-spec/p4runtime/main/P4Runtime-Spec.html#sec-client-arbitration-and-controller-replication.
+func arbitrationRole(arb *p4.MasterArbitrationUpdate) MastershipRole {
+	switch code.Code(arb.GetStatus().GetCode()) {
+	case code.Code_OK:
+		return MastershipPrimary
+	case code.Code_ALREADY_EXISTS:
+		return MastershipBackup
+	case code.Code_NOT_FOUND:
+		return MastershipNoPrimary
+	default:
+		return MastershipUnknown
+	}
+}
+
 // P4rtClient ... P4 Runtime client object.
 type P4rtClient struct {
-	client     p4.P4RuntimeClient
-	conn       *grpc.ClientConn
-	stream     p4.P4Runtime_StreamChannelClient
+	client   p4.P4RuntimeClient
+	conn     *grpc.ClientConn
+	stream   p4.P4Runtime_StreamChannelClient
+	deviceID uint64
+	digests  chan *p4.DigestList
+
+	// electionMu guards electionID, as the mastership may be re-arbitrated while writing.
+	electionMu sync.RWMutex
 	electionID p4.Uint128
-	deviceID   uint64
-	digests    chan *p4.DigestList
+	// role is the last MastershipRole received from the device.
+	role int32
+	// streamFailed is set when the stream channel is broken.
+	streamFailed int32
+	// arbitrations receives the roles of arbitration updates, it's closed when the stream fails.
+	arbitrations chan MastershipRole
 
 	// exported fields
 	P4Info *p4ConfigV1.P4Info
@@ -116,6 +170,15 @@ func TimeBasedElectionId() p4.Uint128 {
 	}
 }
 
+// StandbyElectionId generates an election id lower than any TimeBasedElectionId,
+// so that a client using it becomes primary only if no other client is connected.
+func StandbyElectionId() p4.Uint128 {
+	return p4.Uint128{
+		High: 0,
+		Low:  uint64(time.Now().UnixNano()),
+	}
+}
+
 // CheckStatus ... Check client connection status.
 func (c *P4rtClient) CheckStatus() connectivity.State {
 	return c.conn.GetState()
@@ -123,7 +186,10 @@ func (c *P4rtClient) CheckStatus() connectivity.State {
 
 // SetMastership .. API.
 func (c *P4rtClient) SetMastership(electionID p4.Uint128) (err error) {
+	c.electionMu.Lock()
 	c.electionID = electionID
+	c.electionMu.Unlock()
+
 	mastershipReq := &p4.StreamMessageRequest{
 		Update: &p4.StreamMessageRequest_Arbitration{
 			Arbitration: &p4.MasterArbitrationUpdate{
@@ -137,6 +203,40 @@ func (c *P4rtClient) SetMastership(electionID p4.Uint128) (err error) {
 	return
 }
 
+// ElectionID returns the election id of the last arbitration sent by the client.
+func (c *P4rtClient) ElectionID() p4.Uint128 {
+	c.electionMu.RLock()
+	defer c.electionMu.RUnlock()
+
+	return c.electionID
+}
+
+// Role returns the role of the client from the last arbitration update.
+func (c *P4rtClient) Role() MastershipRole {
+	return MastershipRole(atomic.LoadInt32(&c.role))
+}
+
+// IsStreamUp returns true until the stream channel is broken.
+func (c *P4rtClient) IsStreamUp() bool {
+	return c.stream != nil && atomic.LoadInt32(&c.streamFailed) == 0
+}
+
+// Arbitrations returns the roles of the client from the arbitration updates, the channel is closed
+// when the stream fails. Updates are dropped if they are not consumed.
+func (c *P4rtClient) Arbitrations() <-chan MastershipRole {
+	return c.arbitrations
+}
+
+func (c *P4rtClient) setRole(role MastershipRole) {
+	atomic.StoreInt32(&c.role, int32(role))
+
+	select {
+	case c.arbitrations <- role:
+	default:
+		log.WithField("role", role).Trace("arbitration update not consumed")
+	}
+}
+
 // SendPacketOut .. send packet out p4 server.
 func (c *P4rtClient) SendPacketOut(packet []byte) (err error) {
 	pktOutReq := &p4.StreamMessageRequest{
@@ -163,17 +263,24 @@ func (c *P4rtClient) Init() (err error) {
 	}
 
 	go func() {
+		defer close(c.arbitrations)
+
 		for {
 			res, err := c.stream.Recv()
 			if err != nil {
 				log.Println("stream recv error: ", err)
+				atomic.StoreInt32(&c.role, int32(MastershipUnknown))
+				atomic.StoreInt32(&c.streamFailed, 1)
+
 				return
 			} else if arb := res.GetArbitration(); arb != nil {
-				if code.Code(arb.Status.Code) == code.Code_OK {
-					log.Println("client is master")
-				} else {
-					log.Println("client is not master")
-				}
+				role := arbitrationRole(arb)
+				log.WithFields(log.Fields{
+					"role":                role,
+					"primary election id": arb.GetElectionId(),
+				}).Info("P4Runtime mastership arbitration update")
+
+				c.setRole(role)
 			} else if dig := res.GetDigest(); dig != nil {
 				c.digests <- dig
 			} else {
@@ -232,24 +339,61 @@ func (c *P4rtClient) ReadTableEntry(entry *p4.TableEntry) (*p4.ReadResponse, err
 	return c.ReadReq(entity)
 }
 
+// ReadCounterEntries .. Read a batch of counter entries in a single request.
+func (c *P4rtClient) ReadCounterEntries(entries []*p4.CounterEntry) (*p4.ReadResponse, error) {
+	entities := make([]*p4.Entity, 0, len(entries))
+
+	for _, entry := range entries {
+		entities = append(entities, &p4.Entity{
+			Entity: &p4.Entity_CounterEntry{CounterEntry: entry},
+		})
+	}
+
+	return c.ReadReqEntities(entities)
+}
+
 // ReadReqEntities ... Read request Entity.
 func (c *P4rtClient) ReadReqEntities(entities []*p4.Entity) (*p4.ReadResponse, error) {
 	req := &p4.ReadRequest{
 		DeviceId: c.deviceID,
 		Entities: entities,
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		2*time.Second)
+	defer cancel()
+
 	log.Traceln(proto.MarshalTextString(req))
 
-	readClient, err := c.client.Read(context.Background(), req)
-	if err == nil {
-		readRes, err := readClient.Recv()
-		if err == nil {
-			log.Traceln(proto.MarshalTextString(readRes))
-			return readRes, nil
+	readClient, err := c.client.Read(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return recvAllReadResponses(readClient)
+}
+
+// recvAllReadResponses merges all responses of a server-streamed Read RPC.
+// P4Runtime servers are free to split large read results over multiple messages.
+func recvAllReadResponses(readClient p4.P4Runtime_ReadClient) (*p4.ReadResponse, error) {
+	readRes := &p4.ReadResponse{}
+
+	for {
+		res, err := readClient.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
 		}
+
+		log.Traceln(proto.MarshalTextString(res))
+
+		readRes.Entities = append(readRes.Entities, res.GetEntities()...)
 	}
 
-	return nil, err
+	return readRes, nil
 }
 
 // ReadReq ... Read Request.
@@ -265,15 +409,11 @@ func (c *P4rtClient) ReadReq(entity *p4.Entity) (*p4.ReadResponse, error) {
 	log.Traceln(proto.MarshalTextString(&req))
 
 	readClient, err := c.client.Read(ctx, &req)
-	if err == nil {
-		readRes, err := readClient.Recv()
-		if err == nil {
-			log.Traceln(proto.MarshalTextString(readRes))
-			return readRes, nil
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, err
+	return recvAllReadResponses(readClient)
 }
 
 func (c *P4rtClient) ClearTable(tableID uint32) error {
@@ -397,9 +537,10 @@ func (c *P4rtClient) ApplyMeterEntries(methodType p4.Update_Type, entries ...*p4
 
 // WriteReq ... Write Request.
 func (c *P4rtClient) WriteReq(update *p4.Update) error {
+	electionID := c.ElectionID()
 	req := &p4.WriteRequest{
 		DeviceId:   c.deviceID,
-		ElectionId: &c.electionID,
+		ElectionId: &electionID,
 		Updates:    []*p4.Update{update},
 	}
 
@@ -410,16 +551,24 @@ func (c *P4rtClient) WriteReq(update *p4.Update) error {
 
 // WriteBatchReq ... Write batch Request to up4.
 func (c *P4rtClient) WriteBatchReq(updates []*p4.Update) error {
+	return c.WriteBatchReqWithAtomicity(context.Background(), updates, p4.WriteRequest_CONTINUE_ON_ERROR)
+}
+
+// WriteBatchReqWithAtomicity ... Write batch Request to up4 with the given atomicity.
+// Servers not supporting the requested atomicity return an Unimplemented error.
+func (c *P4rtClient) WriteBatchReqWithAtomicity(ctx context.Context, updates []*p4.Update, atomicity p4.WriteRequest_Atomicity) error {
+	electionID := c.ElectionID()
 	req := &p4.WriteRequest{
 		DeviceId:   c.deviceID,
-		ElectionId: &c.electionID,
+		ElectionId: &electionID,
+		Atomicity:  atomicity,
 	}
 
 	req.Updates = append(req.Updates, updates...)
 
 	log.Traceln(proto.MarshalTextString(req))
 
-	_, err := c.client.Write(context.Background(), req)
+	_, err := c.client.Write(ctx, req)
 
 	return convertError(err)
 }
@@ -498,7 +647,9 @@ func (c *P4rtClient) SetForwardingPipelineConfig(p4InfoPath, deviceConfigPath st
 	pipeline.P4Info = p4Info
 	pipeline.P4DeviceConfig = deviceConfig
 
-	err = SetPipelineConfig(c.client, c.deviceID, &c.electionID, &pipeline)
+	electionID := c.ElectionID()
+
+	err = SetPipelineConfig(c.client, c.deviceID, &electionID, &pipeline)
 	if err != nil {
 		log.Println("set pipeline config error ", err)
 		return
@@ -564,8 +715,8 @@ func LoadDeviceConfig(deviceConfigPath string) (P4DeviceConfig, error) {
 	return bin, nil
 }
 
-// CreateChannel ... Create p4runtime client channel.
-func CreateChannel(host string, deviceID uint64) (*P4rtClient, error) {
+// CreateChannel ... Create p4runtime client channel, arbitrating the mastership with the given election id.
+func CreateChannel(host string, deviceID uint64, electionID p4.Uint128) (*P4rtClient, error) {
 	log.Println("create channel")
 
 	conn, err := GetConnection(host)
@@ -575,10 +726,11 @@ func CreateChannel(host string, deviceID uint64) (*P4rtClient, error) {
 	}
 
 	client := &P4rtClient{
-		digests:  make(chan *p4.DigestList, 1024),
-		client:   p4.NewP4RuntimeClient(conn),
-		conn:     conn,
-		deviceID: deviceID,
+		digests:      make(chan *p4.DigestList, 1024),
+		arbitrations: make(chan MastershipRole, 16),
+		client:       p4.NewP4RuntimeClient(conn),
+		conn:         conn,
+		deviceID:     deviceID,
 	}
 
 	err = client.Init()
@@ -596,7 +748,7 @@ func CreateChannel(host string, deviceID uint64) (*P4rtClient, error) {
 		}
 	}
 
-	err = client.SetMastership(TimeBasedElectionId())
+	err = client.SetMastership(electionID)
 	if err != nil {
 		log.Error("Set Mastership error: ", err)
 		closeStreamOnError()
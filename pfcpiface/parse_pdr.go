@@ -79,6 +79,11 @@ func (pr portRange) isRangeMatch() bool {
 	return !pr.isExactMatch() && !pr.isWildcardMatch()
 }
 
+// contains returns true if port is covered by this portRange.
+func (pr portRange) contains(port uint16) bool {
+	return pr.isWildcardMatch() || (pr.low <= port && port <= pr.high)
+}
+
 // Returns portRange as an exact match, without checking if it is one. isExactMatch() must be true
 // before calling asExactMatchUnchecked.
 func (pr portRange) asExactMatchUnchecked() portRangeTernaryRule {
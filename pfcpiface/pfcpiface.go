@@ -20,6 +20,7 @@ import (
 
 	reuse "github.com/libp2p/go-reuseport"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -39,11 +40,18 @@ type PFCPIface struct {
 
 	httpSrv      *http.Server
 	httpEndpoint string
+	// lbGRPCSrv serves the gRPC API of the UPF to the load balancers, if enabled
+	lbGRPCSrv *grpc.Server
+
+	reconciler *reconciler
+	registrar  *lbRegistrar
+	drainer    *drainer
 
 	uc *upfCollector
 	nc *PfcpNodeCollector
 
-	mu sync.Mutex
+	mu      sync.Mutex
+	stopped bool
 }
 
 func NewPFCPIface(conf Conf) *PFCPIface {
@@ -51,10 +59,10 @@ func NewPFCPIface(conf Conf) *PFCPIface {
 		conf: conf,
 	}
 
-	if conf.EnableP4rt {
-		pfcpIface.fp = &UP4{}
-	} else {
-		pfcpIface.fp = &bess{}
+	pfcpIface.fp = newDatapath(datapathName(conf))
+	if conf.Mirror.Secondary != "" {
+		pfcpIface.fp = newMirror(datapathName(conf), pfcpIface.fp,
+			conf.Mirror.Secondary, newDatapath(conf.Mirror.Secondary))
 	}
 
 	httpPort := "8080"
@@ -76,7 +84,41 @@ func (p *PFCPIface) mustInit() {
 	p.node = NewPFCPNode(p.upf, &p.conf)
 	httpMux := http.NewServeMux()
 
-	setupConfigHandler(httpMux, p.upf)
+	keepaliveInterval := lbKeepaliveIntervalDefault
+	if p.conf.LoadBalancers.KeepaliveInterval != "" {
+		keepaliveInterval, _ = time.ParseDuration(p.conf.LoadBalancers.KeepaliveInterval)
+	}
+
+	p.registrar = newLBRegistrar(p.upf, p.node.registerReq(), keepaliveInterval)
+	httpMux.Handle("/v1/lb/registration", p.registrar)
+	httpMux.HandleFunc("/v1/lb/ues", p.registrar.serveUEs)
+
+	setupConfigHandler(httpMux, p.upf, p.registrar)
+
+	if p.conf.LoadBalancers.GRPCListenAddr != "" {
+		var err error
+
+		p.lbGRPCSrv, err = newLBGRPCServer(p.upf, p.registrar, p.conf.HTTP)
+		if err != nil {
+			log.Fatalln("Failed to create the gRPC server of the load balancers:", err)
+		}
+	}
+
+	p.drainer = newDrainer(p.upf, p.registrar, p.conf.Drain, p.Stop)
+	httpMux.Handle("/v1/drain", p.drainer)
+
+	// an empty interval disables periodic reconciliation, which can still be triggered on demand
+	var reconcileInterval time.Duration
+	if p.conf.ReconcileInterval != "" {
+		reconcileInterval, _ = time.ParseDuration(p.conf.ReconcileInterval)
+	}
+
+	p.reconciler = newReconciler(p.upf, reconcileInterval)
+	httpMux.Handle("/v1/datapath/reconcile", p.reconciler)
+
+	if dp, ok := p.fp.(httpServingDatapath); ok {
+		dp.setupHandlers(httpMux)
+	}
 
 	var err error
 
@@ -89,7 +131,21 @@ func (p *PFCPIface) mustInit() {
 	// Note: due to error with golangci-lint ("Error: G112: Potential Slowloris Attack
 	// because ReadHeaderTimeout is not configured in the http.Server (gosec)"),
 	// the ReadHeaderTimeout is set to the same value as in nginx (client_header_timeout)
-	p.httpSrv = &http.Server{Addr: p.httpEndpoint, Handler: httpMux, ReadHeaderTimeout: 60 * time.Second}
+	if mode := p.conf.HTTP.Auth[httpRouteGroupConfig].Mode; mode == "" || mode == httpAuthNone {
+		log.Warnln("http.auth.config is not set, any host can configure, drain and stop the UPF")
+	}
+
+	handler, err := newHTTPAuthenticator(p.conf.HTTP, httpMux)
+	if err != nil {
+		log.Fatalln("Failed to set up the authentication of the HTTP API:", err)
+	}
+
+	tlsConfig, err := serverTLSConfig(p.conf.HTTP.TLS)
+	if err != nil {
+		log.Fatalln("Failed to load the TLS config of the HTTP API:", err)
+	}
+
+	p.httpSrv = &http.Server{Addr: p.httpEndpoint, Handler: handler, TLSConfig: tlsConfig, ReadHeaderTimeout: 60 * time.Second}
 }
 
 func (p *PFCPIface) Run() {
@@ -103,14 +159,39 @@ func (p *PFCPIface) Run() {
 
 	p.mustInit()
 
+	p.reconciler.Start()
+
 	go func() {
-		if err := p.httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if p.httpSrv.TLSConfig != nil {
+			// the certificates are in TLSConfig
+			err = p.httpSrv.ListenAndServeTLS("", "")
+		} else {
+			err = p.httpSrv.ListenAndServe()
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalln("http server failed", err)
 		}
 
 		log.Infoln("http server closed")
 	}()
 
+	if p.lbGRPCSrv != nil {
+		listener, err := net.Listen("tcp", p.conf.LoadBalancers.GRPCListenAddr)
+		if err != nil {
+			log.Fatalln("Failed to listen for the gRPC API of the load balancers:", err)
+		}
+
+		go func() {
+			if err := p.lbGRPCSrv.Serve(listener); err != nil {
+				log.Fatalln("gRPC server of the load balancers failed", err)
+			}
+
+			log.Infoln("gRPC server of the load balancers closed")
+		}()
+	}
+
 	//http.HandleFunc("/registergw", RegisterGw)
 	//server := http.Server{Addr: ":8082"}
 	//log.Traceln("starting http server on 8082")
@@ -128,16 +209,10 @@ func (p *PFCPIface) Run() {
 	//fmt.Println("parham log : calling PushPFCPInfo")
 	//lAddr := p.node.LocalAddr().String()
 	//PushPFCPInfo(lAddr)
-	fmt.Println("parham log : calling PushPFCPInfoNew")
-	fmt.Println("upf info before calling PushPFCPInfoNew :")
-	fmt.Println("dnn = ", p.node.upf.Dnn)
-	fmt.Println("AccessIP = ", p.node.upf.AccessIP)
-	fmt.Println("CoreIP = ", p.node.upf.CoreIP)
-	fmt.Println("nodeID = ", p.node.upf.NodeID)
-
-	//PushPFCPInfoNew(p.node.upf)
-	p.node.RegisterTolb(enterlb)
-	p.node.RegisterTolb(exitlb)
+	p.registrar.Start()
+	// the PFCP node is bound, the PFCP-LB can send it sessions once registered
+	p.registrar.setServing()
+
 	// blocking
 	p.node.Serve()
 }
@@ -181,73 +256,6 @@ func PushPFCPInfo(lAddr string) error {
 	return nil
 }
 
-func PushPFCPInfoNew(upf *upf) {
-	fmt.Println("waiting to register both Gateways")
-	time.Sleep(2 * time.Second)
-	for {
-		if upf.accessGwRegistered && upf.coreGwRegistered {
-			break
-		}
-		time.Sleep(1 * time.Second)
-	}
-	fmt.Println("registered both Gateways")
-	fmt.Println("upf info inside PushPFCPInfoNew :")
-	fmt.Println("dnn = ", upf.Dnn)
-	fmt.Println("AccessIP = ", upf.AccessIP)
-	fmt.Println("CoreIP = ", upf.CoreIP)
-	fmt.Println("nodeID = ", upf.NodeID)
-	// get IP
-	ip_str := GetLocalIP()
-	pfcpInfo := &PfcpInfo{
-		Ip:  ip_str,
-		Upf: upf,
-	}
-	fmt.Println("parham log : local ip = ", ip_str)
-	pfcpInfoJson, _ := json.Marshal(pfcpInfo)
-
-	fmt.Printf("parham log : json encoded pfcpInfo [%s] ", pfcpInfoJson)
-
-	// change the IP here
-	requestURL := "http://upf-http:8081/"
-	jsonBody := []byte(pfcpInfoJson)
-
-	bodyReader := bytes.NewReader(jsonBody)
-	req, err := http.NewRequest(http.MethodPost, requestURL, bodyReader)
-	if err != nil {
-		log.Errorf("client: could not create request: %s\n", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	client := http.Client{
-		Timeout: 10 * time.Second,
-	}
-	//upfhttpIP := net.ParseIP("upf")
-	//upfIP := net.ParseIP("upf-http")
-	//fmt.Println("parham log : ip of upfIP and upfhttpIP =", upfIP, upfhttpIP)
-	done := false
-	for !done {
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Errorf("client: error making http request: %s\n", err)
-			time.Sleep(1 * time.Second)
-		} else {
-			done = true
-			//body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				log.Errorf("error reading http respose: %s\n", err)
-			} else {
-				fmt.Println("parham log : resp header = ", resp.Header)
-				fmt.Println("parham log : resp status = ", resp.Status)
-			}
-
-			return
-		}
-	}
-	// waiting for http response
-
-}
-
 // GetLocalIP returns ip of first non loopback interface in string
 func GetLocalIP() string {
 	addrs, err := net.InterfaceAddrs()
@@ -291,10 +299,20 @@ func GetMac(interfaceName string) string {
 }
 
 // Stop sends cancellation signal to main Go routine and waits for shutdown to complete.
+// Stopping again, e.g. on a signal once drained, has no effect.
 func (p *PFCPIface) Stop() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.stopped {
+		return
+	}
+
+	p.stopped = true
+
+	// deregister first, so that the LBs stop sending traffic to the UPF
+	p.registrar.Stop()
+
 	ctxHttpShutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer func() {
 		cancel()
@@ -304,8 +322,24 @@ func (p *PFCPIface) Stop() {
 		log.Errorln("Failed to shutdown http: ", err)
 	}
 
+	if p.lbGRPCSrv != nil {
+		p.lbGRPCSrv.GracefulStop()
+	}
+
+	// allows starting a new PFCPIface in the same process, e.g. in tests
+	clearProm(p.uc, p.nc)
+
+	p.reconciler.Stop()
+
 	p.node.Stop()
 
 	// Wait for PFCP node shutdown
 	p.node.Done()
+
+	// the sessions are deleted once the PFCP connections are closed
+	p.upf.sessionHooks.close()
+
+	if err := p.upf.lbs().Close(); err != nil {
+		log.Warnln("Failed to close the client of the load balancers:", err)
+	}
 }
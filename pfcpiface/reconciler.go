@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// driftReport summarizes the differences found between the rules expected from the
+// PFCP sessions and the rules actually programmed in the datapath.
+type driftReport struct {
+	Datapath  string `json:"datapath"`
+	Missing   int    `json:"missing"`
+	Extra     int    `json:"extra"`
+	Different int    `json:"different"`
+	Repaired  int    `json:"repaired"`
+	Failed    int    `json:"failed"`
+}
+
+func (r driftReport) hasDrift() bool {
+	return r.Missing+r.Extra+r.Different > 0
+}
+
+// reconcilableDatapath is implemented by datapaths that can read back their forwarding state.
+type reconcilableDatapath interface {
+	// Reconcile compares the programmed state with the rules of the given sessions
+	// and repairs any missing, extra or different entries.
+	Reconcile(sessions []PFCPSession) (driftReport, error)
+}
+
+// reconcileEntry is a datapath entry identified by its match key and compared by its value.
+type reconcileEntry struct {
+	key   string
+	value string
+	// obj is the datapath-specific representation of the entry
+	obj interface{}
+}
+
+// entryDiff holds the result of comparing expected and actual datapath entries.
+type entryDiff struct {
+	// missing are expected entries not found in the datapath
+	missing []reconcileEntry
+	// extra are entries found in the datapath that are not expected
+	extra []reconcileEntry
+	// different are expected entries whose value differs from the one in the datapath
+	different []reconcileEntry
+}
+
+func diffEntries(expected, actual []reconcileEntry) entryDiff {
+	var diff entryDiff
+
+	actualByKey := make(map[string]reconcileEntry, len(actual))
+	for _, e := range actual {
+		actualByKey[e.key] = e
+	}
+
+	expectedKeys := make(map[string]struct{}, len(expected))
+
+	for _, e := range expected {
+		expectedKeys[e.key] = struct{}{}
+
+		a, ok := actualByKey[e.key]
+		if !ok {
+			diff.missing = append(diff.missing, e)
+			continue
+		}
+
+		if a.value != e.value {
+			diff.different = append(diff.different, e)
+		}
+	}
+
+	for _, a := range actual {
+		if _, ok := expectedKeys[a.key]; !ok {
+			diff.extra = append(diff.extra, a)
+		}
+	}
+
+	return diff
+}
+
+// add accumulates the counts of diff into the report.
+func (r *driftReport) add(diff entryDiff) {
+	r.Missing += len(diff.missing)
+	r.Extra += len(diff.extra)
+	r.Different += len(diff.different)
+}
+
+// reconciler periodically checks that the datapath state matches the PFCP sessions.
+type reconciler struct {
+	upf      *upf
+	interval time.Duration
+
+	// runMu ensures a single reconciliation run at a time
+	runMu sync.Mutex
+	// startOnce starts the periodic reconciliation, or closes done if Stop runs first
+	startOnce sync.Once
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newReconciler(upf *upf, interval time.Duration) *reconciler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &reconciler{
+		upf:      upf,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the periodic reconciliation in the background. No-op if the interval is zero.
+func (r *reconciler) Start() {
+	r.startOnce.Do(func() {
+		if r.interval == 0 {
+			close(r.done)
+			return
+		}
+
+		log.Infof("Starting datapath reconciliation every %v", r.interval)
+
+		go r.run()
+	})
+}
+
+func (r *reconciler) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := r.reconcileOnce(); err != nil {
+				log.Errorf("Datapath reconciliation failed: %v", err)
+			}
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop stops the periodic reconciliation and waits for the running one to complete.
+func (r *reconciler) Stop() {
+	r.cancel()
+	// Start never runs after Stop
+	r.startOnce.Do(func() { close(r.done) })
+	<-r.done
+}
+
+func (r *reconciler) reconcileOnce() (driftReport, error) {
+	dp, ok := r.upf.datapath.(reconcilableDatapath)
+	if !ok {
+		return driftReport{}, ErrUnsupported("reconciliation for datapath", fmt.Sprintf("%T", r.upf.datapath))
+	}
+
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	dpMetrics := getDatapathMetrics()
+
+	// the sessions being changed are only compared once their rules and their store agree, or
+	// the committed rules of a session not yet stored would be deleted as extra
+	r.upf.rulesMu.Lock()
+	report, err := dp.Reconcile(r.upf.allSessions())
+	r.upf.rulesMu.Unlock()
+
+	if err != nil {
+		dpMetrics.reconcileRuns.WithLabelValues(report.Datapath, "failure").Inc()
+		return report, err
+	}
+
+	dpMetrics.reconcileRuns.WithLabelValues(report.Datapath, "success").Inc()
+	dpMetrics.driftEntries.WithLabelValues(report.Datapath, "missing").Add(float64(report.Missing))
+	dpMetrics.driftEntries.WithLabelValues(report.Datapath, "extra").Add(float64(report.Extra))
+	dpMetrics.driftEntries.WithLabelValues(report.Datapath, "different").Add(float64(report.Different))
+	dpMetrics.driftRepairFailures.WithLabelValues(report.Datapath).Add(float64(report.Failed))
+
+	reportLog := log.WithFields(log.Fields{
+		"datapath":  report.Datapath,
+		"missing":   report.Missing,
+		"extra":     report.Extra,
+		"different": report.Different,
+		"repaired":  report.Repaired,
+		"failed":    report.Failed,
+	})
+
+	if report.hasDrift() {
+		reportLog.Warn("Datapath drift detected")
+	} else {
+		reportLog.Debug("No datapath drift detected")
+	}
+
+	return report, nil
+}
+
+// ServeHTTP triggers a reconciliation on demand and returns the drift report.
+func (r *reconciler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		sendHTTPResp(http.StatusMethodNotAllowed, w)
+		return
+	}
+
+	report, err := r.reconcileOnce()
+	if err != nil {
+		log.Errorf("On-demand datapath reconciliation failed: %v", err)
+		sendHTTPResp(http.StatusInternalServerError, w)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Errorln("http response write failed : ", err)
+	}
+}
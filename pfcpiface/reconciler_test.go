@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_diffEntries(t *testing.T) {
+	expected := []reconcileEntry{
+		{key: "a", value: "1"},
+		{key: "b", value: "2"},
+		{key: "c", value: "3"},
+	}
+
+	actual := []reconcileEntry{
+		{key: "a", value: "1"},
+		{key: "b", value: "20"},
+		{key: "d", value: "4"},
+	}
+
+	diff := diffEntries(expected, actual)
+
+	require.Equal(t, []reconcileEntry{{key: "c", value: "3"}}, diff.missing)
+	require.Equal(t, []reconcileEntry{{key: "b", value: "2"}}, diff.different, "expected value should be used for repair")
+	require.Equal(t, []reconcileEntry{{key: "d", value: "4"}}, diff.extra)
+
+	var report driftReport
+
+	report.add(diff)
+	require.Equal(t, driftReport{Missing: 1, Extra: 1, Different: 1}, report)
+
+	require.Empty(t, diffEntries(expected, expected))
+}
+
+// recordingReconcileDatapath records the sessions it is asked to reconcile.
+type recordingReconcileDatapath struct {
+	datapath
+	reconciled chan []PFCPSession
+}
+
+func (d *recordingReconcileDatapath) Reconcile(sessions []PFCPSession) (driftReport, error) {
+	d.reconciled <- sessions
+	return driftReport{Datapath: "recording"}, nil
+}
+
+func Test_reconciler_waitsForSessionChanges(t *testing.T) {
+	dp := &recordingReconcileDatapath{reconciled: make(chan []PFCPSession, 1)}
+	u := &upf{datapath: dp}
+
+	var stored []PFCPSession
+
+	u.setSessionsSource(func() []PFCPSession { return stored })
+
+	r := newReconciler(u, 0)
+
+	// the rules of a session are committed but the session is not stored yet
+	u.rulesMu.RLock()
+
+	go func() {
+		_, err := r.reconcileOnce()
+		require.NoError(t, err)
+	}()
+
+	require.Never(t, func() bool { return len(dp.reconciled) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	stored = []PFCPSession{{localSEID: 1}}
+	u.rulesMu.RUnlock()
+
+	select {
+	case sessions := <-dp.reconciled:
+		require.Equal(t, []PFCPSession{{localSEID: 1}}, sessions)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "reconciliation not run")
+	}
+}
+
+func Test_reconciler_stopWithoutStart(t *testing.T) {
+	r := newReconciler(&upf{}, time.Minute)
+
+	stopped := make(chan struct{})
+
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Stop blocked")
+	}
+
+	// Start is a no-op once stopped
+	r.Start()
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// webhookQueueSize bounds the events waiting to be sent to the webhook, the following
+	// events are dropped.
+	webhookQueueSize = 1024
+	// webhookTimeout is the timeout of the requests sent to the webhook.
+	webhookTimeout = 5 * time.Second
+)
+
+// webhookSessionHook posts each session event to a URL, in the background and in order.
+type webhookSessionHook struct {
+	sessionEventRecorder
+
+	url    string
+	client http.Client
+	events chan sessionEvent
+	done   chan struct{}
+}
+
+func newWebhookSessionHook(url string) *webhookSessionHook {
+	h := &webhookSessionHook{
+		url:    url,
+		client: http.Client{Timeout: webhookTimeout},
+		events: make(chan sessionEvent, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	h.sessionEventRecorder = sessionEventRecorder{record: h.enqueue}
+
+	go h.run()
+
+	return h
+}
+
+func (h *webhookSessionHook) enqueue(event sessionEvent) {
+	select {
+	case h.events <- event:
+	default:
+		log.Warnf("Session webhook queue full, dropping %v event of session %v", event.Event, event.LocalSEID)
+	}
+}
+
+func (h *webhookSessionHook) run() {
+	defer close(h.done)
+
+	for event := range h.events {
+		if err := h.post(event); err != nil {
+			log.Warnln("Failed to send session event to webhook:", err)
+		}
+	}
+}
+
+func (h *webhookSessionHook) post(event sessionEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s replied %s", h.url, resp.Status)
+	}
+
+	return nil
+}
+
+// Close sends the queued events, then stops.
+func (h *webhookSessionHook) Close() error {
+	close(h.events)
+	<-h.done
+
+	return nil
+}
+
+// fileSessionHook appends each session event to a file, as a line of JSON.
+type fileSessionHook struct {
+	sessionEventRecorder
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileSessionHook(path string) (*fileSessionHook, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &fileSessionHook{file: file}
+	h.sessionEventRecorder = sessionEventRecorder{record: h.write}
+
+	return h, nil
+}
+
+func (h *fileSessionHook) write(event sessionEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Errorln("Failed to encode session event:", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		log.Warnln("Failed to write session event:", err)
+	}
+}
+
+func (h *fileSessionHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.file.Close()
+}
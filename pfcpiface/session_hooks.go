@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sessionHook is notified of the lifecycle of the PFCP sessions by the message handlers, once
+// their rules are installed in the datapath and the session is stored. The hooks are called
+// from the goroutine handling the PFCP connection, and must not block it.
+type sessionHook interface {
+	// OnSessionCreated is called once session is established, priority is the message priority
+	// of the Session Establishment Request.
+	OnSessionCreated(pConn *PFCPConn, session PFCPSession, priority uint8)
+	// OnSessionModified is called once a session is modified from old to new.
+	OnSessionModified(pConn *PFCPConn, old, new PFCPSession, priority uint8)
+	// OnSessionDeleted is called once session is deleted, whether requested by the SMF or not.
+	OnSessionDeleted(pConn *PFCPConn, session PFCPSession)
+}
+
+// sessionHooks calls all its hooks in order.
+type sessionHooks []sessionHook
+
+// newSessionHooks returns the hooks of upf: the load balancers unless disabled, then the sinks of conf.
+func newSessionHooks(upf *upf, conf SessionHooksConf) sessionHooks {
+	var hooks sessionHooks
+
+	if !upf.lbDisabled {
+		hooks = append(hooks, newLBSessionHook(upf))
+	}
+
+	if conf.WebhookURL != "" {
+		hooks = append(hooks, newWebhookSessionHook(conf.WebhookURL))
+	}
+
+	if conf.LogFile != "" {
+		hook, err := newFileSessionHook(conf.LogFile)
+		if err != nil {
+			log.Fatalln("Failed to open the session log file:", err)
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks
+}
+
+func (hooks sessionHooks) created(pConn *PFCPConn, session PFCPSession, priority uint8) {
+	for _, h := range hooks {
+		h.OnSessionCreated(pConn, session, priority)
+	}
+}
+
+func (hooks sessionHooks) modified(pConn *PFCPConn, old, new PFCPSession, priority uint8) {
+	for _, h := range hooks {
+		h.OnSessionModified(pConn, old, new, priority)
+	}
+}
+
+func (hooks sessionHooks) deleted(pConn *PFCPConn, session PFCPSession) {
+	for _, h := range hooks {
+		h.OnSessionDeleted(pConn, session)
+	}
+}
+
+// close releases the resources of the hooks, once the PFCP connections are closed.
+func (hooks sessionHooks) close() {
+	for _, h := range hooks {
+		if c, ok := h.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Warnln("Failed to close session hook:", err)
+			}
+		}
+	}
+}
+
+// sessionRulesView is the JSON representation of the rules of a session.
+type sessionRulesView struct {
+	PDRs []shadowPDRView `json:"pdrs"`
+	FARs []shadowFARView `json:"fars"`
+	QERs []shadowQERView `json:"qers"`
+}
+
+func newSessionRulesView(rules PacketForwardingRules) *sessionRulesView {
+	v := &sessionRulesView{
+		PDRs: make([]shadowPDRView, 0, len(rules.pdrs)),
+		FARs: make([]shadowFARView, 0, len(rules.fars)),
+		QERs: make([]shadowQERView, 0, len(rules.qers)),
+	}
+
+	for _, p := range rules.pdrs {
+		v.PDRs = append(v.PDRs, newShadowPDRView(p))
+	}
+
+	for _, f := range rules.fars {
+		v.FARs = append(v.FARs, newShadowFARView(f))
+	}
+
+	for _, q := range rules.qers {
+		v.QERs = append(v.QERs, newShadowQERView(q))
+	}
+
+	return v
+}
+
+// sessionEvent is a session lifecycle event, as sent to the webhook and written to the log file.
+type sessionEvent struct {
+	Event      string            `json:"event"`
+	Time       time.Time         `json:"time"`
+	NodeID     string            `json:"nodeid"`
+	LocalSEID  uint64            `json:"local_seid"`
+	RemoteSEID uint64            `json:"remote_seid"`
+	Old        *sessionRulesView `json:"old,omitempty"`
+	New        *sessionRulesView `json:"new,omitempty"`
+}
+
+// sessionEventRecorder adapts a sink of sessionEvents to a sessionHook.
+type sessionEventRecorder struct {
+	record func(event sessionEvent)
+}
+
+func newSessionEvent(event string, pConn *PFCPConn, old, new *PFCPSession) sessionEvent {
+	e := sessionEvent{
+		Event:  event,
+		Time:   time.Now(),
+		NodeID: pConn.nodeID.remote,
+	}
+
+	for _, s := range []*PFCPSession{old, new} {
+		if s != nil {
+			e.LocalSEID, e.RemoteSEID = s.localSEID, s.remoteSEID
+		}
+	}
+
+	if old != nil {
+		e.Old = newSessionRulesView(old.PacketForwardingRules)
+	}
+
+	if new != nil {
+		e.New = newSessionRulesView(new.PacketForwardingRules)
+	}
+
+	return e
+}
+
+func (r sessionEventRecorder) OnSessionCreated(pConn *PFCPConn, session PFCPSession, priority uint8) {
+	r.record(newSessionEvent("created", pConn, nil, &session))
+}
+
+func (r sessionEventRecorder) OnSessionModified(pConn *PFCPConn, old, new PFCPSession, priority uint8) {
+	r.record(newSessionEvent("modified", pConn, &old, &new))
+}
+
+func (r sessionEventRecorder) OnSessionDeleted(pConn *PFCPConn, session PFCPSession) {
+	r.record(newSessionEvent("deleted", pConn, &session, nil))
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// runTestSessionLifecycle establishes, modifies and deletes a session, removing one of its PDRs.
+func runTestSessionLifecycle(t *testing.T, pConn *PFCPConn) {
+	_, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(1, migrationPriority))
+	require.NoError(t, err)
+
+	_, err = pConn.handleSessionModificationRequest(
+		message.NewSessionModificationRequest(0, 0, 1, 2, 0, ie.NewRemovePDR(ie.NewPDRID(2))))
+	require.NoError(t, err)
+
+	_, err = pConn.handleSessionDeletionRequest(message.NewSessionDeletionRequest(0, 0, 1, 3, 0))
+	require.NoError(t, err)
+}
+
+func requireSessionEvents(t *testing.T, events []sessionEvent) {
+	require.Len(t, events, 3)
+
+	for i, name := range []string{"created", "modified", "deleted"} {
+		require.Equal(t, name, events[i].Event)
+		require.Equal(t, "smf", events[i].NodeID)
+		require.Equal(t, uint64(1), events[i].RemoteSEID)
+		require.NotZero(t, events[i].LocalSEID)
+	}
+
+	require.Nil(t, events[0].Old)
+	require.Len(t, events[0].New.PDRs, 2)
+	require.Len(t, events[0].New.FARs, 2)
+
+	// the rules before the modification are not altered by it
+	require.Len(t, events[1].Old.PDRs, 2)
+	require.Len(t, events[1].New.PDRs, 1)
+	require.Equal(t, uint32(1), events[1].New.PDRs[0].ID)
+
+	require.Len(t, events[2].Old.PDRs, 1)
+	require.Nil(t, events[2].New)
+}
+
+func Test_sessionHooks_file(t *testing.T) {
+	u := &upf{}
+	pConn := newLBTestPFCPConn(t, u)
+
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	hooks := newSessionHooks(u, SessionHooksConf{LogFile: path})
+	require.Len(t, hooks, 2)
+
+	u.sessionHooks = hooks[1:]
+	runTestSessionLifecycle(t, pConn)
+	u.sessionHooks.close()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	var events []sessionEvent
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e sessionEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+
+		events = append(events, e)
+	}
+
+	require.NoError(t, scanner.Err())
+	requireSessionEvents(t, events)
+}
+
+func Test_sessionHooks_webhook(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []sessionEvent
+		fail   = true
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// a failed event is not retried, and doesn't block the following ones
+		if fail {
+			fail = false
+
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		var e sessionEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+
+		events = append(events, e)
+	}))
+	defer srv.Close()
+
+	u := &upf{}
+	pConn := newLBTestPFCPConn(t, u)
+	u.sessionHooks = sessionHooks{newWebhookSessionHook(srv.URL)}
+
+	_, err := pConn.handleSessionEstablishmentRequest(newLBTestEstablishmentRequest(2, 0))
+	require.NoError(t, err)
+
+	runTestSessionLifecycle(t, pConn)
+
+	// the queued events are sent before closing
+	u.sessionHooks.close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	requireSessionEvents(t, events)
+}
+
+func Test_sessionHooks_failedEstablishment(t *testing.T) {
+	var events []sessionEvent
+
+	u := &upf{}
+	pConn := newLBTestPFCPConn(t, u)
+	u.sessionHooks = sessionHooks{sessionEventRecorder{record: func(e sessionEvent) {
+		events = append(events, e)
+	}}}
+
+	// the FAR of the PDR is missing
+	req := message.NewSessionEstablishmentRequest(1, 0, 0, 1, 0,
+		ie.NewNodeID("", "", "smf"),
+		ie.NewFSEID(1, net.ParseIP("10.0.0.1"), nil),
+		ie.NewCreatePDR(
+			ie.NewPDRID(1),
+			ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess)),
+		),
+	)
+
+	_, err := pConn.handleSessionEstablishmentRequest(req)
+	require.Error(t, err)
+	require.Empty(t, events)
+}
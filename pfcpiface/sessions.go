@@ -29,6 +29,21 @@ func (p PacketForwardingRules) String() string {
 	return fmt.Sprintf("PDRs=%v, FARs=%v, QERs=%v", p.pdrs, p.fars, p.qers)
 }
 
+// IsEmpty returns true if there are no rules.
+func (p PacketForwardingRules) IsEmpty() bool {
+	return len(p.pdrs) == 0 && len(p.fars) == 0 && len(p.qers) == 0
+}
+
+// clone returns a copy of the rules not sharing their slices, e.g. to keep the rules of a
+// session before modifying it.
+func (p PacketForwardingRules) clone() PacketForwardingRules {
+	return PacketForwardingRules{
+		pdrs: append([]pdr{}, p.pdrs...),
+		fars: append([]far{}, p.fars...),
+		qers: append([]qer{}, p.qers...),
+	}
+}
+
 // NewPFCPSession allocates an session with ID.
 func (pConn *PFCPConn) NewPFCPSession(rseid uint64) (PFCPSession, bool) {
 
@@ -56,11 +71,12 @@ func (pConn *PFCPConn) RemoveSession(session PFCPSession) {
 	session.metrics.Delete()
 	pConn.SaveSessions(session.metrics)
 
-	for _, p := range session.pdrs {
-		delete(pConn.sentIpsToRouters, p.ueAddress)
+	// a session failing to be established was never stored
+	if _, ok := pConn.store.GetSession(session.localSEID); ok {
+		pConn.upf.sessionHooks.deleted(pConn, session)
 	}
 
-	if err := pConn.store.DeleteSession(session.localSEID, pConn); err != nil {
+	if err := pConn.store.DeleteSession(session.localSEID); err != nil {
 		log.Errorf("Failed to delete PFCP session from store: %v", err)
 	}
 }
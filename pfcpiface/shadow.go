@@ -0,0 +1,407 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// modeShadow selects the shadow datapath.
+const modeShadow = "shadow"
+
+// shadow is a datapath that doesn't forward any traffic. It accepts all rules and records
+// them per session, so that they can be inspected through the HTTP API. It allows running
+// the PFCP Agent next to a production one, to validate the behaviour of the SMF and of
+// the PFCP parsing logic without touching forwarding.
+type shadow struct {
+	mu       sync.RWMutex
+	sessions map[uint64]*shadowSession
+	slice    *SliceInfo
+}
+
+type shadowSession struct {
+	pdrs       map[uint32]pdr
+	fars       map[uint32]far
+	qers       map[uint32]qer
+	messages   uint64
+	lastUpdate time.Time
+}
+
+func (s *shadowSession) isEmpty() bool {
+	return len(s.pdrs) == 0 && len(s.fars) == 0 && len(s.qers) == 0
+}
+
+func (s *shadow) SetUpfInfo(u *upf, conf *Conf) {
+	log.Println("SetUpfInfo shadow")
+
+	s.sessions = make(map[uint64]*shadowSession)
+}
+
+func (s *shadow) session(fseid uint64) *shadowSession {
+	sess, ok := s.sessions[fseid]
+	if !ok {
+		sess = &shadowSession{
+			pdrs: make(map[uint32]pdr),
+			fars: make(map[uint32]far),
+			qers: make(map[uint32]qer),
+		}
+		s.sessions[fseid] = sess
+	}
+
+	return sess
+}
+
+func (s *shadow) BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx {
+	return newChangesTx(ctx, all, s.commitRules)
+}
+
+func (s *shadow) commitRules(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	touched := make(map[uint64]*shadowSession)
+
+	upserted := changes.upserted()
+
+	for _, p := range upserted.pdrs {
+		sess := s.session(p.fseID)
+		sess.pdrs[p.pdrID] = p
+		touched[p.fseID] = sess
+	}
+
+	for _, f := range upserted.fars {
+		sess := s.session(f.fseID)
+		sess.fars[f.farID] = f
+		touched[f.fseID] = sess
+	}
+
+	for _, q := range upserted.qers {
+		sess := s.session(q.fseID)
+		sess.qers[q.qerID] = q
+		touched[q.fseID] = sess
+	}
+
+	for _, p := range changes.deleted.pdrs {
+		sess := s.session(p.fseID)
+		delete(sess.pdrs, p.pdrID)
+		touched[p.fseID] = sess
+	}
+
+	for _, f := range changes.deleted.fars {
+		sess := s.session(f.fseID)
+		delete(sess.fars, f.farID)
+		touched[f.fseID] = sess
+	}
+
+	for _, q := range changes.deleted.qers {
+		sess := s.session(q.fseID)
+		delete(sess.qers, q.qerID)
+		touched[q.fseID] = sess
+	}
+
+	now := time.Now()
+
+	for fseid, sess := range touched {
+		if sess.isEmpty() {
+			delete(s.sessions, fseid)
+			continue
+		}
+
+		sess.messages++
+		sess.lastUpdate = now
+	}
+
+	log.WithFields(log.Fields{
+		"created": changes.created,
+		"updated": changes.updated,
+		"deleted": changes.deleted,
+	}).Debug("Recorded rules in shadow datapath")
+
+	return nil
+}
+
+func (s *shadow) AddSliceInfo(sliceInfo *SliceInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.slice = sliceInfo
+
+	return nil
+}
+
+// IsConnected always returns true, as there is no actual datapath.
+func (s *shadow) IsConnected(accessIP *net.IP) bool {
+	return true
+}
+
+func (s *shadow) Exit() {
+	log.Println("Exit function shadow")
+}
+
+// SendEndMarkers drops the end markers, as the shadow datapath doesn't forward any traffic.
+func (s *shadow) SendEndMarkers(endMarkerList *[][]byte) error {
+	return nil
+}
+
+func (s *shadow) SummaryLatencyJitter(uc *upfCollector, ch chan<- prometheus.Metric) {
+}
+
+func (s *shadow) PortStats(uc *upfCollector, ch chan<- prometheus.Metric) {
+}
+
+func (s *shadow) SessionStats(pc *PfcpNodeCollector, ch chan<- prometheus.Metric) error {
+	return nil
+}
+
+func (s *shadow) setupHandlers(mux *http.ServeMux) {
+	mux.Handle("/v1/datapath/rules", s)
+}
+
+// shadowRules is the JSON representation of the rules recorded by the shadow datapath.
+type shadowRules struct {
+	Sessions []shadowSessionView `json:"sessions"`
+	Slice    *shadowSliceView    `json:"slice,omitempty"`
+}
+
+type shadowSessionView struct {
+	FSEID      uint64          `json:"fseid"`
+	Messages   uint64          `json:"messages"`
+	LastUpdate time.Time       `json:"last_update"`
+	PDRs       []shadowPDRView `json:"pdrs"`
+	FARs       []shadowFARView `json:"fars"`
+	QERs       []shadowQERView `json:"qers"`
+}
+
+type shadowPDRView struct {
+	ID                uint32   `json:"id"`
+	Precedence        uint32   `json:"precedence"`
+	SrcInterface      string   `json:"src_interface"`
+	TunnelIPv4Dst     string   `json:"tunnel_ipv4_dst,omitempty"`
+	TEID              uint32   `json:"teid,omitempty"`
+	UEAddress         string   `json:"ue_address,omitempty"`
+	ApplicationFilter string   `json:"application_filter"`
+	FARID             uint32   `json:"far_id"`
+	QERIDs            []uint32 `json:"qer_ids"`
+	NeedDecap         bool     `json:"need_decap"`
+	AllocIP           bool     `json:"alloc_ip"`
+}
+
+type shadowFARView struct {
+	ID            uint32   `json:"id"`
+	Actions       []string `json:"actions"`
+	DstInterface  uint8    `json:"dst_interface"`
+	TunnelIPv4Src string   `json:"tunnel_ipv4_src,omitempty"`
+	TunnelIPv4Dst string   `json:"tunnel_ipv4_dst,omitempty"`
+	TEID          uint32   `json:"teid,omitempty"`
+	SendEndMarker bool     `json:"send_end_marker"`
+}
+
+type shadowQERView struct {
+	ID         uint32 `json:"id"`
+	Level      string `json:"level"`
+	QFI        uint8  `json:"qfi"`
+	ULGateOpen bool   `json:"ul_gate_open"`
+	DLGateOpen bool   `json:"dl_gate_open"`
+	ULMbrKbps  uint64 `json:"ul_mbr_kbps"`
+	DLMbrKbps  uint64 `json:"dl_mbr_kbps"`
+	ULGbrKbps  uint64 `json:"ul_gbr_kbps"`
+	DLGbrKbps  uint64 `json:"dl_gbr_kbps"`
+}
+
+type shadowSliceView struct {
+	Name         string `json:"name"`
+	UplinkMbr    uint64 `json:"uplink_mbr"`
+	DownlinkMbr  uint64 `json:"downlink_mbr"`
+	ULBurstBytes uint64 `json:"ul_burst_bytes"`
+	DLBurstBytes uint64 `json:"dl_burst_bytes"`
+}
+
+func ipViewOrEmpty(ip uint32) string {
+	if ip == 0 {
+		return ""
+	}
+
+	return int2ip(ip).String()
+}
+
+func newShadowPDRView(p pdr) shadowPDRView {
+	srcIface := "unknown"
+
+	switch {
+	case p.IsUplink():
+		srcIface = "access"
+	case p.IsDownlink():
+		srcIface = "core"
+	}
+
+	return shadowPDRView{
+		ID:                p.pdrID,
+		Precedence:        p.precedence,
+		SrcInterface:      srcIface,
+		TunnelIPv4Dst:     ipViewOrEmpty(p.tunnelIP4Dst),
+		TEID:              p.tunnelTEID,
+		UEAddress:         ipViewOrEmpty(p.ueAddress),
+		ApplicationFilter: p.appFilter.String(),
+		FARID:             p.farID,
+		QERIDs:            append([]uint32{}, p.qerIDList...),
+		NeedDecap:         p.needDecap != 0,
+		AllocIP:           p.allocIPFlag,
+	}
+}
+
+func newShadowFARView(f far) shadowFARView {
+	actions := make([]string, 0)
+
+	for _, a := range []struct {
+		flag uint8
+		name string
+	}{
+		{ActionForward, "forward"},
+		{ActionDrop, "drop"},
+		{ActionBuffer, "buffer"},
+		{ActionNotify, "notify"},
+	} {
+		if f.applyAction&a.flag != 0 {
+			actions = append(actions, a.name)
+		}
+	}
+
+	return shadowFARView{
+		ID:            f.farID,
+		Actions:       actions,
+		DstInterface:  f.dstIntf,
+		TunnelIPv4Src: ipViewOrEmpty(f.tunnelIP4Src),
+		TunnelIPv4Dst: ipViewOrEmpty(f.tunnelIP4Dst),
+		TEID:          f.tunnelTEID,
+		SendEndMarker: f.sendEndMarker,
+	}
+}
+
+func newShadowQERView(q qer) shadowQERView {
+	level, ok := qosLevelName[q.qosLevel]
+	if !ok {
+		level = "invalid"
+	}
+
+	return shadowQERView{
+		ID:         q.qerID,
+		Level:      level,
+		QFI:        q.qfi,
+		ULGateOpen: q.ulStatus == ie.GateStatusOpen,
+		DLGateOpen: q.dlStatus == ie.GateStatusOpen,
+		ULMbrKbps:  q.ulMbr,
+		DLMbrKbps:  q.dlMbr,
+		ULGbrKbps:  q.ulGbr,
+		DLGbrKbps:  q.dlGbr,
+	}
+}
+
+func newShadowSessionView(fseid uint64, sess *shadowSession) shadowSessionView {
+	view := shadowSessionView{
+		FSEID:      fseid,
+		Messages:   sess.messages,
+		LastUpdate: sess.lastUpdate,
+		PDRs:       make([]shadowPDRView, 0, len(sess.pdrs)),
+		FARs:       make([]shadowFARView, 0, len(sess.fars)),
+		QERs:       make([]shadowQERView, 0, len(sess.qers)),
+	}
+
+	for _, p := range sess.pdrs {
+		view.PDRs = append(view.PDRs, newShadowPDRView(p))
+	}
+
+	for _, f := range sess.fars {
+		view.FARs = append(view.FARs, newShadowFARView(f))
+	}
+
+	for _, q := range sess.qers {
+		view.QERs = append(view.QERs, newShadowQERView(q))
+	}
+
+	sort.Slice(view.PDRs, func(i, j int) bool { return view.PDRs[i].ID < view.PDRs[j].ID })
+	sort.Slice(view.FARs, func(i, j int) bool { return view.FARs[i].ID < view.FARs[j].ID })
+	sort.Slice(view.QERs, func(i, j int) bool { return view.QERs[i].ID < view.QERs[j].ID })
+
+	return view
+}
+
+// rules returns the recorded rules of the session with the given F-SEID, or of all sessions if fseid is nil.
+func (s *shadow) rules(fseid *uint64) (shadowRules, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules := shadowRules{Sessions: make([]shadowSessionView, 0)}
+
+	if s.slice != nil {
+		rules.Slice = &shadowSliceView{
+			Name:         s.slice.name,
+			UplinkMbr:    s.slice.uplinkMbr,
+			DownlinkMbr:  s.slice.downlinkMbr,
+			ULBurstBytes: s.slice.ulBurstBytes,
+			DLBurstBytes: s.slice.dlBurstBytes,
+		}
+	}
+
+	if fseid != nil {
+		sess, ok := s.sessions[*fseid]
+		if !ok {
+			return rules, false
+		}
+
+		rules.Sessions = append(rules.Sessions, newShadowSessionView(*fseid, sess))
+
+		return rules, true
+	}
+
+	for id, sess := range s.sessions {
+		rules.Sessions = append(rules.Sessions, newShadowSessionView(id, sess))
+	}
+
+	sort.Slice(rules.Sessions, func(i, j int) bool { return rules.Sessions[i].FSEID < rules.Sessions[j].FSEID })
+
+	return rules, true
+}
+
+// ServeHTTP returns the recorded rules of all sessions, or of a single one if the fseid query parameter is set.
+func (s *shadow) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendHTTPResp(http.StatusMethodNotAllowed, w)
+		return
+	}
+
+	var fseid *uint64
+
+	if param := r.URL.Query().Get("fseid"); param != "" {
+		id, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			sendHTTPResp(http.StatusBadRequest, w)
+			return
+		}
+
+		fseid = &id
+	}
+
+	rules, ok := s.rules(fseid)
+	if !ok {
+		sendHTTPResp(http.StatusNotFound, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		log.Errorln("http response write failed : ", err)
+	}
+}
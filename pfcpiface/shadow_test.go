@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func getShadowTestRules(t *testing.T, s *shadow, query string) (int, shadowRules) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/datapath/rules"+query, nil))
+
+	var rules shadowRules
+
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&rules))
+	}
+
+	return rec.Code, rules
+}
+
+func Test_shadow_recordsRules(t *testing.T) {
+	s := &shadow{}
+	s.SetUpfInfo(nil, nil)
+
+	rules := newSWTestRules()
+	tx := s.BeginRules(context.Background(), rules)
+	createRules(tx, rules)
+	require.NoError(t, tx.Commit())
+
+	code, got := getShadowTestRules(t, s, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, got.Sessions, 1)
+
+	sess := got.Sessions[0]
+	require.Equal(t, uint64(swTestFSEID), sess.FSEID)
+	require.Equal(t, uint64(1), sess.Messages)
+	require.Len(t, sess.PDRs, 2)
+	require.Equal(t, "access", sess.PDRs[0].SrcInterface)
+	require.Equal(t, swTestUEAddress.String(), sess.PDRs[0].UEAddress)
+	require.Equal(t, uint32(swTestUplinkTEID), sess.PDRs[0].TEID)
+	require.True(t, sess.PDRs[0].NeedDecap)
+	require.Equal(t, "core", sess.PDRs[1].SrcInterface)
+	require.Len(t, sess.FARs, 2)
+	require.Equal(t, []string{"forward"}, sess.FARs[1].Actions)
+	require.Equal(t, swTestGNBAddress.String(), sess.FARs[1].TunnelIPv4Dst)
+	require.Len(t, sess.QERs, 1)
+	require.Equal(t, "session", sess.QERs[0].Level)
+	require.Equal(t, uint8(9), sess.QERs[0].QFI)
+
+	// buffer downlink traffic, only the updated FAR is sent
+	buffering := rules.fars[1]
+	buffering.applyAction = ActionBuffer | ActionNotify
+	tx = s.BeginRules(context.Background(), rules)
+	tx.UpdateFAR(buffering)
+	require.NoError(t, tx.Commit())
+
+	code, got = getShadowTestRules(t, s, "?fseid=1")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, got.Sessions, 1)
+	require.Equal(t, uint64(2), got.Sessions[0].Messages)
+	require.Len(t, got.Sessions[0].PDRs, 2)
+	require.Equal(t, []string{"buffer", "notify"}, got.Sessions[0].FARs[1].Actions)
+
+	tx = s.BeginRules(context.Background(), PacketForwardingRules{})
+	deleteRules(tx, rules)
+	require.NoError(t, tx.Commit())
+
+	code, got = getShadowTestRules(t, s, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, got.Sessions)
+}
+
+func Test_shadow_ServeHTTP(t *testing.T) {
+	s := &shadow{}
+	s.SetUpfInfo(nil, nil)
+	tx := s.BeginRules(context.Background(), newSWTestRules())
+	createRules(tx, newSWTestRules())
+	require.NoError(t, tx.Commit())
+
+	code, _ := getShadowTestRules(t, s, "?fseid=2")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = getShadowTestRules(t, s, "?fseid=foo")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/datapath/rules", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
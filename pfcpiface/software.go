@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// modeSoftware selects the software datapath.
+const modeSoftware = "software"
+
+// software is a datapath forwarding packets in userspace, for development and testing
+// without BESS or a switch. GTP-U packets are sent and received with UDP sockets on the
+// access (and core) addresses, while N6 packets are read from and written to a TUN
+// device used as the core interface.
+type software struct {
+	pipeline *swPipeline
+
+	accessIP   net.IP
+	coreIP     net.IP
+	accessConn *net.UDPConn
+	// coreConn receives GTP-U packets on the core address (N9), nil if it is the same as the access address.
+	coreConn *net.UDPConn
+	tun      io.ReadWriteCloser
+
+	// mu guards connected
+	mu        sync.RWMutex
+	connected bool
+	// wg tracks the goroutines reading packets
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func (s *software) SetUpfInfo(u *upf, conf *Conf) {
+	log.Println("SetUpfInfo software")
+
+	notifier := NewDownlinkDataNotifier(u.reportNotifyChan, 20*time.Second)
+	s.pipeline = newSWPipeline(s, notifier.Notify)
+	s.accessIP = u.AccessIP
+	s.coreIP = u.CoreIP
+
+	if err := s.open(conf.CoreIface.IfName); err != nil {
+		log.Errorf("Failed to set up the software datapath: %v", err)
+		s.closeOnce.Do(s.close)
+
+		return
+	}
+
+	s.setConnected(true)
+}
+
+func (s *software) open(tunName string) error {
+	var err error
+
+	s.accessConn, err = net.ListenUDP("udp4", &net.UDPAddr{IP: s.accessIP, Port: tunnelGTPUPort})
+	if err != nil {
+		return err
+	}
+
+	if s.coreIP != nil && !s.coreIP.Equal(s.accessIP) {
+		s.coreConn, err = net.ListenUDP("udp4", &net.UDPAddr{IP: s.coreIP, Port: tunnelGTPUPort})
+		if err != nil {
+			return err
+		}
+	}
+
+	s.tun, err = openTUN(tunName)
+	if err != nil {
+		return err
+	}
+
+	s.wg.Add(2)
+
+	go s.readGTPU(s.accessConn, access, s.accessIP)
+	go s.readTUN()
+
+	if s.coreConn != nil {
+		s.wg.Add(1)
+
+		go s.readGTPU(s.coreConn, core, s.coreIP)
+	}
+
+	log.Infof("Software datapath forwarding GTP-U on %v and N6 traffic on %v", s.accessIP, tunName)
+
+	return nil
+}
+
+func (s *software) close() {
+	closers := make([]io.Closer, 0, 3)
+
+	if s.accessConn != nil {
+		closers = append(closers, s.accessConn)
+	}
+
+	if s.coreConn != nil {
+		closers = append(closers, s.coreConn)
+	}
+
+	if s.tun != nil {
+		closers = append(closers, s.tun)
+	}
+
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			log.Warnf("Failed to close software datapath port: %v", err)
+		}
+	}
+
+	s.wg.Wait()
+}
+
+func (s *software) readGTPU(conn *net.UDPConn, iface uint8, localIP net.IP) {
+	defer s.wg.Done()
+
+	buf := make([]byte, swMaxPacketSize)
+
+	for {
+		n, remote, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Debugf("Stopped reading GTP-U packets on %v: %v", localIP, err)
+			return
+		}
+
+		s.pipeline.handleGTPU(iface, localIP, remote, buf[:n])
+	}
+}
+
+func (s *software) readTUN() {
+	defer s.wg.Done()
+
+	buf := make([]byte, swMaxPacketSize)
+
+	for {
+		n, err := s.tun.Read(buf)
+		if err != nil {
+			log.Debugf("Stopped reading N6 packets: %v", err)
+			return
+		}
+
+		s.pipeline.handleIP(buf[:n])
+	}
+}
+
+func (s *software) sendGTPU(src net.IP, dst *net.UDPAddr, msg []byte) error {
+	conn := s.accessConn
+	if s.coreConn != nil && src.Equal(s.coreIP) {
+		conn = s.coreConn
+	}
+
+	_, err := conn.WriteToUDP(msg, dst)
+
+	return err
+}
+
+func (s *software) sendIP(pkt []byte) error {
+	if s.tun == nil {
+		return ErrOperationFailedWithReason("send N6 packet", "TUN device not open")
+	}
+
+	_, err := s.tun.Write(pkt)
+	return err
+}
+
+func (s *software) setConnected(connected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connected = connected
+
+	value := 0.0
+	if connected {
+		value = 1
+	}
+
+	getDatapathMetrics().connected.WithLabelValues(modeSoftware).Set(value)
+}
+
+func (s *software) IsConnected(accessIP *net.IP) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.connected
+}
+
+func (s *software) Exit() {
+	log.Println("Exit function software")
+
+	s.setConnected(false)
+	s.closeOnce.Do(s.close)
+}
+
+func (s *software) AddSliceInfo(sliceInfo *SliceInfo) error {
+	s.pipeline.setSliceMeter(sliceInfo.uplinkMbr, sliceInfo.ulBurstBytes,
+		sliceInfo.downlinkMbr, sliceInfo.dlBurstBytes)
+
+	return nil
+}
+
+// SendEndMarkers sends the GTP-U payload of the end marker packets built by the PFCP agent.
+func (s *software) SendEndMarkers(endMarkerList *[][]byte) error {
+	for _, eMarker := range *endMarkerList {
+		packet := gopacket.NewPacket(eMarker, layers.LayerTypeEthernet, gopacket.Default)
+
+		ipLayer, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+		if !ok {
+			return ErrInvalidArgumentWithReason("end marker", eMarker, "not an IPv4 packet")
+		}
+
+		udpLayer, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if !ok {
+			return ErrInvalidArgumentWithReason("end marker", eMarker, "not an UDP packet")
+		}
+
+		dst := &net.UDPAddr{IP: ipLayer.DstIP, Port: int(udpLayer.DstPort)}
+		if err := s.sendGTPU(ipLayer.SrcIP, dst, udpLayer.Payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *software) BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx {
+	return newChangesTx(ctx, all, func(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error {
+		s.pipeline.apply(upfMsgTypeAdd, changes.upserted())
+		s.pipeline.apply(upfMsgTypeDel, changes.deleted)
+
+		return nil
+	})
+}
+
+// SummaryLatencyJitter is not supported, the software datapath doesn't measure latencies.
+func (s *software) SummaryLatencyJitter(uc *upfCollector, ch chan<- prometheus.Metric) {
+}
+
+func (s *software) PortStats(uc *upfCollector, ch chan<- prometheus.Metric) {
+	accessPort, corePort := s.pipeline.portStats()
+
+	portStats := func(ifaceLabel string, c swPortCounters) {
+		ch <- prometheus.MustNewConstMetric(uc.packets, prometheus.CounterValue, float64(c.rx.packets), ifaceLabel, "rx")
+		ch <- prometheus.MustNewConstMetric(uc.packets, prometheus.CounterValue, float64(c.tx.packets), ifaceLabel, "tx")
+		ch <- prometheus.MustNewConstMetric(uc.bytes, prometheus.CounterValue, float64(c.rx.bytes), ifaceLabel, "rx")
+		ch <- prometheus.MustNewConstMetric(uc.bytes, prometheus.CounterValue, float64(c.tx.bytes), ifaceLabel, "tx")
+		ch <- prometheus.MustNewConstMetric(uc.dropped, prometheus.CounterValue, float64(c.rxDropped), ifaceLabel, "rx")
+		ch <- prometheus.MustNewConstMetric(uc.dropped, prometheus.CounterValue, float64(c.txDropped), ifaceLabel, "tx")
+	}
+
+	portStats("Access", accessPort)
+	portStats("Core", corePort)
+}
+
+func (s *software) SessionStats(pc *PfcpNodeCollector, ch chan<- prometheus.Metric) error {
+	for _, st := range s.pipeline.pdrStats() {
+		fseidString := strconv.FormatUint(st.fseID, 10)
+		pdrString := strconv.FormatUint(uint64(st.pdrID), 10)
+
+		ueIPString := "unknown"
+		if st.ueAddress != 0 {
+			ueIPString = int2ip(st.ueAddress).String()
+		}
+
+		ch <- prometheus.MustNewConstMetric(pc.sessionTxPackets, prometheus.GaugeValue,
+			float64(st.counters.tx.packets), fseidString, pdrString, ueIPString)
+		ch <- prometheus.MustNewConstMetric(pc.sessionRxPackets, prometheus.GaugeValue,
+			float64(st.counters.rx.packets), fseidString, pdrString, ueIPString)
+		ch <- prometheus.MustNewConstMetric(pc.sessionDroppedPackets, prometheus.GaugeValue,
+			float64(st.counters.dropped), fseidString, pdrString, ueIPString)
+		ch <- prometheus.MustNewConstMetric(pc.sessionTxBytes, prometheus.GaugeValue,
+			float64(st.counters.tx.bytes), fseidString, pdrString, ueIPString)
+	}
+
+	return nil
+}
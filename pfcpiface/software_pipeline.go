@@ -0,0 +1,691 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	log "github.com/sirupsen/logrus"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+const (
+	gtpuMsgTypeEchoRequest  = 1
+	gtpuMsgTypeEchoResponse = 2
+	gtpuMsgTypeGPDU         = 255
+
+	gtpuHeaderLen = 8
+	// gtpuExtPDUSessionContainer is the type of the PDU session container extension header carrying the QFI.
+	gtpuExtPDUSessionContainer = 0x85
+	// gtpuIERecovery is the type of the Recovery IE, mandatory in Echo Response messages.
+	gtpuIERecovery = 14
+
+	// swMaxPacketSize is the size of the buffers packets are read into.
+	swMaxPacketSize = 65535
+	// swBufferSize is the maximum number of packets buffered per FAR.
+	swBufferSize = 64
+	// swBurstDurationMs is the burst duration used to size the token buckets, as for BESS' default QCI.
+	swBurstDurationMs = 10
+)
+
+// swEgress sends the packets forwarded by the software pipeline.
+type swEgress interface {
+	// sendGTPU sends the GTP-U message msg from the local address src to dst.
+	sendGTPU(src net.IP, dst *net.UDPAddr, msg []byte) error
+	// sendIP sends the IP packet pkt to the core (N6) network.
+	sendIP(pkt []byte) error
+}
+
+// swPacket is a packet received by the software pipeline.
+type swPacket struct {
+	srcIface uint8
+	// tunnelIP4Dst and tunnelTEID are set for packets received in a GTP-U tunnel.
+	tunnelIP4Dst uint32
+	tunnelTEID   uint32
+	// data is the IP packet, without any GTP-U encapsulation.
+	data []byte
+	// buffered is true for packets released from a FAR buffer, already counted on reception.
+	buffered bool
+}
+
+// swPacketInfo holds the packet fields PDRs are matched against.
+type swPacketInfo struct {
+	srcIP   uint32
+	dstIP   uint32
+	srcPort uint16
+	dstPort uint16
+	proto   uint8
+}
+
+func parseSWPacketInfo(data []byte) (swPacketInfo, error) {
+	var (
+		ip   layers.IPv4
+		info swPacketInfo
+	)
+
+	if err := ip.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		return info, err
+	}
+
+	info.srcIP = ip2int(ip.SrcIP)
+	info.dstIP = ip2int(ip.DstIP)
+	info.proto = uint8(ip.Protocol)
+
+	switch ip.Protocol {
+	case layers.IPProtocolTCP, layers.IPProtocolUDP:
+		if len(ip.Payload) >= 4 {
+			info.srcPort = binary.BigEndian.Uint16(ip.Payload[0:2])
+			info.dstPort = binary.BigEndian.Uint16(ip.Payload[2:4])
+		}
+	}
+
+	return info, nil
+}
+
+// swMatchPDR returns true if the packet matches all the fields of the PDR, using the same fields as BESS' pdrLookup.
+func swMatchPDR(p *pdr, pkt *swPacket, info *swPacketInfo) bool {
+	return pkt.srcIface&p.srcIfaceMask == p.srcIface&p.srcIfaceMask &&
+		pkt.tunnelIP4Dst&p.tunnelIP4DstMask == p.tunnelIP4Dst&p.tunnelIP4DstMask &&
+		pkt.tunnelTEID&p.tunnelTEIDMask == p.tunnelTEID&p.tunnelTEIDMask &&
+		info.srcIP&p.appFilter.srcIPMask == p.appFilter.srcIP&p.appFilter.srcIPMask &&
+		info.dstIP&p.appFilter.dstIPMask == p.appFilter.dstIP&p.appFilter.dstIPMask &&
+		info.proto&p.appFilter.protoMask == p.appFilter.proto&p.appFilter.protoMask &&
+		p.appFilter.srcPortRange.contains(info.srcPort) &&
+		p.appFilter.dstPortRange.contains(info.dstPort)
+}
+
+// encapGTPU prepends a GTP-U G-PDU header to payload. If qfi is not zero,
+// a PDU session container extension header carrying the QFI is added.
+func encapGTPU(teid uint32, qfi uint8, payload []byte) []byte {
+	hdrLen := gtpuHeaderLen
+	if qfi != 0 {
+		// sequence number, N-PDU number, next extension header type and the PDU session container
+		hdrLen += 8
+	}
+
+	msg := make([]byte, hdrLen+len(payload))
+	msg[0] = 0x30 // version 1, protocol type GTP
+	msg[1] = gtpuMsgTypeGPDU
+	binary.BigEndian.PutUint16(msg[2:4], uint16(hdrLen-gtpuHeaderLen+len(payload)))
+	binary.BigEndian.PutUint32(msg[4:8], teid)
+
+	if qfi != 0 {
+		msg[0] |= 0x04 // extension header flag
+		msg[11] = gtpuExtPDUSessionContainer
+		msg[12] = 1    // length in 4-octet units
+		msg[13] = 0x00 // PDU type 0, DL PDU session information
+		msg[14] = qfi & 0x3f
+		msg[15] = 0 // no more extension headers
+	}
+
+	copy(msg[hdrLen:], payload)
+
+	return msg
+}
+
+// gtpuEchoResponse builds the Echo Response for an Echo Request with the given sequence number.
+func gtpuEchoResponse(seq uint16) []byte {
+	msg := make([]byte, gtpuHeaderLen+6)
+	msg[0] = 0x32 // version 1, protocol type GTP, sequence number flag
+	msg[1] = gtpuMsgTypeEchoResponse
+	binary.BigEndian.PutUint16(msg[2:4], 6)
+	binary.BigEndian.PutUint16(msg[8:10], seq)
+	msg[12] = gtpuIERecovery
+
+	return msg
+}
+
+// swTokenBucket polices traffic to a rate, allowing bursts up to burst bytes.
+type swTokenBucket struct {
+	// rate is in bytes per second
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newSWTokenBucket(rate, burst uint64, now time.Time) *swTokenBucket {
+	return &swTokenBucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// allow consumes n tokens and returns true, if available.
+func (tb *swTokenBucket) allow(n int, now time.Time) bool {
+	if elapsed := now.Sub(tb.last).Seconds(); elapsed > 0 {
+		tb.tokens = math.Min(tb.burst, tb.tokens+elapsed*tb.rate)
+		tb.last = now
+	}
+
+	if tb.tokens < float64(n) {
+		return false
+	}
+
+	tb.tokens -= float64(n)
+
+	return true
+}
+
+// newSWRateLimiter returns a token bucket for a rate in kbps, or nil if the rate is unlimited.
+func newSWRateLimiter(kbps uint64, now time.Time) *swTokenBucket {
+	if kbps == 0 {
+		return nil
+	}
+
+	burst := maxUint64(calcBurstSizeFromRate(kbps, swBurstDurationMs), DefaultBurstSize)
+
+	return newSWTokenBucket(kbps*1000/8, burst, now)
+}
+
+// swRuleKey identifies a PDR, FAR or QER in the software pipeline.
+type swRuleKey struct {
+	fseID uint64
+	id    uint32
+}
+
+type swPacketCounters struct {
+	packets uint64
+	bytes   uint64
+}
+
+func (c *swPacketCounters) count(n int) {
+	c.packets++
+	c.bytes += uint64(n)
+}
+
+// swPDRCounters are the per-PDR counters, exported as session stats.
+type swPDRCounters struct {
+	rx      swPacketCounters
+	tx      swPacketCounters
+	dropped uint64
+}
+
+// swPortCounters are the per-interface counters, exported as port stats.
+type swPortCounters struct {
+	rx, tx               swPacketCounters
+	rxDropped, txDropped uint64
+}
+
+type swPDR struct {
+	pdr
+	counters swPDRCounters
+}
+
+type swFAR struct {
+	far
+	// buffered are the packets received while the FAR buffers.
+	buffered []swPacket
+}
+
+type swQER struct {
+	qer
+	ul, dl *swTokenBucket
+}
+
+// swPipeline implements the UPF forwarding pipeline in userspace: PDR lookup, QER policing,
+// FAR forwarding, dropping or buffering, and GTP-U encapsulation and decapsulation.
+type swPipeline struct {
+	egress swEgress
+	// notify is called with the F-SEID of a session whose downlink data is being buffered.
+	notify func(fseid uint64)
+	now    func() time.Time
+
+	mu   sync.Mutex
+	pdrs map[swRuleKey]*swPDR
+	// pdrsByTEID indexes the PDRs matching on an exact TEID, pdrsByUEAddress the downlink
+	// PDRs matching on an exact destination address. All other PDRs are in wildcardPDRs.
+	pdrsByTEID      map[uint32]map[swRuleKey]*swPDR
+	pdrsByUEAddress map[uint32]map[swRuleKey]*swPDR
+	wildcardPDRs    map[swRuleKey]*swPDR
+	fars            map[swRuleKey]*swFAR
+	qers            map[swRuleKey]*swQER
+	// sliceUplink and sliceDownlink police the aggregate traffic of the slice, nil if unlimited.
+	sliceUplink   *swTokenBucket
+	sliceDownlink *swTokenBucket
+	accessPort    swPortCounters
+	corePort      swPortCounters
+}
+
+func newSWPipeline(egress swEgress, notify func(fseid uint64)) *swPipeline {
+	return &swPipeline{
+		egress:          egress,
+		notify:          notify,
+		now:             time.Now,
+		pdrs:            make(map[swRuleKey]*swPDR),
+		pdrsByTEID:      make(map[uint32]map[swRuleKey]*swPDR),
+		pdrsByUEAddress: make(map[uint32]map[swRuleKey]*swPDR),
+		wildcardPDRs:    make(map[swRuleKey]*swPDR),
+		fars:            make(map[swRuleKey]*swFAR),
+		qers:            make(map[swRuleKey]*swQER),
+	}
+}
+
+// pdrIndex returns the index the PDR belongs to and its index key.
+func (sp *swPipeline) pdrIndex(p *pdr) (map[uint32]map[swRuleKey]*swPDR, uint32) {
+	if p.tunnelTEIDMask == math.MaxUint32 {
+		return sp.pdrsByTEID, p.tunnelTEID
+	}
+
+	if p.IsDownlink() && p.appFilter.dstIPMask == math.MaxUint32 {
+		return sp.pdrsByUEAddress, p.appFilter.dstIP
+	}
+
+	return nil, 0
+}
+
+func (sp *swPipeline) addPDR(p pdr) {
+	key := swRuleKey{fseID: p.fseID, id: p.pdrID}
+
+	sp.delPDR(key)
+
+	entry := &swPDR{pdr: p}
+	sp.pdrs[key] = entry
+
+	index, indexKey := sp.pdrIndex(&p)
+	if index == nil {
+		sp.wildcardPDRs[key] = entry
+		return
+	}
+
+	if index[indexKey] == nil {
+		index[indexKey] = make(map[swRuleKey]*swPDR)
+	}
+
+	index[indexKey][key] = entry
+}
+
+func (sp *swPipeline) delPDR(key swRuleKey) {
+	entry, ok := sp.pdrs[key]
+	if !ok {
+		return
+	}
+
+	delete(sp.pdrs, key)
+
+	index, indexKey := sp.pdrIndex(&entry.pdr)
+	if index == nil {
+		delete(sp.wildcardPDRs, key)
+		return
+	}
+
+	delete(index[indexKey], key)
+
+	if len(index[indexKey]) == 0 {
+		delete(index, indexKey)
+	}
+}
+
+// addFAR adds or updates the FAR, and returns the packets to process again if it stopped buffering.
+func (sp *swPipeline) addFAR(f far) []swPacket {
+	key := swRuleKey{fseID: f.fseID, id: f.farID}
+
+	entry, ok := sp.fars[key]
+	if !ok {
+		sp.fars[key] = &swFAR{far: f}
+		return nil
+	}
+
+	entry.far = f
+
+	if f.Buffers() {
+		return nil
+	}
+
+	buffered := entry.buffered
+	entry.buffered = nil
+
+	return buffered
+}
+
+func (sp *swPipeline) addQER(q qer) {
+	now := sp.now()
+
+	sp.qers[swRuleKey{fseID: q.fseID, id: q.qerID}] = &swQER{
+		qer: q,
+		ul:  newSWRateLimiter(q.ulMbr, now),
+		dl:  newSWRateLimiter(q.dlMbr, now),
+	}
+}
+
+// apply adds or deletes all rules in the pipeline.
+func (sp *swPipeline) apply(method upfMsgType, rules PacketForwardingRules) {
+	var flushed []swPacket
+
+	sp.mu.Lock()
+
+	for _, p := range rules.pdrs {
+		switch method {
+		case upfMsgTypeAdd, upfMsgTypeMod:
+			sp.addPDR(p)
+		case upfMsgTypeDel:
+			sp.delPDR(swRuleKey{fseID: p.fseID, id: p.pdrID})
+		}
+	}
+
+	for _, f := range rules.fars {
+		switch method {
+		case upfMsgTypeAdd, upfMsgTypeMod:
+			flushed = append(flushed, sp.addFAR(f)...)
+		case upfMsgTypeDel:
+			delete(sp.fars, swRuleKey{fseID: f.fseID, id: f.farID})
+		}
+	}
+
+	for _, q := range rules.qers {
+		switch method {
+		case upfMsgTypeAdd, upfMsgTypeMod:
+			sp.addQER(q)
+		case upfMsgTypeDel:
+			delete(sp.qers, swRuleKey{fseID: q.fseID, id: q.qerID})
+		}
+	}
+
+	sp.mu.Unlock()
+
+	if len(flushed) != 0 {
+		log.Debugf("Releasing %d buffered packets", len(flushed))
+	}
+
+	for _, pkt := range flushed {
+		sp.process(pkt)
+	}
+}
+
+// setSliceMeter configures the slice rate limits, in bits per second. A zero rate means unlimited.
+func (sp *swPipeline) setSliceMeter(uplinkBps, uplinkBurst, downlinkBps, downlinkBurst uint64) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	newBucket := func(bps, burst uint64) *swTokenBucket {
+		if bps == 0 {
+			return nil
+		}
+
+		if burst == 0 {
+			burst = DefaultBurstSize
+		}
+
+		return newSWTokenBucket(bps/8, burst, sp.now())
+	}
+
+	sp.sliceUplink = newBucket(uplinkBps, uplinkBurst)
+	sp.sliceDownlink = newBucket(downlinkBps, downlinkBurst)
+}
+
+// lookupPDR returns the highest priority PDR matching the packet, or nil.
+func (sp *swPipeline) lookupPDR(pkt *swPacket, info *swPacketInfo) *swPDR {
+	var best *swPDR
+
+	match := func(candidates map[swRuleKey]*swPDR) {
+		for _, entry := range candidates {
+			if best != nil && entry.precedence >= best.precedence {
+				continue
+			}
+
+			if swMatchPDR(&entry.pdr, pkt, info) {
+				best = entry
+			}
+		}
+	}
+
+	if pkt.tunnelTEID != 0 {
+		match(sp.pdrsByTEID[pkt.tunnelTEID])
+	}
+
+	match(sp.pdrsByUEAddress[info.dstIP])
+	match(sp.wildcardPDRs)
+
+	return best
+}
+
+// police applies the QERs of the PDR and the slice meter to a packet of n bytes.
+// It returns the QFI to mark the packet with, and false if the packet must be dropped.
+func (sp *swPipeline) police(p *swPDR, n int) (qfi uint8, ok bool) {
+	now := sp.now()
+
+	for _, qerID := range p.qerIDList {
+		q, found := sp.qers[swRuleKey{fseID: p.fseID, id: qerID}]
+		if !found {
+			continue
+		}
+
+		status, bucket := q.ulStatus, q.ul
+		if p.IsDownlink() {
+			status, bucket = q.dlStatus, q.dl
+		}
+
+		if status != ie.GateStatusOpen {
+			return 0, false
+		}
+
+		if bucket != nil && !bucket.allow(n, now) {
+			return 0, false
+		}
+
+		if qfi == 0 {
+			qfi = q.qfi
+		}
+	}
+
+	slice := sp.sliceUplink
+	if p.IsDownlink() {
+		slice = sp.sliceDownlink
+	}
+
+	if slice != nil && !slice.allow(n, now) {
+		return 0, false
+	}
+
+	return qfi, true
+}
+
+func (sp *swPipeline) portCounters(iface uint8) *swPortCounters {
+	if iface == access {
+		return &sp.accessPort
+	}
+
+	return &sp.corePort
+}
+
+// handleGTPU processes a GTP-U message received from remote on the local address localIP.
+func (sp *swPipeline) handleGTPU(iface uint8, localIP net.IP, remote *net.UDPAddr, msg []byte) {
+	var gtp layers.GTPv1U
+
+	if err := gtp.DecodeFromBytes(msg, gopacket.NilDecodeFeedback); err != nil {
+		log.Debugf("Dropping invalid GTP-U message from %v: %v", remote, err)
+
+		sp.mu.Lock()
+		sp.portCounters(iface).rxDropped++
+		sp.mu.Unlock()
+
+		return
+	}
+
+	switch gtp.MessageType {
+	case gtpuMsgTypeGPDU:
+		sp.process(swPacket{
+			srcIface:     iface,
+			tunnelIP4Dst: ip2int(localIP),
+			tunnelTEID:   gtp.TEID,
+			data:         gtp.LayerPayload(),
+		})
+	case gtpuMsgTypeEchoRequest:
+		if err := sp.egress.sendGTPU(localIP, remote, gtpuEchoResponse(gtp.SequenceNumber)); err != nil {
+			log.Errorf("Failed to send GTP-U Echo Response to %v: %v", remote, err)
+		}
+	default:
+		log.Tracef("Ignoring GTP-U message of type %v from %v", gtp.MessageType, remote)
+	}
+}
+
+// handleIP processes an IP packet received from the core (N6) network.
+func (sp *swPipeline) handleIP(pkt []byte) {
+	sp.process(swPacket{srcIface: core, data: pkt})
+}
+
+// process looks up the PDR of the packet and applies its FAR and QERs.
+func (sp *swPipeline) process(pkt swPacket) {
+	info, err := parseSWPacketInfo(pkt.data)
+
+	sp.mu.Lock()
+
+	inPort := sp.portCounters(pkt.srcIface)
+	if !pkt.buffered {
+		inPort.rx.count(len(pkt.data))
+	}
+
+	if err != nil {
+		inPort.rxDropped++
+		sp.mu.Unlock()
+
+		return
+	}
+
+	p := sp.lookupPDR(&pkt, &info)
+	if p == nil {
+		inPort.rxDropped++
+		sp.mu.Unlock()
+
+		return
+	}
+
+	if !pkt.buffered {
+		p.counters.rx.count(len(pkt.data))
+	}
+
+	f, ok := sp.fars[swRuleKey{fseID: p.fseID, id: p.farID}]
+
+	switch {
+	case !ok, f.Drops(), !f.Forwards() && !f.Buffers():
+		p.counters.dropped++
+		sp.mu.Unlock()
+
+		return
+	case f.Buffers():
+		sp.bufferLocked(p, f, pkt)
+		return
+	}
+
+	qfi, ok := sp.police(p, len(pkt.data))
+	if !ok {
+		p.counters.dropped++
+		sp.mu.Unlock()
+
+		return
+	}
+
+	outIface := uint8(core)
+	if f.dstIntf == ie.DstInterfaceAccess {
+		outIface = access
+	}
+
+	outPort := sp.portCounters(outIface)
+
+	var send func() error
+
+	switch {
+	case f.tunnelIP4Dst != 0:
+		if outIface != access {
+			// the QFI is only signalled to the access network
+			qfi = 0
+		}
+
+		msg := encapGTPU(f.tunnelTEID, qfi, pkt.data)
+		src := int2ip(f.tunnelIP4Src)
+		dst := &net.UDPAddr{IP: int2ip(f.tunnelIP4Dst), Port: int(f.tunnelPort)}
+
+		if dst.Port == 0 {
+			dst.Port = tunnelGTPUPort
+		}
+
+		send = func() error { return sp.egress.sendGTPU(src, dst, msg) }
+	case outIface == core:
+		send = func() error { return sp.egress.sendIP(pkt.data) }
+	default:
+		// packets towards the access network must be encapsulated
+		p.counters.dropped++
+		sp.mu.Unlock()
+
+		return
+	}
+
+	p.counters.tx.count(len(pkt.data))
+	outPort.tx.count(len(pkt.data))
+	sp.mu.Unlock()
+
+	if err := send(); err != nil {
+		log.Debugf("Failed to send packet of PDR %v: %v", p.pdrID, err)
+
+		sp.mu.Lock()
+		outPort.txDropped++
+		sp.mu.Unlock()
+	}
+}
+
+// bufferLocked stores the packet until the FAR forwards or drops, and notifies the control plane if requested.
+// Callers must hold mu, which is released.
+func (sp *swPipeline) bufferLocked(p *swPDR, f *swFAR, pkt swPacket) {
+	if len(f.buffered) >= swBufferSize {
+		p.counters.dropped++
+		sp.mu.Unlock()
+
+		return
+	}
+
+	// the packet data is owned by the reader, which reuses it
+	pkt.data = append([]byte(nil), pkt.data...)
+	pkt.buffered = true
+	f.buffered = append(f.buffered, pkt)
+
+	notify := f.applyAction&ActionNotify != 0
+	fseid := p.fseID
+
+	sp.mu.Unlock()
+
+	if notify && sp.notify != nil {
+		sp.notify(fseid)
+	}
+}
+
+// swPDRStats is a snapshot of the counters of a PDR.
+type swPDRStats struct {
+	fseID     uint64
+	pdrID     uint32
+	ueAddress uint32
+	counters  swPDRCounters
+}
+
+func (sp *swPipeline) pdrStats() []swPDRStats {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	stats := make([]swPDRStats, 0, len(sp.pdrs))
+
+	for _, p := range sp.pdrs {
+		stats = append(stats, swPDRStats{
+			fseID:     p.fseID,
+			pdrID:     p.pdrID,
+			ueAddress: p.ueAddress,
+			counters:  p.counters,
+		})
+	}
+
+	return stats
+}
+
+func (sp *swPipeline) portStats() (accessPort, corePort swPortCounters) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	return sp.accessPort, sp.corePort
+}
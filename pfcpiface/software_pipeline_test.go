@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+var (
+	swTestN3Address  = net.IP{198, 18, 0, 1}
+	swTestGNBAddress = net.IP{198, 18, 0, 10}
+	swTestUEAddress  = net.IP{10, 0, 0, 1}
+	swTestAppAddress = net.IP{8, 8, 8, 8}
+)
+
+const (
+	swTestFSEID        = 1
+	swTestUplinkTEID   = 0x10
+	swTestDownlinkTEID = 0x20
+)
+
+type swSentGTPU struct {
+	src net.IP
+	dst *net.UDPAddr
+	msg []byte
+}
+
+// swRecorder records the packets sent by the software pipeline.
+type swRecorder struct {
+	gtpu []swSentGTPU
+	ip   [][]byte
+}
+
+func (r *swRecorder) sendGTPU(src net.IP, dst *net.UDPAddr, msg []byte) error {
+	r.gtpu = append(r.gtpu, swSentGTPU{src: src, dst: dst, msg: msg})
+	return nil
+}
+
+func (r *swRecorder) sendIP(pkt []byte) error {
+	r.ip = append(r.ip, append([]byte(nil), pkt...))
+	return nil
+}
+
+func newSWTestRules() PacketForwardingRules {
+	ue := ip2int(swTestUEAddress)
+
+	return PacketForwardingRules{
+		pdrs: []pdr{
+			{
+				srcIface: access, srcIfaceMask: 0xff,
+				tunnelIP4Dst: ip2int(swTestN3Address), tunnelIP4DstMask: math.MaxUint32,
+				tunnelTEID: swTestUplinkTEID, tunnelTEIDMask: math.MaxUint32,
+				ueAddress: ue,
+				appFilter: applicationFilter{srcIP: ue, srcIPMask: math.MaxUint32},
+				pdrID:     1, fseID: swTestFSEID, farID: 1, qerIDList: []uint32{1}, needDecap: 1, precedence: 100,
+			},
+			{
+				srcIface: core, srcIfaceMask: 0xff,
+				ueAddress: ue,
+				appFilter: applicationFilter{dstIP: ue, dstIPMask: math.MaxUint32},
+				pdrID:     2, fseID: swTestFSEID, farID: 2, qerIDList: []uint32{1}, precedence: 100,
+			},
+		},
+		fars: []far{
+			{farID: 1, fseID: swTestFSEID, applyAction: ActionForward, dstIntf: ie.DstInterfaceCore},
+			{
+				farID: 2, fseID: swTestFSEID, applyAction: ActionForward, dstIntf: ie.DstInterfaceAccess,
+				tunnelType: 1, tunnelIP4Src: ip2int(swTestN3Address), tunnelIP4Dst: ip2int(swTestGNBAddress),
+				tunnelTEID: swTestDownlinkTEID, tunnelPort: tunnelGTPUPort,
+			},
+		},
+		qers: []qer{
+			{qerID: 1, fseID: swTestFSEID, qosLevel: SessionQos, qfi: 9},
+		},
+	}
+}
+
+func newSWTestPacket(t *testing.T, src, dst net.IP, srcPort, dstPort uint16, payloadLen int) []byte {
+	ipLayer := &layers.IPv4{Version: 4, TTL: 64, SrcIP: src, DstIP: dst, Protocol: layers.IPProtocolUDP}
+	udpLayer := &layers.UDP{SrcPort: layers.UDPPort(srcPort), DstPort: layers.UDPPort(dstPort)}
+	require.NoError(t, udpLayer.SetNetworkLayerForChecksum(ipLayer))
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, ipLayer, udpLayer, gopacket.Payload(make([]byte, payloadLen))))
+
+	return buf.Bytes()
+}
+
+func newSWTestPipeline(rules PacketForwardingRules) (*swPipeline, *swRecorder, *[]uint64) {
+	recorder := &swRecorder{}
+	notified := make([]uint64, 0)
+
+	sp := newSWPipeline(recorder, func(fseid uint64) { notified = append(notified, fseid) })
+	sp.apply(upfMsgTypeAdd, rules)
+
+	return sp, recorder, &notified
+}
+
+func sendSWTestUplink(sp *swPipeline, teid uint32, pkt []byte) {
+	gnb := &net.UDPAddr{IP: swTestGNBAddress, Port: tunnelGTPUPort}
+	sp.handleGTPU(access, swTestN3Address, gnb, encapGTPU(teid, 0, pkt))
+}
+
+func Test_swPipeline_uplink(t *testing.T) {
+	sp, recorder, _ := newSWTestPipeline(newSWTestRules())
+
+	pkt := newSWTestPacket(t, swTestUEAddress, swTestAppAddress, 1000, 80, 10)
+
+	sendSWTestUplink(sp, swTestUplinkTEID, pkt)
+	// unknown TEID
+	sendSWTestUplink(sp, swTestUplinkTEID+1, pkt)
+
+	require.Equal(t, [][]byte{pkt}, recorder.ip, "the decapsulated packet should be sent to the core")
+	require.Empty(t, recorder.gtpu)
+
+	accessPort, corePort := sp.portStats()
+	require.Equal(t, uint64(2), accessPort.rx.packets)
+	require.Equal(t, uint64(1), accessPort.rxDropped)
+	require.Equal(t, uint64(1), corePort.tx.packets)
+}
+
+func Test_swPipeline_downlink(t *testing.T) {
+	sp, recorder, _ := newSWTestPipeline(newSWTestRules())
+
+	pkt := newSWTestPacket(t, swTestAppAddress, swTestUEAddress, 80, 1000, 10)
+	sp.handleIP(pkt)
+
+	require.Len(t, recorder.gtpu, 1)
+
+	sent := recorder.gtpu[0]
+	require.True(t, sent.src.Equal(swTestN3Address))
+	require.True(t, sent.dst.IP.Equal(swTestGNBAddress))
+	require.Equal(t, tunnelGTPUPort, sent.dst.Port)
+
+	var gtp layers.GTPv1U
+
+	require.NoError(t, gtp.DecodeFromBytes(sent.msg, gopacket.NilDecodeFeedback))
+	require.Equal(t, uint32(swTestDownlinkTEID), gtp.TEID)
+	require.Equal(t, uint8(gtpuMsgTypeGPDU), gtp.MessageType)
+	require.Len(t, gtp.GTPExtensionHeaders, 1)
+	require.Equal(t, uint8(gtpuExtPDUSessionContainer), gtp.GTPExtensionHeaders[0].Type)
+	require.Equal(t, uint8(9), gtp.GTPExtensionHeaders[0].Content[1], "the QFI of the QER should be set")
+	require.Equal(t, pkt, gtp.LayerPayload())
+
+	stats := sp.pdrStats()
+	require.Len(t, stats, 2)
+
+	for _, st := range stats {
+		if st.pdrID == 2 {
+			require.Equal(t, uint64(1), st.counters.rx.packets)
+			require.Equal(t, uint64(1), st.counters.tx.packets)
+		}
+	}
+}
+
+func Test_swPipeline_matchesApplicationFilter(t *testing.T) {
+	rules := newSWTestRules()
+
+	// higher priority PDR dropping the uplink traffic towards port 53
+	dnsPDR := rules.pdrs[0]
+	dnsPDR.pdrID = 3
+	dnsPDR.farID = 3
+	dnsPDR.precedence = 10
+	dnsPDR.appFilter.proto = uint8(layers.IPProtocolUDP)
+	dnsPDR.appFilter.protoMask = math.MaxUint8
+	dnsPDR.appFilter.dstPortRange = newExactMatchPortRange(53)
+
+	rules.pdrs = append(rules.pdrs, dnsPDR)
+	rules.fars = append(rules.fars, far{farID: 3, fseID: swTestFSEID, applyAction: ActionDrop})
+
+	sp, recorder, _ := newSWTestPipeline(rules)
+
+	sendSWTestUplink(sp, swTestUplinkTEID, newSWTestPacket(t, swTestUEAddress, swTestAppAddress, 1000, 53, 10))
+	require.Empty(t, recorder.ip)
+
+	sendSWTestUplink(sp, swTestUplinkTEID, newSWTestPacket(t, swTestUEAddress, swTestAppAddress, 1000, 80, 10))
+	require.Len(t, recorder.ip, 1)
+}
+
+func Test_swPipeline_buffering(t *testing.T) {
+	rules := newSWTestRules()
+	rules.fars[1].applyAction = ActionBuffer | ActionNotify
+
+	sp, recorder, notified := newSWTestPipeline(rules)
+
+	pkt := newSWTestPacket(t, swTestAppAddress, swTestUEAddress, 80, 1000, 10)
+
+	for i := 0; i < swBufferSize+1; i++ {
+		sp.handleIP(pkt)
+	}
+
+	require.Empty(t, recorder.gtpu)
+	require.NotEmpty(t, *notified)
+	require.Equal(t, uint64(swTestFSEID), (*notified)[0])
+
+	// the FAR forwards again, e.g. after a service request
+	forwardFAR := rules.fars[1]
+	forwardFAR.applyAction = ActionForward
+
+	sp.apply(upfMsgTypeMod, PacketForwardingRules{fars: []far{forwardFAR}})
+
+	require.Len(t, recorder.gtpu, swBufferSize, "buffered packets should be released")
+
+	for _, st := range sp.pdrStats() {
+		if st.pdrID == 2 {
+			require.Equal(t, uint64(swBufferSize+1), st.counters.rx.packets)
+			require.Equal(t, uint64(1), st.counters.dropped, "packets exceeding the buffer should be dropped")
+		}
+	}
+}
+
+func Test_swPipeline_policing(t *testing.T) {
+	rules := newSWTestRules()
+	// 8 Mbps, i.e. a DefaultBurstSize bucket refilled at 1 MB/s
+	rules.qers[0].ulMbr = 8000
+
+	sp, recorder, _ := newSWTestPipeline(rules)
+
+	now := time.Unix(0, 0)
+	sp.now = func() time.Time { return now }
+	sp.apply(upfMsgTypeMod, PacketForwardingRules{qers: rules.qers})
+
+	pkt := newSWTestPacket(t, swTestUEAddress, swTestAppAddress, 1000, 80, 1000)
+
+	for i := 0; i < 2*DefaultBurstSize/len(pkt); i++ {
+		sendSWTestUplink(sp, swTestUplinkTEID, pkt)
+	}
+
+	require.Len(t, recorder.ip, DefaultBurstSize/len(pkt), "packets exceeding the burst should be dropped")
+
+	now = now.Add(time.Millisecond)
+
+	sendSWTestUplink(sp, swTestUplinkTEID, pkt)
+	require.Len(t, recorder.ip, DefaultBurstSize/len(pkt)+1, "tokens should be refilled over time")
+
+	// closing the gate drops all packets
+	rules.qers[0].ulStatus = ie.GateStatusClosed
+	sp.apply(upfMsgTypeMod, PacketForwardingRules{qers: rules.qers})
+
+	now = now.Add(time.Second)
+
+	sendSWTestUplink(sp, swTestUplinkTEID, pkt)
+	require.Len(t, recorder.ip, DefaultBurstSize/len(pkt)+1)
+}
+
+func Test_swPipeline_delete(t *testing.T) {
+	rules := newSWTestRules()
+	sp, recorder, _ := newSWTestPipeline(rules)
+
+	sp.apply(upfMsgTypeDel, rules)
+
+	sendSWTestUplink(sp, swTestUplinkTEID, newSWTestPacket(t, swTestUEAddress, swTestAppAddress, 1000, 80, 10))
+	sp.handleIP(newSWTestPacket(t, swTestAppAddress, swTestUEAddress, 80, 1000, 10))
+
+	require.Empty(t, recorder.ip)
+	require.Empty(t, recorder.gtpu)
+	require.Empty(t, sp.pdrStats())
+	require.Empty(t, sp.pdrsByTEID)
+	require.Empty(t, sp.pdrsByUEAddress)
+}
+
+func Test_swPipeline_echo(t *testing.T) {
+	sp, recorder, _ := newSWTestPipeline(PacketForwardingRules{})
+
+	echoRequest := []byte{0x32, gtpuMsgTypeEchoRequest, 0, 4, 0, 0, 0, 0, 0x12, 0x34, 0, 0}
+	gnb := &net.UDPAddr{IP: swTestGNBAddress, Port: tunnelGTPUPort}
+
+	sp.handleGTPU(access, swTestN3Address, gnb, echoRequest)
+
+	require.Len(t, recorder.gtpu, 1)
+	require.Equal(t, gnb, recorder.gtpu[0].dst)
+
+	var gtp layers.GTPv1U
+
+	require.NoError(t, gtp.DecodeFromBytes(recorder.gtpu[0].msg, gopacket.NilDecodeFeedback))
+	require.Equal(t, uint8(gtpuMsgTypeEchoResponse), gtp.MessageType)
+	require.Equal(t, uint16(0x1234), gtp.SequenceNumber)
+}
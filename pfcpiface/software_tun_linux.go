@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ifReq is the ifreq structure used to configure the TUN device.
+type ifReq struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// openTUN attaches to the TUN device name, creating it if it doesn't exist.
+// Packets are read and written without the packet information header.
+func openTUN(name string) (io.ReadWriteCloser, error) {
+	if len(name) >= syscall.IFNAMSIZ {
+		return nil, ErrInvalidArgumentWithReason("TUN device name", name, "name too long")
+	}
+
+	fd, err := syscall.Open("/dev/net/tun", syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, ErrOperationFailedWithReason("open /dev/net/tun", err.Error())
+	}
+
+	var req ifReq
+
+	copy(req.name[:], name)
+	req.flags = syscall.IFF_TUN | syscall.IFF_NO_PI
+
+	//nolint:gosec // the ioctl requires a pointer to the ifreq structure
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TUNSETIFF, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		syscall.Close(fd)
+		return nil, ErrOperationFailedWithReason("attach to TUN device "+name, errno.Error())
+	}
+
+	// non-blocking mode lets the runtime poller unblock reads on Close
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, ErrOperationFailedWithReason("set TUN device non-blocking", err.Error())
+	}
+
+	return os.NewFile(uintptr(fd), "/dev/net/tun"), nil
+}
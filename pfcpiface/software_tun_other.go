@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+//go:build !linux
+// +build !linux
+
+package pfcpiface
+
+import (
+	"io"
+	"runtime"
+)
+
+// openTUN is only supported on Linux.
+func openTUN(name string) (io.ReadWriteCloser, error) {
+	return nil, ErrUnsupported("TUN devices on", runtime.GOOS)
+}
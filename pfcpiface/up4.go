@@ -4,18 +4,21 @@
 package pfcpiface
 
 import (
+	"context"
 	"encoding/binary"
 	"flag"
 	"fmt"
 	"math"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"google.golang.org/grpc/connectivity"
 
 	"github.com/omec-project/upf-epc/internal/p4constants"
-	"google.golang.org/grpc/codes"
 
 	p4 "github.com/p4lang/p4runtime/go/p4/v1"
 
@@ -29,9 +32,18 @@ const (
 	preQosCounterID = iota
 	postQosCounterID
 
-	// 253 base stations + 1 dbuf (fixed in UP4) + 1 reserved (fixed in P4 pipeline)
-	maxGTPTunnelPeerIDs = 253
-	maxApplicationIDs   = 254
+	// tunnel peer ID 0 is reserved in the P4 pipeline, and 1 is the dbuf tunnel peer, also
+	// using an entry of the tunnel peers table (fixed in UP4).
+	firstGTPTunnelPeerID         = 2
+	reservedGTPTunnelPeerEntries = 1
+	// application ID 0 is the default application, without an entry in the applications table.
+	firstApplicationID = 1
+
+	idPoolTunnelPeers  = "tunnel_peer"
+	idPoolApplications = "application"
+
+	// up4CounterReadBatchSize is the max number of counter cells read in a single P4Runtime request.
+	up4CounterReadBatchSize = 256
 
 	meterTypeApplication uint8 = 1
 	meterTypeSession     uint8 = 2
@@ -55,7 +67,7 @@ type internalAppReference struct {
 }
 
 type internalApp struct {
-	id uint8
+	id uint16
 	// usedBy keeps track of <F-SEID (UE session); PDR-ID> pairs using this application filter.
 	usedBy set.Set
 }
@@ -66,6 +78,39 @@ type up4ApplicationFilter struct {
 	appProto  uint8
 }
 
+// pdrCounterRef identifies the PDR that uses a pre/post-QoS counter cell.
+type pdrCounterRef struct {
+	ctrID     uint32
+	fseid     uint64
+	pdrID     uint32
+	uplink    bool
+	ueAddress uint32
+}
+
+// pdrCounterStats holds counter values read from UP4 for a single PDR.
+type pdrCounterStats struct {
+	ref  pdrCounterRef
+	pre  *p4.CounterData
+	post *p4.CounterData
+}
+
+// droppedPackets returns the number of packets dropped by QoS enforcement.
+func (s pdrCounterStats) droppedPackets() int64 {
+	if dropped := s.pre.GetPacketCount() - s.post.GetPacketCount(); dropped > 0 {
+		return dropped
+	}
+
+	return 0
+}
+
+type up4PortStats struct {
+	iface   string
+	dir     string
+	packets int64
+	bytes   int64
+	dropped int64
+}
+
 type counter struct {
 	maxSize        uint64
 	counterID      uint64
@@ -94,9 +139,12 @@ type tnlPeerReference struct {
 }
 
 type tunnelPeer struct {
-	id uint8
+	id uint16
 	// usedBy keeps track of <F-SEID (UE session); FAR-ID> pairs using this tunnel peer.
 	usedBy set.Set
+	// idleSince is the time the last reference was removed. Idle tunnel peers are kept in UP4
+	// and reclaimed, least recently used first, when tunnel peer IDs are exhausted.
+	idleSince time.Time
 }
 
 func (t tunnelPeer) String() string {
@@ -106,6 +154,7 @@ func (t tunnelPeer) String() string {
 
 type UP4 struct {
 	conf P4rtcInfo
+	upf  *upf
 
 	host            string
 	deviceID        uint64
@@ -121,21 +170,35 @@ type UP4 struct {
 	connectedMu sync.RWMutex
 
 	initOnce sync.Once
+	// stateInitialized is set once the UP4 state is cleared or restored at startup.
+	stateInitialized bool
 	// tryConnectMu ensures a single re-connection try
 	tryConnectMu sync.Mutex
+	// stateMu serializes the reconciliation with regular rule updates.
+	stateMu sync.RWMutex
+	// noAtomicWrites is set if the P4Runtime server doesn't support rollback-on-error writes.
+	noAtomicWrites int32
 
 	p4RtTranslator *P4rtTranslator
 
 	// TODO: create UP4Store object and move these fields there
 	counters []counter
+	// countersMu guards pdrCounters, as it's read concurrently by the Prometheus collectors.
+	countersMu sync.RWMutex
+	// pdrCounters maps an allocated counter cell index to the PDR using it.
+	pdrCounters map[uint32]pdrCounterRef
 	// tunnelPeerMu guards concurrent R/W access to tunnel peers,
 	// as tunnel peers are likely to be shared between different UE sessions.
-	tunnelPeerMu       sync.Mutex
-	tunnelPeerIDs      map[tunnelParams]tunnelPeer
-	tunnelPeerIDsPool  []uint8
+	tunnelPeerMu      sync.Mutex
+	tunnelPeerIDs     map[tunnelParams]tunnelPeer
+	tunnelPeerIDsPool []uint16
+	// tunnelPeerIDsSize is the number of tunnel peer IDs, derived from the p4info.
+	tunnelPeerIDsSize  int64
 	applicationMu      sync.Mutex
 	applicationIDs     map[up4ApplicationFilter]internalApp
-	applicationIDsPool []uint8
+	applicationIDsPool []uint16
+	// applicationIDsSize is the number of application IDs, derived from the p4info.
+	applicationIDsSize int64
 
 	// meters stores the mapping from <F-SEID; QER ID> -> P4 Meter Cell ID.
 	// P4 Meter Cell ID is retrieved from appMeterCellIDsPool or sessMeterCellIDsPool,
@@ -224,14 +287,248 @@ func (up4 *UP4) AddSliceInfo(sliceInfo *SliceInfo) error {
 	return nil
 }
 
+// SummaryLatencyJitter is a no-op for UP4: the P4 pipeline does not timestamp packets,
+// so there is no latency or jitter information to export.
 func (up4 *UP4) SummaryLatencyJitter(uc *upfCollector, ch chan<- prometheus.Metric) {
 }
 
-func (up4 *UP4) SessionStats(*PfcpNodeCollector, chan<- prometheus.Metric) error {
+// SessionStats exports per-PDR packet and byte counters read from the pre/post-QoS counters.
+// Metric names and labels are the same as exported by the BESS datapath.
+func (up4 *UP4) SessionStats(pc *PfcpNodeCollector, ch chan<- prometheus.Metric) error {
+	stats, err := up4.readPDRCounters()
+	if err != nil {
+		return err
+	}
+
+	for _, st := range stats {
+		fseidString := strconv.FormatUint(st.ref.fseid, 10)
+		pdrString := strconv.FormatUint(uint64(st.ref.pdrID), 10)
+
+		ueIPString := "unknown"
+		if st.ref.ueAddress != 0 {
+			ueIPString = int2ip(st.ref.ueAddress).String()
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			pc.sessionTxPackets,
+			prometheus.GaugeValue,
+			float64(st.post.GetPacketCount()),
+			fseidString,
+			pdrString,
+			ueIPString,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			pc.sessionRxPackets,
+			prometheus.GaugeValue,
+			float64(st.pre.GetPacketCount()),
+			fseidString,
+			pdrString,
+			ueIPString,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			pc.sessionDroppedPackets,
+			prometheus.GaugeValue,
+			float64(st.droppedPackets()),
+			fseidString,
+			pdrString,
+			ueIPString,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			pc.sessionTxBytes,
+			prometheus.GaugeValue,
+			float64(st.post.GetByteCount()),
+			fseidString,
+			pdrString,
+			ueIPString,
+		)
+	}
+
 	return nil
 }
 
+// PortStats exports UPF-wide packet and byte counters aggregated from the per-PDR counters.
+// Uplink PDRs are received on the Access interface and sent out on the Core interface,
+// downlink PDRs the other way around.
 func (up4 *UP4) PortStats(uc *upfCollector, ch chan<- prometheus.Metric) {
+	if !up4.IsConnected(nil) {
+		return
+	}
+
+	stats, err := up4.readPDRCounters()
+	if err != nil {
+		log.Errorf("Failed to read UP4 counters: %v", err)
+		return
+	}
+
+	for _, ps := range aggregatePortStats(stats) {
+		ch <- prometheus.MustNewConstMetric(uc.packets, prometheus.CounterValue,
+			float64(ps.packets), ps.iface, ps.dir)
+		ch <- prometheus.MustNewConstMetric(uc.bytes, prometheus.CounterValue,
+			float64(ps.bytes), ps.iface, ps.dir)
+		ch <- prometheus.MustNewConstMetric(uc.dropped, prometheus.CounterValue,
+			float64(ps.dropped), ps.iface, ps.dir)
+	}
+}
+
+// readPDRCounters reads the pre- and post-QoS counter cells of all tracked PDRs.
+// Cells are read in batches of up4CounterReadBatchSize indexes, both counters in the same request.
+func (up4 *UP4) readPDRCounters() ([]pdrCounterStats, error) {
+	if !up4.IsConnected(nil) {
+		return nil, ErrOperationFailedWithReason("read UP4 counters", "UP4 server not connected")
+	}
+
+	up4.countersMu.RLock()
+	refs := make([]pdrCounterRef, 0, len(up4.pdrCounters))
+
+	for _, ref := range up4.pdrCounters {
+		refs = append(refs, ref)
+	}
+	up4.countersMu.RUnlock()
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ctrID < refs[j].ctrID })
+
+	stats := make([]pdrCounterStats, 0, len(refs))
+
+	for start := 0; start < len(refs); start += up4CounterReadBatchSize {
+		end := start + up4CounterReadBatchSize
+		if end > len(refs) {
+			end = len(refs)
+		}
+
+		batch := refs[start:end]
+		entries := make([]*p4.CounterEntry, 0, 2*len(batch))
+
+		for _, ref := range batch {
+			entries = append(entries,
+				&p4.CounterEntry{
+					CounterId: p4constants.CounterPreQosPipePreQosCounter,
+					Index:     &p4.Index{Index: int64(ref.ctrID)},
+				},
+				&p4.CounterEntry{
+					CounterId: p4constants.CounterPostQosPipePostQosCounter,
+					Index:     &p4.Index{Index: int64(ref.ctrID)},
+				})
+		}
+
+		resp, err := up4.p4client.ReadCounterEntries(entries)
+		if err != nil {
+			return nil, ErrOperationFailedWithReason("read UP4 counters", err.Error())
+		}
+
+		stats = append(stats, buildPDRCounterStats(batch, resp.GetEntities())...)
+	}
+
+	return stats, nil
+}
+
+// trackPDRCounter remembers which PDR uses a counter cell, so that counter values can be
+// mapped back to the F-SEID and UE address. Must be called after UE address mappings are updated.
+func (up4 *UP4) trackPDRCounter(p pdr) {
+	ueAddr := p.ueAddress
+	if p.IsUplink() {
+		ueAddr = up4.fseidToUEAddr[p.fseID]
+	}
+
+	up4.countersMu.Lock()
+	defer up4.countersMu.Unlock()
+
+	up4.pdrCounters[p.ctrID] = pdrCounterRef{
+		ctrID:     p.ctrID,
+		fseid:     p.fseID,
+		pdrID:     p.pdrID,
+		uplink:    p.IsUplink(),
+		ueAddress: ueAddr,
+	}
+}
+
+func (up4 *UP4) untrackPDRCounter(p pdr) {
+	up4.countersMu.Lock()
+	defer up4.countersMu.Unlock()
+
+	if ref, ok := up4.pdrCounters[p.ctrID]; ok && ref.fseid == p.fseID && ref.pdrID == p.pdrID {
+		delete(up4.pdrCounters, p.ctrID)
+	}
+}
+
+func (up4 *UP4) resetTrackedPDRCounters() {
+	up4.countersMu.Lock()
+	defer up4.countersMu.Unlock()
+
+	up4.pdrCounters = make(map[uint32]pdrCounterRef)
+}
+
+// buildPDRCounterStats joins counter entities read from UP4 with the PDRs using the counter cells.
+// PDRs without any counter entity in the response are skipped.
+func buildPDRCounterStats(refs []pdrCounterRef, entities []*p4.Entity) []pdrCounterStats {
+	pre := make(map[int64]*p4.CounterData)
+	post := make(map[int64]*p4.CounterData)
+
+	for _, entity := range entities {
+		entry := entity.GetCounterEntry()
+		if entry == nil || entry.GetIndex() == nil {
+			continue
+		}
+
+		switch entry.GetCounterId() {
+		case p4constants.CounterPreQosPipePreQosCounter:
+			pre[entry.GetIndex().GetIndex()] = entry.GetData()
+		case p4constants.CounterPostQosPipePostQosCounter:
+			post[entry.GetIndex().GetIndex()] = entry.GetData()
+		}
+	}
+
+	stats := make([]pdrCounterStats, 0, len(refs))
+
+	for _, ref := range refs {
+		preData, preOk := pre[int64(ref.ctrID)]
+		postData, postOk := post[int64(ref.ctrID)]
+
+		if !preOk && !postOk {
+			log.WithField("counter index", ref.ctrID).Debug("No counter data read for PDR")
+			continue
+		}
+
+		stats = append(stats, pdrCounterStats{
+			ref:  ref,
+			pre:  preData,
+			post: postData,
+		})
+	}
+
+	return stats
+}
+
+// aggregatePortStats sums per-PDR counters into per-interface, per-direction totals.
+func aggregatePortStats(stats []pdrCounterStats) []up4PortStats {
+	const (
+		accessRx = iota
+		accessTx
+		coreRx
+		coreTx
+	)
+
+	ports := []up4PortStats{
+		accessRx: {iface: "Access", dir: "rx"},
+		accessTx: {iface: "Access", dir: "tx"},
+		coreRx:   {iface: "Core", dir: "rx"},
+		coreTx:   {iface: "Core", dir: "tx"},
+	}
+
+	for _, st := range stats {
+		rx, tx := coreRx, accessTx
+		if st.ref.uplink {
+			rx, tx = accessRx, coreTx
+		}
+
+		ports[rx].packets += st.pre.GetPacketCount()
+		ports[rx].bytes += st.pre.GetByteCount()
+		ports[rx].dropped += st.droppedPackets()
+
+		ports[tx].packets += st.post.GetPacketCount()
+		ports[tx].bytes += st.post.GetByteCount()
+	}
+
+	return ports
 }
 
 func (up4 *UP4) initCounter(counterID uint8, name string, counterSize uint64) {
@@ -258,15 +555,13 @@ func (up4 *UP4) releaseCounterID(p4counterID uint8, val uint64) {
 
 func (up4 *UP4) allocateCounterID(p4counterID uint8) (uint64, error) {
 	if up4.counters[p4counterID].counterIDsPool.Cardinality() == 0 {
-		return 0, ErrOperationFailedWithReason("allocate Counter ID",
-			"no free Counter IDs available")
+		return 0, ErrNoResourcesAvailable("Counter IDs")
 	}
 
 	allocated := up4.counters[p4counterID].counterIDsPool.Pop()
 
 	if allocated == nil {
-		return 0, ErrOperationFailedWithReason("allocate Counter ID",
-			"no free Counter IDs available")
+		return 0, ErrNoResourcesAvailable("Counter IDs")
 	}
 
 	return allocated.(uint64), nil
@@ -283,7 +578,7 @@ func (up4 *UP4) setupChannel() error {
 	})
 	setupLog.Debug("Trying to setup P4Rt channel")
 
-	client, err := CreateChannel(up4.host, up4.deviceID)
+	client, err := CreateChannel(up4.host, up4.deviceID, up4.electionID())
 	if err != nil {
 		setupLog.Errorf("create channel failed: %v", err)
 		return err
@@ -292,6 +587,7 @@ func (up4 *UP4) setupChannel() error {
 	up4.p4client = client
 
 	up4.p4RtTranslator = newP4RtTranslator(up4.p4client.P4Info)
+	up4.sizeIDPools()
 
 	setupLog.Debug("P4Rt channel created")
 
@@ -365,25 +661,65 @@ func (up4 *UP4) initMetersPools() {
 
 func (up4 *UP4) initTunnelPeerIDs() {
 	up4.tunnelPeerIDs = make(map[tunnelParams]tunnelPeer)
-	// a simple queue storing available tunnel peer IDs
-	// 0 is reserved;
-	// 1 is reserved for dbuf
-	up4.tunnelPeerIDsPool = make([]uint8, 0, maxGTPTunnelPeerIDs)
-
-	for i := 2; i < maxGTPTunnelPeerIDs+2; i++ {
-		up4.tunnelPeerIDsPool = append(up4.tunnelPeerIDsPool, uint8(i))
-	}
+	// a simple queue storing available tunnel peer IDs, filled by sizeIDPools
+	up4.tunnelPeerIDsPool = make([]uint16, 0)
+	up4.tunnelPeerIDsSize = 0
 }
 
 func (up4 *UP4) initApplicationIDs() {
 	up4.applicationIDs = make(map[up4ApplicationFilter]internalApp)
-	// a simple queue storing available application IDs
-	// 0 is reserved;
-	up4.applicationIDsPool = make([]uint8, 0, maxApplicationIDs)
+	// a simple queue storing available application IDs, filled by sizeIDPools
+	up4.applicationIDsPool = make([]uint16, 0)
+	up4.applicationIDsSize = 0
+}
+
+// growIDPool adds the IDs from first+oldSize to first+newSize to pool.
+// IDs are never removed, as they may be allocated.
+func growIDPool(pool []uint16, first, oldSize, newSize int64) []uint16 {
+	for id := first + oldSize; id < first+newSize; id++ {
+		pool = append(pool, uint16(id))
+	}
+
+	return pool
+}
+
+func setIDPoolMetrics(pool string, size int64, free int) {
+	metrics := getDatapathMetrics()
+	metrics.up4IDPoolSize.WithLabelValues(pool).Set(float64(size))
+	metrics.up4IDPoolFree.WithLabelValues(pool).Set(float64(free))
+}
+
+// sizeIDPools sizes the tunnel peer and application ID pools from the table sizes and
+// the bitwidth of the ID fields in the p4info of UP4.
+func (up4 *UP4) sizeIDPools() {
+	tunnelPeers, err := up4.p4RtTranslator.getIDPoolSize(p4constants.TablePreQosPipeTunnelPeers, reservedGTPTunnelPeerEntries,
+		p4constants.TablePreQosPipeTunnelPeers, FieldTunnelPeerID, firstGTPTunnelPeerID)
+	if err != nil {
+		log.Errorf("Could not size the tunnel peer IDs pool: %v", err)
+	} else if tunnelPeers > up4.tunnelPeerIDsSize {
+		up4.tunnelPeerMu.Lock()
+		up4.tunnelPeerIDsPool = growIDPool(up4.tunnelPeerIDsPool, firstGTPTunnelPeerID, up4.tunnelPeerIDsSize, tunnelPeers)
+		up4.tunnelPeerIDsSize = tunnelPeers
+		setIDPoolMetrics(idPoolTunnelPeers, up4.tunnelPeerIDsSize, len(up4.tunnelPeerIDsPool))
+		up4.tunnelPeerMu.Unlock()
+	}
 
-	for i := 1; i < maxApplicationIDs+1; i++ {
-		up4.applicationIDsPool = append(up4.applicationIDsPool, uint8(i))
+	applications, err := up4.p4RtTranslator.getIDPoolSize(p4constants.TablePreQosPipeApplications, 0,
+		p4constants.TablePreQosPipeTerminationsUplink, FieldApplicationID, firstApplicationID)
+	if err != nil {
+		log.Errorf("Could not size the application IDs pool: %v", err)
+	} else if applications > up4.applicationIDsSize {
+		up4.applicationMu.Lock()
+		up4.applicationIDsPool = growIDPool(up4.applicationIDsPool, firstApplicationID, up4.applicationIDsSize, applications)
+		up4.applicationIDsSize = applications
+		setIDPoolMetrics(idPoolApplications, up4.applicationIDsSize, len(up4.applicationIDsPool))
+		up4.applicationMu.Unlock()
 	}
+
+	log.WithFields(log.Fields{
+		"tunnel peer IDs": up4.tunnelPeerIDsSize,
+		"application IDs": up4.applicationIDsSize,
+	}).Debug("ID pools sized from p4info")
 }
 
 // This function ensures that PFCP Agent is connected to UP4.
@@ -408,6 +744,7 @@ func (up4 *UP4) SetUpfInfo(u *upf, conf *Conf) {
 	log.Println("SetUpfInfo UP4")
 
 	up4.conf = conf.P4rtcIface
+	up4.upf = u
 
 	up4.AccessIP = MustParseStrIP(conf.P4rtcIface.AccessIP)
 	u.AccessIP = up4.AccessIP.IP
@@ -449,6 +786,7 @@ func (up4 *UP4) SetUpfInfo(u *upf, conf *Conf) {
 	up4.fseidToUEAddr = make(map[uint64]uint32)
 
 	up4.counters = make([]counter, 2)
+	up4.pdrCounters = make(map[uint32]pdrCounterRef)
 
 	go up4.keepTryingToConnect()
 }
@@ -461,8 +799,14 @@ func (up4 *UP4) tryConnect() error {
 		return nil
 	}
 
-	// datapath state should be cleared & initialized if P4Rt connection or ForwardingConfig is not setup yet.
-	shouldClearAndInitialize := up4.p4client == nil || up4.p4client.P4Info == nil
+	if up4.conf.EnableHA && up4.isChannelUp() {
+		// the channel is kept while standby, watchMastership takes over when possible
+		return up4.unsafeTakeover()
+	}
+
+	// datapath state should be initialized if P4Rt connection or ForwardingConfig is not setup yet,
+	// or if a previous initialization failed.
+	startup := up4.p4client == nil || up4.p4client.P4Info == nil || !up4.stateInitialized
 
 	err := up4.setupChannel()
 	if err != nil {
@@ -470,7 +814,13 @@ func (up4 *UP4) tryConnect() error {
 		return err
 	}
 
-	err = up4.initialize(shouldClearAndInitialize)
+	if up4.conf.EnableHA {
+		go up4.watchMastership(up4.p4client)
+
+		return ErrOperationFailedWithReason("connect to UP4", "waiting for mastership arbitration")
+	}
+
+	err = up4.initialize(startup)
 	if err != nil {
 		log.Errorf("Failed to initialize UP4: %v", err)
 		return err
@@ -493,18 +843,19 @@ func (up4 *UP4) keepTryingToConnect() {
 	}
 }
 
-func (up4 *UP4) clearTables() error {
-	tableIDs := []uint32{
-		p4constants.TablePreQosPipeSessionsUplink,
-		p4constants.TablePreQosPipeSessionsDownlink,
-		p4constants.TablePreQosPipeTerminationsUplink,
-		p4constants.TablePreQosPipeTerminationsDownlink,
-		p4constants.TablePreQosPipeTunnelPeers,
-		p4constants.TablePreQosPipeInterfaces,
-		p4constants.TablePreQosPipeApplications,
-	}
+// up4OwnedTables are the UP4 tables fully managed by pfcpiface.
+var up4OwnedTables = []uint32{
+	p4constants.TablePreQosPipeSessionsUplink,
+	p4constants.TablePreQosPipeSessionsDownlink,
+	p4constants.TablePreQosPipeTerminationsUplink,
+	p4constants.TablePreQosPipeTerminationsDownlink,
+	p4constants.TablePreQosPipeTunnelPeers,
+	p4constants.TablePreQosPipeInterfaces,
+	p4constants.TablePreQosPipeApplications,
+}
 
-	if err := up4.p4client.ClearTables(tableIDs); err != nil {
+func (up4 *UP4) clearTables() error {
+	if err := up4.p4client.ClearTables(up4OwnedTables); err != nil {
 		return err
 	}
 
@@ -548,14 +899,18 @@ func (up4 *UP4) listenToDDNs() {
 	notifier := NewDownlinkDataNotifier(up4.reportNotifyChan, 20*time.Second)
 
 	for {
-		if up4.IsConnected(nil) {
-			// blocking
-			digestData := up4.p4client.GetNextDigestData()
+		if !up4.IsConnected(nil) {
+			// e.g., while standby
+			time.Sleep(time.Second)
+			continue
+		}
 
-			ueAddr := binary.BigEndian.Uint32(digestData)
-			if fseid, exists := up4.ueAddrToFSEID[ueAddr]; exists {
-				notifier.Notify(fseid)
-			}
+		// blocking
+		digestData := up4.p4client.GetNextDigestData()
+
+		ueAddr := binary.BigEndian.Uint32(digestData)
+		if fseid, exists := up4.ueAddrToFSEID[ueAddr]; exists {
+			notifier.Notify(fseid)
 		}
 	}
 }
@@ -568,6 +923,7 @@ func (up4 *UP4) clearDatapathState() error {
 	}
 
 	up4.initAllCounters()
+	up4.resetTrackedPDRCounters()
 	up4.initMetersPools()
 
 	err = up4.initInterfaces()
@@ -580,15 +936,29 @@ func (up4 *UP4) clearDatapathState() error {
 
 // initialize configures the UP4-related objects.
 // A caller should ensure that P4Client is not nil and the P4Runtime channel is open.
-func (up4 *UP4) initialize(shouldClear bool) error {
-	// always clear datapath state at startup or
-	// on UP4 datapath restart if ClearStateOnRestart is enabled.
-	if shouldClear || up4.conf.ClearStateOnRestart {
+func (up4 *UP4) initialize(startup bool) error {
+	switch {
+	case up4.conf.EnableHA:
+		// the state of UP4 is kept, as it may have been written by another pfcpiface
+		if err := up4.restoreDatapathState(false); err != nil {
+			return err
+		}
+	case up4.conf.ClearStateOnRestart:
+		// clear datapath state at startup and on UP4 datapath restart.
 		if err := up4.clearDatapathState(); err != nil {
 			return err
 		}
+	case startup:
+		// warm start: the state of UP4 is kept across pfcpiface restarts,
+		// refusing to start if it can't be restored consistently.
+		if err := up4.restoreDatapathState(true); err != nil {
+			log.Errorf("Failed to restore UP4 state, set clear_state_on_restart to clear it: %v", err)
+			return err
+		}
 	}
 
+	up4.stateInitialized = true
+
 	up4.initOnce.Do(func() {
 		go up4.listenToDDNs()
 
@@ -646,16 +1016,17 @@ func findRelatedApplicationQER(pdr pdr, qers []qer) (qer, error) {
 }
 
 // Returns error if we reach maximum supported GTP Tunnel Peers.
-func (up4 *UP4) unsafeAllocateGTPTunnelPeerID() (uint8, error) {
+func (up4 *UP4) unsafeAllocateGTPTunnelPeerID() (uint16, error) {
 	if len(up4.tunnelPeerIDsPool) == 0 {
-		return 0, ErrOperationFailedWithReason("allocate GTP Tunnel Peer ID",
-			"no free tunnel peer IDs available")
+		return 0, ErrNoResourcesAvailable("GTP tunnel peer IDs")
 	}
 
 	// pick top from queue
 	allocated := up4.tunnelPeerIDsPool[0]
 	up4.tunnelPeerIDsPool = up4.tunnelPeerIDsPool[1:]
 
+	getDatapathMetrics().up4IDPoolFree.WithLabelValues(idPoolTunnelPeers).Set(float64(len(up4.tunnelPeerIDsPool)))
+
 	log.WithFields(log.Fields{
 		"ID":   allocated,
 		"pool": up4.tunnelPeerIDsPool,
@@ -664,18 +1035,38 @@ func (up4 *UP4) unsafeAllocateGTPTunnelPeerID() (uint8, error) {
 	return allocated, nil
 }
 
-func (up4 *UP4) unsafeReleaseAllocatedGTPTunnelPeer(tunnelParams tunnelParams) {
-	allocated, exists := up4.tunnelPeerIDs[tunnelParams]
-	if exists {
-		delete(up4.tunnelPeerIDs, tunnelParams)
-		up4.tunnelPeerIDsPool = append(up4.tunnelPeerIDsPool, allocated.id)
+// unsafeReclaimIdleGTPTunnelPeer removes the least recently used idle tunnel peer, returning its ID
+// and tunnel params. The entry of the tunnel peer is left in UP4, to be modified by the caller.
+func (up4 *UP4) unsafeReclaimIdleGTPTunnelPeer() (tunnelParams, tunnelPeer, bool) {
+	var (
+		lruParams tunnelParams
+		lruPeer   tunnelPeer
+		found     bool
+	)
 
-		log.WithFields(log.Fields{
-			"tunnel params": tunnelParams,
-			"tunnel peer":   allocated,
-			"pool":          up4.tunnelPeerIDsPool,
-		}).Trace("Tunnel peer ID released")
+	for params, peer := range up4.tunnelPeerIDs {
+		if peer.usedBy.Cardinality() != 0 {
+			continue
+		}
+
+		if !found || peer.idleSince.Before(lruPeer.idleSince) {
+			lruParams, lruPeer, found = params, peer, true
+		}
+	}
+
+	if !found {
+		return tunnelParams{}, tunnelPeer{}, false
 	}
+
+	delete(up4.tunnelPeerIDs, lruParams)
+	getDatapathMetrics().up4TunnelPeersReclaimed.Inc()
+
+	log.WithFields(log.Fields{
+		"tunnel params": lruParams,
+		"tunnel peer":   lruPeer,
+	}).Debug("Idle tunnel peer reclaimed")
+
+	return lruParams, lruPeer, true
 }
 
 func (up4 *UP4) getGTPTunnelPeer(tnlParams tunnelParams) (tunnelPeer, bool) {
@@ -687,7 +1078,38 @@ func (up4 *UP4) getGTPTunnelPeer(tnlParams tunnelParams) (tunnelPeer, bool) {
 	return tnlPeer, exists
 }
 
-func (up4 *UP4) addOrUpdateGTPTunnelPeer(far far) error {
+// unsafeRemoveTunnelPeerReference removes the tnlRef reference from the tunnel peers other than
+// tunnelParams, e.g. after a handover, making them idle if no longer used.
+func (up4 *UP4) unsafeRemoveTunnelPeerReference(batch *up4Batch, tnlRef tnlPeerReference, tunnelParams tunnelParams) {
+	for params, peer := range up4.tunnelPeerIDs {
+		if params == tunnelParams || !peer.usedBy.Contains(tnlRef) {
+			continue
+		}
+
+		peer.usedBy.Remove(tnlRef)
+
+		idleSince := peer.idleSince
+		if peer.usedBy.Cardinality() == 0 {
+			peer.idleSince = time.Now()
+			up4.tunnelPeerIDs[params] = peer
+		}
+
+		params := params
+
+		batch.onRollback(func() {
+			up4.tunnelPeerMu.Lock()
+			defer up4.tunnelPeerMu.Unlock()
+
+			if peer, exists := up4.tunnelPeerIDs[params]; exists {
+				peer.usedBy.Add(tnlRef)
+				peer.idleSince = idleSince
+				up4.tunnelPeerIDs[params] = peer
+			}
+		})
+	}
+}
+
+func (up4 *UP4) addOrUpdateGTPTunnelPeer(batch *up4Batch, far far) error {
 	up4.tunnelPeerMu.Lock()
 	defer up4.tunnelPeerMu.Unlock()
 
@@ -700,52 +1122,84 @@ func (up4 *UP4) addOrUpdateGTPTunnelPeer(far far) error {
 		tunnelPort:   far.tunnelPort,
 	}
 
+	tnlRef := tnlPeerReference{
+		far.fseID, far.farID,
+	}
+
+	up4.unsafeRemoveTunnelPeerReference(batch, tnlRef, tunnelParams)
+
 	tnlPeer, exists := up4.tunnelPeerIDs[tunnelParams]
 	if !exists {
+		methodType = p4.Update_INSERT
+
 		newID, err := up4.unsafeAllocateGTPTunnelPeerID()
+		releaseID := func() {
+			up4.tunnelPeerIDsPool = append(up4.tunnelPeerIDsPool, newID)
+			getDatapathMetrics().up4IDPoolFree.WithLabelValues(idPoolTunnelPeers).Set(float64(len(up4.tunnelPeerIDsPool)))
+		}
+
 		if err != nil {
-			return err
+			reclaimedParams, reclaimed, ok := up4.unsafeReclaimIdleGTPTunnelPeer()
+			if !ok {
+				getDatapathMetrics().up4IDPoolExhausted.WithLabelValues(idPoolTunnelPeers).Inc()
+				return err
+			}
+
+			// the entry of the reclaimed tunnel peer is overwritten
+			newID, methodType = reclaimed.id, p4.Update_MODIFY
+			releaseID = func() { up4.tunnelPeerIDs[reclaimedParams] = reclaimed }
 		}
 
 		tnlPeer = tunnelPeer{
-			id: newID,
-			usedBy: set.NewSet(tnlPeerReference{
-				far.fseID, far.farID,
-			}),
+			id:     newID,
+			usedBy: set.NewSet(tnlRef),
 		}
 
-		methodType = p4.Update_INSERT
-	} else {
-		// tunnel peer already exists.
-		// since we use Set to keep track of tunnel peers in use,
-		// it will not be added to the set if tunnel peer was already created for this UE session.
-		tnlPeer.usedBy.Add(tnlPeerReference{
-			far.fseID, far.farID,
+		gtpTunnelPeerEntry, err := up4.p4RtTranslator.BuildGTPTunnelPeerTableEntry(tnlPeer.id, tunnelParams)
+		if err != nil {
+			releaseID()
+			return err
+		}
+
+		batch.addTableEntries(methodType, gtpTunnelPeerEntry)
+		batch.onRollback(func() {
+			up4.tunnelPeerMu.Lock()
+			defer up4.tunnelPeerMu.Unlock()
+
+			// the ID goes back to the pool, or to the reclaimed tunnel peer
+			delete(up4.tunnelPeerIDs, tunnelParams)
+			releaseID()
 		})
-	}
 
-	releaseTnlPeerID := func() {
-		if !exists {
-			up4.unsafeReleaseAllocatedGTPTunnelPeer(tunnelParams)
-		}
+		up4.tunnelPeerIDs[tunnelParams] = tnlPeer
+
+		return nil
 	}
 
 	gtpTunnelPeerEntry, err := up4.p4RtTranslator.BuildGTPTunnelPeerTableEntry(tnlPeer.id, tunnelParams)
 	if err != nil {
-		releaseTnlPeerID()
 		return err
 	}
 
-	if err := up4.p4client.ApplyTableEntries(methodType, gtpTunnelPeerEntry); err != nil {
-		releaseTnlPeerID()
-		return err
-	}
+	batch.addTableEntries(methodType, gtpTunnelPeerEntry)
 
-	up4.tunnelPeerIDs[tunnelParams] = tnlPeer
+	// tunnel peer already exists.
+	// since we use Set to keep track of tunnel peers in use,
+	// it will not be added to the set if tunnel peer was already created for this UE session.
+	if tnlPeer.usedBy.Add(tnlRef) {
+		batch.onRollback(func() {
+			up4.tunnelPeerMu.Lock()
+			defer up4.tunnelPeerMu.Unlock()
+
+			tnlPeer.usedBy.Remove(tnlRef)
+		})
+	}
 
 	return nil
 }
 
+// removeGTPTunnelPeer removes the reference of far from its tunnel peer. A tunnel peer no longer used
+// is kept in UP4 as idle, so that it can be reused by new sessions, until its ID is reclaimed.
 func (up4 *UP4) removeGTPTunnelPeer(far far) {
 	up4.tunnelPeerMu.Lock()
 	defer up4.tunnelPeerMu.Unlock()
@@ -766,10 +1220,14 @@ func (up4 *UP4) removeGTPTunnelPeer(far far) {
 		return
 	}
 
-	removeLog.WithField("tunnel-peer", tnlPeer)
+	removeLog = removeLog.WithField("tunnel-peer", tnlPeer)
 
 	removeLog.Debug("Found GTP tunnel peer for tunnel params")
 
+	if !tnlPeer.usedBy.Contains(tnlPeerReference{far.fseID, far.farID}) {
+		return
+	}
+
 	tnlPeer.usedBy.Remove(tnlPeerReference{
 		far.fseID, far.farID,
 	})
@@ -779,32 +1237,25 @@ func (up4 *UP4) removeGTPTunnelPeer(far far) {
 		return
 	}
 
-	gtpTunnelPeerEntry, err := up4.p4RtTranslator.BuildGTPTunnelPeerTableEntry(tnlPeer.id, tunnelParams)
-	if err != nil {
-		removeLog.Error("failed to build GTP tunnel peer entry to remove")
-		return
-	}
-
-	removeLog.Debug("Removing GTP Tunnel Peer ID")
+	removeLog.Debug("GTP tunnel peer is idle")
 
-	if err := up4.p4client.ApplyTableEntries(p4.Update_DELETE, gtpTunnelPeerEntry); err != nil {
-		removeLog.Error("failed to remove GTP tunnel peer")
-	}
-
-	up4.unsafeReleaseAllocatedGTPTunnelPeer(tunnelParams)
+	tnlPeer.idleSince = time.Now()
+	up4.tunnelPeerIDs[tunnelParams] = tnlPeer
 }
 
 // Returns error if we reach maximum supported Application IDs.
-func (up4 *UP4) unsafeAllocateInternalApplicationID() (uint8, error) {
+func (up4 *UP4) unsafeAllocateInternalApplicationID() (uint16, error) {
 	if len(up4.applicationIDsPool) == 0 {
-		return 0, ErrOperationFailedWithReason("allocate Application ID",
-			"no free application IDs available")
+		getDatapathMetrics().up4IDPoolExhausted.WithLabelValues(idPoolApplications).Inc()
+		return 0, ErrNoResourcesAvailable("application IDs")
 	}
 
 	// pick top from queue
 	allocated := up4.applicationIDsPool[0]
 	up4.applicationIDsPool = up4.applicationIDsPool[1:]
 
+	getDatapathMetrics().up4IDPoolFree.WithLabelValues(idPoolApplications).Set(float64(len(up4.applicationIDsPool)))
+
 	return allocated, nil
 }
 
@@ -813,21 +1264,32 @@ func (up4 *UP4) unsafeReleaseInternalApplicationID(appFilter up4ApplicationFilte
 	if exists {
 		up4.applicationIDsPool = append(up4.applicationIDsPool, allocated.id)
 		delete(up4.applicationIDs, appFilter)
+
+		getDatapathMetrics().up4IDPoolFree.WithLabelValues(idPoolApplications).Set(float64(len(up4.applicationIDsPool)))
 	}
 }
 
-func (up4 *UP4) addInternalApplicationIDAndGetP4rtEntry(pdr pdr) (*p4.TableEntry, uint8, error) {
+func (up4 *UP4) addInternalApplicationIDAndGetP4rtEntry(batch *up4Batch, pdr pdr) (*p4.TableEntry, uint16, error) {
 	up4.applicationMu.Lock()
 	defer up4.applicationMu.Unlock()
 
+	appRef := internalAppReference{
+		pdr.fseID, pdr.pdrID,
+	}
+
 	appFilter := toUP4ApplicationFilter(pdr)
 	if up4Application, exists := up4.applicationIDs[appFilter]; exists {
 		// application already exists, increment 'usedBy'.
 		// since we use Set usedBy will not be incremented if
 		// application was already created for this UE session + PDR ID.
-		up4Application.usedBy.Add(internalAppReference{
-			pdr.fseID, pdr.pdrID,
-		})
+		if up4Application.usedBy.Add(appRef) {
+			batch.onRollback(func() {
+				up4.applicationMu.Lock()
+				defer up4.applicationMu.Unlock()
+
+				up4Application.usedBy.Remove(appRef)
+			})
+		}
 
 		return nil, up4Application.id, nil
 	}
@@ -838,24 +1300,29 @@ func (up4 *UP4) addInternalApplicationIDAndGetP4rtEntry(pdr pdr) (*p4.TableEntry
 	}
 
 	up4Application := internalApp{
-		id: newAppID,
-		usedBy: set.NewSet(internalAppReference{
-			pdr.fseID, pdr.pdrID,
-		}),
+		id:     newAppID,
+		usedBy: set.NewSet(appRef),
 	}
 
 	applicationsEntry, err := up4.p4RtTranslator.BuildApplicationsTableEntry(pdr, up4.conf.SliceID, newAppID)
 	if err != nil {
-		up4.unsafeReleaseInternalApplicationID(appFilter)
+		up4.applicationIDsPool = append(up4.applicationIDsPool, newAppID)
 		return nil, 0, ErrOperationFailedWithReason("build P4rt table entry for Applications table", err.Error())
 	}
 
 	up4.applicationIDs[appFilter] = up4Application
 
+	batch.onRollback(func() {
+		up4.applicationMu.Lock()
+		defer up4.applicationMu.Unlock()
+
+		up4.unsafeReleaseInternalApplicationID(appFilter)
+	})
+
 	return applicationsEntry, up4Application.id, nil
 }
 
-func (up4 *UP4) removeInternalApplicationIDAndGetP4rtEntry(pdr pdr) (*p4.TableEntry, uint8) {
+func (up4 *UP4) removeInternalApplicationIDAndGetP4rtEntry(pdr pdr) (*p4.TableEntry, uint16) {
 	up4.applicationMu.Lock()
 	defer up4.applicationMu.Unlock()
 
@@ -888,8 +1355,7 @@ func (up4 *UP4) allocateAppMeterCellID() (uint32, error) {
 	// pick from set
 	allocated := up4.appMeterCellIDsPool.Pop()
 	if allocated == nil {
-		return 0, ErrOperationFailedWithReason("allocate Application Meter Cell ID",
-			"no free AppMeter Cell IDs available")
+		return 0, ErrNoResourcesAvailable("AppMeter Cell IDs")
 	}
 
 	log.WithFields(log.Fields{
@@ -918,8 +1384,7 @@ func (up4 *UP4) allocateSessionMeterCellID() (uint32, error) {
 	// pick from set
 	allocated := up4.sessMeterCellIDsPool.Pop()
 	if allocated == nil {
-		return 0, ErrOperationFailedWithReason("allocate Session Meter Cell ID",
-			"no free SessionMeter Cell IDs available")
+		return 0, ErrNoResourcesAvailable("SessionMeter Cell IDs")
 	}
 
 	log.WithFields(log.Fields{
@@ -944,11 +1409,27 @@ func (up4 *UP4) releaseSessionMeterCellID(allocated uint32) {
 	}).Debug("Session meter cell ID released")
 }
 
-func (up4 *UP4) updateUEAddrAndFSEIDMappings(pdr pdr) {
+func (up4 *UP4) updateUEAddrAndFSEIDMappings(batch *up4Batch, pdr pdr) {
 	if pdr.IsUplink() {
 		return
 	}
 
+	prevFSEID, fseidExists := up4.ueAddrToFSEID[pdr.ueAddress]
+	prevUEAddr, ueAddrExists := up4.fseidToUEAddr[pdr.fseID]
+
+	batch.onRollback(func() {
+		delete(up4.ueAddrToFSEID, pdr.ueAddress)
+		delete(up4.fseidToUEAddr, pdr.fseID)
+
+		if fseidExists {
+			up4.ueAddrToFSEID[pdr.ueAddress] = prevFSEID
+		}
+
+		if ueAddrExists {
+			up4.fseidToUEAddr[pdr.fseID] = prevUEAddr
+		}
+	})
+
 	// update both maps in one shot
 	up4.ueAddrToFSEID[pdr.ueAddress], up4.fseidToUEAddr[pdr.fseID] = pdr.fseID, pdr.ueAddress
 }
@@ -962,14 +1443,14 @@ func (up4 *UP4) removeUeAddrAndFSEIDMappings(pdr pdr) {
 	delete(up4.fseidToUEAddr, pdr.fseID)
 }
 
-func (up4 *UP4) updateTunnelPeersBasedOnFARs(fars []far) error {
+func (up4 *UP4) updateTunnelPeersBasedOnFARs(batch *up4Batch, fars []far) error {
 	for _, far := range fars {
 		logger := log.WithFields(log.Fields{
 			"far": far,
 		})
 		// downlink FAR with tunnel params that does encapsulation
 		if far.Forwards() && far.dstIntf == ie.DstInterfaceAccess && far.tunnelTEID != 0 {
-			if err := up4.addOrUpdateGTPTunnelPeer(far); err != nil {
+			if err := up4.addOrUpdateGTPTunnelPeer(batch, far); err != nil {
 				logger.Errorf("Failed to add or update GTP tunnel peer: %v", err)
 				return err
 			}
@@ -1017,9 +1498,9 @@ func getMeterConfigurationFromQER(mbr uint64, gbr uint64) *p4.MeterConfig {
 	}
 }
 
-// configureApplicationMeter installs P4Runtime Meter Entries based on QoS configuration from QER.
+// configureApplicationMeter adds P4Runtime Meter Entries based on QoS configuration from QER to batch.
 // If bidirectional, this function allocates two independent meter cell IDs, one per direction.
-func (up4 *UP4) configureApplicationMeter(q qer, bidirectional bool) (meter, error) {
+func (up4 *UP4) configureApplicationMeter(batch *up4Batch, q qer, bidirectional bool) (meter, error) {
 	entries := make([]*p4.MeterEntry, 0)
 
 	appMeter := meter{
@@ -1047,15 +1528,13 @@ func (up4 *UP4) configureApplicationMeter(q qer, bidirectional bool) (meter, err
 		appMeter.downlinkCellID = uplinkCellID
 	}
 
-	releaseIDs := func() {
-		if appMeter.uplinkCellID != 0 {
-			up4.releaseSessionMeterCellID(appMeter.uplinkCellID)
-		}
+	batch.onRollback(func() {
+		up4.releaseAppMeterCellID(appMeter.uplinkCellID)
 
 		if appMeter.downlinkCellID != appMeter.uplinkCellID {
-			up4.releaseSessionMeterCellID(appMeter.downlinkCellID)
+			up4.releaseAppMeterCellID(appMeter.downlinkCellID)
 		}
-	}
+	})
 
 	if appMeter.uplinkCellID != 0 {
 		meterConfig := getMeterConfigurationFromQER(q.ulMbr, q.ulGbr)
@@ -1073,18 +1552,14 @@ func (up4 *UP4) configureApplicationMeter(q qer, bidirectional bool) (meter, err
 		entries = append(entries, meterEntry)
 	}
 
-	err = up4.p4client.ApplyMeterEntries(p4.Update_MODIFY, entries...)
-	if err != nil {
-		releaseIDs()
-		return meter{}, err
-	}
+	batch.addMeterEntries(p4.Update_MODIFY, entries...)
 
 	return appMeter, nil
 }
 
-// configureSessionMeter installs two P4Runtime Meter Entries.
+// configureSessionMeter adds two P4Runtime Meter Entries to batch.
 // Session QER is always bidirectional. Thus, this function always configures two independent cell IDs.
-func (up4 *UP4) configureSessionMeter(q qer) (meter, error) {
+func (up4 *UP4) configureSessionMeter(batch *up4Batch, q qer) (meter, error) {
 	uplinkCellID, err := up4.allocateSessionMeterCellID()
 	if err != nil {
 		return meter{}, err
@@ -1096,10 +1571,10 @@ func (up4 *UP4) configureSessionMeter(q qer) (meter, error) {
 		return meter{}, err
 	}
 
-	releaseIDs := func() {
+	batch.onRollback(func() {
 		up4.releaseSessionMeterCellID(uplinkCellID)
 		up4.releaseSessionMeterCellID(downlinkCellID)
-	}
+	})
 
 	logger := log.WithFields(log.Fields{
 		"uplink cell ID":   uplinkCellID,
@@ -1118,15 +1593,9 @@ func (up4 *UP4) configureSessionMeter(q qer) (meter, error) {
 		"uplink meter entry":   uplinkMeterEntry,
 		"downlink meter entry": downlinkMeterEntry,
 	})
-	logger.Debug("Installing P4 Meter entries")
+	logger.Debug("Adding P4 Meter entries to batch")
 
-	err = up4.p4client.ApplyMeterEntries(p4.Update_MODIFY, uplinkMeterEntry, downlinkMeterEntry)
-	if err != nil {
-		releaseIDs()
-		return meter{}, err
-	}
-
-	logger.Debug("P4 Meter entries installed successfully")
+	batch.addMeterEntries(p4.Update_MODIFY, uplinkMeterEntry, downlinkMeterEntry)
 
 	return meter{
 		meterType:      meterTypeSession,
@@ -1135,7 +1604,7 @@ func (up4 *UP4) configureSessionMeter(q qer) (meter, error) {
 	}, nil
 }
 
-func (up4 *UP4) configureMeters(qers []qer) error {
+func (up4 *UP4) configureMeters(batch *up4Batch, qers []qer) error {
 	log.WithFields(log.Fields{
 		"qers": qers,
 	}).Debug("Configuring P4 Meters based on QERs")
@@ -1160,28 +1629,33 @@ func (up4 *UP4) configureMeters(qers []qer) error {
 				// if only a single QER is created, the QER is marked as Application QER,
 				// and all PDRs points to the same QER, which is not unique per direction.
 				// Therefore, we have to configure bidirectional meter (two independent cells, one per direction).
-				meter, err = up4.configureApplicationMeter(qer, true)
+				meter, err = up4.configureApplicationMeter(batch, qer, true)
 			} else {
-				meter, err = up4.configureApplicationMeter(qer, false)
+				meter, err = up4.configureApplicationMeter(batch, qer, false)
 			}
 		case SessionQos:
-			meter, err = up4.configureSessionMeter(qer)
+			meter, err = up4.configureSessionMeter(batch, qer)
 		default:
 			// unknown, type of QER
 			continue
 		}
 
 		if err != nil {
-			return ErrOperationFailedWithReason("configure P4 Meter from QER", err.Error())
+			return fmt.Errorf("configure P4 Meter from QER: %w", err)
 		}
 
 		logger = logger.WithField("P4 meter", meter)
 		logger.Debug("P4 meter successfully configured!")
 
-		up4.meters[meterID{
+		id := meterID{
 			qerID: qer.qerID,
 			fseid: qer.fseID,
-		}] = meter
+		}
+		up4.meters[id] = meter
+
+		batch.onRollback(func() {
+			delete(up4.meters, id)
+		})
 	}
 
 	return nil
@@ -1195,7 +1669,7 @@ func verifyPDR(pdr pdr) error {
 	return nil
 }
 
-func (up4 *UP4) resetMeter(meterID uint32, meter meter) {
+func resetMeterEntries(meterID uint32, meter meter) []*p4.MeterEntry {
 	entries := make([]*p4.MeterEntry, 0, 2)
 
 	entry := &p4.MeterEntry{
@@ -1213,13 +1687,11 @@ func (up4 *UP4) resetMeter(meterID uint32, meter meter) {
 		entries = append(entries, entry)
 	}
 
-	err := up4.p4client.ApplyMeterEntries(p4.Update_MODIFY, entries...)
-	if err != nil {
-		log.Errorf("Failed to reset %v meter entries: %v", p4constants.GetMeterIDToNameMap()[meterID], err)
-	}
+	return entries
 }
 
-func (up4 *UP4) resetMeters(qers []qer) {
+// resetMeters adds the meter entries resetting the meters of qers to batch and releases their cell IDs.
+func (up4 *UP4) resetMeters(batch *up4Batch, qers []qer) {
 	log.WithFields(log.Fields{
 		"qers": qers,
 	}).Debug("Resetting P4 Meters")
@@ -1240,14 +1712,14 @@ func (up4 *UP4) resetMeters(qers []qer) {
 		}
 
 		if meter.meterType == meterTypeApplication {
-			up4.resetMeter(p4constants.MeterPreQosPipeAppMeter, meter)
+			batch.addMeterEntries(p4.Update_MODIFY, resetMeterEntries(p4constants.MeterPreQosPipeAppMeter, meter)...)
 			up4.releaseAppMeterCellID(meter.uplinkCellID)
 
 			if meter.downlinkCellID != meter.uplinkCellID {
 				up4.releaseAppMeterCellID(meter.downlinkCellID)
 			}
 		} else if meter.meterType == meterTypeSession {
-			up4.resetMeter(p4constants.MeterPreQosPipeSessionMeter, meter)
+			batch.addMeterEntries(p4.Update_MODIFY, resetMeterEntries(p4constants.MeterPreQosPipeSessionMeter, meter)...)
 			up4.releaseSessionMeterCellID(meter.uplinkCellID)
 			up4.releaseSessionMeterCellID(meter.downlinkCellID)
 		}
@@ -1262,7 +1734,8 @@ func (up4 *UP4) resetMeters(qers []qer) {
 	}
 }
 
-func (up4 *UP4) resetCounter(pdr pdr) error {
+// counterResetEntries returns the counter entries clearing the counter cells of pdr.
+func counterResetEntries(pdr pdr) []*p4.CounterEntry {
 	builderLog := log.WithFields(log.Fields{
 		"Cell ID": pdr.ctrID,
 		"PDR ID":  pdr.pdrID,
@@ -1277,248 +1750,261 @@ func (up4 *UP4) resetCounter(pdr pdr) error {
 
 	cntrIndex := &p4.Index{Index: int64(pdr.ctrID)}
 
-	ingressCntrEntry := &p4.CounterEntry{
-		CounterId: p4constants.CounterPreQosPipePreQosCounter,
-		Index:     cntrIndex,
-		Data:      resetValue,
-	}
-
-	egressCntrEntry := &p4.CounterEntry{
-		CounterId: p4constants.CounterPostQosPipePostQosCounter,
-		Index:     cntrIndex,
-		Data:      resetValue,
-	}
-
-	updates := []*p4.Update{
+	return []*p4.CounterEntry{
 		{
-			Type: p4.Update_MODIFY,
-			Entity: &p4.Entity{
-				Entity: &p4.Entity_CounterEntry{CounterEntry: ingressCntrEntry},
-			},
+			CounterId: p4constants.CounterPreQosPipePreQosCounter,
+			Index:     cntrIndex,
+			Data:      resetValue,
 		},
 		{
-			Type: p4.Update_MODIFY,
-			Entity: &p4.Entity{
-				Entity: &p4.Entity_CounterEntry{CounterEntry: egressCntrEntry},
-			},
+			CounterId: p4constants.CounterPostQosPipePostQosCounter,
+			Index:     cntrIndex,
+			Data:      resetValue,
 		},
 	}
-
-	return up4.p4client.WriteBatchReq(updates)
 }
 
-// modifyUP4ForwardingConfiguration builds P4Runtime table entries and
-// inserts/modifies/removes table entries from UP4 device, according to methodType.
-func (up4 *UP4) modifyUP4ForwardingConfiguration(pdrs []pdr, allFARs []far, qers []qer, methodType p4.Update_Type) error {
-	for _, pdr := range pdrs {
-		if err := verifyPDR(pdr); err != nil {
-			return err
-		}
+// buildPDREntries builds P4Runtime table entries for the Sessions and Terminations tables for a PDR.
+// getApplication returns the application ID to use for the PDR and, if it must be written as well,
+// the Applications table entry.
+func (up4 *UP4) buildPDREntries(pdr pdr, allFARs []far, qers []qer,
+	getApplication func(pdr pdr) (*p4.TableEntry, uint16, error)) ([]*p4.TableEntry, error) {
+	if err := verifyPDR(pdr); err != nil {
+		return nil, err
+	}
 
-		entriesToApply := make([]*p4.TableEntry, 0)
+	entriesToApply := make([]*p4.TableEntry, 0)
 
-		pdrLog := log.WithFields(log.Fields{
-			"pdr": pdr,
-		})
-		pdrLog.Debug("Installing P4 table entries for PDR")
+	pdrLog := log.WithFields(log.Fields{
+		"pdr": pdr,
+	})
+	pdrLog.Debug("Building P4 table entries for PDR")
 
-		far, err := findRelatedFAR(pdr, allFARs)
-		if err != nil {
-			pdrLog.Warning("no related FAR for PDR found: ", err)
-			return err
-		}
+	far, err := findRelatedFAR(pdr, allFARs)
+	if err != nil {
+		pdrLog.Warning("no related FAR for PDR found: ", err)
+		return nil, err
+	}
 
-		pdrLog = pdrLog.WithField("related FAR", far)
-		pdrLog.Debug("Found related FAR for PDR")
+	pdrLog = pdrLog.WithField("related FAR", far)
+	pdrLog.Debug("Found related FAR for PDR")
 
-		tunnelParams := tunnelParams{
-			tunnelIP4Src: ip2int(up4.AccessIP.IP),
-			tunnelIP4Dst: far.tunnelIP4Dst,
-			tunnelPort:   far.tunnelPort,
-		}
+	tunnelParams := tunnelParams{
+		tunnelIP4Src: ip2int(up4.AccessIP.IP),
+		tunnelIP4Dst: far.tunnelIP4Dst,
+		tunnelPort:   far.tunnelPort,
+	}
 
-		tunnelPeerID, exists := up4.getGTPTunnelPeer(tunnelParams)
-		if !exists && far.tunnelTEID != 0 {
-			return ErrNotFoundWithParam("allocated GTP tunnel peer ID", "tunnel params", tunnelParams)
-		}
+	tunnelPeerID, exists := up4.getGTPTunnelPeer(tunnelParams)
+	if !exists && far.tunnelTEID != 0 {
+		return nil, ErrNotFoundWithParam("allocated GTP tunnel peer ID", "tunnel params", tunnelParams)
+	}
 
-		var sessMeter = meter{meterTypeSession, 0, 0}
-		if len(pdr.qerIDList) == 2 {
-			// if 2 QERs are provided, the second one is Session QER
-			sessMeter = up4.meters[meterID{
-				qerID: pdr.qerIDList[1],
-				fseid: pdr.fseID,
-			}]
-			pdrLog.Debug("Application meter found for PDR: ", sessMeter)
-		} // else: if only 1 QER provided, set sessMeterIdx to 0, and use only per-app metering
-
-		sessionsEntry, err := up4.p4RtTranslator.BuildSessionsTableEntry(pdr, sessMeter, tunnelPeerID.id, far.Buffers())
-		if err != nil {
-			return ErrOperationFailedWithReason("build P4rt table entry for Sessions table", err.Error())
-		}
+	var sessMeter = meter{meterTypeSession, 0, 0}
+	if len(pdr.qerIDList) == 2 {
+		// if 2 QERs are provided, the second one is Session QER
+		sessMeter = up4.meters[meterID{
+			qerID: pdr.qerIDList[1],
+			fseid: pdr.fseID,
+		}]
+		pdrLog.Debug("Application meter found for PDR: ", sessMeter)
+	} // else: if only 1 QER provided, set sessMeterIdx to 0, and use only per-app metering
 
-		entriesToApply = append(entriesToApply, sessionsEntry)
+	sessionsEntry, err := up4.p4RtTranslator.BuildSessionsTableEntry(pdr, sessMeter, tunnelPeerID.id, far.Buffers())
+	if err != nil {
+		return nil, ErrOperationFailedWithReason("build P4rt table entry for Sessions table", err.Error())
+	}
 
-		if pdr.IsUplink() {
-			ueAddr, exists := up4.fseidToUEAddr[pdr.fseID]
-			if !exists {
-				// this is only possible if a linked DL PDR was not provided in the same PFCP Establishment message
-				log.Error("UE Address not found for uplink PDR, a linked DL PDR was not provided?")
-				return ErrOperationFailedWithReason("adding UP4 entries", "UE Address not found for uplink PDR, a linked DL PDR was not provided?")
-			}
+	entriesToApply = append(entriesToApply, sessionsEntry)
 
-			pdr.ueAddress = ueAddr
+	if pdr.IsUplink() {
+		ueAddr, exists := up4.fseidToUEAddr[pdr.fseID]
+		if !exists {
+			// this is only possible if a linked DL PDR was not provided in the same PFCP Establishment message
+			log.Error("UE Address not found for uplink PDR, a linked DL PDR was not provided?")
+			return nil, ErrOperationFailedWithReason("adding UP4 entries", "UE Address not found for uplink PDR, a linked DL PDR was not provided?")
 		}
 
-		// as a default value is installed if no application filtering rule exists
-		var applicationID uint8 = DefaultApplicationID
+		pdr.ueAddress = ueAddr
+	}
 
-		if !pdr.IsAppFilterEmpty() {
-			if methodType != p4.Update_DELETE {
-				if entry, appID, err := up4.addInternalApplicationIDAndGetP4rtEntry(pdr); err == nil {
-					if entry != nil {
-						entriesToApply = append(entriesToApply, entry)
-					}
+	// as a default value is installed if no application filtering rule exists
+	var applicationID uint16 = DefaultApplicationID
 
-					applicationID = appID
-				}
-			} else {
-				entry, appID := up4.removeInternalApplicationIDAndGetP4rtEntry(pdr)
-				if entry != nil {
-					entriesToApply = append(entriesToApply, entry)
-				}
+	if !pdr.IsAppFilterEmpty() {
+		var entry *p4.TableEntry
 
-				applicationID = appID
-			}
+		entry, applicationID, err = getApplication(pdr)
+		if err != nil {
+			return nil, err
 		}
 
-		var appMeter = meter{meterTypeApplication, 0, 0}
-		if len(pdr.qerIDList) != 0 {
-			// if only 1 QER provided, it's an application QER
-			// if 2 QERs provided, the first one is an application QER
-			// if more than 2 QERs provided, TODO: not supported
-			appMeter = up4.meters[meterID{
-				qerID: pdr.qerIDList[0],
-				fseid: pdr.fseID,
-			}]
-			pdrLog.Debug("Application meter found for PDR: ", appMeter)
+		if entry != nil {
+			entriesToApply = append(entriesToApply, entry)
 		}
+	}
 
-		var qfi uint8 = DefaultQFI
+	var appMeter = meter{meterTypeApplication, 0, 0}
+	if len(pdr.qerIDList) != 0 {
+		// if only 1 QER provided, it's an application QER
+		// if 2 QERs provided, the first one is an application QER
+		// if more than 2 QERs provided, TODO: not supported
+		appMeter = up4.meters[meterID{
+			qerID: pdr.qerIDList[0],
+			fseid: pdr.fseID,
+		}]
+		pdrLog.Debug("Application meter found for PDR: ", appMeter)
+	}
 
-		relatedQER, err := findRelatedApplicationQER(pdr, qers)
-		if err != nil {
-			pdrLog.Warning(err)
-		} else {
-			pdrLog.Debug("Related QER found for PDR: ", relatedQER)
-			qfi = relatedQER.qfi
-		}
+	var qfi uint8 = DefaultQFI
 
-		tc, exists := up4.conf.QFIToTC[relatedQER.qfi]
-		if !exists {
-			tc = up4.conf.DefaultTC
+	relatedQER, err := findRelatedApplicationQER(pdr, qers)
+	if err != nil {
+		pdrLog.Warning(err)
+	} else {
+		pdrLog.Debug("Related QER found for PDR: ", relatedQER)
+		qfi = relatedQER.qfi
+	}
+
+	tc, exists := up4.conf.QFIToTC[relatedQER.qfi]
+	if !exists {
+		tc = up4.conf.DefaultTC
+	}
+
+	terminationsEntry, err := up4.p4RtTranslator.BuildTerminationsTableEntry(pdr, appMeter, far,
+		applicationID, qfi, tc, relatedQER)
+	if err != nil {
+		return nil, ErrOperationFailedWithReason("build P4rt table entry for Terminations table", err.Error())
+	}
+
+	entriesToApply = append(entriesToApply, terminationsEntry)
+
+	return entriesToApply, nil
+}
+
+// modifyUP4ForwardingConfiguration builds P4Runtime table entries and adds them to batch,
+// to insert/modify/remove table entries from UP4 device, according to methodType.
+func (up4 *UP4) modifyUP4ForwardingConfiguration(batch *up4Batch, pdrs []pdr, allFARs []far, qers []qer, methodType p4.Update_Type) error {
+	getApplication := func(pdr pdr) (*p4.TableEntry, uint16, error) {
+		if methodType == p4.Update_DELETE {
+			entry, appID := up4.removeInternalApplicationIDAndGetP4rtEntry(pdr)
+			return entry, appID, nil
 		}
 
-		terminationsEntry, err := up4.p4RtTranslator.BuildTerminationsTableEntry(pdr, appMeter, far,
-			applicationID, qfi, tc, relatedQER)
+		return up4.addInternalApplicationIDAndGetP4rtEntry(batch, pdr)
+	}
+
+	for _, pdr := range pdrs {
+		entriesToApply, err := up4.buildPDREntries(pdr, allFARs, qers, getApplication)
 		if err != nil {
-			return ErrOperationFailedWithReason("build P4rt table entry for Terminations table", err.Error())
+			return err
 		}
 
-		entriesToApply = append(entriesToApply, terminationsEntry)
-
-		pdrLog = pdrLog.WithFields(log.Fields{
+		log.WithFields(log.Fields{
+			"pdr":         pdr,
 			"entries":     entriesToApply,
 			"method type": p4.Update_Type_name[int32(methodType)],
-		})
-		pdrLog.Debug("Applying table entries")
+		}).Debug("Adding table entries to batch")
 
-		err = up4.p4client.ApplyTableEntries(methodType, entriesToApply...)
-		if err != nil {
-			p4Error, ok := err.(*P4RuntimeError)
-			if !ok {
-				// not a P4Runtime error, returning err
-				return ErrOperationFailedWithReason("applying table entries to UP4", err.Error())
-			}
+		batch.addTableEntries(methodType, entriesToApply...)
+	}
 
-			for _, status := range p4Error.Get() {
-				// ignore ALREADY_EXISTS or OK
-				if status.GetCanonicalCode() == int32(codes.AlreadyExists) ||
-					status.GetCanonicalCode() == int32(codes.OK) {
-					continue
-				}
+	return nil
+}
 
-				return ErrOperationFailedWithReason("applying table entries to UP4", p4Error.Error())
-			}
-		}
+func (up4 *UP4) sendCreate(ctx context.Context, all PacketForwardingRules, updated PacketForwardingRules) error {
+	batch := &up4Batch{}
+
+	err := up4.buildCreateBatch(batch, all, updated)
+	if err == nil {
+		err = up4.writeBatch(ctx, batch)
+	}
+
+	if err != nil {
+		// writeBatch already reverted the allocations if the write failed
+		batch.revert()
+		return err
+	}
+
+	for i := range updated.pdrs {
+		up4.trackPDRCounter(all.pdrs[i])
 	}
 
 	return nil
 }
 
-func (up4 *UP4) sendCreate(all PacketForwardingRules, updated PacketForwardingRules) error {
+func (up4 *UP4) buildCreateBatch(batch *up4Batch, all PacketForwardingRules, updated PacketForwardingRules) error {
 	for i := range updated.pdrs {
 		val, err := up4.allocateCounterID(preQosCounterID)
 		if err != nil {
-			return ErrOperationFailedWithReason("Counter ID allocation", err.Error())
+			return fmt.Errorf("Counter ID allocation: %w", err)
 		}
 
+		batch.onRollback(func() {
+			up4.releaseCounterID(preQosCounterID, val)
+		})
+
 		all.pdrs[i].ctrID = uint32(val)
 
-		if err := up4.resetCounter(all.pdrs[i]); err != nil {
-			return ErrOperationFailedWithReason("Reset Counters", err.Error())
-		}
+		batch.addCounterEntries(p4.Update_MODIFY, counterResetEntries(all.pdrs[i])...)
 	}
 
 	for _, p := range updated.pdrs {
-		up4.updateUEAddrAndFSEIDMappings(p)
+		up4.updateUEAddrAndFSEIDMappings(batch, p)
 	}
 
-	if err := up4.configureMeters(updated.qers); err != nil {
+	if err := up4.configureMeters(batch, updated.qers); err != nil {
 		return err
 	}
 
-	if err := up4.updateTunnelPeersBasedOnFARs(updated.fars); err != nil {
-		// TODO: revert operations (e.g. reset counter)
+	if err := up4.updateTunnelPeersBasedOnFARs(batch, updated.fars); err != nil {
 		return err
 	}
 
-	if err := up4.modifyUP4ForwardingConfiguration(all.pdrs, all.fars, all.qers, p4.Update_INSERT); err != nil {
-		// TODO: revert operations (e.g. reset counter)
-		return err
-	}
-
-	return nil
+	return up4.modifyUP4ForwardingConfiguration(batch, all.pdrs, all.fars, all.qers, p4.Update_INSERT)
 }
 
-func (up4 *UP4) sendUpdate(all PacketForwardingRules, updated PacketForwardingRules) error {
+func (up4 *UP4) sendUpdate(ctx context.Context, all PacketForwardingRules, updated PacketForwardingRules) error {
+	batch := &up4Batch{}
+
 	// Update PDR IE might modify UE IP <-> F-SEID mappings
 	for _, p := range updated.pdrs {
-		up4.updateUEAddrAndFSEIDMappings(p)
+		up4.updateUEAddrAndFSEIDMappings(batch, p)
 	}
 
-	if err := up4.updateTunnelPeersBasedOnFARs(updated.fars); err != nil {
-		return err
+	err := up4.updateTunnelPeersBasedOnFARs(batch, updated.fars)
+	if err == nil {
+		err = up4.modifyUP4ForwardingConfiguration(batch, all.pdrs, all.fars, all.qers, p4.Update_MODIFY)
+	}
+
+	if err == nil {
+		err = up4.writeBatch(ctx, batch)
 	}
 
-	if err := up4.modifyUP4ForwardingConfiguration(all.pdrs, all.fars, all.qers, p4.Update_MODIFY); err != nil {
+	if err != nil {
+		batch.revert()
 		return err
 	}
 
 	return nil
 }
 
-func (up4 *UP4) sendDelete(deleted PacketForwardingRules) error {
+// sendDelete removes all entries of a session in a single batch. Resources are released even if the write fails,
+// as the session is removed anyway. Entries possibly left in UP4 are removed by the datapath reconciliation.
+func (up4 *UP4) sendDelete(ctx context.Context, deleted PacketForwardingRules) error {
+	batch := &up4Batch{}
+
 	for i := range deleted.pdrs {
+		up4.untrackPDRCounter(deleted.pdrs[i])
 		up4.releaseCounterID(preQosCounterID,
 			uint64(deleted.pdrs[i].ctrID))
 	}
 
-	if err := up4.modifyUP4ForwardingConfiguration(deleted.pdrs, deleted.fars, deleted.qers, p4.Update_DELETE); err != nil {
+	if err := up4.modifyUP4ForwardingConfiguration(batch, deleted.pdrs, deleted.fars, deleted.qers, p4.Update_DELETE); err != nil {
 		return err
 	}
 
-	up4.resetMeters(deleted.qers)
+	up4.resetMeters(batch, deleted.qers)
 
 	for _, f := range deleted.fars {
 		up4.removeGTPTunnelPeer(f)
@@ -1528,39 +2014,282 @@ func (up4 *UP4) sendDelete(deleted PacketForwardingRules) error {
 		up4.removeUeAddrAndFSEIDMappings(p)
 	}
 
-	return nil
+	// nothing to revert on failure, see above
+	batch.rollback = nil
+
+	return up4.writeBatch(ctx, batch)
 }
 
-func (up4 *UP4) SendMsgToUPF(method upfMsgType, all PacketForwardingRules, updated PacketForwardingRules) uint8 {
-	err := up4.tryConnect()
-	if err != nil {
+func (up4 *UP4) BeginRules(ctx context.Context, all PacketForwardingRules) rulesTx {
+	return newChangesTx(ctx, all, up4.commitRules)
+}
+
+// commitRules writes the changes of a PFCP message. Rules of a new session are inserted, while
+// existing sessions are modified with all their rules, before removing the deleted ones.
+func (up4 *UP4) commitRules(ctx context.Context, all PacketForwardingRules, changes ruleChanges) error {
+	if err := up4.tryConnect(); err != nil {
 		log.Error("UP4 server not connected")
-		return ie.CauseRequestRejected
+		return ErrDatapathUnavailable(datapathUP4)
 	}
 
 	up4Log := log.WithFields(log.Fields{
-		"method-type":   method,
-		"all":           all,
-		"updated-rules": updated,
+		"all":     all,
+		"created": changes.created,
+		"updated": changes.updated,
+		"deleted": changes.deleted,
 	})
 	up4Log.Debug("Sending PFCP message to UP4..")
 
-	switch method {
-	case upfMsgTypeAdd:
-		err = up4.sendCreate(all, updated)
-	case upfMsgTypeMod:
-		err = up4.sendUpdate(all, updated)
-	case upfMsgTypeDel:
-		err = up4.sendDelete(all)
+	up4.stateMu.RLock()
+	defer up4.stateMu.RUnlock()
+
+	var err error
+
+	upserted := changes.upserted()
+
+	switch {
+	case upserted.IsEmpty():
+	case len(changes.updated.pdrs) == 0 && len(changes.created.pdrs) == len(all.pdrs):
+		// the session has no other PDRs, it's a new session
+		err = up4.sendCreate(ctx, all, upserted)
 	default:
-		// unknown upfMsgType
-		return ie.CauseRequestRejected
+		err = up4.sendUpdate(ctx, all, upserted)
+	}
+
+	if err == nil && !changes.deleted.IsEmpty() {
+		err = up4.sendDelete(ctx, changes.deleted)
 	}
 
 	if err != nil {
 		up4Log.Errorf("failed to apply forwarding configuration to UP4: %v", err)
-		return ie.CauseRequestRejected
+		return err
+	}
+
+	return nil
+}
+
+// canonicalBytes strips leading zeros, as P4Runtime servers may return byte strings in canonical form.
+func canonicalBytes(b []byte) string {
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+
+	return fmt.Sprintf("%x", b)
+}
+
+func tableEntryMatchKey(entry *p4.TableEntry) string {
+	matches := make([]string, 0, len(entry.GetMatch()))
+
+	for _, m := range entry.GetMatch() {
+		var value string
+
+		switch v := m.GetFieldMatchType().(type) {
+		case *p4.FieldMatch_Exact_:
+			value = canonicalBytes(v.Exact.GetValue())
+		case *p4.FieldMatch_Ternary_:
+			value = canonicalBytes(v.Ternary.GetValue()) + "&" + canonicalBytes(v.Ternary.GetMask())
+		case *p4.FieldMatch_Lpm:
+			value = fmt.Sprintf("%s/%d", canonicalBytes(v.Lpm.GetValue()), v.Lpm.GetPrefixLen())
+		case *p4.FieldMatch_Range_:
+			value = canonicalBytes(v.Range.GetLow()) + "-" + canonicalBytes(v.Range.GetHigh())
+		case *p4.FieldMatch_Optional_:
+			value = canonicalBytes(v.Optional.GetValue())
+		}
+
+		matches = append(matches, fmt.Sprintf("%d=%s", m.GetFieldId(), value))
+	}
+
+	sort.Strings(matches)
+
+	return fmt.Sprintf("%d/%d/%s", entry.GetTableId(), entry.GetPriority(), strings.Join(matches, ","))
+}
+
+func tableEntryActionValue(entry *p4.TableEntry) string {
+	action := entry.GetAction().GetAction()
+	params := make([]string, 0, len(action.GetParams()))
+
+	for _, p := range action.GetParams() {
+		params = append(params, fmt.Sprintf("%d=%s", p.GetParamId(), canonicalBytes(p.GetValue())))
+	}
+
+	sort.Strings(params)
+
+	return fmt.Sprintf("%d(%s)", action.GetActionId(), strings.Join(params, ","))
+}
+
+func tableReconcileEntry(entry *p4.TableEntry) reconcileEntry {
+	return reconcileEntry{
+		key:   tableEntryMatchKey(entry),
+		value: tableEntryActionValue(entry),
+		obj:   entry,
+	}
+}
+
+func (up4 *UP4) tunnelPeerTableEntries() ([]*p4.TableEntry, error) {
+	up4.tunnelPeerMu.Lock()
+	defer up4.tunnelPeerMu.Unlock()
+
+	entries := make([]*p4.TableEntry, 0, len(up4.tunnelPeerIDs))
+
+	for params, peer := range up4.tunnelPeerIDs {
+		entry, err := up4.p4RtTranslator.BuildGTPTunnelPeerTableEntry(peer.id, params)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// expectedTableEntries builds the table entries expected in UP4 for the interfaces,
+// the allocated tunnel peers and applications, and the PDRs of sessions.
+func (up4 *UP4) expectedTableEntries(sessions []PFCPSession) ([]reconcileEntry, error) {
+	entries := make([]reconcileEntry, 0)
+	keys := make(map[string]struct{})
+
+	addEntries := func(tableEntries ...*p4.TableEntry) {
+		for _, e := range tableEntries {
+			entry := tableReconcileEntry(e)
+			// applications and tunnel peers can be shared between PDRs
+			if _, ok := keys[entry.key]; ok {
+				continue
+			}
+
+			keys[entry.key] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+
+	uePoolEntry, err := up4.p4RtTranslator.BuildInterfaceTableEntry(up4.ueIPPool, up4.conf.SliceID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	n3AddrEntry, err := up4.p4RtTranslator.BuildInterfaceTableEntry(up4.AccessIP, up4.conf.SliceID, false)
+	if err != nil {
+		return nil, err
 	}
 
-	return ie.CauseRequestAccepted
+	addEntries(uePoolEntry, n3AddrEntry)
+
+	tunnelPeerEntries, err := up4.tunnelPeerTableEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	addEntries(tunnelPeerEntries...)
+
+	// look up already allocated applications only, without changing their references
+	getApplication := func(pdr pdr) (*p4.TableEntry, uint16, error) {
+		up4.applicationMu.Lock()
+		app, exists := up4.applicationIDs[toUP4ApplicationFilter(pdr)]
+		up4.applicationMu.Unlock()
+
+		if !exists {
+			return nil, DefaultApplicationID, nil
+		}
+
+		entry, err := up4.p4RtTranslator.BuildApplicationsTableEntry(pdr, up4.conf.SliceID, app.id)
+		if err != nil {
+			log.Errorf("Failed to build Applications table entry for reconciliation: %v", err)
+			return nil, app.id, nil
+		}
+
+		return entry, app.id, nil
+	}
+
+	for _, session := range sessions {
+		for _, pdr := range session.pdrs {
+			pdrEntries, err := up4.buildPDREntries(pdr, session.fars, session.qers, getApplication)
+			if err != nil {
+				return nil, err
+			}
+
+			addEntries(pdrEntries...)
+		}
+	}
+
+	return entries, nil
+}
+
+// readOwnedTableEntries reads the entries of the UP4 tables owned by pfcpiface.
+func (up4 *UP4) readOwnedTableEntries() ([]*p4.TableEntry, error) {
+	entries := make([]*p4.TableEntry, 0)
+
+	for _, tableID := range up4OwnedTables {
+		resp, err := up4.p4client.ReadTableEntry(&p4.TableEntry{TableId: tableID})
+		if err != nil {
+			return nil, ErrOperationFailedWithReason("read UP4 table entries", err.Error())
+		}
+
+		for _, entity := range resp.GetEntities() {
+			if entry := entity.GetTableEntry(); entry != nil {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// Reconcile reads back the UP4 tables owned by pfcpiface and repairs them against the rules of sessions.
+// Meters and counters are not reconciled.
+func (up4 *UP4) Reconcile(sessions []PFCPSession) (driftReport, error) {
+	report := driftReport{Datapath: "up4"}
+
+	if !up4.IsConnected(nil) {
+		return report, ErrOperationFailedWithReason("reconcile UP4", "not connected")
+	}
+
+	// block rule updates, so that the read back state is consistent with the sessions
+	up4.stateMu.Lock()
+	defer up4.stateMu.Unlock()
+
+	expected, err := up4.expectedTableEntries(sessions)
+	if err != nil {
+		return report, err
+	}
+
+	tableEntries, err := up4.readOwnedTableEntries()
+	if err != nil {
+		return report, err
+	}
+
+	actual := make([]reconcileEntry, 0, len(tableEntries))
+
+	for _, entry := range tableEntries {
+		actual = append(actual, tableReconcileEntry(entry))
+	}
+
+	diff := diffEntries(expected, actual)
+	report.add(diff)
+
+	repair := func(methodType p4.Update_Type, entries []reconcileEntry) {
+		for _, e := range entries {
+			if err := up4.p4client.ApplyTableEntries(methodType, e.obj.(*p4.TableEntry)); err != nil {
+				log.WithFields(log.Fields{
+					"entry":       e.obj,
+					"method type": p4.Update_Type_name[int32(methodType)],
+				}).Errorf("Failed to repair UP4 table entry: %v", err)
+
+				report.Failed++
+
+				continue
+			}
+
+			if methodType == p4.Update_DELETE {
+				up4.releaseRestoredReferences(e.obj.(*p4.TableEntry))
+			}
+
+			report.Repaired++
+		}
+	}
+
+	repair(p4.Update_INSERT, diff.missing)
+	repair(p4.Update_MODIFY, diff.different)
+	repair(p4.Update_DELETE, diff.extra)
+
+	return report, nil
 }
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// up4Batch collects all P4Runtime updates required by a single PFCP message,
+// so that they can be written to UP4 in a single Write request.
+type up4Batch struct {
+	updates []*p4.Update
+	// rollback reverts the in-memory allocations done while building the batch.
+	rollback []func()
+}
+
+func (b *up4Batch) addTableEntries(methodType p4.Update_Type, entries ...*p4.TableEntry) {
+	for _, entry := range entries {
+		b.updates = append(b.updates, &p4.Update{
+			Type:   methodType,
+			Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: entry}},
+		})
+	}
+}
+
+func (b *up4Batch) addMeterEntries(methodType p4.Update_Type, entries ...*p4.MeterEntry) {
+	for _, entry := range entries {
+		b.updates = append(b.updates, &p4.Update{
+			Type:   methodType,
+			Entity: &p4.Entity{Entity: &p4.Entity_MeterEntry{MeterEntry: entry}},
+		})
+	}
+}
+
+func (b *up4Batch) addCounterEntries(methodType p4.Update_Type, entries ...*p4.CounterEntry) {
+	for _, entry := range entries {
+		b.updates = append(b.updates, &p4.Update{
+			Type:   methodType,
+			Entity: &p4.Entity{Entity: &p4.Entity_CounterEntry{CounterEntry: entry}},
+		})
+	}
+}
+
+// onRollback registers f to be called if the batch cannot be written.
+func (b *up4Batch) onRollback(f func()) {
+	b.rollback = append(b.rollback, f)
+}
+
+// revert undoes the in-memory allocations in reverse order.
+func (b *up4Batch) revert() {
+	for i := len(b.rollback) - 1; i >= 0; i-- {
+		b.rollback[i]()
+	}
+
+	b.rollback = nil
+}
+
+func (up4 *UP4) atomicWritesUnsupported() bool {
+	return atomic.LoadInt32(&up4.noAtomicWrites) == 1
+}
+
+func (up4 *UP4) setAtomicWritesUnsupported() {
+	atomic.StoreInt32(&up4.noAtomicWrites, 1)
+}
+
+// isIgnorableUpdateError returns true for errors meaning that the update is already in effect.
+func isIgnorableUpdateError(update *p4.Update, code int32) bool {
+	switch update.GetType() {
+	case p4.Update_INSERT:
+		return code == int32(codes.AlreadyExists)
+	case p4.Update_DELETE:
+		return code == int32(codes.NotFound)
+	default:
+		return false
+	}
+}
+
+// withoutIgnorableUpdates removes the updates that failed with an ignorable error from updates.
+// Returns false if any update failed for a different reason.
+func withoutIgnorableUpdates(updates []*p4.Update, errors []*p4.Error) ([]*p4.Update, bool) {
+	if len(errors) != len(updates) {
+		return nil, false
+	}
+
+	remaining := make([]*p4.Update, 0, len(updates))
+
+	for i, e := range errors {
+		switch {
+		case e.GetCanonicalCode() == int32(codes.OK), e.GetCanonicalCode() == int32(codes.Aborted):
+			// Aborted updates were rolled back because of another failed update.
+			remaining = append(remaining, updates[i])
+		case isIgnorableUpdateError(updates[i], e.GetCanonicalCode()):
+			continue
+		default:
+			return nil, false
+		}
+	}
+
+	return remaining, true
+}
+
+// compensatingUpdate returns the update reverting update, or nil if it cannot be reverted.
+func compensatingUpdate(update *p4.Update) *p4.Update {
+	switch update.GetType() {
+	case p4.Update_INSERT:
+		return &p4.Update{Type: p4.Update_DELETE, Entity: update.GetEntity()}
+	case p4.Update_DELETE:
+		return &p4.Update{Type: p4.Update_INSERT, Entity: update.GetEntity()}
+	case p4.Update_MODIFY:
+		// meters are reset to their default configuration, as the previous one is not known.
+		if meterEntry := update.GetEntity().GetMeterEntry(); meterEntry != nil {
+			return &p4.Update{
+				Type: p4.Update_MODIFY,
+				Entity: &p4.Entity{Entity: &p4.Entity_MeterEntry{MeterEntry: &p4.MeterEntry{
+					MeterId: meterEntry.GetMeterId(),
+					Index:   meterEntry.GetIndex(),
+				}}},
+			}
+		}
+
+		// counter resets are harmless, and the previous value of modified table entries is not known.
+		return nil
+	default:
+		return nil
+	}
+}
+
+// writeBatch writes all updates of batch to UP4. The updates are written atomically (rollback-on-error),
+// if supported by the P4Runtime server. Otherwise, updates applied before a failure are reverted
+// with compensating updates. If the batch cannot be written, the in-memory allocations are reverted.
+func (up4 *UP4) writeBatch(ctx context.Context, batch *up4Batch) error {
+	if len(batch.updates) == 0 {
+		return nil
+	}
+
+	err := up4.writeAtomicBatch(ctx, batch.updates)
+	if err != nil {
+		batch.revert()
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("applying table entries to UP4: %w", ctx.Err())
+		}
+
+		return ErrOperationFailedWithReason("applying table entries to UP4", err.Error())
+	}
+
+	return nil
+}
+
+func (up4 *UP4) writeAtomicBatch(ctx context.Context, updates []*p4.Update) error {
+	if !up4.atomicWritesUnsupported() {
+		err := up4.p4client.WriteBatchReqWithAtomicity(ctx, updates, p4.WriteRequest_ROLLBACK_ON_ERROR)
+		if err == nil {
+			return nil
+		}
+
+		if status.Code(err) != codes.Unimplemented {
+			p4Error, ok := err.(*P4RuntimeError)
+			if !ok {
+				return err
+			}
+
+			// the whole batch was rolled back, retry without the updates that are already in effect
+			remaining, ok := withoutIgnorableUpdates(updates, p4Error.Get())
+			if !ok {
+				return err
+			}
+
+			if len(remaining) == 0 {
+				return nil
+			}
+
+			return up4.p4client.WriteBatchReqWithAtomicity(ctx, remaining, p4.WriteRequest_ROLLBACK_ON_ERROR)
+		}
+
+		log.Warn("UP4 does not support atomic writes, falling back to compensating updates on failures")
+		up4.setAtomicWritesUnsupported()
+	}
+
+	return up4.writeBatchWithCompensation(ctx, updates)
+}
+
+func (up4 *UP4) writeBatchWithCompensation(ctx context.Context, updates []*p4.Update) error {
+	err := up4.p4client.WriteBatchReqWithAtomicity(ctx, updates, p4.WriteRequest_CONTINUE_ON_ERROR)
+	if err == nil {
+		return nil
+	}
+
+	p4Error, ok := err.(*P4RuntimeError)
+	if !ok || len(p4Error.Get()) != len(updates) {
+		// we can't tell which updates were applied
+		return err
+	}
+
+	failed := false
+	compensations := make([]*p4.Update, 0)
+
+	for i, e := range p4Error.Get() {
+		switch {
+		case e.GetCanonicalCode() == int32(codes.OK):
+			if c := compensatingUpdate(updates[i]); c != nil {
+				compensations = append(compensations, c)
+			}
+		case isIgnorableUpdateError(updates[i], e.GetCanonicalCode()):
+			continue
+		default:
+			failed = true
+		}
+	}
+
+	if !failed {
+		return nil
+	}
+
+	if len(compensations) != 0 {
+		// compensations are written even if ctx is done, not to leave partial changes behind
+		if cErr := up4.p4client.WriteBatchReq(compensations); cErr != nil {
+			log.Errorf("Failed to revert partially applied UP4 updates, leftovers are left to the reconciliation: %v", cErr)
+		}
+	}
+
+	return err
+}
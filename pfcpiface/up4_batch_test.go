@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"testing"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// writeRecorder is a P4Runtime client recording Write requests and replying with canned errors.
+type writeRecorder struct {
+	p4.P4RuntimeClient
+
+	requests []*p4.WriteRequest
+	replies  []error
+}
+
+func (w *writeRecorder) Write(ctx context.Context, req *p4.WriteRequest, opts ...grpc.CallOption) (*p4.WriteResponse, error) {
+	w.requests = append(w.requests, req)
+
+	if len(w.replies) == 0 {
+		return &p4.WriteResponse{}, nil
+	}
+
+	err := w.replies[0]
+	w.replies = w.replies[1:]
+
+	return &p4.WriteResponse{}, err
+}
+
+func perUpdateError(t *testing.T, updateCodes ...codes.Code) error {
+	st := status.New(codes.Unknown, "batch failed")
+
+	for _, c := range updateCodes {
+		var err error
+
+		st, err = st.WithDetails(&p4.Error{CanonicalCode: int32(c)})
+		require.NoError(t, err)
+	}
+
+	return st.Err()
+}
+
+func testTableEntry(tableID uint32) *p4.TableEntry {
+	return &p4.TableEntry{TableId: tableID}
+}
+
+func newTestBatch(rolledBack *bool) *up4Batch {
+	batch := &up4Batch{}
+	batch.addTableEntries(p4.Update_INSERT, testTableEntry(1), testTableEntry(2))
+	batch.addMeterEntries(p4.Update_MODIFY, &p4.MeterEntry{MeterId: 3, Index: &p4.Index{Index: 1}})
+	batch.onRollback(func() { *rolledBack = true })
+
+	return batch
+}
+
+func Test_up4Batch_revertOrder(t *testing.T) {
+	var order []int
+
+	batch := &up4Batch{}
+	batch.onRollback(func() { order = append(order, 1) })
+	batch.onRollback(func() { order = append(order, 2) })
+
+	batch.revert()
+	batch.revert()
+
+	require.Equal(t, []int{2, 1}, order, "rollbacks should run once, in reverse order")
+}
+
+func Test_writeBatch(t *testing.T) {
+	tests := []struct {
+		name               string
+		replies            []error
+		wantErr            bool
+		wantRolledBack     bool
+		wantAtomicity      []p4.WriteRequest_Atomicity
+		wantLastReqUpdates []p4.Update_Type
+	}{
+		{
+			name:          "atomic write succeeds",
+			wantAtomicity: []p4.WriteRequest_Atomicity{p4.WriteRequest_ROLLBACK_ON_ERROR},
+		},
+		{
+			name:           "atomic write fails",
+			replies:        []error{perUpdateError(t, codes.OK, codes.ResourceExhausted, codes.Aborted)},
+			wantErr:        true,
+			wantRolledBack: true,
+			wantAtomicity:  []p4.WriteRequest_Atomicity{p4.WriteRequest_ROLLBACK_ON_ERROR},
+		},
+		{
+			name:    "atomic write retried without existing entries",
+			replies: []error{perUpdateError(t, codes.AlreadyExists, codes.Aborted, codes.Aborted)},
+			wantAtomicity: []p4.WriteRequest_Atomicity{
+				p4.WriteRequest_ROLLBACK_ON_ERROR, p4.WriteRequest_ROLLBACK_ON_ERROR,
+			},
+			wantLastReqUpdates: []p4.Update_Type{p4.Update_INSERT, p4.Update_MODIFY},
+		},
+		{
+			name: "non-atomic write compensates applied updates",
+			replies: []error{
+				status.Error(codes.Unimplemented, "atomicity not supported"),
+				perUpdateError(t, codes.OK, codes.ResourceExhausted, codes.OK),
+			},
+			wantErr:        true,
+			wantRolledBack: true,
+			wantAtomicity: []p4.WriteRequest_Atomicity{
+				p4.WriteRequest_ROLLBACK_ON_ERROR, p4.WriteRequest_CONTINUE_ON_ERROR, p4.WriteRequest_CONTINUE_ON_ERROR,
+			},
+			wantLastReqUpdates: []p4.Update_Type{p4.Update_DELETE, p4.Update_MODIFY},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &writeRecorder{replies: tt.replies}
+			up4 := &UP4{p4client: &P4rtClient{client: recorder}}
+
+			rolledBack := false
+			batch := newTestBatch(&rolledBack)
+
+			err := up4.writeBatch(context.Background(), batch)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.wantRolledBack, rolledBack)
+
+			atomicity := make([]p4.WriteRequest_Atomicity, 0, len(recorder.requests))
+			for _, req := range recorder.requests {
+				atomicity = append(atomicity, req.GetAtomicity())
+			}
+
+			require.Equal(t, tt.wantAtomicity, atomicity)
+
+			if tt.wantLastReqUpdates != nil {
+				lastReq := recorder.requests[len(recorder.requests)-1]
+
+				updateTypes := make([]p4.Update_Type, 0, len(lastReq.GetUpdates()))
+				for _, u := range lastReq.GetUpdates() {
+					updateTypes = append(updateTypes, u.GetType())
+				}
+
+				require.Equal(t, tt.wantLastReqUpdates, updateTypes)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/internal/p4constants"
+	"github.com/omec-project/upf-epc/pkg/fake_up4"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeUP4P4InfoPath = "../conf/p4/bin/p4info.txt"
+	// fakeUP4DDNDigestID is the ID of ddn_digest_t in the p4info.
+	fakeUP4DDNDigestID = 396224266
+)
+
+func startFakeUP4(t *testing.T) (*fake_up4.FakeUP4, string) {
+	fu, err := fake_up4.NewFakeUP4(fakeUP4P4InfoPath)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		if err := fu.Serve(listener); err != nil {
+			t.Logf("fake UP4 stopped: %v", err)
+		}
+	}()
+
+	t.Cleanup(fu.Stop)
+
+	return fu, listener.Addr().String()
+}
+
+// newFakeUP4TestUP4 returns a UP4 connected to a fake UP4, with the state of UP4 cleared.
+func newFakeUP4TestUP4(t *testing.T) (*UP4, *fake_up4.FakeUP4, chan uint64) {
+	fu, address := startFakeUP4(t)
+
+	host, port, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+
+	u := &upf{reportNotifyChan: make(chan uint64, 8)}
+	u.setSessionsSource(func() []PFCPSession { return nil })
+
+	up4 := &UP4{}
+	up4.SetUpfInfo(u, &Conf{
+		CPIface: CPIfaceInfo{UEIPPool: "10.250.0.0/16"},
+		P4rtcIface: P4rtcInfo{
+			AccessIP:            swTestN3Address.String() + "/32",
+			P4rtcServer:         host,
+			P4rtcPort:           port,
+			ClearStateOnRestart: true,
+		},
+	})
+
+	require.Eventually(t, func() bool { return up4.IsConnected(nil) }, 10*time.Second, 50*time.Millisecond)
+
+	return up4, fu, u.reportNotifyChan
+}
+
+func Test_UP4_fakeUP4Session(t *testing.T) {
+	rules := newSWTestRules()
+	up4, fu, _ := newFakeUP4TestUP4(t)
+
+	require.Len(t, fu.GetTableEntries(p4constants.TablePreQosPipeInterfaces), 2, "N3 address and UE pool")
+
+	tx := up4.BeginRules(context.Background(), rules)
+	createRules(tx, rules)
+	require.NoError(t, tx.Commit())
+
+	for _, table := range []uint32{
+		p4constants.TablePreQosPipeSessionsUplink,
+		p4constants.TablePreQosPipeSessionsDownlink,
+		p4constants.TablePreQosPipeTerminationsUplink,
+		p4constants.TablePreQosPipeTerminationsDownlink,
+		p4constants.TablePreQosPipeTunnelPeers,
+	} {
+		require.Len(t, fu.GetTableEntries(table), 1, "table %d", table)
+	}
+
+	tx = up4.BeginRules(context.Background(), PacketForwardingRules{})
+	deleteRules(tx, rules)
+	require.NoError(t, tx.Commit())
+
+	for _, table := range []uint32{
+		p4constants.TablePreQosPipeSessionsUplink,
+		p4constants.TablePreQosPipeSessionsDownlink,
+		p4constants.TablePreQosPipeTerminationsUplink,
+		p4constants.TablePreQosPipeTerminationsDownlink,
+	} {
+		require.Empty(t, fu.GetTableEntries(table), "table %d", table)
+	}
+
+	// the tunnel peer is kept as idle
+	require.Len(t, fu.GetTableEntries(p4constants.TablePreQosPipeTunnelPeers), 1)
+}
+
+func Test_UP4_fakeUP4DownlinkDataNotification(t *testing.T) {
+	rules := newSWTestRules()
+	up4, fu, reports := newFakeUP4TestUP4(t)
+
+	tx := up4.BeginRules(context.Background(), rules)
+	createRules(tx, rules)
+	require.NoError(t, tx.Commit())
+
+	ueAddr := make([]byte, 4)
+	binary.BigEndian.PutUint32(ueAddr, ip2int(swTestUEAddress))
+
+	require.NoError(t, fu.SendDigest(fakeUP4DDNDigestID, ueAddr))
+
+	select {
+	case fseid := <-reports:
+		require.Equal(t, uint64(swTestFSEID), fseid)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no Downlink Data Notification for the digest")
+	}
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/connectivity"
+)
+
+// electionID returns the election id of a new P4Runtime channel. In HA mode, the channel is opened as standby,
+// so that it becomes primary only if no other pfcpiface is connected to UP4.
+func (up4 *UP4) electionID() p4.Uint128 {
+	if up4.conf.EnableHA {
+		return StandbyElectionId()
+	}
+
+	return TimeBasedElectionId()
+}
+
+// isStandbyElectionID returns true if id was generated by StandbyElectionId.
+func isStandbyElectionID(id p4.Uint128) bool {
+	return id.High == 0
+}
+
+// isChannelUp returns true if the P4Runtime channel to UP4 is usable, regardless of the mastership.
+func (up4 *UP4) isChannelUp() bool {
+	return up4.p4client != nil && up4.p4client.IsStreamUp() && up4.p4client.CheckStatus() == connectivity.Ready
+}
+
+// watchMastership follows the arbitration updates of client, until its stream fails. In HA mode, pfcpiface:
+//   - keeps the mastership with a time based election id, if it became primary being the only client;
+//   - takes over UP4 when it becomes primary, restoring its state from the switch;
+//   - claims the mastership if the primary disconnects;
+//   - goes standby if another pfcpiface becomes primary.
+func (up4 *UP4) watchMastership(client *P4rtClient) {
+	metrics := getDatapathMetrics()
+
+	for role := range client.Arbitrations() {
+		switch role {
+		case MastershipPrimary:
+			if isStandbyElectionID(client.ElectionID()) {
+				// a time based election id is higher than the ones of standby instances connecting later
+				if err := client.SetMastership(TimeBasedElectionId()); err != nil {
+					log.Errorf("Failed to keep UP4 mastership: %v", err)
+				}
+
+				continue
+			}
+
+			up4.tryConnectMu.Lock()
+
+			if err := up4.unsafeTakeover(); err != nil {
+				log.Errorf("Failed to take over UP4: %v", err)
+			}
+
+			up4.tryConnectMu.Unlock()
+		case MastershipBackup:
+			if up4.IsConnected(nil) {
+				log.Warn("Another pfcpiface became primary of UP4, going standby")
+			}
+
+			up4.setConnectedStatus(false)
+			metrics.up4Primary.Set(0)
+		case MastershipNoPrimary:
+			log.Info("UP4 has no primary, claiming the mastership")
+
+			if err := client.SetMastership(TimeBasedElectionId()); err != nil {
+				log.Errorf("Failed to claim UP4 mastership: %v", err)
+			}
+		case MastershipUnknown:
+			log.Warn("Unexpected UP4 mastership arbitration status")
+		}
+	}
+
+	// the stream failed, a new channel is set up by keepTryingToConnect
+	up4.tryConnectMu.Lock()
+	defer up4.tryConnectMu.Unlock()
+
+	if up4.p4client == client {
+		up4.setConnectedStatus(false)
+		metrics.up4Primary.Set(0)
+	}
+}
+
+// unsafeTakeover initializes UP4 from the switch state once primary. A caller should hold tryConnectMu.
+func (up4 *UP4) unsafeTakeover() error {
+	if up4.IsConnected(nil) {
+		return nil
+	}
+
+	if up4.p4client.Role() != MastershipPrimary || isStandbyElectionID(up4.p4client.ElectionID()) {
+		return ErrOperationFailedWithReason("take over UP4", "not primary")
+	}
+
+	// block reconciliation while the state is restored
+	up4.stateMu.Lock()
+	defer up4.stateMu.Unlock()
+
+	if err := up4.initialize(false); err != nil {
+		return err
+	}
+
+	up4.setConnectedStatus(true)
+
+	metrics := getDatapathMetrics()
+	metrics.up4Primary.Set(1)
+	metrics.up4Takeovers.Inc()
+
+	log.Info("pfcpiface is primary of UP4")
+
+	return nil
+}
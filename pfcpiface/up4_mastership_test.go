@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"os"
+	"testing"
+
+	set "github.com/deckarep/golang-set"
+	"github.com/golang/protobuf/proto" //nolint:staticcheck // P4Runtime stubs are based on the deprecated proto.
+	"github.com/omec-project/upf-epc/internal/p4constants"
+	p4ConfigV1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// mastershipTestClient is a P4Runtime client reading canned table entries and recording writes.
+type mastershipTestClient struct {
+	p4.P4RuntimeClient
+
+	entries []*p4.TableEntry
+	meters  []*p4.MeterEntry
+	writes  []*p4.WriteRequest
+}
+
+func (c *mastershipTestClient) Read(ctx context.Context, req *p4.ReadRequest, opts ...grpc.CallOption) (p4.P4Runtime_ReadClient, error) {
+	res := &p4.ReadResponse{}
+
+	if req.Entities[0].GetMeterEntry() != nil {
+		for _, entry := range c.meters {
+			res.Entities = append(res.Entities, &p4.Entity{Entity: &p4.Entity_MeterEntry{MeterEntry: entry}})
+		}
+
+		return &readTestClient{responses: []*p4.ReadResponse{res}}, nil
+	}
+
+	for _, entry := range c.entries {
+		if entry.TableId == req.Entities[0].GetTableEntry().GetTableId() {
+			res.Entities = append(res.Entities, &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: entry}})
+		}
+	}
+
+	return &readTestClient{responses: []*p4.ReadResponse{res}}, nil
+}
+
+func (c *mastershipTestClient) Write(ctx context.Context, req *p4.WriteRequest, opts ...grpc.CallOption) (*p4.WriteResponse, error) {
+	c.writes = append(c.writes, req)
+
+	return &p4.WriteResponse{}, nil
+}
+
+type readTestClient struct {
+	grpc.ClientStream
+
+	responses []*p4.ReadResponse
+}
+
+func (r *readTestClient) Recv() (*p4.ReadResponse, error) {
+	if len(r.responses) == 0 {
+		return nil, io.EOF
+	}
+
+	res := r.responses[0]
+	r.responses = r.responses[1:]
+
+	return res, nil
+}
+
+// streamTestClient records the arbitration updates sent by a P4Runtime client.
+type streamTestClient struct {
+	p4.P4Runtime_StreamChannelClient
+
+	electionIDs []p4.Uint128
+}
+
+func (s *streamTestClient) Send(req *p4.StreamMessageRequest) error {
+	s.electionIDs = append(s.electionIDs, *req.GetArbitration().GetElectionId())
+
+	return nil
+}
+
+func newMastershipTestTranslator(t *testing.T) *P4rtTranslator {
+	b, err := os.ReadFile("../conf/p4/bin/p4info.txt")
+	require.NoError(t, err)
+
+	p4Info := &p4ConfigV1.P4Info{}
+	require.NoError(t, proto.UnmarshalText(string(b), p4Info))
+
+	return newP4RtTranslator(p4Info)
+}
+
+func newMastershipTestUP4(t *testing.T) *UP4 {
+	up4 := &UP4{
+		p4RtTranslator: newMastershipTestTranslator(t),
+		AccessIP:       MustParseStrIP(swTestN3Address.String() + "/32"),
+		ueIPPool:       MustParseStrIP("10.250.0.0/16"),
+		counters:       make([]counter, 2),
+		ueAddrToFSEID:  make(map[uint32]uint64),
+		fseidToUEAddr:  make(map[uint64]uint32),
+		pdrCounters:    make(map[uint32]pdrCounterRef),
+	}
+	up4.initTunnelPeerIDs()
+	up4.initApplicationIDs()
+	up4.sizeIDPools()
+	up4.initCounter(preQosCounterID, "pre_qos_counter", 16)
+	up4.appMeterCellIDsPool = set.NewSet(uint32(1), uint32(2), uint32(3))
+	up4.sessMeterCellIDsPool = set.NewSet(uint32(1), uint32(2), uint32(3))
+
+	return up4
+}
+
+// newMastershipTestEntries returns the entries written to UP4 by another pfcpiface for an uplink PDR.
+func newMastershipTestEntries(t *testing.T, tr *P4rtTranslator) ([]*p4.TableEntry, tunnelParams, pdr) {
+	rules := newSWTestRules()
+
+	uplink := rules.pdrs[0]
+	uplink.ctrID = 7
+	uplink.appFilter.dstIP = ip2int(net.ParseIP("10.0.0.8"))
+	uplink.appFilter.dstIPMask = math.MaxUint32
+	uplink.appFilter.dstPortRange = newExactMatchPortRange(80)
+	uplink.appFilter.proto, uplink.appFilter.protoMask = 6, 0xff
+
+	params := tunnelParams{
+		tunnelIP4Src: ip2int(swTestN3Address),
+		tunnelIP4Dst: ip2int(swTestGNBAddress),
+		tunnelPort:   tunnelGTPUPort,
+	}
+
+	peer, err := tr.BuildGTPTunnelPeerTableEntry(5, params)
+	require.NoError(t, err)
+
+	app, err := tr.BuildApplicationsTableEntry(uplink, 0, 3)
+	require.NoError(t, err)
+
+	session, err := tr.BuildSessionsTableEntry(uplink, meter{uplinkCellID: 2}, 0, false)
+	require.NoError(t, err)
+
+	termination, err := tr.BuildTerminationsTableEntry(uplink, meter{uplinkCellID: 1}, rules.fars[0], 3, 9, 0, rules.qers[0])
+	require.NoError(t, err)
+
+	return []*p4.TableEntry{peer, app, session, termination}, params, uplink
+}
+
+func Test_UP4_watchMastership(t *testing.T) {
+	conn, err := grpc.Dial("localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	up4 := newMastershipTestUP4(t)
+	up4.conf.EnableHA = true
+	entries, _, _ := newMastershipTestEntries(t, up4.p4RtTranslator)
+
+	p4rt := &mastershipTestClient{entries: entries}
+	stream := &streamTestClient{}
+	client := &P4rtClient{
+		client:       p4rt,
+		conn:         conn,
+		stream:       stream,
+		arbitrations: make(chan MastershipRole, 16),
+	}
+	up4.p4client = client
+
+	// standby, then the primary disconnects
+	require.NoError(t, client.SetMastership(StandbyElectionId()))
+	client.setRole(MastershipBackup)
+	client.setRole(MastershipNoPrimary)
+	close(client.arbitrations)
+
+	up4.watchMastership(client)
+	require.Len(t, stream.electionIDs, 2)
+	require.False(t, isStandbyElectionID(stream.electionIDs[1]), "mastership should be claimed over the old primary")
+	require.False(t, up4.IsConnected(nil))
+
+	// the device elects the standby
+	takeovers := getDatapathMetrics().up4Takeovers
+	before := testutil.ToFloat64(takeovers)
+
+	client.arbitrations = make(chan MastershipRole, 16)
+	client.setRole(MastershipPrimary)
+	close(client.arbitrations)
+
+	up4.watchMastership(client)
+	require.Equal(t, before+1, testutil.ToFloat64(takeovers))
+	require.False(t, up4.connected, "the closed arbitrations should disconnect UP4, as the stream failed")
+	require.False(t, up4.counters[preQosCounterID].counterIDsPool.Contains(uint64(7)))
+	require.NotContains(t, up4.tunnelPeerIDsPool, uint16(5))
+
+	// the tables are not cleared, only the missing interfaces are inserted
+	require.Len(t, p4rt.writes, 1)
+
+	for _, update := range p4rt.writes[0].Updates {
+		require.Equal(t, p4.Update_INSERT, update.Type)
+		require.Equal(t, uint32(p4constants.TablePreQosPipeInterfaces), update.GetEntity().GetTableEntry().GetTableId())
+	}
+}
+
+func Test_UP4_watchMastership_onlyClient(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	up4.conf.EnableHA = true
+
+	stream := &streamTestClient{}
+	client := &P4rtClient{stream: stream, arbitrations: make(chan MastershipRole, 16)}
+	up4.p4client = client
+
+	// primary with a standby election id, as no other pfcpiface is connected
+	require.NoError(t, client.SetMastership(StandbyElectionId()))
+	client.setRole(MastershipPrimary)
+	close(client.arbitrations)
+
+	up4.watchMastership(client)
+	require.Len(t, stream.electionIDs, 2)
+	require.False(t, isStandbyElectionID(client.ElectionID()))
+	require.False(t, up4.connected, "UP4 is taken over on the arbitration update of the new election id")
+}
@@ -0,0 +1,598 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"fmt"
+	"time"
+
+	set "github.com/deckarep/golang-set"
+	"github.com/omec-project/upf-epc/internal/p4constants"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// restoredIDs counts the IDs found in use in UP4 by restoreIDPools.
+type restoredIDs struct {
+	tunnelPeers  int
+	applications int
+	meterCells   int
+	counterCells int
+	// conflicts counts the entries using IDs that cannot be allocated,
+	// e.g. an ID used twice or referencing a missing tunnel peer or application.
+	conflicts int
+}
+
+// restoredReference is the reference to a tunnel peer or an application by an entry restored from UP4,
+// identified by the UE address and application ID matched by the entry. It keeps the tunnel peer or
+// the application in use, until the entry is removed from UP4 or the PFCP session of the UE is restored.
+type restoredReference struct {
+	tableID uint32
+	ueAddr  uint32
+	appID   uint16
+}
+
+// restoreDatapathState initializes UP4 without clearing it: the IDs used by its table entries and meters
+// are allocated, so that the traffic of existing sessions keeps being forwarded. If strict, an error is
+// returned when the state of UP4 is inconsistent, instead of restoring the consistent part only.
+func (up4 *UP4) restoreDatapathState(strict bool) error {
+	entries, err := up4.readOwnedTableEntries()
+	if err != nil {
+		return err
+	}
+
+	meterEntries, err := up4.readMeterEntries()
+	if err != nil {
+		return err
+	}
+
+	if up4.counters[preQosCounterID].counterIDsPool == nil {
+		up4.initAllCounters()
+	}
+
+	if up4.appMeterCellIDsPool == nil || up4.sessMeterCellIDsPool == nil {
+		up4.initMetersPools()
+	}
+
+	restored := up4.restoreIDPools(entries)
+	restored.meterCells += up4.restoreMeterCells(meterEntries)
+
+	sessions, conflicts := up4.restoreSessions(entries, up4.knownSessions())
+	restored.conflicts += conflicts
+
+	log.WithFields(log.Fields{
+		"tunnel peers": restored.tunnelPeers,
+		"applications": restored.applications,
+		"meter cells":  restored.meterCells,
+		"counters":     restored.counterCells,
+		"sessions":     sessions,
+		"conflicts":    restored.conflicts,
+	}).Info("ID pools restored from UP4")
+
+	setIDPoolMetrics(idPoolTunnelPeers, up4.tunnelPeerIDsSize, len(up4.tunnelPeerIDsPool))
+	setIDPoolMetrics(idPoolApplications, up4.applicationIDsSize, len(up4.applicationIDsPool))
+
+	if restored.conflicts != 0 {
+		if strict {
+			return ErrOperationFailedWithReason("restore UP4 state",
+				fmt.Sprintf("%d inconsistent entries found, UP4 must be cleared", restored.conflicts))
+		}
+
+		log.Warnf("%d inconsistent entries found in UP4, their IDs are not restored", restored.conflicts)
+	}
+
+	return up4.restoreInterfaces(entries)
+}
+
+// knownSessions returns the PFCP sessions known to pfcpiface, if any.
+func (up4 *UP4) knownSessions() []PFCPSession {
+	if up4.upf == nil {
+		return nil
+	}
+
+	return up4.upf.allSessions()
+}
+
+// readMeterEntries reads the cells of the application and session meters from UP4.
+func (up4 *UP4) readMeterEntries() ([]*p4.MeterEntry, error) {
+	entities := make([]*p4.Entity, 0, 2)
+
+	for _, meterID := range []uint32{p4constants.MeterPreQosPipeAppMeter, p4constants.MeterPreQosPipeSessionMeter} {
+		entities = append(entities, &p4.Entity{
+			Entity: &p4.Entity_MeterEntry{MeterEntry: &p4.MeterEntry{MeterId: meterID}},
+		})
+	}
+
+	resp, err := up4.p4client.ReadReqEntities(entities)
+	if err != nil {
+		return nil, ErrOperationFailedWithReason("read UP4 meter entries", err.Error())
+	}
+
+	meterEntries := make([]*p4.MeterEntry, 0)
+
+	for _, entity := range resp.GetEntities() {
+		if entry := entity.GetMeterEntry(); entry != nil {
+			meterEntries = append(meterEntries, entry)
+		}
+	}
+
+	return meterEntries, nil
+}
+
+// restoreMeterCells allocates the meter cells configured in UP4, even if not used by any table entry,
+// e.g. meters of QERs whose PDRs were not written yet.
+func (up4 *UP4) restoreMeterCells(meterEntries []*p4.MeterEntry) int {
+	restored := 0
+
+	for _, entry := range meterEntries {
+		if entry.GetConfig() == nil || entry.GetIndex() == nil {
+			// default configuration, the cell is free
+			continue
+		}
+
+		var pool set.Set
+
+		switch entry.GetMeterId() {
+		case p4constants.MeterPreQosPipeAppMeter:
+			pool = up4.appMeterCellIDsPool
+		case p4constants.MeterPreQosPipeSessionMeter:
+			pool = up4.sessMeterCellIDsPool
+		default:
+			continue
+		}
+
+		cell := uint32(entry.GetIndex().GetIndex())
+		if pool.Contains(cell) {
+			pool.Remove(cell)
+			restored++
+		}
+	}
+
+	return restored
+}
+
+// removeID removes id from pool, returning false if id is not available.
+func removeID(pool []uint16, id uint16) ([]uint16, bool) {
+	for i, v := range pool {
+		if v == id {
+			return append(pool[:i], pool[i+1:]...), true
+		}
+	}
+
+	return pool, false
+}
+
+// entryUEAddress returns the UE address matched by entry, if any.
+func (up4 *UP4) entryUEAddress(entry *p4.TableEntry) (uint32, bool) {
+	mf, err := up4.p4RtTranslator.getFieldMatch(entry, FieldUEAddress)
+	if err != nil || mf.GetExact() == nil {
+		return 0, false
+	}
+
+	return uint32(bytesToUint64(mf.GetExact().GetValue())), true
+}
+
+// entryApplicationID returns the application ID matched by a terminations entry, if any.
+func (up4 *UP4) entryApplicationID(entry *p4.TableEntry) (uint16, bool) {
+	mf, err := up4.p4RtTranslator.getFieldMatch(entry, FieldApplicationID)
+	if err != nil || mf.GetExact() == nil {
+		return 0, false
+	}
+
+	return uint16(bytesToUint64(mf.GetExact().GetValue())), true
+}
+
+// entryRestoredReference returns the restored reference of an entry matching a UE address.
+func (up4 *UP4) entryRestoredReference(entry *p4.TableEntry) (restoredReference, bool) {
+	ueAddr, ok := up4.entryUEAddress(entry)
+	if !ok {
+		return restoredReference{}, false
+	}
+
+	// only terminations match an application ID
+	appID, _ := up4.entryApplicationID(entry)
+
+	return restoredReference{tableID: entry.TableId, ueAddr: ueAddr, appID: appID}, true
+}
+
+// restoreIDPools allocates the tunnel peer IDs, application IDs, meter cells and counter cells used by entries.
+// IDs already allocated are kept, so that restoring the state of the same switch is idempotent.
+// Tunnel peers and applications are referenced by the entries using them, as the PFCP sessions
+// of the entries may be unknown.
+func (up4 *UP4) restoreIDPools(entries []*p4.TableEntry) restoredIDs {
+	up4.tunnelPeerMu.Lock()
+	defer up4.tunnelPeerMu.Unlock()
+
+	up4.applicationMu.Lock()
+	defer up4.applicationMu.Unlock()
+
+	var restored restoredIDs
+
+	for _, entry := range entries {
+		entryLog := log.WithField("entry", entry)
+
+		switch entry.TableId {
+		case p4constants.TablePreQosPipeTunnelPeers:
+			id, params, err := up4.p4RtTranslator.ParseGTPTunnelPeerTableEntry(entry)
+			if err != nil {
+				entryLog.Warnf("Failed to parse tunnel peer: %v", err)
+				restored.conflicts++
+
+				continue
+			}
+
+			if id < firstGTPTunnelPeerID {
+				// e.g., dbuf
+				continue
+			}
+
+			if peer, exists := up4.tunnelPeerIDs[params]; exists && peer.id == id {
+				continue
+			}
+
+			pool, ok := removeID(up4.tunnelPeerIDsPool, id)
+			if !ok {
+				entryLog.Warn("Tunnel peer ID is already in use")
+				restored.conflicts++
+
+				continue
+			}
+
+			up4.tunnelPeerIDsPool = pool
+			up4.tunnelPeerIDs[params] = tunnelPeer{id: id, usedBy: set.NewSet()}
+			restored.tunnelPeers++
+		case p4constants.TablePreQosPipeApplications:
+			appFilter, id, err := up4.p4RtTranslator.ParseApplicationsTableEntry(entry)
+			if err != nil {
+				entryLog.Warnf("Failed to parse application: %v", err)
+				restored.conflicts++
+
+				continue
+			}
+
+			if app, exists := up4.applicationIDs[appFilter]; exists && app.id == id {
+				continue
+			}
+
+			pool, ok := removeID(up4.applicationIDsPool, id)
+			if !ok {
+				entryLog.Warn("Application ID is already in use")
+				restored.conflicts++
+
+				continue
+			}
+
+			up4.applicationIDsPool = pool
+			up4.applicationIDs[appFilter] = internalApp{id: id, usedBy: set.NewSet()}
+			restored.applications++
+		}
+	}
+
+	restored.conflicts += up4.unsafeRestoreReferences(entries)
+	restored.meterCells, restored.counterCells = up4.restoreCells(entries, &restored.conflicts)
+
+	return restored
+}
+
+// unsafeRestoreReferences adds a restoredReference to the tunnel peers and applications used by entries.
+// Returns the number of entries referencing missing tunnel peers or applications.
+// A caller should hold tunnelPeerMu and applicationMu.
+func (up4 *UP4) unsafeRestoreReferences(entries []*p4.TableEntry) int {
+	peers := make(map[uint16]tunnelPeer, len(up4.tunnelPeerIDs))
+	for _, peer := range up4.tunnelPeerIDs {
+		peers[peer.id] = peer
+	}
+
+	apps := make(map[uint16]internalApp, len(up4.applicationIDs))
+	for _, app := range up4.applicationIDs {
+		apps[app.id] = app
+	}
+
+	conflicts := 0
+
+	for _, entry := range entries {
+		ref, ok := up4.entryRestoredReference(entry)
+		if !ok {
+			continue
+		}
+
+		switch entry.TableId {
+		case p4constants.TablePreQosPipeSessionsDownlink:
+			id, err := up4.p4RtTranslator.getActionParamValueByName(entry, FieldTunnelPeerID)
+			if err != nil || id < firstGTPTunnelPeerID {
+				// e.g., buffering sessions
+				continue
+			}
+
+			peer, exists := peers[uint16(id)]
+			if !exists {
+				log.WithField("entry", entry).Warn("Session uses a missing tunnel peer")
+				conflicts++
+
+				continue
+			}
+
+			peer.usedBy.Add(ref)
+		case p4constants.TablePreQosPipeTerminationsUplink, p4constants.TablePreQosPipeTerminationsDownlink:
+			if ref.appID == DefaultApplicationID {
+				continue
+			}
+
+			app, exists := apps[ref.appID]
+			if !exists {
+				log.WithField("entry", entry).Warn("Termination uses a missing application")
+				conflicts++
+
+				continue
+			}
+
+			app.usedBy.Add(ref)
+		}
+	}
+
+	return conflicts
+}
+
+// restoreCells allocates the meter cells and the counter cells used by entries. A counter cell used
+// by more than one entry is counted in conflicts, while meter cells can be shared by the PDRs of a QER.
+func (up4 *UP4) restoreCells(entries []*p4.TableEntry, conflicts *int) (int, int) {
+	meterCells, counterCells := 0, 0
+	counters := up4.counters[preQosCounterID]
+	usedCounters := make(map[uint64]bool)
+
+	removeMeterCell := func(pool set.Set, entry *p4.TableEntry) {
+		idx, err := up4.p4RtTranslator.getActionParamValueByName(entry, FieldAppMeterIndex)
+		if entry.TableId == p4constants.TablePreQosPipeSessionsUplink || entry.TableId == p4constants.TablePreQosPipeSessionsDownlink {
+			idx, err = up4.p4RtTranslator.getActionParamValueByName(entry, FieldSessionMeterIndex)
+		}
+
+		if err != nil || !pool.Contains(uint32(idx)) {
+			// e.g., drop actions without meter
+			return
+		}
+
+		pool.Remove(uint32(idx))
+		meterCells++
+	}
+
+	for _, entry := range entries {
+		switch entry.TableId {
+		case p4constants.TablePreQosPipeSessionsUplink, p4constants.TablePreQosPipeSessionsDownlink:
+			removeMeterCell(up4.sessMeterCellIDsPool, entry)
+		case p4constants.TablePreQosPipeTerminationsUplink, p4constants.TablePreQosPipeTerminationsDownlink:
+			removeMeterCell(up4.appMeterCellIDsPool, entry)
+
+			idx, err := up4.p4RtTranslator.getActionParamValueByName(entry, FieldCounterIndex)
+			if err != nil {
+				continue
+			}
+
+			if usedCounters[idx] || idx >= counters.maxSize {
+				log.WithField("entry", entry).Warn("Counter cell is already in use")
+
+				*conflicts++
+
+				continue
+			}
+
+			usedCounters[idx] = true
+
+			if counters.counterIDsPool.Contains(idx) {
+				counters.counterIDsPool.Remove(idx)
+				counterCells++
+			}
+		}
+	}
+
+	return meterCells, counterCells
+}
+
+// restoreSessions rebuilds the UE address mappings, the counters and the references to tunnel peers
+// and applications of the known PFCP sessions found in UP4. F-SEIDs are not stored in UP4, so the UE
+// addresses of unknown sessions are not mapped. Returns the number of restored sessions and conflicts.
+func (up4 *UP4) restoreSessions(entries []*p4.TableEntry, sessions []PFCPSession) (int, int) {
+	installed := make(map[uint32]bool)
+
+	for _, entry := range entries {
+		if entry.TableId != p4constants.TablePreQosPipeSessionsDownlink {
+			continue
+		}
+
+		if ueAddr, ok := up4.entryUEAddress(entry); ok {
+			installed[ueAddr] = true
+		}
+	}
+
+	restored, conflicts := 0, 0
+
+	for _, session := range sessions {
+		ueAddr, ok := sessionUEAddress(session)
+		if !ok || !installed[ueAddr] {
+			continue
+		}
+
+		if fseid, exists := up4.ueAddrToFSEID[ueAddr]; exists && fseid != session.localSEID {
+			log.WithFields(log.Fields{
+				"UE address": int2ip(ueAddr),
+				"F-SEIDs":    []uint64{fseid, session.localSEID},
+			}).Warn("UE address is used by more than one session")
+
+			conflicts++
+
+			continue
+		}
+
+		up4.ueAddrToFSEID[ueAddr] = session.localSEID
+		up4.fseidToUEAddr[session.localSEID] = ueAddr
+
+		for _, p := range session.pdrs {
+			up4.trackPDRCounter(p)
+		}
+
+		up4.restoreSessionReferences(session, ueAddr)
+
+		restored++
+	}
+
+	return restored, conflicts
+}
+
+// sessionUEAddress returns the UE address of the downlink PDRs of session.
+func sessionUEAddress(session PFCPSession) (uint32, bool) {
+	for _, p := range session.pdrs {
+		if !p.IsUplink() && p.ueAddress != 0 {
+			return p.ueAddress, true
+		}
+	}
+
+	return 0, false
+}
+
+// restoreSessionReferences replaces the restored references of the entries of the UE by the references
+// of its PFCP session, so that the tunnel peers and applications are released when the session is removed.
+func (up4 *UP4) restoreSessionReferences(session PFCPSession, ueAddr uint32) {
+	up4.tunnelPeerMu.Lock()
+	defer up4.tunnelPeerMu.Unlock()
+
+	up4.applicationMu.Lock()
+	defer up4.applicationMu.Unlock()
+
+	for _, f := range session.fars {
+		if f.tunnelTEID == 0 {
+			continue
+		}
+
+		params := tunnelParams{
+			tunnelIP4Src: ip2int(up4.AccessIP.IP),
+			tunnelIP4Dst: f.tunnelIP4Dst,
+			tunnelPort:   f.tunnelPort,
+		}
+
+		if peer, exists := up4.tunnelPeerIDs[params]; exists {
+			peer.usedBy.Add(tnlPeerReference{f.fseID, f.farID})
+		}
+	}
+
+	for _, p := range session.pdrs {
+		if p.IsAppFilterEmpty() {
+			continue
+		}
+
+		if app, exists := up4.applicationIDs[toUP4ApplicationFilter(p)]; exists {
+			app.usedBy.Add(internalAppReference{p.fseID, p.pdrID})
+		}
+	}
+
+	up4.unsafeReleaseRestoredReferences(func(r restoredReference) bool { return r.ueAddr == ueAddr })
+}
+
+// releaseRestoredReferences drops the restored reference of entry, removed from UP4.
+// Tunnel peers no longer used become idle, applications no longer used are released
+// once their entry is removed.
+func (up4 *UP4) releaseRestoredReferences(entry *p4.TableEntry) {
+	up4.tunnelPeerMu.Lock()
+	defer up4.tunnelPeerMu.Unlock()
+
+	up4.applicationMu.Lock()
+	defer up4.applicationMu.Unlock()
+
+	if entry.TableId == p4constants.TablePreQosPipeApplications {
+		appFilter, _, err := up4.p4RtTranslator.ParseApplicationsTableEntry(entry)
+		if err != nil {
+			return
+		}
+
+		if app, exists := up4.applicationIDs[appFilter]; exists && app.usedBy.Cardinality() == 0 {
+			up4.unsafeReleaseInternalApplicationID(appFilter)
+		}
+
+		return
+	}
+
+	ref, ok := up4.entryRestoredReference(entry)
+	if !ok {
+		return
+	}
+
+	up4.unsafeReleaseRestoredReferences(func(r restoredReference) bool { return r == ref })
+}
+
+// unsafeReleaseRestoredReferences removes the restored references matching match from the tunnel peers
+// and applications. A caller should hold tunnelPeerMu and applicationMu.
+func (up4 *UP4) unsafeReleaseRestoredReferences(match func(restoredReference) bool) {
+	release := func(usedBy set.Set) bool {
+		released := false
+
+		for _, r := range usedBy.ToSlice() {
+			if ref, ok := r.(restoredReference); ok && match(ref) {
+				usedBy.Remove(r)
+
+				released = true
+			}
+		}
+
+		return released
+	}
+
+	for params, peer := range up4.tunnelPeerIDs {
+		if release(peer.usedBy) && peer.usedBy.Cardinality() == 0 {
+			peer.idleSince = time.Now()
+			up4.tunnelPeerIDs[params] = peer
+		}
+	}
+
+	for _, app := range up4.applicationIDs {
+		release(app.usedBy)
+	}
+}
+
+// restoreInterfaces writes the N3 address and UE pool to the interfaces table, if missing or different in entries.
+func (up4 *UP4) restoreInterfaces(entries []*p4.TableEntry) error {
+	actual := make(map[string]string)
+
+	for _, entry := range entries {
+		if entry.TableId == p4constants.TablePreQosPipeInterfaces {
+			e := tableReconcileEntry(entry)
+			actual[e.key] = e.value
+		}
+	}
+
+	uePoolEntry, err := up4.p4RtTranslator.BuildInterfaceTableEntry(up4.ueIPPool, up4.conf.SliceID, true)
+	if err != nil {
+		return err
+	}
+
+	n3AddrEntry, err := up4.p4RtTranslator.BuildInterfaceTableEntry(up4.AccessIP, up4.conf.SliceID, false)
+	if err != nil {
+		return err
+	}
+
+	// like initInterfaces, missing entries are written in batch
+	updates := map[p4.Update_Type][]*p4.TableEntry{}
+
+	for _, entry := range []*p4.TableEntry{uePoolEntry, n3AddrEntry} {
+		expected := tableReconcileEntry(entry)
+
+		value, exists := actual[expected.key]
+
+		switch {
+		case !exists:
+			updates[p4.Update_INSERT] = append(updates[p4.Update_INSERT], entry)
+		case value != expected.value:
+			updates[p4.Update_MODIFY] = append(updates[p4.Update_MODIFY], entry)
+		}
+	}
+
+	for _, methodType := range []p4.Update_Type{p4.Update_INSERT, p4.Update_MODIFY} {
+		if len(updates[methodType]) == 0 {
+			continue
+		}
+
+		if err := up4.p4client.ApplyTableEntries(methodType, updates[methodType]...); err != nil {
+			return ErrOperationFailedWithReason("Interfaces initialization", err.Error())
+		}
+	}
+
+	return nil
+}
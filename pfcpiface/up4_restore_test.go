@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"net"
+	"testing"
+
+	"github.com/omec-project/upf-epc/internal/p4constants"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// newWarmStartTestEntries returns the entries written to UP4 for the uplink and downlink PDRs of a session,
+// and the session.
+func newWarmStartTestEntries(t *testing.T, tr *P4rtTranslator) ([]*p4.TableEntry, PFCPSession) {
+	entries, _, uplink := newMastershipTestEntries(t, tr)
+	rules := newSWTestRules()
+	downlink := rules.pdrs[1]
+	downlink.ctrID = 8
+
+	session, err := tr.BuildSessionsTableEntry(downlink, meter{downlinkCellID: 3}, 5, false)
+	require.NoError(t, err)
+
+	termination, err := tr.BuildTerminationsTableEntry(downlink, meter{downlinkCellID: 2}, rules.fars[1], DefaultApplicationID, 9, 0, rules.qers[0])
+	require.NoError(t, err)
+
+	rules.pdrs = []pdr{uplink, downlink}
+
+	return append(entries, session, termination), PFCPSession{localSEID: swTestFSEID, PacketForwardingRules: rules}
+}
+
+func Test_UP4_restoreIDPools(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	entries, params, uplink := newMastershipTestEntries(t, up4.p4RtTranslator)
+
+	restored := up4.restoreIDPools(entries)
+	require.Equal(t, restoredIDs{tunnelPeers: 1, applications: 1, meterCells: 2, counterCells: 1}, restored)
+
+	peer, exists := up4.getGTPTunnelPeer(params)
+	require.True(t, exists)
+	require.Equal(t, uint16(5), peer.id)
+	require.NotContains(t, up4.tunnelPeerIDsPool, uint16(5))
+
+	app, exists := up4.applicationIDs[toUP4ApplicationFilter(uplink)]
+	require.True(t, exists, "new PDRs with the same filter should reuse the application")
+	require.Equal(t, uint16(3), app.id)
+	require.NotContains(t, up4.applicationIDsPool, uint16(3))
+
+	require.False(t, up4.sessMeterCellIDsPool.Contains(uint32(2)))
+	require.False(t, up4.appMeterCellIDsPool.Contains(uint32(1)))
+	require.False(t, up4.counters[preQosCounterID].counterIDsPool.Contains(uint64(7)))
+
+	// restoring the same state again is a no-op
+	require.Equal(t, restoredIDs{}, up4.restoreIDPools(entries))
+	require.Len(t, up4.tunnelPeerIDsPool, int(up4.tunnelPeerIDsSize)-1)
+	require.Len(t, up4.applicationIDsPool, int(up4.applicationIDsSize)-1)
+}
+
+func Test_UP4_restoreIDPools_conflicts(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	entries, _ := newWarmStartTestEntries(t, up4.p4RtTranslator)
+
+	// the same ID is used by another tunnel peer
+	otherPeer, err := up4.p4RtTranslator.BuildGTPTunnelPeerTableEntry(5, tunnelParams{
+		tunnelIP4Src: ip2int(swTestN3Address),
+		tunnelIP4Dst: ip2int(net.ParseIP("198.18.0.20")),
+		tunnelPort:   tunnelGTPUPort,
+	})
+	require.NoError(t, err)
+
+	// a session uses a missing tunnel peer
+	downlink := newSWTestRules().pdrs[1]
+	downlink.ueAddress = ip2int(net.ParseIP("10.0.0.2"))
+
+	session, err := up4.p4RtTranslator.BuildSessionsTableEntry(downlink, meter{}, 6, false)
+	require.NoError(t, err)
+
+	restored := up4.restoreIDPools(append(entries, otherPeer, session))
+	require.Equal(t, 2, restored.conflicts)
+}
+
+func Test_UP4_initialize_warmStart(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	entries, session := newWarmStartTestEntries(t, up4.p4RtTranslator)
+
+	up4.upf = &upf{}
+	up4.upf.setSessionsSource(func() []PFCPSession { return []PFCPSession{session} })
+	up4.p4client = &P4rtClient{client: &mastershipTestClient{
+		entries: entries,
+		meters: []*p4.MeterEntry{
+			{MeterId: p4constants.MeterPreQosPipeAppMeter, Index: &p4.Index{Index: 3}, Config: &p4.MeterConfig{Cir: 1}},
+			{MeterId: p4constants.MeterPreQosPipeAppMeter, Index: &p4.Index{Index: 2}},
+		},
+	}}
+
+	require.NoError(t, up4.initialize(true))
+	require.True(t, up4.stateInitialized)
+
+	// the UE address is mapped to the F-SEID of the known session
+	ueAddr := ip2int(swTestUEAddress)
+	require.Equal(t, uint64(swTestFSEID), up4.ueAddrToFSEID[ueAddr])
+	require.Equal(t, ueAddr, up4.fseidToUEAddr[swTestFSEID])
+	require.Contains(t, up4.pdrCounters, uint32(8))
+
+	// the tunnel peer is used by the session, instead of the restored entries
+	peer, exists := up4.getGTPTunnelPeer(tunnelParams{
+		tunnelIP4Src: ip2int(swTestN3Address),
+		tunnelIP4Dst: ip2int(swTestGNBAddress),
+		tunnelPort:   tunnelGTPUPort,
+	})
+	require.True(t, exists)
+	require.Equal(t, []interface{}{tnlPeerReference{swTestFSEID, 2}}, peer.usedBy.ToSlice())
+
+	app := up4.applicationIDs[toUP4ApplicationFilter(session.pdrs[0])]
+	require.Equal(t, []interface{}{internalAppReference{swTestFSEID, 1}}, app.usedBy.ToSlice())
+
+	// configured meter cells are allocated, even if unused by the tables
+	require.False(t, up4.appMeterCellIDsPool.Contains(uint32(3)))
+	require.False(t, up4.appMeterCellIDsPool.Contains(uint32(2)))
+	require.False(t, up4.sessMeterCellIDsPool.Contains(uint32(3)))
+	require.False(t, up4.counters[preQosCounterID].counterIDsPool.Contains(uint64(8)))
+
+	// removing the session makes the tunnel peer idle
+	up4.removeGTPTunnelPeer(session.fars[1])
+
+	peer, _ = up4.getGTPTunnelPeer(tunnelParams{
+		tunnelIP4Src: ip2int(swTestN3Address),
+		tunnelIP4Dst: ip2int(swTestGNBAddress),
+		tunnelPort:   tunnelGTPUPort,
+	})
+	require.False(t, peer.idleSince.IsZero())
+}
+
+func Test_UP4_initialize_warmStartInconsistent(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	entries, _ := newWarmStartTestEntries(t, up4.p4RtTranslator)
+
+	// two terminations use the same counter cell
+	uplink := newSWTestRules().pdrs[0]
+	uplink.ueAddress = ip2int(net.ParseIP("10.0.0.2"))
+	uplink.ctrID = 7
+
+	termination, err := up4.p4RtTranslator.BuildTerminationsTableEntry(uplink, meter{}, newSWTestRules().fars[0], DefaultApplicationID, 9, 0, qer{})
+	require.NoError(t, err)
+
+	up4.p4client = &P4rtClient{client: &mastershipTestClient{entries: append(entries, termination)}}
+
+	require.Error(t, up4.initialize(true))
+	require.False(t, up4.stateInitialized, "the state should be restored again on the next connection")
+}
+
+func Test_UP4_releaseRestoredReferences(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	entries, _ := newWarmStartTestEntries(t, up4.p4RtTranslator)
+
+	require.Zero(t, up4.restoreIDPools(entries).conflicts)
+
+	params := tunnelParams{
+		tunnelIP4Src: ip2int(swTestN3Address),
+		tunnelIP4Dst: ip2int(swTestGNBAddress),
+		tunnelPort:   tunnelGTPUPort,
+	}
+
+	peer, _ := up4.getGTPTunnelPeer(params)
+	require.Equal(t, 1, peer.usedBy.Cardinality(), "the tunnel peer is used by the downlink session")
+
+	// the entries of the unknown session are removed by the reconciliation, terminations before applications
+	for i := len(entries) - 1; i >= 0; i-- {
+		up4.releaseRestoredReferences(entries[i])
+	}
+
+	peer, _ = up4.getGTPTunnelPeer(params)
+	require.Zero(t, peer.usedBy.Cardinality())
+	require.False(t, peer.idleSince.IsZero())
+	require.Empty(t, up4.applicationIDs)
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/internal/p4constants"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+func counterEntity(counterID uint32, index int64, packets, bytes int64) *p4.Entity {
+	return &p4.Entity{
+		Entity: &p4.Entity_CounterEntry{
+			CounterEntry: &p4.CounterEntry{
+				CounterId: counterID,
+				Index:     &p4.Index{Index: index},
+				Data:      &p4.CounterData{PacketCount: packets, ByteCount: bytes},
+			},
+		},
+	}
+}
+
+func Test_buildPDRCounterStats(t *testing.T) {
+	refs := []pdrCounterRef{
+		{ctrID: 1, fseid: 10, pdrID: 1, uplink: true, ueAddress: 0x0a000001},
+		{ctrID: 2, fseid: 10, pdrID: 2, uplink: false, ueAddress: 0x0a000001},
+		{ctrID: 3, fseid: 11, pdrID: 1, uplink: true},
+	}
+
+	entities := []*p4.Entity{
+		counterEntity(p4constants.CounterPreQosPipePreQosCounter, 1, 10, 1000),
+		counterEntity(p4constants.CounterPostQosPipePostQosCounter, 1, 8, 800),
+		counterEntity(p4constants.CounterPreQosPipePreQosCounter, 2, 5, 500),
+		counterEntity(p4constants.CounterPostQosPipePostQosCounter, 2, 5, 500),
+		// unrelated counter cell
+		counterEntity(p4constants.CounterPreQosPipePreQosCounter, 42, 1, 1),
+	}
+
+	stats := buildPDRCounterStats(refs, entities)
+	require.Len(t, stats, 2, "PDR without counter data should be skipped")
+
+	require.Equal(t, refs[0], stats[0].ref)
+	require.EqualValues(t, 10, stats[0].pre.GetPacketCount())
+	require.EqualValues(t, 800, stats[0].post.GetByteCount())
+	require.EqualValues(t, 2, stats[0].droppedPackets())
+
+	require.Equal(t, refs[1], stats[1].ref)
+	require.EqualValues(t, 0, stats[1].droppedPackets())
+}
+
+func Test_aggregatePortStats(t *testing.T) {
+	stats := []pdrCounterStats{
+		{
+			ref:  pdrCounterRef{ctrID: 1, uplink: true},
+			pre:  &p4.CounterData{PacketCount: 10, ByteCount: 1000},
+			post: &p4.CounterData{PacketCount: 8, ByteCount: 800},
+		},
+		{
+			ref:  pdrCounterRef{ctrID: 2, uplink: false},
+			pre:  &p4.CounterData{PacketCount: 5, ByteCount: 500},
+			post: &p4.CounterData{PacketCount: 4, ByteCount: 400},
+		},
+		{
+			// missing post-QoS data must not panic
+			ref: pdrCounterRef{ctrID: 3, uplink: false},
+			pre: &p4.CounterData{PacketCount: 1, ByteCount: 100},
+		},
+	}
+
+	require.Equal(t, []up4PortStats{
+		{iface: "Access", dir: "rx", packets: 10, bytes: 1000, dropped: 2},
+		{iface: "Access", dir: "tx", packets: 4, bytes: 400},
+		{iface: "Core", dir: "rx", packets: 6, bytes: 600, dropped: 2},
+		{iface: "Core", dir: "tx", packets: 8, bytes: 800},
+	}, aggregatePortStats(stats))
+}
+
+func newIDPoolsTestFAR(fseID uint64, gNB uint32) far {
+	return far{
+		farID: 2, fseID: fseID, applyAction: ActionForward, dstIntf: ie.DstInterfaceAccess,
+		tunnelType: 1, tunnelIP4Dst: gNB, tunnelTEID: 1, tunnelPort: tunnelGTPUPort,
+	}
+}
+
+func Test_UP4_addOrUpdateGTPTunnelPeer_reclaimIdle(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	up4.tunnelPeerIDsPool = up4.tunnelPeerIDsPool[:2]
+
+	reclaimed := getDatapathMetrics().up4TunnelPeersReclaimed
+	before := testutil.ToFloat64(reclaimed)
+
+	first, second, third := newIDPoolsTestFAR(1, 1), newIDPoolsTestFAR(2, 2), newIDPoolsTestFAR(3, 3)
+
+	batch := &up4Batch{}
+	require.NoError(t, up4.addOrUpdateGTPTunnelPeer(batch, first))
+	require.NoError(t, up4.addOrUpdateGTPTunnelPeer(batch, second))
+
+	// both tunnel peers are idle, the first one is the least recently used
+	up4.removeGTPTunnelPeer(first)
+	up4.removeGTPTunnelPeer(second)
+	require.Len(t, up4.tunnelPeerIDs, 2, "idle tunnel peers are kept")
+
+	firstParams := tunnelParams{tunnelIP4Src: ip2int(up4.AccessIP.IP), tunnelIP4Dst: 1, tunnelPort: tunnelGTPUPort}
+	firstPeer := up4.tunnelPeerIDs[firstParams]
+	firstPeer.idleSince = firstPeer.idleSince.Add(-time.Second)
+	up4.tunnelPeerIDs[firstParams] = firstPeer
+
+	batch = &up4Batch{}
+	require.NoError(t, up4.addOrUpdateGTPTunnelPeer(batch, third))
+	require.Equal(t, before+1, testutil.ToFloat64(reclaimed))
+	require.Len(t, batch.updates, 1)
+	require.Equal(t, p4.Update_MODIFY, batch.updates[0].Type, "the entry of the reclaimed tunnel peer is overwritten")
+
+	peer, exists := up4.getGTPTunnelPeer(tunnelParams{tunnelIP4Src: ip2int(up4.AccessIP.IP), tunnelIP4Dst: 3, tunnelPort: tunnelGTPUPort})
+	require.True(t, exists)
+	require.Equal(t, firstPeer.id, peer.id)
+
+	// a failed write gives the ID back to the reclaimed tunnel peer
+	batch.revert()
+
+	peer, exists = up4.getGTPTunnelPeer(firstParams)
+	require.True(t, exists)
+	require.Equal(t, firstPeer.id, peer.id)
+	require.Len(t, up4.tunnelPeerIDs, 2)
+}
+
+func Test_UP4_addOrUpdateGTPTunnelPeer_exhausted(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+	up4.tunnelPeerIDsPool = up4.tunnelPeerIDsPool[:1]
+
+	exhausted := getDatapathMetrics().up4IDPoolExhausted.WithLabelValues(idPoolTunnelPeers)
+	before := testutil.ToFloat64(exhausted)
+
+	require.NoError(t, up4.addOrUpdateGTPTunnelPeer(&up4Batch{}, newIDPoolsTestFAR(1, 1)))
+
+	err := up4.addOrUpdateGTPTunnelPeer(&up4Batch{}, newIDPoolsTestFAR(2, 2))
+	require.ErrorIs(t, err, errNoResources)
+	require.Equal(t, uint8(ie.CauseNoResourcesAvailable), datapathErrorCause(err))
+	require.Equal(t, before+1, testutil.ToFloat64(exhausted))
+}
+
+func Test_UP4_addOrUpdateGTPTunnelPeer_handover(t *testing.T) {
+	up4 := newMastershipTestUP4(t)
+
+	require.NoError(t, up4.addOrUpdateGTPTunnelPeer(&up4Batch{}, newIDPoolsTestFAR(1, 1)))
+	// the UE moves to another base station
+	require.NoError(t, up4.addOrUpdateGTPTunnelPeer(&up4Batch{}, newIDPoolsTestFAR(1, 2)))
+
+	peer, exists := up4.getGTPTunnelPeer(tunnelParams{tunnelIP4Src: ip2int(up4.AccessIP.IP), tunnelIP4Dst: 1, tunnelPort: tunnelGTPUPort})
+	require.True(t, exists)
+	require.Zero(t, peer.usedBy.Cardinality(), "the old tunnel peer should be idle")
+	require.False(t, peer.idleSince.IsZero())
+}
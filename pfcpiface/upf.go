@@ -4,8 +4,11 @@
 package pfcpiface
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Showmax/go-fqdn"
@@ -36,31 +39,63 @@ type UeResource struct {
 }
 
 type upf struct {
-	EnableUeIPAlloc    bool `json:"enableueipalloc"`
-	EnableEndMarker    bool `json:"enableendmarker"`
-	EnableFlowMeasure  bool
-	accessIface        string
-	coreIface          string
-	ippoolCidr         string
-	AccessIP           net.IP `json:"accessip"`
-	CoreIP             net.IP `json:"coreip"`
-	NodeID             string `json:"nodeid"`
-	gwIP               string
-	ippool             *IPPool
-	peers              []string
-	accessGwRegistered bool
-	coreGwRegistered   bool
-	Dnn                string `json:"dnn"`
-	reportNotifyChan   chan uint64
-	sliceInfo          *SliceInfo
-	readTimeout        time.Duration
-	Hostname           string `json:"hostname"`
+	EnableUeIPAlloc   bool `json:"enableueipalloc"`
+	EnableEndMarker   bool `json:"enableendmarker"`
+	EnableFlowMeasure bool
+	accessIface       string
+	coreIface         string
+	ippoolCidr        string
+	AccessIP          net.IP `json:"accessip"`
+	CoreIP            net.IP `json:"coreip"`
+	NodeID            string `json:"nodeid"`
+	gwIP              string
+	ippool            *IPPool
+	peers             []string
+	Dnn               string `json:"dnn"`
+	reportNotifyChan  chan uint64
+	sliceInfo         *SliceInfo
+	readTimeout       time.Duration
+	Hostname          string `json:"hostname"`
 	datapath
 	maxReqRetries uint8
 	respTimeout   time.Duration
 	enableHBTimer bool
 	hbInterval    time.Duration
 	ueransim      bool
+
+	// base URLs of the load balancers, see LBConf
+	enterLBURL string
+	exitLBURL  string
+	pfcpLBURL  string
+
+	// sessionsMu guards sessionsSource
+	sessionsMu sync.RWMutex
+	// sessionsSource returns a snapshot of all PFCP sessions installed in the datapath.
+	// It is set by the PFCPNode and used by datapaths to replay their state.
+	sessionsSource func() []PFCPSession
+	// rulesMu is read-locked from the commit of the rules of a session to the update of the
+	// session store, and write-locked by the reconciliation, which must see both agree.
+	rulesMu sync.RWMutex
+
+	// load is reported to the PFCP-LB and advertised to the SMF
+	load *loadTracker
+	// sessionHooks are notified of the lifecycle of the PFCP sessions
+	sessionHooks sessionHooks
+	// lbClient sends the requests to the Enter-LB and Exit-LB
+	lbClient lbClient
+	// lbDisabled is set for a shadow UPF, which neither registers to the LBs nor pushes them
+	// its UEs, as its datapath doesn't forward the traffic they would send it
+	lbDisabled bool
+	// lbSources are the networks the LBs announce their gateway from, any if empty
+	lbSources []*net.IPNet
+	// lbTLS is the TLS config of the HTTP client of the LBs, the default one if nil
+	lbTLS *tls.Config
+	// accessNet and coreNet are the networks of the access and core interfaces, nil if unknown
+	accessNet *net.IPNet
+	coreNet   *net.IPNet
+
+	// draining is set atomically once the UPF is drained, rejecting new sessions
+	draining int32
 }
 
 // to be replaced with go-pfcp structs
@@ -83,6 +118,33 @@ func (u *upf) isConnected() bool {
 	return u.datapath.IsConnected(&u.AccessIP)
 }
 
+func (u *upf) setSessionsSource(source func() []PFCPSession) {
+	u.sessionsMu.Lock()
+	defer u.sessionsMu.Unlock()
+
+	u.sessionsSource = source
+}
+
+func (u *upf) setDraining() {
+	atomic.StoreInt32(&u.draining, 1)
+}
+
+func (u *upf) isDraining() bool {
+	return atomic.LoadInt32(&u.draining) == 1
+}
+
+// allSessions returns all PFCP sessions known to the UPF, across all PFCP connections.
+func (u *upf) allSessions() []PFCPSession {
+	u.sessionsMu.RLock()
+	defer u.sessionsMu.RUnlock()
+
+	if u.sessionsSource == nil {
+		return nil
+	}
+
+	return u.sessionsSource()
+}
+
 func (u *upf) addSliceInfo(sliceInfo *SliceInfo) error {
 	if sliceInfo == nil {
 		return ErrInvalidArgument("sliceInfo", sliceInfo)
@@ -134,6 +196,9 @@ func NewUPF(conf *Conf, fp datapath) *upf {
 		readTimeout:       time.Second * time.Duration(conf.ReadTimeout),
 		Hostname:          conf.CPIface.NodeID,
 		ueransim:          conf.Ueransim,
+		enterLBURL:        valueOrDefault(conf.LoadBalancers.EnterLBURL, enterLBURLDefault),
+		exitLBURL:         valueOrDefault(conf.LoadBalancers.ExitLBURL, exitLBURLDefault),
+		pfcpLBURL:         valueOrDefault(conf.LoadBalancers.PFCPLBURL, pfcpLBURLDefault),
 	}
 
 	if len(conf.CPIface.Peers) > 0 {
@@ -146,18 +211,25 @@ func NewUPF(conf *Conf, fp datapath) *upf {
 	}
 
 	if !conf.EnableP4rt {
-		u.AccessIP, err = GetUnicastAddressFromInterface(conf.AccessIface.IfName)
-
+		u.accessNet, err = GetUnicastNetworkFromInterface(conf.AccessIface.IfName)
 		if err != nil {
 			log.Errorln(err)
 			return nil
 		}
 
-		u.CoreIP, err = GetUnicastAddressFromInterface(conf.CoreIface.IfName)
+		u.coreNet, err = GetUnicastNetworkFromInterface(conf.CoreIface.IfName)
 		if err != nil {
 			log.Errorln(err)
 			return nil
 		}
+
+		u.AccessIP, u.CoreIP = u.accessNet.IP, u.coreNet.IP
+	}
+
+	// validated with the config
+	u.lbSources, _ = parseSources(conf.LoadBalancers.AllowedSources)
+	if len(u.lbSources) == 0 {
+		log.Warnln("load_balancers.allowed_sources is empty, any host can register a gateway")
 	}
 
 	u.respTimeout, err = time.ParseDuration(conf.RespTimeout)
@@ -181,6 +253,21 @@ func NewUPF(conf *Conf, fp datapath) *upf {
 		}
 	}
 
+	u.load = newLoadTracker(u, conf)
+
+	u.lbTLS, err = clientTLSConfig(conf.HTTP.TLS)
+	if err != nil {
+		log.Fatalln("Failed to load the TLS config of the load balancers client:", err)
+	}
+
+	u.lbClient, err = newLBClient(u, conf.LoadBalancers)
+	if err != nil {
+		log.Fatalln("Failed to create the client of the load balancers:", err)
+	}
+
+	u.lbDisabled = datapathName(*conf) == modeShadow
+	u.sessionHooks = newSessionHooks(u, conf.SessionHooks)
+
 	u.datapath.SetUpfInfo(u, conf)
 	fmt.Println("upf info :")
 	fmt.Println("dnn = ", u.Dnn)
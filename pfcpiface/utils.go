@@ -80,6 +80,17 @@ func hex2int(hexStr string) uint32 {
 	return uint32(result)
 }
 
+// bytesToUint64 converts a big-endian byte string of up to 8 bytes, like P4Runtime values.
+func bytesToUint64(b []byte) uint64 {
+	var n uint64
+
+	for _, v := range b {
+		n = n<<8 | uint64(v)
+	}
+
+	return n
+}
+
 func int2ip(nn uint32) net.IP {
 	ip := make(net.IP, 4)
 	binary.BigEndian.PutUint32(ip, nn)
@@ -95,6 +106,15 @@ func maxUint64(x, y uint64) uint64 {
 	return x
 }
 
+// valueOrDefault returns value, or defaultValue if value is empty.
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
 // Returns the bandwidth delay product for a given rate in kbps and duration in ms.
 func calcBurstSizeFromRate(kbps uint64, ms uint64) uint64 {
 	return uint64((float64(kbps) * 1000 / 8) * (float64(ms) / 1000))
@@ -114,6 +134,17 @@ func MustParseStrIP(address string) *net.IPNet {
 
 // GetUnicastAddressFromInterface returns a unicast IP address configured on the interface.
 func GetUnicastAddressFromInterface(interfaceName string) (net.IP, error) {
+	network, err := GetUnicastNetworkFromInterface(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return network.IP, nil
+}
+
+// GetUnicastNetworkFromInterface returns the first address of interfaceName, with the mask of
+// its network.
+func GetUnicastNetworkFromInterface(interfaceName string) (*net.IPNet, error) {
 	iface, err := net.InterfaceByName(interfaceName)
 	if err != nil {
 		return nil, err
@@ -124,12 +155,16 @@ func GetUnicastAddressFromInterface(interfaceName string) (net.IP, error) {
 		return nil, err
 	}
 
-	ip, _, err := net.ParseCIDR(addresses[0].String())
+	if len(addresses) == 0 {
+		return nil, ErrNotFoundWithParam("address", "interface", interfaceName)
+	}
+
+	ip, network, err := net.ParseCIDR(addresses[0].String())
 	if err != nil {
 		return nil, err
 	}
 
-	return ip, nil
+	return &net.IPNet{IP: ip, Mask: network.Mask}, nil
 }
 
 func GetSliceTCMeterIndex(sliceID uint8, TC uint8) (int64, error) {
@@ -5,12 +5,13 @@ package pfcpiface
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
 	"os/exec"
-	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -41,21 +42,40 @@ type ConfigHandler struct {
 	upf *upf
 }
 type RegisterGw struct {
-	upf *upf
+	upf       *upf
+	registrar *lbRegistrar
 }
 
-func setupConfigHandler(mux *http.ServeMux, upf *upf) {
+func setupConfigHandler(mux *http.ServeMux, upf *upf, registrar *lbRegistrar) {
 	cfgHandler := ConfigHandler{upf: upf}
 	mux.Handle("/v1/config/network-slices", &cfgHandler)
-	registerGw := RegisterGw{upf: upf}
+	registerGw := RegisterGw{upf: upf, registrar: registrar}
 	mux.Handle("/registergw", &registerGw)
 }
 
+// execCommand creates the commands configuring the routes and ARP entries of the gateways,
+// replaced in tests.
+var execCommand = exec.Command
+
+const (
+	// registerGwMaxBodySize bounds the body of the requests to /registergw.
+	registerGwMaxBodySize = 1024
+	// gatewayPrefixLenDefault is the prefix length of the networks of the access and core
+	// interfaces when they are unknown, e.g. with UP4.
+	gatewayPrefixLenDefault = 24
+)
+
 type GWRegisterReq struct {
 	GwIP  string `json:"gwip"`
 	GwMac string `json:"gwmac"`
 }
 
+// httpErrorResp is the body of the responses to the failed requests.
+type httpErrorResp struct {
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
 func (registerGw *RegisterGw) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Infoln("handle http request for /registergw")
 
@@ -63,29 +83,21 @@ func (registerGw *RegisterGw) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	case "PUT":
 		fallthrough
 	case "POST":
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			log.Errorln("http req read body failed.")
-			sendHTTPResp(http.StatusBadRequest, w)
-		}
-
-		log.Traceln(string(body))
-
 		var registerReq GWRegisterReq
 
-		err = json.Unmarshal(body, &registerReq)
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, registerGwMaxBodySize)).Decode(&registerReq)
 		if err != nil {
-			log.Errorln("Json unmarshal failed for http request")
-			sendHTTPResp(http.StatusBadRequest, w)
-		}
+			log.Errorln("Json unmarshal failed for http request:", err)
+			sendHTTPError(w, ErrInvalidArgumentWithReason("body", "", err.Error()))
 
-		err = registerGw.handleRegisterGW(registerReq)
-		if err != nil {
-			log.Errorln("handle gw register req failed")
-			sendHTTPResp(http.StatusInternalServerError, w)
+			return
 		}
-		if registerReq.GwIP == registerGw.upf.gwIP {
-			go PushPFCPInfoNew(registerGw.upf)
+
+		if _, err := registerGw.announceGateway(registerReq, remoteIP(r.RemoteAddr)); err != nil {
+			log.Errorln("handle gw register req failed:", err)
+			sendHTTPError(w, err)
+
+			return
 		}
 
 		sendHTTPResp(http.StatusCreated, w)
@@ -93,64 +105,165 @@ func (registerGw *RegisterGw) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		log.Infoln(w, "Sorry, only PUT and POST methods are supported.")
 		sendHTTPResp(http.StatusMethodNotAllowed, w)
 	}
+}
+
+// remoteIP returns the IP of the host:port addr of a peer, nil if invalid.
+func remoteIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+
+	return net.ParseIP(host)
+}
+
+// parseSources parses the IP addresses and CIDR networks of sources.
+func parseSources(sources []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(sources))
+
+	for _, s := range sources {
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, ErrInvalidArgumentWithReason("conf.LoadBalancers.AllowedSources", s, "invalid IP address or CIDR")
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// announceGateway configures the gateway announced by an LB from source on the HTTP or the gRPC
+// API, and returns the interface it serves.
+func (registerGw *RegisterGw) announceGateway(registerReq GWRegisterReq, source net.IP) (string, error) {
+	if !registerGw.allowed(source) {
+		return "", ErrForbidden("gateway announcement from", source)
+	}
+
+	gwIP, gwMac, iface, err := registerGw.parseGateway(registerReq)
+	if err != nil {
+		return "", err
+	}
 
+	if err := registerGw.handleRegisterGW(gwIP, gwMac, iface); err != nil {
+		return "", ErrOperationFailedWithReason("gateway configuration", err.Error())
+	}
+
+	registerGw.registrar.gatewayLearned(iface, gwIP.String())
+
+	return iface, nil
 }
 
-func (registerGw *RegisterGw) handleRegisterGW(registerReq GWRegisterReq) error {
+// allowed returns true if the LBs can announce their gateway from source.
+func (registerGw *RegisterGw) allowed(source net.IP) bool {
+	if len(registerGw.upf.lbSources) == 0 {
+		return true
+	}
+
+	for _, network := range registerGw.upf.lbSources {
+		if source != nil && network.Contains(source) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// interfaceNetwork returns the network of the access or core interface with address ip.
+func interfaceNetwork(network *net.IPNet, ip net.IP) *net.IPNet {
+	if network != nil {
+		return network
+	}
+
+	if ip.To4() == nil {
+		return nil
+	}
 
-	var cmd *exec.Cmd
+	mask := net.CIDRMask(gatewayPrefixLenDefault, 8*net.IPv4len)
 
-	reqGwOctets := strings.Split(registerReq.GwIP, ".")
-	reqThirdOctet := reqGwOctets[2]
+	return &net.IPNet{IP: ip.To4().Mask(mask), Mask: mask}
+}
 
-	accessGwOctets := strings.Split(registerGw.upf.AccessIP.String(), ".")
-	accessThirdOctet := accessGwOctets[2]
+// parseGateway validates registerReq, and returns the gateway IP and MAC addresses and the
+// interface whose network the gateway belongs to.
+func (registerGw *RegisterGw) parseGateway(registerReq GWRegisterReq) (net.IP, net.HardwareAddr, string, error) {
+	gwIP := net.ParseIP(registerReq.GwIP).To4()
+	if gwIP == nil || !gwIP.IsGlobalUnicast() {
+		return nil, nil, "", ErrInvalidArgumentWithReason("gwip", registerReq.GwIP, "not a unicast IPv4 address")
+	}
 
-	coreGwOctets := strings.Split(registerGw.upf.CoreIP.String(), ".")
-	coreThirdOctet := coreGwOctets[2]
+	gwMac, err := net.ParseMAC(registerReq.GwMac)
+	if err != nil || len(gwMac) != 6 {
+		return nil, nil, "", ErrInvalidArgumentWithReason("gwmac", registerReq.GwMac, "not an EUI-48 MAC address")
+	}
 
-	var iface string
+	if gwMac[0]&1 == 1 {
+		return nil, nil, "", ErrInvalidArgumentWithReason("gwmac", registerReq.GwMac, "not a unicast MAC address")
+	}
 
-	switch reqThirdOctet {
-	case accessThirdOctet:
-		iface = "access"
-	case coreThirdOctet:
-		iface = "core"
+	upf := registerGw.upf
+
+	for _, i := range []struct {
+		name    string
+		ip      net.IP
+		network *net.IPNet
+	}{
+		{"access", upf.AccessIP, interfaceNetwork(upf.accessNet, upf.AccessIP)},
+		{"core", upf.CoreIP, interfaceNetwork(upf.coreNet, upf.CoreIP)},
+	} {
+		if i.network == nil || !i.network.Contains(gwIP) {
+			continue
+		}
+
+		if gwIP.Equal(i.ip) {
+			return nil, nil, "", ErrInvalidArgumentWithReason("gwip", registerReq.GwIP, "address of the "+i.name+" interface")
+		}
+
+		return gwIP, gwMac, i.name, nil
 	}
-	accessGwip := fmt.Sprint("192.168.252.", reqGwOctets[3])
-	coreGwip := fmt.Sprint("192.168.250.", reqGwOctets[3])
+
+	return nil, nil, "", ErrInvalidArgumentWithReason("gwip", registerReq.GwIP, "not in the access or core network")
+}
+
+// handleRegisterGW configures gwIP, with MAC address gwMac, as the gateway of iface.
+func (registerGw *RegisterGw) handleRegisterGW(gwIP net.IP, gwMac net.HardwareAddr, iface string) error {
 	if registerGw.upf.ueransim {
-		addAccessRoute := exec.Command("ip", "route", "replace", "192.168.251.0/24", "via", accessGwip)
-		fmt.Println(addAccessRoute.String())
-		accesscombinedOutput, err := addAccessRoute.CombinedOutput()
-		if err != nil {
-			fmt.Printf("Error executing command: %v\nCombined Output: %s", cmd.String(), accesscombinedOutput)
+		accessGwip := fmt.Sprint("192.168.252.", gwIP[3])
+		coreGwip := fmt.Sprint("192.168.250.", gwIP[3])
+
+		addAccessRoute := execCommand("ip", "route", "replace", "192.168.251.0/24", "via", accessGwip)
+		if output, err := addAccessRoute.CombinedOutput(); err != nil {
+			log.Errorf("Error executing command: %v\nCombined Output: %s", addAccessRoute.String(), output)
 			return err
 		}
 
-		addCoreRoute := exec.Command("ip", "route", "replace", "192.168.200.0/24", "via", coreGwip)
-		corecombinedOutput, err := addCoreRoute.CombinedOutput()
-		if err != nil {
-			fmt.Printf("Error executing command: %v\nCombined Output: %s", cmd.String(), corecombinedOutput)
+		addCoreRoute := execCommand("ip", "route", "replace", "192.168.200.0/24", "via", coreGwip)
+		if output, err := addCoreRoute.CombinedOutput(); err != nil {
+			log.Errorf("Error executing command: %v\nCombined Output: %s", addCoreRoute.String(), output)
 			return err
 		}
 	}
-	cmd = exec.Command("arp", "-s", registerReq.GwIP, registerReq.GwMac, "-i", iface)
-	combinedOutput, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("Error executing command: %v\nCombined Output: %s", cmd.String(), combinedOutput)
+
+	cmd := execCommand("arp", "-s", gwIP.String(), gwMac.String(), "-i", iface)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Errorf("Error executing command: %v\nCombined Output: %s", cmd.String(), output)
 		return err
 	}
 
-	switch iface {
-	case "access":
-		registerGw.upf.accessGwRegistered = true
-	case "core":
-		registerGw.upf.coreGwRegistered = true
-	}
-	log.Traceln("static arp applied successfully for ip : ", registerReq.GwIP)
-	return nil
+	log.Traceln("static arp applied successfully for ip : ", gwIP)
 
+	return nil
 }
 
 func (c *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -184,6 +297,33 @@ func (c *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// sendHTTPError replies to a failed request with the status of err: 400 Bad Request for an
+// invalid argument, 401 Unauthorized, 403 Forbidden, or 500 Internal Server Error.
+func sendHTTPError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, errInvalidArgument):
+		status = http.StatusBadRequest
+	case errors.Is(err, errUnauthenticated):
+		status = http.StatusUnauthorized
+	case errors.Is(err, errForbidden):
+		status = http.StatusForbidden
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	resp := httpErrorResp{
+		Message: http.StatusText(status),
+		Error:   err.Error(),
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorln("http response write failed : ", err)
+	}
+}
+
 func sendHTTPResp(status int, w http.ResponseWriter) {
 	w.WriteHeader(status)
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022-present Open Networking Foundation
+
+package pfcpiface
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/stretchr/testify/require"
+)
+
+// recordCommands replaces the commands run by the UPF by no-ops, and returns the commands run.
+func recordCommands(t testing.TB) func() []string {
+	var (
+		mu       sync.Mutex
+		commands []string
+	)
+
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		mu.Lock()
+		defer mu.Unlock()
+
+		commands = append(commands, strings.Join(append([]string{name}, arg...), " "))
+
+		return exec.Command("true")
+	}
+
+	t.Cleanup(func() { execCommand = exec.Command })
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), commands...)
+	}
+}
+
+func Test_RegisterGw_handshake(t *testing.T) {
+	commands := recordCommands(t)
+
+	u := &upf{
+		AccessIP: net.ParseIP("192.168.252.3"),
+		CoreIP:   net.ParseIP("192.168.250.3"),
+		NodeID:   "upf-0",
+		gwIP:     lbTestGwIP,
+	}
+	lbs := startTestLBs(t, u)
+
+	r := newLBRegistrar(u, RegisterReq{GwIP: u.gwIP, Hostname: u.NodeID}, 0)
+
+	mux := http.NewServeMux()
+	setupConfigHandler(mux, u, r)
+
+	upfServer := httptest.NewServer(mux)
+	defer upfServer.Close()
+
+	// once the UPF is registered, the LBs configure themselves as gateways of its interfaces
+	lbs.enter.SetGateway(upfServer.URL, &fake_lb.GatewayRegistration{GwIP: "192.168.252.1", GwMac: "00:00:00:00:00:02"})
+	lbs.exit.SetGateway(upfServer.URL, &fake_lb.GatewayRegistration{GwIP: lbTestGwIP, GwMac: "00:00:00:00:00:03"})
+
+	r.Start()
+	r.setServing()
+
+	defer r.Stop()
+
+	// the UPF registers to the PFCP-LB once both gateways are known
+	require.Eventually(t, func() bool { return r.getState() == lbReady }, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, lbs.pfcp.GetRegistrations(), 1)
+
+	require.Equal(t, lbRegistrationStatus{
+		State:         "ready",
+		AccessGateway: "192.168.252.1",
+		CoreGateway:   lbTestGwIP,
+	}, r.status())
+	require.ElementsMatch(t, []string{
+		"arp -s 192.168.252.1 00:00:00:00:00:02 -i access",
+		"arp -s 192.168.250.1 00:00:00:00:00:03 -i core",
+	}, commands())
+
+	registration := lbs.pfcp.GetRegistrations()[0]
+	require.Equal(t, "upf-0", registration.UPF["nodeid"])
+	require.Equal(t, "192.168.252.3", registration.UPF["accessip"])
+	require.Equal(t, "192.168.250.3", registration.UPF["coreip"])
+}
+
+func Test_RegisterGw_methodNotAllowed(t *testing.T) {
+	mux := http.NewServeMux()
+	setupConfigHandler(mux, &upf{}, newLBRegistrar(&upf{}, RegisterReq{}, 0))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/registergw", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+// newTestRegisterGw returns the /registergw handler of a UPF with an access network of unknown
+// prefix length, and a /23 core network.
+func newTestRegisterGw() *RegisterGw {
+	u := &upf{
+		AccessIP: net.ParseIP("192.168.252.3"),
+		CoreIP:   net.ParseIP("192.168.250.3"),
+		coreNet:  &net.IPNet{IP: net.ParseIP("192.168.250.3"), Mask: net.CIDRMask(23, 32)},
+	}
+
+	return &RegisterGw{upf: u, registrar: newLBRegistrar(u, RegisterReq{}, 0)}
+}
+
+func postRegisterGw(registerGw *RegisterGw, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	registerGw.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/registergw", strings.NewReader(body)))
+
+	return rec
+}
+
+func Test_RegisterGw_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		commands []string
+	}{
+		{
+			name:     "access gateway",
+			body:     `{"gwip": "192.168.252.1", "gwmac": "00:00:00:00:00:02"}`,
+			status:   http.StatusCreated,
+			commands: []string{"arp -s 192.168.252.1 00:00:00:00:00:02 -i access"},
+		},
+		{
+			name:     "core gateway resolved by the network of the interface",
+			body:     `{"gwip": "192.168.251.1", "gwmac": "00:00:00:00:00:03"}`,
+			status:   http.StatusCreated,
+			commands: []string{"arp -s 192.168.251.1 00:00:00:00:00:03 -i core"},
+		},
+		{name: "invalid JSON", body: `{"gwip": `, status: http.StatusBadRequest},
+		{name: "body too large", body: `{"gwip": "` + strings.Repeat("1", 2048) + `"}`, status: http.StatusBadRequest},
+		{name: "truncated IP", body: `{"gwip": "192.168.252", "gwmac": "00:00:00:00:00:02"}`, status: http.StatusBadRequest},
+		{name: "IPv6", body: `{"gwip": "2001:db8::1", "gwmac": "00:00:00:00:00:02"}`, status: http.StatusBadRequest},
+		{name: "outside the networks", body: `{"gwip": "10.0.0.1", "gwmac": "00:00:00:00:00:02"}`, status: http.StatusBadRequest},
+		{name: "address of the UPF", body: `{"gwip": "192.168.252.3", "gwmac": "00:00:00:00:00:02"}`, status: http.StatusBadRequest},
+		{name: "arp option as MAC", body: `{"gwip": "192.168.252.1", "gwmac": "-d"}`, status: http.StatusBadRequest},
+		{name: "multicast MAC", body: `{"gwip": "192.168.252.1", "gwmac": "01:00:5e:00:00:01"}`, status: http.StatusBadRequest},
+		{name: "EUI-64 MAC", body: `{"gwip": "192.168.252.1", "gwmac": "00:00:00:00:00:00:00:02"}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := recordCommands(t)
+			registerGw := newTestRegisterGw()
+
+			rec := postRegisterGw(registerGw, tt.body)
+			require.Equal(t, tt.status, rec.Code)
+			require.Equal(t, tt.commands, commands())
+
+			if tt.status != http.StatusCreated {
+				var resp httpErrorResp
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+				require.Equal(t, http.StatusText(tt.status), resp.Message)
+				require.NotEmpty(t, resp.Error)
+
+				require.Equal(t, lbRegistrationStatus{State: "unregistered"}, registerGw.registrar.status())
+			}
+		})
+	}
+}
+
+func Test_RegisterGw_allowedSources(t *testing.T) {
+	commands := recordCommands(t)
+
+	registerGw := newTestRegisterGw()
+
+	var err error
+	registerGw.upf.lbSources, err = parseSources([]string{"198.51.100.7", "192.0.2.0/24"})
+	require.NoError(t, err)
+
+	body := `{"gwip": "192.168.252.1", "gwmac": "00:00:00:00:00:02"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/registergw", strings.NewReader(body))
+	req.RemoteAddr = "198.51.100.8:41000"
+
+	rec := httptest.NewRecorder()
+	registerGw.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Empty(t, commands())
+
+	// httptest requests are sent from 192.0.2.1
+	require.Equal(t, http.StatusCreated, postRegisterGw(registerGw, body).Code)
+	require.Equal(t, "192.168.252.1", registerGw.registrar.status().AccessGateway)
+}
+
+func Test_RegisterGw_commandFailure(t *testing.T) {
+	execCommand = func(name string, arg ...string) *exec.Cmd { return exec.Command("false") }
+	t.Cleanup(func() { execCommand = exec.Command })
+
+	registerGw := newTestRegisterGw()
+
+	rec := postRegisterGw(registerGw, `{"gwip": "192.168.252.1", "gwmac": "00:00:00:00:00:02"}`)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Empty(t, registerGw.registrar.status().AccessGateway)
+}
+
+func FuzzRegisterGw_ServeHTTP(f *testing.F) {
+	commands := recordCommands(f)
+
+	for _, seed := range []string{
+		`{"gwip": "192.168.252.1", "gwmac": "00:00:00:00:00:02"}`,
+		`{"gwip": "192.168.251.1", "gwmac": "0000.0000.0003"}`,
+		`{"gwip": "192.168.252", "gwmac": "-d"}`,
+		`{"gwip": "::ffff:192.168.252.1", "gwmac": "00-00-00-00-00-02"}`,
+		`{"gwip": 1}`,
+		``,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, body string) {
+		before := len(commands())
+
+		rec := postRegisterGw(newTestRegisterGw(), body)
+		require.Contains(t, []int{http.StatusCreated, http.StatusBadRequest}, rec.Code)
+
+		// only valid gateways reach the neighbor table
+		for _, cmd := range commands()[before:] {
+			args := strings.Fields(cmd)
+			require.Len(t, args, 6, cmd)
+			require.NotNil(t, net.ParseIP(args[2]).To4(), cmd)
+
+			_, err := net.ParseMAC(args[3])
+			require.NoError(t, err, cmd)
+		}
+	})
+}
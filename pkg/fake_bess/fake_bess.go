@@ -4,15 +4,21 @@
 package fake_bess
 
 import (
+	"encoding/binary"
 	"fmt"
 	"github.com/omec-project/upf-epc/pfcpiface/bess_pb"
 	"google.golang.org/grpc"
 	"net"
+	"sync"
 )
 
 type FakeBESS struct {
 	grpcServer *grpc.Server
 	service    *fakeBessService
+
+	socketsMu       sync.Mutex
+	notifySocket    *unixPacketSocket
+	endMarkerSocket *unixPacketSocket
 }
 
 // NewFakeBESS creates a new fake BESS gRPC server. Its modules can be programmed in the same way
@@ -42,9 +48,86 @@ func (b *FakeBESS) Run(address string) error {
 	return nil
 }
 
-// Stop the BESS gRPC server.
+// Stop the BESS gRPC server and close the notify and end marker sockets, if any.
 func (b *FakeBESS) Stop() {
 	b.grpcServer.Stop()
+
+	b.socketsMu.Lock()
+	defer b.socketsMu.Unlock()
+
+	if b.notifySocket != nil {
+		b.notifySocket.close()
+		b.notifySocket = nil
+	}
+
+	if b.endMarkerSocket != nil {
+		b.endMarkerSocket.close()
+		b.endMarkerSocket = nil
+	}
+}
+
+// ListenNotifySocket listens on the unixpacket socket at path (e.g. /tmp/notifycp), to which the
+// PFCP agent connects to receive the Downlink Data Notifications sent by NotifyDownlinkData.
+func (b *FakeBESS) ListenNotifySocket(path string) (err error) {
+	b.socketsMu.Lock()
+	defer b.socketsMu.Unlock()
+
+	b.notifySocket, err = listenUnixPacketSocket(path)
+
+	return
+}
+
+// ListenEndMarkerSocket listens on the unixpacket socket at path (e.g. /tmp/pfcpport), to which
+// the PFCP agent connects to send end marker packets. See GetEndMarkers.
+func (b *FakeBESS) ListenEndMarkerSocket(path string) (err error) {
+	b.socketsMu.Lock()
+	defer b.socketsMu.Unlock()
+
+	b.endMarkerSocket, err = listenUnixPacketSocket(path)
+
+	return
+}
+
+// NotifyDownlinkData notifies the PFCP agent of a downlink packet buffered for the session
+// with the given F-SEID, in the same way as BESS does. It fails if no PFCP agent is connected
+// to the notify socket.
+func (b *FakeBESS) NotifyDownlinkData(fseid uint64) error {
+	b.socketsMu.Lock()
+	defer b.socketsMu.Unlock()
+
+	if b.notifySocket == nil {
+		return fmt.Errorf("notify socket: %w", errNoPeer)
+	}
+
+	msg := make([]byte, 8)
+	binary.LittleEndian.PutUint64(msg, fseid)
+
+	return b.notifySocket.send(msg)
+}
+
+// GetEndMarkers returns the end marker packets sent by the PFCP agent, in order of arrival.
+func (b *FakeBESS) GetEndMarkers() [][]byte {
+	b.socketsMu.Lock()
+	defer b.socketsMu.Unlock()
+
+	if b.endMarkerSocket == nil {
+		return nil
+	}
+
+	return b.endMarkerSocket.getReceived()
+}
+
+// SetPortStats sets the counters of the port with the given name (e.g. accessFast), as reported
+// by GetPortStats. GetPortStats fails for the ports without counters.
+func (b *FakeBESS) SetPortStats(name string, inc, out *bess_pb.GetPortStatsResponse_Stat) {
+	b.service.SetPortStats(name, inc, out)
+}
+
+// SetFlowMeasureStatistics sets the per PDR statistics collected by the FlowMeasure module with
+// the given name (e.g. preQosFlowMeasure), returned by the next flip and read commands.
+// Latency and jitter percentiles that are not set are read as zero.
+func (b *FakeBESS) SetFlowMeasureStatistics(module string, stats []*bess_pb.FlowMeasureReadResponse_Statistic) {
+	b.service.SetFlowMeasureStatistics(module, stats)
 }
 
 func (b *FakeBESS) GetPdrTableEntries() (entries map[uint32][]FakePdr) {
@@ -89,6 +172,19 @@ func (b *FakeBESS) GetSessionQerTableEntries() (entries []FakeQer) {
 	return
 }
 
+// GetSliceMeterEntries returns the slice meter rules, one per direction.
+func (b *FakeBESS) GetSliceMeterEntries() (entries []*bess_pb.QosCommandAddArg) {
+	msgs := b.service.GetOrAddModule(sliceMeterModuleName).GetState()
+	for _, m := range msgs {
+		e, ok := m.(*bess_pb.QosCommandAddArg)
+		if !ok {
+			panic("unexpected message type")
+		}
+		entries = append(entries, e)
+	}
+	return
+}
+
 func (b *FakeBESS) GetAppQerTableEntries() (entries []FakeQer) {
 	msgs := b.service.GetOrAddModule(appQerModuleName).GetState()
 	for _, m := range msgs {
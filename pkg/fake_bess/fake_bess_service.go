@@ -5,6 +5,7 @@ package fake_bess
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"github.com/omec-project/upf-epc/pfcpiface/bess_pb"
 	"github.com/omec-project/upf-epc/pkg/utils"
@@ -14,6 +15,8 @@ import (
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	"math"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -22,6 +25,11 @@ const (
 	farLookupModuleName  = "farLookup"
 	sessionQerModuleName = "sessionQERLookup"
 	appQerModuleName     = "appQERLookup"
+	sliceMeterModuleName = "sliceMeter"
+
+	preQosFlowMeasureModuleName    = "preQosFlowMeasure"
+	postDlQosFlowMeasureModuleName = "postDLQosFlowMeasure"
+	postUlQosFlowMeasureModuleName = "postULQosFlowMeasure"
 )
 
 type FakePdr struct {
@@ -72,6 +80,11 @@ func (p FakePdr) IsDownlink() bool {
 	return p.srcIface == 2
 }
 
+// FSEID returns the F-SEID of the session the PDR belongs to, as allocated by the PFCP agent.
+func (p FakePdr) FSEID() uint64 {
+	return p.fseID
+}
+
 func (p FakePdr) String() string {
 	return fmt.Sprintf("PDR(id=%v, F-SEID=%v, srcIface=%v, tunnelIPv4Dst=%v/%x, "+
 		"tunnelTEID=%v/%x, ueAddress=%v, applicationFilter=%v, precedence=%v, F-SEID IP=%v, "+
@@ -140,13 +153,18 @@ func (q FakeQer) String() string {
 
 type fakeBessService struct {
 	bess_pb.UnimplementedBESSControlServer
-	modules map[string]module
-	mtx     sync.Mutex
+	modules   map[string]module
+	portStats map[string]*bess_pb.GetPortStatsResponse
+	// flowMeasureFlag is shared by the FlowMeasure modules, as BESS sets it per packet in the
+	// pre-QoS module and the post-QoS modules follow it.
+	flowMeasureFlag uint64
+	mtx             sync.Mutex
 }
 
 func newFakeBESSService() *fakeBessService {
 	return &fakeBessService{
-		modules: make(map[string]module),
+		modules:   make(map[string]module),
+		portStats: make(map[string]*bess_pb.GetPortStatsResponse),
 	}
 }
 
@@ -160,18 +178,23 @@ func (b *fakeBessService) unsafeGetOrAddModule(name string) module {
 	if _, ok := b.modules[name]; !ok {
 		if name == pdrLookupModuleName {
 			b.modules[name] = &wildcardModule{
-				baseModule{name: name},
-				nil,
+				baseModule: baseModule{name: name},
+				index:      make(map[string]*bess_pb.WildcardMatchCommandAddArg),
 			}
 		} else if name == farLookupModuleName {
 			b.modules[name] = &exactMatchModule{
-				baseModule{name: name},
-				nil,
+				baseModule: baseModule{name: name},
+				index:      make(map[string]*bess_pb.ExactMatchCommandAddArg),
 			}
-		} else if name == appQerModuleName || name == sessionQerModuleName {
+		} else if name == appQerModuleName || name == sessionQerModuleName || name == sliceMeterModuleName {
 			b.modules[name] = &qosModule{
-				baseModule{name: name},
-				nil,
+				baseModule: baseModule{name: name},
+				index:      make(map[string]*bess_pb.QosCommandAddArg),
+			}
+		} else if isFlowMeasureModuleName(name) {
+			b.modules[name] = &flowMeasureModule{
+				baseModule: baseModule{name: name},
+				flag:       &b.flowMeasureFlag,
 			}
 		} else {
 			log.Fatalf("unknown module name: %v", name)
@@ -180,37 +203,110 @@ func (b *fakeBessService) unsafeGetOrAddModule(name string) module {
 	return b.modules[name]
 }
 
+func isKnownModuleName(name string) bool {
+	switch name {
+	case pdrLookupModuleName, farLookupModuleName, appQerModuleName, sessionQerModuleName, sliceMeterModuleName:
+		return true
+	default:
+		return isFlowMeasureModuleName(name)
+	}
+}
+
+func isFlowMeasureModuleName(name string) bool {
+	switch name {
+	case preQosFlowMeasureModuleName, postDlQosFlowMeasureModuleName, postUlQosFlowMeasureModuleName:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *fakeBessService) GetModuleInfo(ctx context.Context, request *bess_pb.GetModuleInfoRequest) (*bess_pb.GetModuleInfoResponse, error) {
+	if !isKnownModuleName(request.Name) {
+		return &bess_pb.GetModuleInfoResponse{
+			Error: &bess_pb.Error{Code: int32(codes.NotFound), Errmsg: "no module '" + request.Name + "' found"},
+		}, nil
+	}
+
+	return &bess_pb.GetModuleInfoResponse{Name: request.Name}, nil
+}
+
 func (b *fakeBessService) GetPortStats(ctx context.Context, request *bess_pb.GetPortStatsRequest) (*bess_pb.GetPortStatsResponse, error) {
 	b.mtx.Lock()
 	defer b.mtx.Unlock()
-	// TODO: implement it
-	return &bess_pb.GetPortStatsResponse{}, nil
+
+	stats, ok := b.portStats[request.Name]
+	if !ok {
+		return &bess_pb.GetPortStatsResponse{
+			Error: &bess_pb.Error{Code: int32(codes.NotFound), Errmsg: "no port '" + request.Name + "' found"},
+		}, nil
+	}
+
+	return proto.Clone(stats).(*bess_pb.GetPortStatsResponse), nil
+}
+
+// SetPortStats sets the counters returned by GetPortStats for the port with the given name.
+func (b *fakeBessService) SetPortStats(name string, inc, out *bess_pb.GetPortStatsResponse_Stat) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.portStats[name] = &bess_pb.GetPortStatsResponse{
+		Inc: proto.Clone(inc).(*bess_pb.GetPortStatsResponse_Stat),
+		Out: proto.Clone(out).(*bess_pb.GetPortStatsResponse_Stat),
+	}
+}
+
+// SetFlowMeasureStatistics replaces the statistics collected by the FlowMeasure module with the
+// given name in its active buffer, i.e. the buffer that is read after the next flip.
+func (b *fakeBessService) SetFlowMeasureStatistics(name string, stats []*bess_pb.FlowMeasureReadResponse_Statistic) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	m, ok := b.unsafeGetOrAddModule(name).(*flowMeasureModule)
+	if !ok {
+		log.Fatalf("not a FlowMeasure module: %v", name)
+	}
+
+	m.setStatistics(stats)
 }
 
 func (b *fakeBessService) ModuleCommand(ctx context.Context, request *bess_pb.CommandRequest) (*bess_pb.CommandResponse, error) {
 	b.mtx.Lock()
 	defer b.mtx.Unlock()
 
+	if !isKnownModuleName(request.Name) {
+		return &bess_pb.CommandResponse{
+			Error: &bess_pb.Error{Code: int32(codes.NotFound), Errmsg: "no module '" + request.Name + "' found"},
+		}, nil
+	}
+
 	m := b.unsafeGetOrAddModule(request.Name)
-	if err := m.HandleRequest(request.Cmd, request.Arg); err != nil {
+
+	data, err := m.HandleRequest(request.Cmd, request.Arg)
+	if err != nil {
 		return nil, err
 	}
 
-	return &bess_pb.CommandResponse{}, nil
+	return &bess_pb.CommandResponse{Data: data}, nil
 }
 
-func fieldsAreEqual(a, b []*bess_pb.FieldData) bool {
-	if len(a) != len(b) {
-		return false
-	}
-
-	for i := 0; i < len(a); i++ {
-		if a[i].String() != b[i].String() {
-			return false
+// fieldsKey returns a key identifying the match fields of an entry, equal for equal fields.
+// Entries are indexed by key so that lookups don't slow down as the tables grow, like in BESS.
+func fieldsKey(fields ...[]*bess_pb.FieldData) string {
+	var sb strings.Builder
+	for _, fs := range fields {
+		for _, f := range fs {
+			switch v := f.GetEncoding().(type) {
+			case *bess_pb.FieldData_ValueInt:
+				sb.WriteString("i" + strconv.FormatUint(v.ValueInt, 16))
+			case *bess_pb.FieldData_ValueBin:
+				sb.WriteString("b" + hex.EncodeToString(v.ValueBin))
+			}
+			sb.WriteByte(',')
 		}
+		sb.WriteByte('|')
 	}
-
-	return true
+	return sb.String()
 }
 
 func UnmarshalPdr(wc *bess_pb.WildcardMatchCommandAddArg) (p FakePdr) {
@@ -286,7 +382,8 @@ func UnmarshalAppQer(qc *bess_pb.QosCommandAddArg) (q FakeQer) {
 // Fake BESS module
 type module interface {
 	Name() string
-	HandleRequest(cmd string, arg *anypb.Any) error
+	// HandleRequest executes cmd and returns the command response data, if any.
+	HandleRequest(cmd string, arg *anypb.Any) (*anypb.Any, error)
 	GetState() []proto.Message
 }
 
@@ -298,9 +395,9 @@ func (b *baseModule) Name() string {
 	return b.name
 }
 
-func (b *baseModule) HandleRequest(cmd string, arg *anypb.Any) (err error) {
+func (b *baseModule) HandleRequest(cmd string, arg *anypb.Any) (data *anypb.Any, err error) {
 	if !isValidCommand(cmd) {
-		return status.Errorf(codes.InvalidArgument, "invalid command: %v", cmd)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid command: %v", cmd)
 	}
 
 	return
@@ -309,6 +406,8 @@ func (b *baseModule) HandleRequest(cmd string, arg *anypb.Any) (err error) {
 type wildcardModule struct {
 	baseModule
 	entries []*bess_pb.WildcardMatchCommandAddArg
+	// index holds the entries by fieldsKey of their values and masks
+	index map[string]*bess_pb.WildcardMatchCommandAddArg
 }
 
 func (w *wildcardModule) GetState() (msgs []proto.Message) {
@@ -318,8 +417,12 @@ func (w *wildcardModule) GetState() (msgs []proto.Message) {
 	return msgs
 }
 
-func (w *wildcardModule) HandleRequest(cmd string, arg *anypb.Any) (err error) {
-	if err = w.baseModule.HandleRequest(cmd, arg); err != nil {
+func (w *wildcardModule) HandleRequest(cmd string, arg *anypb.Any) (data *anypb.Any, err error) {
+	if cmd == "get_rules" {
+		return anypb.New(&bess_pb.WildcardMatchConfig{Rules: w.entries})
+	}
+
+	if data, err = w.baseModule.HandleRequest(cmd, arg); err != nil {
 		return
 	}
 
@@ -329,60 +432,58 @@ func (w *wildcardModule) HandleRequest(cmd string, arg *anypb.Any) (err error) {
 		wc := &bess_pb.WildcardMatchCommandAddArg{}
 		err = arg.UnmarshalTo(wc)
 		if err != nil {
-			return err
-		}
-		var existing *bess_pb.WildcardMatchCommandAddArg
-		for _, e := range w.entries {
-			if fieldsAreEqual(e.GetValues(), wc.GetValues()) &&
-				fieldsAreEqual(e.GetMasks(), wc.GetMasks()) {
-				existing = e
-			}
+			return nil, err
 		}
-		if existing != nil {
+		key := fieldsKey(wc.GetValues(), wc.GetMasks())
+		if existing, ok := w.index[key]; ok {
 			log.Tracef("updated existing entry %v", existing)
 			existing.Reset()
 			proto.Merge(existing, wc)
 		} else {
 			log.Tracef("added new entry %v", wc)
 			w.entries = append(w.entries, wc)
+			w.index[key] = wc
 		}
 	} else if cmd == "delete" {
 		wc := &bess_pb.WildcardMatchCommandDeleteArg{}
 		err = arg.UnmarshalTo(wc)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		key := fieldsKey(wc.GetValues(), wc.GetMasks())
+		existing, ok := w.index[key]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "entry not found: %v", wc)
 		}
-		idx := -1
+		log.Tracef("deleted existing entry %v", existing)
+		delete(w.index, key)
 		for i, e := range w.entries {
-			if fieldsAreEqual(e.GetValues(), wc.GetValues()) &&
-				fieldsAreEqual(e.GetMasks(), wc.GetMasks()) {
-				idx = i
+			if e == existing {
+				w.entries = append(w.entries[:i], w.entries[i+1:]...)
+				break
 			}
 		}
-		if idx == -1 {
-			return status.Errorf(codes.NotFound, "entry not found: %v", wc)
-		} else {
-			log.Tracef("deleted existing entry %v", w.entries[idx])
-			w.entries = append(w.entries[:idx], w.entries[idx+1:]...)
-		}
 	} else if cmd == "clear" {
 		wc := &bess_pb.WildcardMatchCommandClearArg{}
 		err = arg.UnmarshalTo(wc)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// clear all rules
 		w.entries = nil
+		w.index = make(map[string]*bess_pb.WildcardMatchCommandAddArg)
 	} else {
-		panic("should not happen")
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported command: %v", cmd)
 	}
 
-	return nil
+	return nil, nil
 }
 
 type exactMatchModule struct {
 	baseModule
 	entries []*bess_pb.ExactMatchCommandAddArg
+	// index holds the entries by fieldsKey of their fields
+	index map[string]*bess_pb.ExactMatchCommandAddArg
 }
 
 func (e *exactMatchModule) GetState() (msgs []proto.Message) {
@@ -392,8 +493,12 @@ func (e *exactMatchModule) GetState() (msgs []proto.Message) {
 	return
 }
 
-func (e *exactMatchModule) HandleRequest(cmd string, arg *anypb.Any) (err error) {
-	if err = e.baseModule.HandleRequest(cmd, arg); err != nil {
+func (e *exactMatchModule) HandleRequest(cmd string, arg *anypb.Any) (data *anypb.Any, err error) {
+	if cmd == "get_runtime_config" {
+		return anypb.New(&bess_pb.ExactMatchConfig{Rules: e.entries})
+	}
+
+	if data, err = e.baseModule.HandleRequest(cmd, arg); err != nil {
 		return
 	}
 
@@ -403,58 +508,58 @@ func (e *exactMatchModule) HandleRequest(cmd string, arg *anypb.Any) (err error)
 		em := &bess_pb.ExactMatchCommandAddArg{}
 		err = arg.UnmarshalTo(em)
 		if err != nil {
-			return err
-		}
-		var existing *bess_pb.ExactMatchCommandAddArg
-		for _, et := range e.entries {
-			if fieldsAreEqual(et.GetFields(), em.GetFields()) {
-				existing = et
-			}
+			return nil, err
 		}
-		if existing != nil {
+		key := fieldsKey(em.GetFields())
+		if existing, ok := e.index[key]; ok {
 			log.Tracef("updated existing entry %v", em)
 			existing.Reset()
 			proto.Merge(existing, em)
 		} else {
 			log.Tracef("added new entry %v", em)
 			e.entries = append(e.entries, em)
+			e.index[key] = em
 		}
 	} else if cmd == "delete" {
 		em := &bess_pb.ExactMatchCommandDeleteArg{}
 		err = arg.UnmarshalTo(em)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		idx := -1
+		key := fieldsKey(em.GetFields())
+		existing, ok := e.index[key]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "entry not found: %v", em)
+		}
+		log.Tracef("deleted existing entry %v", existing)
+		delete(e.index, key)
 		for i, et := range e.entries {
-			if fieldsAreEqual(et.GetFields(), em.GetFields()) {
-				idx = i
+			if et == existing {
+				e.entries = append(e.entries[:i], e.entries[i+1:]...)
+				break
 			}
 		}
-		if idx == -1 {
-			return status.Errorf(codes.NotFound, "entry not found: %v", em)
-		} else {
-			log.Tracef("deleted existing entry %v", e.entries[idx])
-			e.entries = append(e.entries[:idx], e.entries[idx+1:]...)
-		}
 	} else if cmd == "clear" {
 		em := &bess_pb.ExactMatchCommandClearArg{}
 		err = arg.UnmarshalTo(em)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// clear all rules
 		e.entries = nil
+		e.index = make(map[string]*bess_pb.ExactMatchCommandAddArg)
 	} else {
-		panic("should not happen")
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported command: %v", cmd)
 	}
 
-	return nil
+	return nil, nil
 }
 
 type qosModule struct {
 	baseModule
 	entries []*bess_pb.QosCommandAddArg
+	// index holds the entries by fieldsKey of their fields
+	index map[string]*bess_pb.QosCommandAddArg
 }
 
 func (q *qosModule) GetState() (msgs []proto.Message) {
@@ -464,8 +569,8 @@ func (q *qosModule) GetState() (msgs []proto.Message) {
 	return
 }
 
-func (q *qosModule) HandleRequest(cmd string, arg *anypb.Any) (err error) {
-	if err = q.baseModule.HandleRequest(cmd, arg); err != nil {
+func (q *qosModule) HandleRequest(cmd string, arg *anypb.Any) (data *anypb.Any, err error) {
+	if data, err = q.baseModule.HandleRequest(cmd, arg); err != nil {
 		return
 	}
 
@@ -475,53 +580,121 @@ func (q *qosModule) HandleRequest(cmd string, arg *anypb.Any) (err error) {
 		wc := &bess_pb.QosCommandAddArg{}
 		err = arg.UnmarshalTo(wc)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		var existing *bess_pb.QosCommandAddArg
-		for _, e := range q.entries {
-			if fieldsAreEqual(e.GetFields(), wc.GetFields()) {
-				existing = e
-			}
-		}
-		if existing != nil {
+		key := fieldsKey(wc.GetFields())
+		if existing, ok := q.index[key]; ok {
 			log.Tracef("updated existing entry %v", existing)
 			existing.Reset()
 			proto.Merge(existing, wc)
 		} else {
 			log.Tracef("added new entry %v", wc)
 			q.entries = append(q.entries, wc)
+			q.index[key] = wc
 		}
 	} else if cmd == "delete" {
 		qc := &bess_pb.QosCommandDeleteArg{}
 		err = arg.UnmarshalTo(qc)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		key := fieldsKey(qc.GetFields())
+		existing, ok := q.index[key]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "entry not found: %v", qc)
 		}
-		idx := -1
+		log.Tracef("deleted existing entry %v", existing)
+		delete(q.index, key)
 		for i, e := range q.entries {
-			if fieldsAreEqual(e.GetFields(), qc.GetFields()) {
-				idx = i
+			if e == existing {
+				q.entries = append(q.entries[:i], q.entries[i+1:]...)
+				break
 			}
 		}
-		if idx == -1 {
-			return status.Errorf(codes.NotFound, "entry not found: %v", qc)
-		} else {
-			log.Tracef("deleted existing entry %v", q.entries[idx])
-			q.entries = append(q.entries[:idx], q.entries[idx+1:]...)
-		}
 	} else if cmd == "clear" {
 		qc := &bess_pb.QosCommandClearArg{}
 		err = arg.UnmarshalTo(qc)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// clear all rules
 		q.entries = nil
+		q.index = make(map[string]*bess_pb.QosCommandAddArg)
 	} else {
-		panic("should not happen")
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported command: %v", cmd)
 	}
 
-	return nil
+	return nil, nil
+}
+
+// flowMeasureModule emulates the FlowMeasure modules, which collect per PDR statistics
+// in two buffers. The flag selects the buffer being written to and is flipped before reading
+// the other one.
+type flowMeasureModule struct {
+	baseModule
+	flag    *uint64
+	buffers [2][]*bess_pb.FlowMeasureReadResponse_Statistic
+}
+
+func (f *flowMeasureModule) GetState() (msgs []proto.Message) {
+	for _, s := range f.buffers[*f.flag] {
+		msgs = append(msgs, s)
+	}
+	return
+}
+
+func (f *flowMeasureModule) setStatistics(stats []*bess_pb.FlowMeasureReadResponse_Statistic) {
+	f.buffers[*f.flag] = nil
+	for _, s := range stats {
+		f.buffers[*f.flag] = append(f.buffers[*f.flag], proto.Clone(s).(*bess_pb.FlowMeasureReadResponse_Statistic))
+	}
+}
+
+func (f *flowMeasureModule) HandleRequest(cmd string, arg *anypb.Any) (data *anypb.Any, err error) {
+	log := log.WithField("module", f.Name()).WithField("cmd", cmd)
+
+	if cmd == "flip" {
+		if err = arg.UnmarshalTo(&bess_pb.FlowMeasureCommandFlipArg{}); err != nil {
+			return nil, err
+		}
+		oldFlag := *f.flag
+		*f.flag ^= 1
+		log.Tracef("flipped buffer flag to %v", *f.flag)
+		return anypb.New(&bess_pb.FlowMeasureFlipResponse{OldFlag: oldFlag})
+	} else if cmd == "read" {
+		rd := &bess_pb.FlowMeasureCommandReadArg{}
+		if err = arg.UnmarshalTo(rd); err != nil {
+			return nil, err
+		}
+		if rd.FlagToRead > 1 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid flag to read: %v", rd.FlagToRead)
+		}
+		resp := &bess_pb.FlowMeasureReadResponse{}
+		for _, s := range f.buffers[rd.FlagToRead] {
+			stat := proto.Clone(s).(*bess_pb.FlowMeasureReadResponse_Statistic)
+			stat.Latency = withPercentiles(stat.Latency, len(rd.LatencyPercentiles))
+			stat.Jitter = withPercentiles(stat.Jitter, len(rd.JitterPercentiles))
+			resp.Statistics = append(resp.Statistics, stat)
+		}
+		if rd.Clear {
+			f.buffers[rd.FlagToRead] = nil
+		}
+		return anypb.New(resp)
+	}
+
+	return nil, status.Errorf(codes.InvalidArgument, "unsupported command: %v", cmd)
+}
+
+// withPercentiles returns h with a value for each of the n requested percentiles, as BESS does.
+// Values that were not set are zero.
+func withPercentiles(h *bess_pb.FlowMeasureReadResponse_Statistic_Histogram, n int) *bess_pb.FlowMeasureReadResponse_Statistic_Histogram {
+	if h == nil {
+		h = &bess_pb.FlowMeasureReadResponse_Statistic_Histogram{}
+	}
+	for len(h.PercentileValuesNs) < n {
+		h.PercentileValuesNs = append(h.PercentileValuesNs, 0)
+	}
+	return h
 }
 
 func isValidCommand(cmd string) bool {
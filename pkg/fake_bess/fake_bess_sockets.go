@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_bess
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxPacketSize is the size of the buffer used to read packets from the unixpacket sockets.
+const maxPacketSize = 2048
+
+var errNoPeer = errors.New("no peer connected to the socket")
+
+// unixPacketSocket emulates the unixpacket sockets on which BESS listens for the PFCP agent,
+// i.e. the socket to notify downlink data and the socket to send end markers.
+type unixPacketSocket struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	received [][]byte
+}
+
+func listenUnixPacketSocket(path string) (*unixPacketSocket, error) {
+	// remove the socket left by a previous instance, as BESS does
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unixpacket", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &unixPacketSocket{listener: listener}
+
+	go s.acceptLoop()
+
+	return s, nil
+}
+
+func (s *unixPacketSocket) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go s.readLoop(conn)
+	}
+}
+
+func (s *unixPacketSocket) readLoop(conn net.Conn) {
+	for {
+		buf := make([]byte, maxPacketSize)
+
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.received = append(s.received, buf[:n])
+		s.mu.Unlock()
+	}
+}
+
+// send writes packet to all the connected peers.
+func (s *unixPacketSocket) send(packet []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conns) == 0 {
+		return errNoPeer
+	}
+
+	for _, conn := range s.conns {
+		if _, err := conn.Write(packet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getReceived returns the packets received from all the peers, in order of arrival.
+func (s *unixPacketSocket) getReceived() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packets := make([][]byte, len(s.received))
+	copy(packets, s.received)
+
+	return packets
+}
+
+func (s *unixPacketSocket) close() {
+	if err := s.listener.Close(); err != nil {
+		log.Warnln("failed to close socket listener:", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+
+	s.conns = nil
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_bess
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pfcpiface/bess_pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func startTestFakeBESS(t *testing.T) (*FakeBESS, bess_pb.BESSControlClient) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	b := NewFakeBESS()
+
+	go func() {
+		if err := b.Run(address); err != nil {
+			t.Logf("fake BESS stopped: %v", err)
+		}
+	}()
+
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		b.Stop()
+	})
+
+	client := bess_pb.NewBESSControlClient(conn)
+
+	// wait for the server to start
+	require.Eventually(t, func() bool {
+		_, err := client.GetModuleInfo(context.Background(), &bess_pb.GetModuleInfoRequest{Name: pdrLookupModuleName})
+		return err == nil
+	}, 10*time.Second, 50*time.Millisecond)
+
+	return b, client
+}
+
+func moduleCommand(t *testing.T, client bess_pb.BESSControlClient, module, cmd string, arg, resp proto.Message) {
+	any, err := anypb.New(arg)
+	require.NoError(t, err)
+
+	res, err := client.ModuleCommand(context.Background(), &bess_pb.CommandRequest{Name: module, Cmd: cmd, Arg: any})
+	require.NoError(t, err)
+	require.Nil(t, res.GetError())
+	require.NoError(t, res.GetData().UnmarshalTo(resp))
+}
+
+func Test_FakeBESS_GetPortStats(t *testing.T) {
+	b, client := startTestFakeBESS(t)
+
+	resp, err := client.GetPortStats(context.Background(), &bess_pb.GetPortStatsRequest{Name: "accessFast"})
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.NotFound), resp.GetError().GetCode())
+
+	b.SetPortStats("accessFast",
+		&bess_pb.GetPortStatsResponse_Stat{Packets: 10, Bytes: 1000, Dropped: 1},
+		&bess_pb.GetPortStatsResponse_Stat{Packets: 20, Bytes: 2000})
+
+	resp, err = client.GetPortStats(context.Background(), &bess_pb.GetPortStatsRequest{Name: "accessFast"})
+	require.NoError(t, err)
+	require.Nil(t, resp.GetError())
+	require.Equal(t, uint64(1), resp.GetInc().GetDropped())
+	require.Equal(t, uint64(2000), resp.GetOut().GetBytes())
+}
+
+func Test_FakeBESS_FlowMeasure(t *testing.T) {
+	b, client := startTestFakeBESS(t)
+
+	b.SetFlowMeasureStatistics(preQosFlowMeasureModuleName, []*bess_pb.FlowMeasureReadResponse_Statistic{
+		{Fseid: 1, Pdr: 2, TotalPackets: 10, TotalBytes: 1000},
+	})
+
+	flip := &bess_pb.FlowMeasureFlipResponse{}
+	moduleCommand(t, client, preQosFlowMeasureModuleName, "flip", &bess_pb.FlowMeasureCommandFlipArg{}, flip)
+	require.Equal(t, uint64(0), flip.OldFlag)
+
+	readArg := &bess_pb.FlowMeasureCommandReadArg{
+		Clear:              true,
+		LatencyPercentiles: []float64{50, 90, 99},
+		JitterPercentiles:  []float64{50, 90, 99},
+		FlagToRead:         flip.OldFlag,
+	}
+
+	stats := &bess_pb.FlowMeasureReadResponse{}
+	moduleCommand(t, client, preQosFlowMeasureModuleName, "read", readArg, stats)
+	require.Len(t, stats.GetStatistics(), 1)
+	require.Equal(t, uint64(10), stats.GetStatistics()[0].GetTotalPackets())
+	// a value for each of the requested percentiles
+	require.Len(t, stats.GetStatistics()[0].GetLatency().GetPercentileValuesNs(), 3)
+
+	// the statistics are cleared after read
+	moduleCommand(t, client, preQosFlowMeasureModuleName, "read", readArg, stats)
+	require.Empty(t, stats.GetStatistics())
+
+	// the post-QoS modules follow the flag flipped in the pre-QoS module
+	b.SetFlowMeasureStatistics(postDlQosFlowMeasureModuleName, []*bess_pb.FlowMeasureReadResponse_Statistic{
+		{Fseid: 1, Pdr: 2, TotalPackets: 5},
+	})
+
+	moduleCommand(t, client, preQosFlowMeasureModuleName, "flip", &bess_pb.FlowMeasureCommandFlipArg{}, flip)
+	require.Equal(t, uint64(1), flip.OldFlag)
+
+	readArg.FlagToRead = flip.OldFlag
+	moduleCommand(t, client, postDlQosFlowMeasureModuleName, "read", readArg, stats)
+	require.Len(t, stats.GetStatistics(), 1)
+	require.Equal(t, uint64(5), stats.GetStatistics()[0].GetTotalPackets())
+
+	res, err := client.ModuleCommand(context.Background(), &bess_pb.CommandRequest{Name: "unknown", Cmd: "read"})
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.NotFound), res.GetError().GetCode())
+}
+
+func Test_FakeBESS_sockets(t *testing.T) {
+	b, _ := startTestFakeBESS(t)
+
+	notifyPath := filepath.Join(t.TempDir(), "notify")
+	endMarkerPath := filepath.Join(t.TempDir(), "endmarker")
+
+	require.NoError(t, b.ListenNotifySocket(notifyPath))
+	require.NoError(t, b.ListenEndMarkerSocket(endMarkerPath))
+
+	require.ErrorIs(t, b.NotifyDownlinkData(1), errNoPeer)
+
+	notifyConn, err := net.Dial("unixpacket", notifyPath)
+	require.NoError(t, err)
+
+	defer notifyConn.Close()
+
+	require.Eventually(t, func() bool { return b.NotifyDownlinkData(0x1122) == nil }, 5*time.Second, 10*time.Millisecond)
+
+	buf := make([]byte, 512)
+	n, err := notifyConn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+	require.Equal(t, uint64(0x1122), binary.LittleEndian.Uint64(buf))
+
+	endMarkerConn, err := net.Dial("unixpacket", endMarkerPath)
+	require.NoError(t, err)
+
+	defer endMarkerConn.Close()
+
+	_, err = endMarkerConn.Write([]byte{0x01, 0x02})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(b.GetEndMarkers()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []byte{0x01, 0x02}, b.GetEndMarkers()[0])
+}
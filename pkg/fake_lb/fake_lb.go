@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_lb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omec-project/upf-epc/pfcpiface/lb_pb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// RegisterRequest is the body of the requests sent by the UPF to /register.
+type RegisterRequest struct {
+	GwIP      string `json:"gwip"`
+	CoreMac   string `json:"coremac"`
+	AccessMac string `json:"accessmac,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+}
+
+// RuleRequest is the body of the requests sent by the UPF to /addrule, with the UE addresses
+// to route to the UPF behind the gateway GwIP.
+type RuleRequest struct {
+	GwIP string   `json:"gwip"`
+	IPs  []string `json:"ip"`
+}
+
+// GatewayRegistration is the body of the requests sent by the load balancers to the /registergw
+// endpoint of the UPF, to configure the gateway of its access or core interface.
+type GatewayRegistration struct {
+	GwIP  string `json:"gwip"`
+	GwMac string `json:"gwmac"`
+}
+
+// callbackTimeout is the timeout of the requests sent to the UPF.
+const callbackTimeout = 10 * time.Second
+
+// FakeLB is a fake Enter-LB (West-LB) or Exit-LB (East-LB), recording the registrations and
+// the UE rules sent by the UPFs, through the HTTP API or the gRPC API.
+type FakeLB struct {
+	httpServer *http.Server
+	grpcServer *grpc.Server
+	faults     faults
+
+	mu              sync.Mutex
+	registrations   []RegisterRequest
+	deregistrations []RegisterRequest
+	rules           []RuleRequest
+	deletedRules    []RuleRequest
+	upfURL          string
+	gateway         *GatewayRegistration
+}
+
+// NewFakeLB creates a new fake load balancer, replying to /register and /addrule with
+// 201 Created, as the real one. Deregistrations are sent with DELETE on /register.
+func NewFakeLB() *FakeLB {
+	l := &FakeLB{}
+	l.httpServer = &http.Server{Handler: l}
+	l.grpcServer = grpc.NewServer()
+	lb_pb.RegisterLoadBalancerServer(l.grpcServer, &grpcService{l: l})
+
+	return l
+}
+
+// Run starts and runs the HTTP server on the given address. Blocking until Stop is called.
+func (l *FakeLB) Run(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return l.Serve(listener)
+}
+
+// Serve runs the HTTP server on listener, e.g. to use a port chosen by the system in tests.
+// Blocking until Stop is called.
+func (l *FakeLB) Serve(listener net.Listener) error {
+	if err := l.httpServer.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Stop the HTTP and gRPC servers, closing the connections of the clients.
+func (l *FakeLB) Stop() {
+	if err := l.httpServer.Close(); err != nil {
+		log.Warnln("failed to close fake LB:", err)
+	}
+
+	l.grpcServer.Stop()
+}
+
+// ServeHTTP handles the requests of the UPFs. It allows using the fake with httptest.
+func (l *FakeLB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete && r.URL.Path == "/register" {
+		l.deregister(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/register":
+		var req RegisterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if l.faults.inject(w) {
+			return
+		}
+
+		l.register(req, RegisterGateway)
+
+		w.WriteHeader(http.StatusCreated)
+	case "/addrule":
+		var req RuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if l.faults.inject(w) {
+			return
+		}
+
+		l.mu.Lock()
+		l.rules = append(l.rules, req)
+		l.mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// register records req, then sends the gateway of the fake to the UPF with announce.
+func (l *FakeLB) register(req RegisterRequest, announce func(upf string, gateway GatewayRegistration) error) {
+	l.mu.Lock()
+	l.registrations = append(l.registrations, req)
+	upf, gateway := l.upfURL, l.gateway
+	l.mu.Unlock()
+
+	if gateway != nil {
+		// the UPF only configures its gateway once registered
+		go func() {
+			if err := announce(upf, *gateway); err != nil {
+				log.Warnln("fake LB failed to register gateway:", err)
+			}
+		}()
+	}
+}
+
+func (l *FakeLB) deregister(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if l.faults.inject(w) {
+		return
+	}
+
+	l.mu.Lock()
+	l.deregistrations = append(l.deregistrations, req)
+	l.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// FailNextRequests replies to the next n requests with statusCode, without recording them.
+func (l *FakeLB) FailNextRequests(n int, statusCode int) {
+	l.faults.failNextRequests(n, statusCode)
+}
+
+// SetLatency delays the replies to all the following requests.
+func (l *FakeLB) SetLatency(latency time.Duration) {
+	l.faults.setLatency(latency)
+}
+
+// SetGateway makes the fake register gateway to the UPF serving its HTTP API at upfURL,
+// after each successful registration. A UPF registered through the gRPC API is sent gateway on
+// its gRPC API, at the host:port upfURL. A nil gateway disables the callback.
+func (l *FakeLB) SetGateway(upfURL string, gateway *GatewayRegistration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.upfURL = upfURL
+	l.gateway = gateway
+}
+
+// GetRegistrations returns the registrations received, in order of arrival.
+func (l *FakeLB) GetRegistrations() []RegisterRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	registrations := make([]RegisterRequest, len(l.registrations))
+	copy(registrations, l.registrations)
+
+	return registrations
+}
+
+// GetDeregistrations returns the deregistrations received, in order of arrival.
+func (l *FakeLB) GetDeregistrations() []RegisterRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	deregistrations := make([]RegisterRequest, len(l.deregistrations))
+	copy(deregistrations, l.deregistrations)
+
+	return deregistrations
+}
+
+// GetRules returns the UE rules received, in order of arrival.
+func (l *FakeLB) GetRules() []RuleRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rules := make([]RuleRequest, len(l.rules))
+	copy(rules, l.rules)
+
+	return rules
+}
+
+// GetDeletedRules returns the deletions of UE rules received through the gRPC API, in order of
+// arrival.
+func (l *FakeLB) GetDeletedRules() []RuleRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rules := make([]RuleRequest, len(l.deletedRules))
+	copy(rules, l.deletedRules)
+
+	return rules
+}
+
+// GetUEAddresses returns the UE addresses routed to the UPF behind the gateway gwIP.
+func (l *FakeLB) GetUEAddresses(gwIP string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var addresses []string
+
+	for _, r := range l.rules {
+		if r.GwIP == gwIP {
+			addresses = append(addresses, r.IPs...)
+		}
+	}
+
+	return addresses
+}
+
+// Reset clears the registrations, deregistrations and UE rules received.
+func (l *FakeLB) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.registrations = nil
+	l.deregistrations = nil
+	l.rules = nil
+	l.deletedRules = nil
+}
+
+// RegisterGateway sends gateway to the /registergw endpoint of the UPF serving its HTTP API at upfURL,
+// as the load balancers do once the UPF is registered.
+func RegisterGateway(upfURL string, gateway GatewayRegistration) error {
+	body, err := json.Marshal(gateway)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: callbackTimeout}
+
+	resp, err := client.Post(strings.TrimSuffix(upfURL, "/")+"/registergw", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("register gateway %s: unexpected status %s", gateway.GwIP, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_lb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pfcpiface/lb_pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func post(t *testing.T, url string, body interface{}) int {
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func Test_FakeLB_register(t *testing.T) {
+	l := NewFakeLB()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		if err := l.Serve(listener); err != nil {
+			t.Logf("fake LB stopped: %v", err)
+		}
+	}()
+	defer l.Stop()
+
+	url := "http://" + listener.Addr().String()
+
+	gateways := make(chan GatewayRegistration, 1)
+	upf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var gw GatewayRegistration
+		if err := json.NewDecoder(r.Body).Decode(&gw); err != nil || r.URL.Path != "/registergw" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		gateways <- gw
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upf.Close()
+
+	l.SetGateway(upf.URL, &GatewayRegistration{GwIP: "198.18.0.1", GwMac: "00:00:00:00:00:01"})
+
+	req := RegisterRequest{GwIP: "198.18.0.10", CoreMac: "00:00:00:00:00:02", Hostname: "upf-0"}
+	require.Equal(t, http.StatusCreated, post(t, url+"/register", req))
+	require.Equal(t, []RegisterRequest{req}, l.GetRegistrations())
+
+	select {
+	case gw := <-gateways:
+		require.Equal(t, "198.18.0.1", gw.GwIP)
+	case <-time.After(5 * time.Second):
+		t.Fatal("gateway not registered to the UPF")
+	}
+
+	deregister, err := http.NewRequest(http.MethodDelete, url+"/register", strings.NewReader(`{"gwip": "198.18.0.10"}`))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(deregister)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []RegisterRequest{{GwIP: "198.18.0.10"}}, l.GetDeregistrations())
+
+	require.Equal(t, http.StatusBadRequest, post(t, url+"/register", "not a request"))
+	require.Equal(t, http.StatusNotFound, post(t, url+"/unknown", req))
+	require.Len(t, l.GetRegistrations(), 1)
+}
+
+func Test_FakeLB_addrule(t *testing.T) {
+	l := NewFakeLB()
+	s := httptest.NewServer(l)
+	defer s.Close()
+
+	require.Equal(t, http.StatusCreated, post(t, s.URL+"/addrule",
+		RuleRequest{GwIP: "198.18.0.10", IPs: []string{"10.250.0.1"}}))
+	require.Equal(t, http.StatusCreated, post(t, s.URL+"/addrule",
+		RuleRequest{GwIP: "198.18.0.11", IPs: []string{"10.250.0.2"}}))
+	require.Equal(t, http.StatusCreated, post(t, s.URL+"/addrule",
+		RuleRequest{GwIP: "198.18.0.10", IPs: []string{"10.250.0.3", "10.250.0.4"}}))
+
+	require.Len(t, l.GetRules(), 3)
+	require.Equal(t, []string{"10.250.0.1", "10.250.0.3", "10.250.0.4"}, l.GetUEAddresses("198.18.0.10"))
+
+	l.Reset()
+	require.Empty(t, l.GetRules())
+}
+
+func Test_FakeLB_faults(t *testing.T) {
+	l := NewFakeLB()
+	s := httptest.NewServer(l)
+	defer s.Close()
+
+	req := RuleRequest{GwIP: "198.18.0.10", IPs: []string{"10.250.0.1"}}
+
+	l.FailNextRequests(2, http.StatusServiceUnavailable)
+	require.Equal(t, http.StatusServiceUnavailable, post(t, s.URL+"/addrule", req))
+	require.Equal(t, http.StatusServiceUnavailable, post(t, s.URL+"/addrule", req))
+	require.Empty(t, l.GetRules())
+
+	l.SetLatency(100 * time.Millisecond)
+
+	start := time.Now()
+	require.Equal(t, http.StatusCreated, post(t, s.URL+"/addrule", req))
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	require.Len(t, l.GetRules(), 1)
+}
+
+func Test_FakeLB_grpc(t *testing.T) {
+	l := NewFakeLB()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		if err := l.ServeGRPC(listener); err != nil {
+			t.Logf("fake LB stopped: %v", err)
+		}
+	}()
+
+	defer l.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	client := lb_pb.NewLoadBalancerClient(conn)
+	ctx := context.Background()
+
+	_, err = client.Register(ctx, &lb_pb.RegisterRequest{GatewayIp: "198.18.0.10", CoreMac: "00:00:00:00:00:01"})
+	require.NoError(t, err)
+	require.Equal(t, []RegisterRequest{{GwIP: "198.18.0.10", CoreMac: "00:00:00:00:00:01"}}, l.GetRegistrations())
+
+	rules := &lb_pb.RulesRequest{GatewayIp: "198.18.0.10", UeAddresses: []string{"10.250.0.1", "10.250.0.2"}}
+
+	l.FailNextRequests(1, http.StatusServiceUnavailable)
+	_, err = client.AddRules(ctx, rules)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	_, err = client.AddRules(ctx, rules)
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.250.0.1", "10.250.0.2"}, l.GetUEAddresses("198.18.0.10"))
+
+	_, err = client.DeleteRules(ctx, rules)
+	require.NoError(t, err)
+	require.Equal(t, []RuleRequest{{GwIP: "198.18.0.10", IPs: []string{"10.250.0.1", "10.250.0.2"}}}, l.GetDeletedRules())
+
+	_, err = client.Deregister(ctx, &lb_pb.RegisterRequest{GatewayIp: "198.18.0.10"})
+	require.NoError(t, err)
+	require.Equal(t, []RegisterRequest{{GwIP: "198.18.0.10"}}, l.GetDeregistrations())
+}
+
+func Test_FakePFCPLB(t *testing.T) {
+	l := NewFakePFCPLB()
+	s := httptest.NewServer(l)
+	defer s.Close()
+
+	reg := map[string]interface{}{
+		"ip":  "192.168.0.10",
+		"upf": map[string]interface{}{"nodeid": "upf-0", "dnn": "internet"},
+	}
+
+	l.FailNextRequests(1, http.StatusInternalServerError)
+	require.Equal(t, http.StatusInternalServerError, post(t, s.URL+"/", reg))
+	require.Empty(t, l.GetRegistrations())
+
+	require.Equal(t, http.StatusCreated, post(t, s.URL+"/", reg))
+
+	registrations := l.GetRegistrations()
+	require.Len(t, registrations, 1)
+	require.Equal(t, "192.168.0.10", registrations[0].IP)
+	require.Equal(t, "upf-0", registrations[0].UPF["nodeid"])
+
+	report := map[string]interface{}{
+		"nodeid":     "upf-0",
+		"load":       42,
+		"throughput": map[string]interface{}{"access": map[string]interface{}{"rx_bps": 1000}},
+	}
+
+	require.Equal(t, http.StatusOK, post(t, s.URL+"/load", report))
+	require.Len(t, l.GetRegistrations(), 1)
+
+	reports := l.GetLoadReports()
+	require.Len(t, reports, 1)
+	require.Equal(t, uint8(42), reports[0].Load)
+	require.Equal(t, Throughput{RxBps: 1000}, reports[0].Throughput["access"])
+
+	resp, err := http.Get(s.URL + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_lb
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PFCPRegistration is the body of the requests sent by the UPF to the PFCP-LB, once the gateways
+// of both its access and core interfaces are registered.
+type PFCPRegistration struct {
+	IP string `json:"ip"`
+	// UPF holds the exported fields of the UPF, e.g. accessip, coreip, nodeid and dnn.
+	UPF map[string]interface{} `json:"upf"`
+}
+
+// Throughput is the throughput of an interface of the UPF, in bits per second.
+type Throughput struct {
+	RxBps float64 `json:"rx_bps"`
+	TxBps float64 `json:"tx_bps"`
+}
+
+// LoadReport is the body of the requests sent periodically by the UPF to /load, relative to
+// the URL it registers on.
+type LoadReport struct {
+	NodeID        string                `json:"nodeid"`
+	Hostname      string                `json:"hostname"`
+	IP            string                `json:"ip"`
+	Load          uint8                 `json:"load"`
+	Sessions      int                   `json:"sessions"`
+	MaxSessions   uint32                `json:"max_sessions"`
+	UEPoolUsed    int                   `json:"ue_pool_used"`
+	UEPoolSize    int                   `json:"ue_pool_size"`
+	Throughput    map[string]Throughput `json:"throughput"`
+	PFCPLatencyMs float64               `json:"pfcp_latency_ms"`
+}
+
+// FakePFCPLB is a fake PFCP-LB, recording the registrations and the load reports of the UPFs.
+type FakePFCPLB struct {
+	httpServer *http.Server
+	faults     faults
+
+	mu              sync.Mutex
+	registrations   []PFCPRegistration
+	deregistrations []PFCPRegistration
+	loadReports     []LoadReport
+}
+
+// NewFakePFCPLB creates a new fake PFCP-LB, replying to the registrations posted on any path but
+// /load with 201 Created. Deregistrations are sent with DELETE.
+func NewFakePFCPLB() *FakePFCPLB {
+	l := &FakePFCPLB{}
+	l.httpServer = &http.Server{Handler: l}
+
+	return l
+}
+
+// Run starts and runs the HTTP server on the given address. Blocking until Stop is called.
+func (l *FakePFCPLB) Run(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return l.Serve(listener)
+}
+
+// Serve runs the HTTP server on listener, e.g. to use a port chosen by the system in tests.
+// Blocking until Stop is called.
+func (l *FakePFCPLB) Serve(listener net.Listener) error {
+	if err := l.httpServer.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Stop the HTTP server, closing the connections of the clients.
+func (l *FakePFCPLB) Stop() {
+	if err := l.httpServer.Close(); err != nil {
+		log.Warnln("failed to close fake PFCP-LB:", err)
+	}
+}
+
+// ServeHTTP handles the requests of the UPFs. It allows using the fake with httptest.
+func (l *FakePFCPLB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/load") {
+		l.reportLoad(w, r)
+		return
+	}
+
+	var req PFCPRegistration
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if l.faults.inject(w) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r.Method == http.MethodDelete {
+		l.deregistrations = append(l.deregistrations, req)
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	l.registrations = append(l.registrations, req)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (l *FakePFCPLB) reportLoad(w http.ResponseWriter, r *http.Request) {
+	var report LoadReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if l.faults.inject(w) {
+		return
+	}
+
+	l.mu.Lock()
+	l.loadReports = append(l.loadReports, report)
+	l.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// FailNextRequests replies to the next n requests with statusCode, without recording them.
+func (l *FakePFCPLB) FailNextRequests(n int, statusCode int) {
+	l.faults.failNextRequests(n, statusCode)
+}
+
+// SetLatency delays the replies to all the following requests.
+func (l *FakePFCPLB) SetLatency(latency time.Duration) {
+	l.faults.setLatency(latency)
+}
+
+// GetRegistrations returns the registrations received, in order of arrival.
+func (l *FakePFCPLB) GetRegistrations() []PFCPRegistration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	registrations := make([]PFCPRegistration, len(l.registrations))
+	copy(registrations, l.registrations)
+
+	return registrations
+}
+
+// GetDeregistrations returns the deregistrations received, in order of arrival.
+func (l *FakePFCPLB) GetDeregistrations() []PFCPRegistration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	deregistrations := make([]PFCPRegistration, len(l.deregistrations))
+	copy(deregistrations, l.deregistrations)
+
+	return deregistrations
+}
+
+// GetLoadReports returns the load reports received, in order of arrival.
+func (l *FakePFCPLB) GetLoadReports() []LoadReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reports := make([]LoadReport, len(l.loadReports))
+	copy(reports, l.loadReports)
+
+	return reports
+}
+
+// Reset clears the registrations, deregistrations and load reports received.
+func (l *FakePFCPLB) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.registrations = nil
+	l.deregistrations = nil
+	l.loadReports = nil
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_lb
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// faults injects failures and latency in the responses of a fake load balancer.
+type faults struct {
+	mu            sync.Mutex
+	failures      int
+	failureStatus int
+	latency       time.Duration
+}
+
+func (f *faults) failNextRequests(n int, statusCode int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = n
+	f.failureStatus = statusCode
+}
+
+func (f *faults) setLatency(latency time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.latency = latency
+}
+
+// next delays the request by the configured latency, then returns true and the failure status
+// code if the request must fail.
+func (f *faults) next() (bool, int) {
+	f.mu.Lock()
+	latency := f.latency
+	fail := f.failures > 0
+
+	if fail {
+		f.failures--
+	}
+
+	status := f.failureStatus
+	f.mu.Unlock()
+
+	time.Sleep(latency)
+
+	return fail, status
+}
+
+// inject delays the request by the configured latency, then replies with the failure status
+// code and returns true if the request must fail.
+func (f *faults) inject(w http.ResponseWriter) bool {
+	fail, status := f.next()
+	if fail {
+		w.WriteHeader(status)
+	}
+
+	return fail
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_lb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/omec-project/upf-epc/pfcpiface/lb_pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// ServeGRPC runs the gRPC API of the fake on listener, recording the requests as the HTTP API
+// does. Blocking until Stop is called.
+func (l *FakeLB) ServeGRPC(listener net.Listener) error {
+	return l.grpcServer.Serve(listener)
+}
+
+// grpcService serves the LoadBalancer service of the fake.
+type grpcService struct {
+	lb_pb.UnimplementedLoadBalancerServer
+
+	l *FakeLB
+}
+
+// fault returns the error of a request failing with an HTTP statusCode, set by FailNextRequests.
+func fault(statusCode int) error {
+	return status.Error(codes.Unavailable, http.StatusText(statusCode))
+}
+
+func newRegisterRequest(req *lb_pb.RegisterRequest) RegisterRequest {
+	return RegisterRequest{
+		GwIP:      req.GetGatewayIp(),
+		CoreMac:   req.GetCoreMac(),
+		AccessMac: req.GetAccessMac(),
+		Hostname:  req.GetHostname(),
+	}
+}
+
+func newRuleRequest(req *lb_pb.RulesRequest) RuleRequest {
+	return RuleRequest{
+		GwIP: req.GetGatewayIp(),
+		IPs:  req.GetUeAddresses(),
+	}
+}
+
+func (s *grpcService) Register(ctx context.Context, req *lb_pb.RegisterRequest) (*lb_pb.RegisterResponse, error) {
+	if fail, statusCode := s.l.faults.next(); fail {
+		return nil, fault(statusCode)
+	}
+
+	s.l.register(newRegisterRequest(req), AnnounceGateway)
+
+	return &lb_pb.RegisterResponse{}, nil
+}
+
+func (s *grpcService) Deregister(ctx context.Context, req *lb_pb.RegisterRequest) (*lb_pb.RegisterResponse, error) {
+	if fail, statusCode := s.l.faults.next(); fail {
+		return nil, fault(statusCode)
+	}
+
+	s.l.mu.Lock()
+	s.l.deregistrations = append(s.l.deregistrations, newRegisterRequest(req))
+	s.l.mu.Unlock()
+
+	return &lb_pb.RegisterResponse{}, nil
+}
+
+func (s *grpcService) AddRules(ctx context.Context, req *lb_pb.RulesRequest) (*lb_pb.RulesResponse, error) {
+	if fail, statusCode := s.l.faults.next(); fail {
+		return nil, fault(statusCode)
+	}
+
+	s.l.mu.Lock()
+	s.l.rules = append(s.l.rules, newRuleRequest(req))
+	s.l.mu.Unlock()
+
+	return &lb_pb.RulesResponse{}, nil
+}
+
+func (s *grpcService) DeleteRules(ctx context.Context, req *lb_pb.RulesRequest) (*lb_pb.RulesResponse, error) {
+	if fail, statusCode := s.l.faults.next(); fail {
+		return nil, fault(statusCode)
+	}
+
+	s.l.mu.Lock()
+	s.l.deletedRules = append(s.l.deletedRules, newRuleRequest(req))
+	s.l.mu.Unlock()
+
+	return &lb_pb.RulesResponse{}, nil
+}
+
+// AnnounceGateway sends gateway to the gRPC API of the UPF at the host:port upfAddr, as the load
+// balancers do once the UPF is registered.
+func AnnounceGateway(upfAddr string, gateway GatewayRegistration) error {
+	conn, err := grpc.Dial(upfAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	_, err = lb_pb.NewUPFClient(conn).AnnounceGateway(ctx, &lb_pb.AnnounceGatewayRequest{
+		GatewayIp:  gateway.GwIP,
+		GatewayMac: gateway.GwMac,
+	})
+	if err != nil {
+		return fmt.Errorf("announce gateway %s: %w", gateway.GwIP, err)
+	}
+
+	return nil
+}
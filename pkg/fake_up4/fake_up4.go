@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_up4
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	p4ConfigV1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc"
+)
+
+// DefaultDeviceID is the P4Runtime device ID of the fake UP4, as used by pfcpiface.
+const DefaultDeviceID = 1
+
+type FakeUP4 struct {
+	grpcServer *grpc.Server
+	service    *fakeP4RuntimeService
+}
+
+// NewFakeUP4 creates a new fake UP4 P4Runtime server, with the pipeline described by the p4info
+// file at p4infoPath (e.g. conf/p4/bin/p4info.txt). The tables, meters and counters can be programmed
+// in the same way as the real UP4 and keep track of their state, but no packet is forwarded.
+func NewFakeUP4(p4infoPath string) (*FakeUP4, error) {
+	p4infoBytes, err := os.ReadFile(p4infoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	p4info := &p4ConfigV1.P4Info{}
+
+	if err := proto.UnmarshalText(string(p4infoBytes), p4info); err != nil {
+		return nil, fmt.Errorf("parse p4info %s: %w", p4infoPath, err)
+	}
+
+	u := &FakeUP4{
+		grpcServer: grpc.NewServer(),
+		service:    newFakeP4RuntimeService(DefaultDeviceID, p4info),
+	}
+	p4.RegisterP4RuntimeServer(u.grpcServer, u.service)
+
+	return u, nil
+}
+
+// Run starts and runs the P4Runtime server on the given address. Blocking until Stop is called.
+func (u *FakeUP4) Run(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return u.Serve(listener)
+}
+
+// Serve runs the P4Runtime server on listener, e.g. to use a port chosen by the system in tests.
+// Blocking until Stop is called.
+func (u *FakeUP4) Serve(listener net.Listener) error {
+	return u.grpcServer.Serve(listener)
+}
+
+// Stop the P4Runtime server, closing the connections of the clients.
+func (u *FakeUP4) Stop() {
+	u.grpcServer.Stop()
+}
+
+// PrimaryElectionID returns the election ID of the primary client, or nil if there is no primary.
+func (u *FakeUP4) PrimaryElectionID() *p4.Uint128 {
+	u.service.mu.Lock()
+	defer u.service.mu.Unlock()
+
+	if u.service.primary == nil {
+		return nil
+	}
+
+	return u.service.primary.electionID
+}
+
+// GetTableEntries returns the entries of the table with ID tableID.
+func (u *FakeUP4) GetTableEntries(tableID uint32) []*p4.TableEntry {
+	u.service.mu.Lock()
+	defer u.service.mu.Unlock()
+
+	entities, err := u.service.unsafeReadTableEntries(&p4.TableEntry{TableId: tableID})
+	if err != nil {
+		return nil
+	}
+
+	entries := make([]*p4.TableEntry, 0, len(entities))
+	for _, e := range entities {
+		entries = append(entries, e.GetTableEntry())
+	}
+
+	return entries
+}
+
+// GetMeterEntries returns the cells of the meter with ID meterID that are not in the default configuration.
+func (u *FakeUP4) GetMeterEntries(meterID uint32) []*p4.MeterEntry {
+	u.service.mu.Lock()
+	defer u.service.mu.Unlock()
+
+	obj, ok := u.service.state[meterID]
+	if !ok || obj.meterConfigs == nil {
+		return nil
+	}
+
+	entries := make([]*p4.MeterEntry, 0, len(obj.meterConfigs))
+
+	for index, config := range obj.meterConfigs {
+		entries = append(entries, &p4.MeterEntry{
+			MeterId: meterID,
+			Index:   &p4.Index{Index: index},
+			Config:  proto.Clone(config).(*p4.MeterConfig),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].GetIndex().GetIndex() < entries[j].GetIndex().GetIndex() })
+
+	return entries
+}
+
+// SetCounterData sets the value of the cell index of the counter with ID counterID, e.g. to emulate traffic.
+func (u *FakeUP4) SetCounterData(counterID uint32, index int64, data *p4.CounterData) error {
+	u.service.mu.Lock()
+	defer u.service.mu.Unlock()
+
+	return u.service.unsafeApplyCounterEntry(p4.Update_MODIFY, &p4.CounterEntry{
+		CounterId: counterID,
+		Index:     &p4.Index{Index: index},
+		Data:      data,
+	})
+}
+
+// SendDigest sends a digest with the given bitstring to the primary client, e.g. to emulate
+// the Downlink Data Notifications of buffered sessions.
+func (u *FakeUP4) SendDigest(digestID uint32, bitstring []byte) error {
+	return u.service.sendDigest(digestID, &p4.P4Data{Data: &p4.P4Data_Bitstring{Bitstring: bitstring}})
+}
@@ -0,0 +1,970 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_up4
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	p4ConfigV1 "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcStatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const p4RuntimeAPIVersion = "1.3.0"
+
+// streamClient is a client connected to the stream channel.
+type streamClient struct {
+	server     p4.P4Runtime_StreamChannelServer
+	electionID *p4.Uint128
+
+	// sendMu serializes the messages sent on the stream, as gRPC streams are not safe for concurrent sends.
+	sendMu sync.Mutex
+}
+
+func (c *streamClient) send(msg *p4.StreamMessageResponse) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	return c.server.Send(msg)
+}
+
+// p4Object is the state of a P4 table, meter or counter.
+type p4Object struct {
+	tableEntries map[string]*p4.TableEntry
+	meterConfigs map[int64]*p4.MeterConfig
+	counterData  map[int64]*p4.CounterData
+}
+
+type fakeP4RuntimeService struct {
+	p4.UnimplementedP4RuntimeServer
+
+	deviceID uint64
+
+	// mu guards all the fields below.
+	mu       sync.Mutex
+	p4info   *p4ConfigV1.P4Info
+	cookie   uint64
+	tables   map[uint32]*p4ConfigV1.Table
+	actions  map[uint32]*p4ConfigV1.Action
+	meters   map[uint32]*p4ConfigV1.Meter
+	counters map[uint32]*p4ConfigV1.Counter
+	state    map[uint32]*p4Object
+	clients  map[*streamClient]struct{}
+	primary  *streamClient
+	// highestElectionID is the highest election ID of the connected clients, i.e. of the primary.
+	highestElectionID *p4.Uint128
+	digestID          uint64
+}
+
+func newFakeP4RuntimeService(deviceID uint64, p4info *p4ConfigV1.P4Info) *fakeP4RuntimeService {
+	s := &fakeP4RuntimeService{
+		deviceID: deviceID,
+		clients:  make(map[*streamClient]struct{}),
+	}
+	s.unsafeSetP4Info(p4info)
+
+	return s
+}
+
+// unsafeSetP4Info loads p4info, clearing the state of the previous pipeline.
+func (s *fakeP4RuntimeService) unsafeSetP4Info(p4info *p4ConfigV1.P4Info) {
+	s.p4info = p4info
+	s.tables = make(map[uint32]*p4ConfigV1.Table)
+	s.actions = make(map[uint32]*p4ConfigV1.Action)
+	s.meters = make(map[uint32]*p4ConfigV1.Meter)
+	s.counters = make(map[uint32]*p4ConfigV1.Counter)
+	s.state = make(map[uint32]*p4Object)
+
+	for _, t := range p4info.GetTables() {
+		s.tables[t.GetPreamble().GetId()] = t
+		s.state[t.GetPreamble().GetId()] = &p4Object{tableEntries: make(map[string]*p4.TableEntry)}
+	}
+
+	for _, a := range p4info.GetActions() {
+		s.actions[a.GetPreamble().GetId()] = a
+	}
+
+	for _, m := range p4info.GetMeters() {
+		s.meters[m.GetPreamble().GetId()] = m
+		s.state[m.GetPreamble().GetId()] = &p4Object{meterConfigs: make(map[int64]*p4.MeterConfig)}
+	}
+
+	for _, c := range p4info.GetCounters() {
+		s.counters[c.GetPreamble().GetId()] = c
+		s.state[c.GetPreamble().GetId()] = &p4Object{counterData: make(map[int64]*p4.CounterData)}
+	}
+}
+
+func (s *fakeP4RuntimeService) checkDeviceID(deviceID uint64) error {
+	if deviceID != s.deviceID {
+		return status.Errorf(codes.NotFound, "unknown device ID %d", deviceID)
+	}
+
+	return nil
+}
+
+// unsafeCheckPrimary returns an error if electionID is not the one of the primary client.
+func (s *fakeP4RuntimeService) unsafeCheckPrimary(electionID *p4.Uint128) error {
+	if s.primary == nil || !proto.Equal(s.primary.electionID, electionID) {
+		return status.Errorf(codes.PermissionDenied, "election ID %v is not the primary one", electionID)
+	}
+
+	return nil
+}
+
+func (s *fakeP4RuntimeService) Capabilities(context.Context, *p4.CapabilitiesRequest) (*p4.CapabilitiesResponse, error) {
+	return &p4.CapabilitiesResponse{P4RuntimeApiVersion: p4RuntimeAPIVersion}, nil
+}
+
+func (s *fakeP4RuntimeService) SetForwardingPipelineConfig(_ context.Context, req *p4.SetForwardingPipelineConfigRequest) (*p4.SetForwardingPipelineConfigResponse, error) {
+	if err := s.checkDeviceID(req.GetDeviceId()); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.unsafeCheckPrimary(req.GetElectionId()); err != nil {
+		return nil, err
+	}
+
+	switch req.GetAction() {
+	case p4.SetForwardingPipelineConfigRequest_VERIFY:
+		return &p4.SetForwardingPipelineConfigResponse{}, nil
+	case p4.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT, p4.SetForwardingPipelineConfigRequest_RECONCILE_AND_COMMIT:
+		if req.GetConfig().GetP4Info() == nil {
+			return nil, status.Error(codes.InvalidArgument, "missing p4info")
+		}
+
+		s.unsafeSetP4Info(req.GetConfig().GetP4Info())
+		s.cookie = req.GetConfig().GetCookie().GetCookie()
+
+		return &p4.SetForwardingPipelineConfigResponse{}, nil
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "unsupported action %v", req.GetAction())
+	}
+}
+
+func (s *fakeP4RuntimeService) GetForwardingPipelineConfig(_ context.Context, req *p4.GetForwardingPipelineConfigRequest) (*p4.GetForwardingPipelineConfigResponse, error) {
+	if err := s.checkDeviceID(req.GetDeviceId()); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &p4.GetForwardingPipelineConfigResponse{
+		Config: &p4.ForwardingPipelineConfig{
+			P4Info: s.p4info,
+			Cookie: &p4.ForwardingPipelineConfig_Cookie{Cookie: s.cookie},
+		},
+	}, nil
+}
+
+// StreamChannel handles the mastership arbitration of the clients. Packet-outs are discarded.
+func (s *fakeP4RuntimeService) StreamChannel(server p4.P4Runtime_StreamChannelServer) error {
+	client := &streamClient{server: server}
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.clients, client)
+
+		if client == s.primary {
+			s.unsafeElectPrimary()
+			s.unsafeNotifyArbitration()
+		}
+	}()
+
+	for {
+		req, err := server.Recv()
+		if err != nil {
+			// the client closed the stream
+			return nil
+		}
+
+		switch {
+		case req.GetArbitration() != nil:
+			if err := s.handleArbitration(client, req.GetArbitration()); err != nil {
+				return err
+			}
+		case req.GetPacket() != nil:
+			log.WithField("payload", req.GetPacket().GetPayload()).Trace("Discarding packet-out")
+		default:
+			log.WithField("request", req).Debug("Ignoring stream message")
+		}
+	}
+}
+
+func (s *fakeP4RuntimeService) handleArbitration(client *streamClient, arb *p4.MasterArbitrationUpdate) error {
+	if err := s.checkDeviceID(arb.GetDeviceId()); err != nil {
+		return err
+	}
+
+	if arb.GetElectionId() == nil {
+		return status.Error(codes.InvalidArgument, "missing election ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for other := range s.clients {
+		if other != client && proto.Equal(other.electionID, arb.GetElectionId()) {
+			return status.Errorf(codes.InvalidArgument, "election ID %v already used by another client", arb.GetElectionId())
+		}
+	}
+
+	client.electionID = arb.GetElectionId()
+	s.clients[client] = struct{}{}
+
+	if s.highestElectionID == nil || !electionIDLess(client.electionID, s.highestElectionID) {
+		s.highestElectionID = client.electionID
+		s.primary = client
+	} else if client == s.primary {
+		// the primary lowered its election ID
+		s.primary = nil
+	}
+
+	s.unsafeNotifyArbitration()
+
+	return nil
+}
+
+func electionIDLess(a, b *p4.Uint128) bool {
+	if a.GetHigh() != b.GetHigh() {
+		return a.GetHigh() < b.GetHigh()
+	}
+
+	return a.GetLow() < b.GetLow()
+}
+
+// unsafeElectPrimary makes the connected client with the highest election ID primary, as Stratum
+// (and so mock-up4) does when the primary disconnects.
+func (s *fakeP4RuntimeService) unsafeElectPrimary() {
+	s.primary = nil
+	s.highestElectionID = nil
+
+	for c := range s.clients {
+		if s.highestElectionID == nil || electionIDLess(s.highestElectionID, c.electionID) {
+			s.highestElectionID = c.electionID
+			s.primary = c
+		}
+	}
+}
+
+// unsafeNotifyArbitration notifies all clients of the result of the mastership arbitration.
+// A client becomes primary with an election ID not lower than the highest one of the connected
+// clients.
+func (s *fakeP4RuntimeService) unsafeNotifyArbitration() {
+	for c := range s.clients {
+		statusCode := code.Code_NOT_FOUND
+
+		switch {
+		case c == s.primary:
+			statusCode = code.Code_OK
+		case s.primary != nil:
+			statusCode = code.Code_ALREADY_EXISTS
+		}
+
+		msg := &p4.StreamMessageResponse{
+			Update: &p4.StreamMessageResponse_Arbitration{
+				Arbitration: &p4.MasterArbitrationUpdate{
+					DeviceId:   s.deviceID,
+					ElectionId: s.highestElectionID,
+					Status:     &rpcStatus.Status{Code: int32(statusCode)},
+				},
+			},
+		}
+
+		if err := c.send(msg); err != nil {
+			log.Warnf("Failed to send arbitration update: %v", err)
+		}
+	}
+}
+
+// sendDigest sends a digest list with data to the primary client.
+func (s *fakeP4RuntimeService) sendDigest(digestID uint32, data ...*p4.P4Data) error {
+	s.mu.Lock()
+	primary := s.primary
+	s.digestID++
+	listID := s.digestID
+	s.mu.Unlock()
+
+	if primary == nil {
+		return status.Error(codes.FailedPrecondition, "no primary client")
+	}
+
+	return primary.send(&p4.StreamMessageResponse{
+		Update: &p4.StreamMessageResponse_Digest{
+			Digest: &p4.DigestList{
+				DigestId: digestID,
+				ListId:   listID,
+				Data:     data,
+			},
+		},
+	})
+}
+
+func (s *fakeP4RuntimeService) Write(_ context.Context, req *p4.WriteRequest) (*p4.WriteResponse, error) {
+	if err := s.checkDeviceID(req.GetDeviceId()); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.unsafeCheckPrimary(req.GetElectionId()); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	switch req.GetAtomicity() {
+	case p4.WriteRequest_CONTINUE_ON_ERROR:
+		errs = make([]error, len(req.GetUpdates()))
+		for i, update := range req.GetUpdates() {
+			errs[i] = s.unsafeApplyUpdate(update)
+		}
+	case p4.WriteRequest_ROLLBACK_ON_ERROR:
+		errs = s.unsafeApplyUpdatesWithRollback(req.GetUpdates())
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "unsupported atomicity %v", req.GetAtomicity())
+	}
+
+	if err := writeError(errs); err != nil {
+		return nil, err
+	}
+
+	return &p4.WriteResponse{}, nil
+}
+
+// unsafeApplyUpdatesWithRollback applies updates, or none of them if any fails.
+func (s *fakeP4RuntimeService) unsafeApplyUpdatesWithRollback(updates []*p4.Update) []error {
+	snapshot := s.unsafeSnapshot()
+	errs := make([]error, len(updates))
+	failed := false
+
+	for i, update := range updates {
+		errs[i] = s.unsafeApplyUpdate(update)
+		if errs[i] != nil {
+			failed = true
+		}
+	}
+
+	if !failed {
+		return errs
+	}
+
+	s.state = snapshot
+
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = status.Error(codes.Aborted, "rolled back because of another failed update")
+		}
+	}
+
+	return errs
+}
+
+// unsafeSnapshot returns a copy of the state. Entries are replaced, not modified, by updates,
+// so they can be shared with the copy.
+func (s *fakeP4RuntimeService) unsafeSnapshot() map[uint32]*p4Object {
+	snapshot := make(map[uint32]*p4Object, len(s.state))
+
+	for id, obj := range s.state {
+		c := &p4Object{}
+
+		if obj.tableEntries != nil {
+			c.tableEntries = make(map[string]*p4.TableEntry, len(obj.tableEntries))
+			for k, v := range obj.tableEntries {
+				c.tableEntries[k] = v
+			}
+		}
+
+		if obj.meterConfigs != nil {
+			c.meterConfigs = make(map[int64]*p4.MeterConfig, len(obj.meterConfigs))
+			for k, v := range obj.meterConfigs {
+				c.meterConfigs[k] = v
+			}
+		}
+
+		if obj.counterData != nil {
+			c.counterData = make(map[int64]*p4.CounterData, len(obj.counterData))
+			for k, v := range obj.counterData {
+				c.counterData[k] = v
+			}
+		}
+
+		snapshot[id] = c
+	}
+
+	return snapshot
+}
+
+// writeError returns the error of a Write request, with one p4.Error per update.
+// See https://p4.org/p4-spec/p4runtime/main/P4Runtime-Spec.html#sec-error-reporting-messages.
+func writeError(errs []error) error {
+	failed := false
+	details := make([]*anypb.Any, 0, len(errs))
+
+	for _, err := range errs {
+		p4Error := &p4.Error{CanonicalCode: int32(codes.OK)}
+
+		if err != nil {
+			failed = true
+			st, _ := status.FromError(err)
+			p4Error = &p4.Error{CanonicalCode: int32(st.Code()), Message: st.Message()}
+		}
+
+		detail, err := anypb.New(proto.MessageV2(p4Error))
+		if err != nil {
+			return status.Errorf(codes.Internal, "marshal error details: %v", err)
+		}
+
+		details = append(details, detail)
+	}
+
+	if !failed {
+		return nil
+	}
+
+	return status.FromProto(&rpcStatus.Status{
+		Code:    int32(codes.Unknown),
+		Message: "write failed",
+		Details: details,
+	}).Err()
+}
+
+func (s *fakeP4RuntimeService) unsafeApplyUpdate(update *p4.Update) error {
+	switch e := update.GetEntity().GetEntity().(type) {
+	case *p4.Entity_TableEntry:
+		return s.unsafeApplyTableEntry(update.GetType(), e.TableEntry)
+	case *p4.Entity_MeterEntry:
+		return s.unsafeApplyMeterEntry(update.GetType(), e.MeterEntry)
+	case *p4.Entity_CounterEntry:
+		return s.unsafeApplyCounterEntry(update.GetType(), e.CounterEntry)
+	default:
+		return status.Errorf(codes.Unimplemented, "unsupported entity %T", e)
+	}
+}
+
+func (s *fakeP4RuntimeService) unsafeApplyTableEntry(updateType p4.Update_Type, entry *p4.TableEntry) error {
+	table, ok := s.tables[entry.GetTableId()]
+	if !ok {
+		return status.Errorf(codes.NotFound, "unknown table %d", entry.GetTableId())
+	}
+
+	if entry.GetIsDefaultAction() {
+		return status.Error(codes.Unimplemented, "default actions are not supported")
+	}
+
+	key, err := tableEntryKey(table, entry)
+	if err != nil {
+		return err
+	}
+
+	entries := s.state[entry.GetTableId()].tableEntries
+	_, exists := entries[key]
+
+	switch updateType {
+	case p4.Update_INSERT:
+		if exists {
+			return status.Errorf(codes.AlreadyExists, "entry already exists in table %s", table.GetPreamble().GetName())
+		}
+
+		if int64(len(entries)) >= table.GetSize() {
+			return status.Errorf(codes.ResourceExhausted, "table %s is full", table.GetPreamble().GetName())
+		}
+	case p4.Update_MODIFY, p4.Update_DELETE:
+		if !exists {
+			return status.Errorf(codes.NotFound, "entry not found in table %s", table.GetPreamble().GetName())
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument, "invalid update type %v", updateType)
+	}
+
+	if updateType == p4.Update_DELETE {
+		delete(entries, key)
+		return nil
+	}
+
+	if err := s.unsafeValidateAction(table, entry.GetAction()); err != nil {
+		return err
+	}
+
+	entries[key] = canonicalTableEntry(entry)
+
+	return nil
+}
+
+// canonicalTableEntry returns a copy of entry with its match fields and action parameters in the
+// canonical binary string representation, as stored and read back by P4Runtime servers.
+func canonicalTableEntry(entry *p4.TableEntry) *p4.TableEntry {
+	entry = proto.Clone(entry).(*p4.TableEntry)
+
+	for _, m := range entry.GetMatch() {
+		switch fm := m.GetFieldMatchType().(type) {
+		case *p4.FieldMatch_Exact_:
+			fm.Exact.Value = canonicalBytestring(fm.Exact.GetValue())
+		case *p4.FieldMatch_Lpm:
+			fm.Lpm.Value = canonicalBytestring(fm.Lpm.GetValue())
+		case *p4.FieldMatch_Ternary_:
+			fm.Ternary.Value = canonicalBytestring(fm.Ternary.GetValue())
+			fm.Ternary.Mask = canonicalBytestring(fm.Ternary.GetMask())
+		case *p4.FieldMatch_Range_:
+			fm.Range.Low = canonicalBytestring(fm.Range.GetLow())
+			fm.Range.High = canonicalBytestring(fm.Range.GetHigh())
+		case *p4.FieldMatch_Optional_:
+			fm.Optional.Value = canonicalBytestring(fm.Optional.GetValue())
+		}
+	}
+
+	for _, param := range entry.GetAction().GetAction().GetParams() {
+		param.Value = canonicalBytestring(param.GetValue())
+	}
+
+	return entry
+}
+
+// validateValue returns an error if value does not fit in bitwidth bits.
+func validateValue(what string, value []byte, bitwidth int32) error {
+	value = canonicalBytes(value)
+
+	if len(value) == 0 {
+		return nil
+	}
+
+	if len(value) > int(bitwidth+7)/8 || (len(value) == int(bitwidth+7)/8 && bitwidth%8 != 0 && value[0]>>uint(bitwidth%8) != 0) {
+		return status.Errorf(codes.OutOfRange, "%s does not fit in %d bits", what, bitwidth)
+	}
+
+	return nil
+}
+
+// canonicalBytes strips the leading zeros of value.
+func canonicalBytes(value []byte) []byte {
+	return bytes.TrimLeft(value, "\x00")
+}
+
+// canonicalBytestring strips the leading zeros of value, keeping a single byte for zero.
+func canonicalBytestring(value []byte) []byte {
+	if len(value) == 0 {
+		return value
+	}
+
+	if c := canonicalBytes(value); len(c) > 0 {
+		return c
+	}
+
+	return []byte{0}
+}
+
+func tableNeedsPriority(table *p4ConfigV1.Table) bool {
+	for _, mf := range table.GetMatchFields() {
+		switch mf.GetMatchType() {
+		case p4ConfigV1.MatchField_TERNARY, p4ConfigV1.MatchField_RANGE, p4ConfigV1.MatchField_OPTIONAL:
+			return true
+		}
+	}
+
+	return false
+}
+
+// tableEntryKey validates the match fields of entry and returns the key identifying it in table.
+func tableEntryKey(table *p4ConfigV1.Table, entry *p4.TableEntry) (string, error) {
+	if needsPriority := tableNeedsPriority(table); needsPriority != (entry.GetPriority() != 0) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid priority %d for table %s",
+			entry.GetPriority(), table.GetPreamble().GetName())
+	}
+
+	fields := make(map[uint32]*p4ConfigV1.MatchField)
+	for _, mf := range table.GetMatchFields() {
+		fields[mf.GetId()] = mf
+	}
+
+	matches := make([]*p4.FieldMatch, len(entry.GetMatch()))
+	copy(matches, entry.GetMatch())
+	sort.Slice(matches, func(i, j int) bool { return matches[i].GetFieldId() < matches[j].GetFieldId() })
+
+	key := &bytes.Buffer{}
+	fmt.Fprintf(key, "%d/", entry.GetPriority())
+
+	for i, m := range matches {
+		mf, ok := fields[m.GetFieldId()]
+		if !ok {
+			return "", status.Errorf(codes.InvalidArgument, "unknown match field %d", m.GetFieldId())
+		}
+
+		if i > 0 && matches[i-1].GetFieldId() == m.GetFieldId() {
+			return "", status.Errorf(codes.InvalidArgument, "duplicate match field %s", mf.GetName())
+		}
+
+		values, err := matchValues(mf, m)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(key, "%d", m.GetFieldId())
+
+		for _, v := range values {
+			if err := validateValue(mf.GetName(), v, mf.GetBitwidth()); err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(key, ":%x", canonicalBytes(v))
+		}
+
+		if m.GetLpm() != nil {
+			fmt.Fprintf(key, ":%d", m.GetLpm().GetPrefixLen())
+		}
+
+		key.WriteString("/")
+	}
+
+	for _, mf := range table.GetMatchFields() {
+		if mf.GetMatchType() != p4ConfigV1.MatchField_EXACT {
+			continue
+		}
+
+		found := false
+
+		for _, m := range matches {
+			found = found || m.GetFieldId() == mf.GetId()
+		}
+
+		if !found {
+			return "", status.Errorf(codes.InvalidArgument, "missing exact match field %s", mf.GetName())
+		}
+	}
+
+	return key.String(), nil
+}
+
+// matchValues returns the values of m, if m is of the match type of mf.
+func matchValues(mf *p4ConfigV1.MatchField, m *p4.FieldMatch) ([][]byte, error) {
+	switch mf.GetMatchType() {
+	case p4ConfigV1.MatchField_EXACT:
+		if m.GetExact() != nil {
+			return [][]byte{m.GetExact().GetValue()}, nil
+		}
+	case p4ConfigV1.MatchField_LPM:
+		if m.GetLpm() != nil {
+			if m.GetLpm().GetPrefixLen() <= 0 || m.GetLpm().GetPrefixLen() > mf.GetBitwidth() {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid prefix length %d of %s",
+					m.GetLpm().GetPrefixLen(), mf.GetName())
+			}
+
+			return [][]byte{m.GetLpm().GetValue()}, nil
+		}
+	case p4ConfigV1.MatchField_TERNARY:
+		if m.GetTernary() != nil {
+			return [][]byte{m.GetTernary().GetValue(), m.GetTernary().GetMask()}, nil
+		}
+	case p4ConfigV1.MatchField_RANGE:
+		if m.GetRange() != nil {
+			return [][]byte{m.GetRange().GetLow(), m.GetRange().GetHigh()}, nil
+		}
+	}
+
+	return nil, status.Errorf(codes.InvalidArgument, "invalid match type of %s", mf.GetName())
+}
+
+func (s *fakeP4RuntimeService) unsafeValidateAction(table *p4ConfigV1.Table, tableAction *p4.TableAction) error {
+	action := tableAction.GetAction()
+	if action == nil {
+		return status.Errorf(codes.InvalidArgument, "missing action for table %s", table.GetPreamble().GetName())
+	}
+
+	allowed := false
+
+	for _, ref := range table.GetActionRefs() {
+		if ref.GetId() == action.GetActionId() && ref.GetScope() != p4ConfigV1.ActionRef_DEFAULT_ONLY {
+			allowed = true
+		}
+	}
+
+	p4Action, ok := s.actions[action.GetActionId()]
+	if !allowed || !ok {
+		return status.Errorf(codes.InvalidArgument, "invalid action %d for table %s",
+			action.GetActionId(), table.GetPreamble().GetName())
+	}
+
+	params := make(map[uint32]*p4.Action_Param)
+	for _, p := range action.GetParams() {
+		params[p.GetParamId()] = p
+	}
+
+	if len(params) != len(action.GetParams()) || len(params) != len(p4Action.GetParams()) {
+		return status.Errorf(codes.InvalidArgument, "invalid params of action %s", p4Action.GetPreamble().GetName())
+	}
+
+	for _, p4Param := range p4Action.GetParams() {
+		p, ok := params[p4Param.GetId()]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "missing param %s of action %s",
+				p4Param.GetName(), p4Action.GetPreamble().GetName())
+		}
+
+		if err := validateValue(p4Param.GetName(), p.GetValue(), p4Param.GetBitwidth()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkIndex(name string, index *p4.Index, size int64) error {
+	if index == nil {
+		return status.Errorf(codes.InvalidArgument, "missing index of %s", name)
+	}
+
+	if index.GetIndex() < 0 || index.GetIndex() >= size {
+		return status.Errorf(codes.OutOfRange, "index %d of %s out of range", index.GetIndex(), name)
+	}
+
+	return nil
+}
+
+func (s *fakeP4RuntimeService) unsafeApplyMeterEntry(updateType p4.Update_Type, entry *p4.MeterEntry) error {
+	meter, ok := s.meters[entry.GetMeterId()]
+	if !ok {
+		return status.Errorf(codes.NotFound, "unknown meter %d", entry.GetMeterId())
+	}
+
+	if updateType != p4.Update_MODIFY {
+		return status.Errorf(codes.InvalidArgument, "invalid update type %v for meter entries", updateType)
+	}
+
+	if err := checkIndex(meter.GetPreamble().GetName(), entry.GetIndex(), meter.GetSize()); err != nil {
+		return err
+	}
+
+	configs := s.state[entry.GetMeterId()].meterConfigs
+
+	if entry.GetConfig() == nil {
+		// reset to the default configuration
+		delete(configs, entry.GetIndex().GetIndex())
+		return nil
+	}
+
+	configs[entry.GetIndex().GetIndex()] = proto.Clone(entry.GetConfig()).(*p4.MeterConfig)
+
+	return nil
+}
+
+func (s *fakeP4RuntimeService) unsafeApplyCounterEntry(updateType p4.Update_Type, entry *p4.CounterEntry) error {
+	counter, ok := s.counters[entry.GetCounterId()]
+	if !ok {
+		return status.Errorf(codes.NotFound, "unknown counter %d", entry.GetCounterId())
+	}
+
+	if updateType != p4.Update_MODIFY {
+		return status.Errorf(codes.InvalidArgument, "invalid update type %v for counter entries", updateType)
+	}
+
+	if err := checkIndex(counter.GetPreamble().GetName(), entry.GetIndex(), counter.GetSize()); err != nil {
+		return err
+	}
+
+	data := &p4.CounterData{}
+	if entry.GetData() != nil {
+		data = proto.Clone(entry.GetData()).(*p4.CounterData)
+	}
+
+	s.state[entry.GetCounterId()].counterData[entry.GetIndex().GetIndex()] = data
+
+	return nil
+}
+
+func (s *fakeP4RuntimeService) Read(req *p4.ReadRequest, server p4.P4Runtime_ReadServer) error {
+	if err := s.checkDeviceID(req.GetDeviceId()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+
+	resp := &p4.ReadResponse{}
+
+	for _, entity := range req.GetEntities() {
+		var (
+			entities []*p4.Entity
+			err      error
+		)
+
+		switch e := entity.GetEntity().(type) {
+		case *p4.Entity_TableEntry:
+			entities, err = s.unsafeReadTableEntries(e.TableEntry)
+		case *p4.Entity_MeterEntry:
+			entities, err = s.unsafeReadMeterEntries(e.MeterEntry)
+		case *p4.Entity_CounterEntry:
+			entities, err = s.unsafeReadCounterEntries(e.CounterEntry)
+		default:
+			err = status.Errorf(codes.Unimplemented, "unsupported entity %T", e)
+		}
+
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+
+		resp.Entities = append(resp.Entities, entities...)
+	}
+
+	s.mu.Unlock()
+
+	return server.Send(resp)
+}
+
+func sortedIDs(ids map[uint32]*p4Object, filter func(uint32) bool) []uint32 {
+	sorted := make([]uint32, 0)
+
+	for id := range ids {
+		if filter(id) {
+			sorted = append(sorted, id)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted
+}
+
+func (s *fakeP4RuntimeService) unsafeReadTableEntries(filter *p4.TableEntry) ([]*p4.Entity, error) {
+	tableIDs := []uint32{filter.GetTableId()}
+
+	if filter.GetTableId() == 0 {
+		tableIDs = sortedIDs(s.state, func(id uint32) bool { return s.tables[id] != nil })
+	} else if _, ok := s.tables[filter.GetTableId()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown table %d", filter.GetTableId())
+	}
+
+	entities := make([]*p4.Entity, 0)
+
+	for _, id := range tableIDs {
+		entries := s.state[id].tableEntries
+
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		if len(filter.GetMatch()) != 0 {
+			key, err := tableEntryKey(s.tables[id], filter)
+			if err != nil {
+				return nil, err
+			}
+
+			keys = []string{key}
+		}
+
+		for _, k := range keys {
+			if entry, ok := entries[k]; ok {
+				entities = append(entities, &p4.Entity{Entity: &p4.Entity_TableEntry{
+					TableEntry: proto.Clone(entry).(*p4.TableEntry),
+				}})
+			}
+		}
+	}
+
+	return entities, nil
+}
+
+// cellIndexes returns the indexes of the cells selected by index, all of them if index is nil.
+func cellIndexes(name string, index *p4.Index, size int64) ([]int64, error) {
+	if index != nil {
+		if err := checkIndex(name, index, size); err != nil {
+			return nil, err
+		}
+
+		return []int64{index.GetIndex()}, nil
+	}
+
+	indexes := make([]int64, size)
+	for i := range indexes {
+		indexes[i] = int64(i)
+	}
+
+	return indexes, nil
+}
+
+func (s *fakeP4RuntimeService) unsafeReadMeterEntries(filter *p4.MeterEntry) ([]*p4.Entity, error) {
+	meterIDs := []uint32{filter.GetMeterId()}
+
+	if filter.GetMeterId() == 0 {
+		meterIDs = sortedIDs(s.state, func(id uint32) bool { return s.meters[id] != nil })
+	} else if _, ok := s.meters[filter.GetMeterId()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown meter %d", filter.GetMeterId())
+	}
+
+	entities := make([]*p4.Entity, 0)
+
+	for _, id := range meterIDs {
+		meter := s.meters[id]
+
+		indexes, err := cellIndexes(meter.GetPreamble().GetName(), filter.GetIndex(), meter.GetSize())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range indexes {
+			entry := &p4.MeterEntry{MeterId: id, Index: &p4.Index{Index: i}}
+			if config, ok := s.state[id].meterConfigs[i]; ok {
+				entry.Config = proto.Clone(config).(*p4.MeterConfig)
+			}
+
+			entities = append(entities, &p4.Entity{Entity: &p4.Entity_MeterEntry{MeterEntry: entry}})
+		}
+	}
+
+	return entities, nil
+}
+
+func (s *fakeP4RuntimeService) unsafeReadCounterEntries(filter *p4.CounterEntry) ([]*p4.Entity, error) {
+	counterIDs := []uint32{filter.GetCounterId()}
+
+	if filter.GetCounterId() == 0 {
+		counterIDs = sortedIDs(s.state, func(id uint32) bool { return s.counters[id] != nil })
+	} else if _, ok := s.counters[filter.GetCounterId()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown counter %d", filter.GetCounterId())
+	}
+
+	entities := make([]*p4.Entity, 0)
+
+	for _, id := range counterIDs {
+		counter := s.counters[id]
+
+		indexes, err := cellIndexes(counter.GetPreamble().GetName(), filter.GetIndex(), counter.GetSize())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range indexes {
+			data := &p4.CounterData{}
+			if d, ok := s.state[id].counterData[i]; ok {
+				data = proto.Clone(d).(*p4.CounterData)
+			}
+
+			entities = append(entities, &p4.Entity{Entity: &p4.Entity_CounterEntry{CounterEntry: &p4.CounterEntry{
+				CounterId: id,
+				Index:     &p4.Index{Index: i},
+				Data:      data,
+			}}})
+		}
+	}
+
+	return entities, nil
+}
@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package fake_up4
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/omec-project/upf-epc/internal/p4constants"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const testP4InfoPath = "../../conf/p4/bin/p4info.txt"
+
+func startTestFakeUP4(t *testing.T) (*FakeUP4, p4.P4RuntimeClient) {
+	u, err := NewFakeUP4(testP4InfoPath)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		if err := u.Serve(listener); err != nil {
+			t.Logf("fake UP4 stopped: %v", err)
+		}
+	}()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		u.Stop()
+	})
+
+	return u, p4.NewP4RuntimeClient(conn)
+}
+
+// arbitrate opens a stream channel with electionID, and returns it with the status of the arbitration.
+func arbitrate(t *testing.T, client p4.P4RuntimeClient, electionID uint64) (p4.P4Runtime_StreamChannelClient, code.Code) {
+	stream, err := client.StreamChannel(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&p4.StreamMessageRequest{
+		Update: &p4.StreamMessageRequest_Arbitration{Arbitration: &p4.MasterArbitrationUpdate{
+			DeviceId:   DefaultDeviceID,
+			ElectionId: &p4.Uint128{Low: electionID},
+		}},
+	}))
+
+	return stream, nextArbitrationStatus(t, stream)
+}
+
+func nextArbitrationStatus(t *testing.T, stream p4.P4Runtime_StreamChannelClient) code.Code {
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.NotNil(t, resp.GetArbitration())
+
+	return code.Code(resp.GetArbitration().GetStatus().GetCode())
+}
+
+func newTestTunnelPeerEntry(t *testing.T, peerID uint8) *p4.TableEntry {
+	action, err := p4constants.BuildActionPreQosPipeLoadTunnelParam(p4constants.ActionPreQosPipeLoadTunnelParamParams{
+		SrcAddr: 0x0a000001,
+		DstAddr: 0x0a000002,
+		Sport:   2152,
+	})
+	require.NoError(t, err)
+
+	entry, err := p4constants.BuildTablePreQosPipeTunnelPeersEntry(p4constants.TablePreQosPipeTunnelPeersMatch{
+		TunnelPeerId: peerID,
+	}, action)
+	require.NoError(t, err)
+
+	return entry
+}
+
+func write(client p4.P4RuntimeClient, electionID uint64, atomicity p4.WriteRequest_Atomicity, updates ...*p4.Update) error {
+	_, err := client.Write(context.Background(), &p4.WriteRequest{
+		DeviceId:   DefaultDeviceID,
+		ElectionId: &p4.Uint128{Low: electionID},
+		Updates:    updates,
+		Atomicity:  atomicity,
+	})
+
+	return err
+}
+
+func tableUpdate(updateType p4.Update_Type, entry *p4.TableEntry) *p4.Update {
+	return &p4.Update{Type: updateType, Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: entry}}}
+}
+
+// updateErrorCodes returns the canonical codes of the p4.Error details of a Write error.
+func updateErrorCodes(t *testing.T, err error) []codes.Code {
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.Unknown, st.Code())
+
+	result := make([]codes.Code, 0)
+
+	for _, detail := range st.Details() {
+		p4Error, ok := detail.(*p4.Error)
+		require.True(t, ok)
+
+		result = append(result, codes.Code(p4Error.GetCanonicalCode()))
+	}
+
+	return result
+}
+
+func Test_FakeUP4_arbitration(t *testing.T) {
+	u, client := startTestFakeUP4(t)
+
+	primary, st := arbitrate(t, client, 2)
+	require.Equal(t, code.Code_OK, st)
+	require.Equal(t, uint64(2), u.PrimaryElectionID().GetLow())
+
+	backup, st := arbitrate(t, client, 1)
+	require.Equal(t, code.Code_ALREADY_EXISTS, st)
+	// the primary is notified too
+	require.Equal(t, code.Code_OK, nextArbitrationStatus(t, primary))
+
+	err := write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR, tableUpdate(p4.Update_INSERT, newTestTunnelPeerEntry(t, 2)))
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the client with the highest election ID becomes primary after the primary disconnects
+	require.NoError(t, primary.CloseSend())
+	require.Equal(t, code.Code_OK, nextArbitrationStatus(t, backup))
+	require.Equal(t, uint64(1), u.PrimaryElectionID().GetLow())
+
+	// a client with a higher election ID claims the mastership
+	other, st := arbitrate(t, client, 3)
+	require.Equal(t, code.Code_OK, st)
+	require.Equal(t, code.Code_ALREADY_EXISTS, nextArbitrationStatus(t, backup))
+	require.NoError(t, other.CloseSend())
+}
+
+func Test_FakeUP4_write(t *testing.T) {
+	u, client := startTestFakeUP4(t)
+
+	_, st := arbitrate(t, client, 1)
+	require.Equal(t, code.Code_OK, st)
+
+	require.NoError(t, write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR,
+		tableUpdate(p4.Update_INSERT, newTestTunnelPeerEntry(t, 2))))
+	require.Len(t, u.GetTableEntries(p4constants.TablePreQosPipeTunnelPeers), 1)
+
+	// keys are compared regardless of the leading zeros of the values
+	padded := newTestTunnelPeerEntry(t, 2)
+	padded.Match[0].GetExact().Value = []byte{0x00, 0x02}
+	err := write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR, tableUpdate(p4.Update_INSERT, padded))
+	require.Equal(t, []codes.Code{codes.AlreadyExists}, updateErrorCodes(t, err))
+
+	// values are stored in the canonical representation, as read back from Stratum
+	padded.Action.GetAction().Params[2].Value = []byte{0x00, 0x00, 0x08, 0x68}
+	require.NoError(t, write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR, tableUpdate(p4.Update_MODIFY, padded)))
+	stored := u.GetTableEntries(p4constants.TablePreQosPipeTunnelPeers)[0]
+	require.Equal(t, []byte{0x02}, stored.GetMatch()[0].GetExact().GetValue())
+	require.Equal(t, []byte{0x08, 0x68}, stored.GetAction().GetAction().GetParams()[2].GetValue())
+
+	// none of the updates is applied if one fails
+	err = write(client, 1, p4.WriteRequest_ROLLBACK_ON_ERROR,
+		tableUpdate(p4.Update_INSERT, newTestTunnelPeerEntry(t, 3)),
+		tableUpdate(p4.Update_DELETE, newTestTunnelPeerEntry(t, 4)))
+	require.Equal(t, []codes.Code{codes.Aborted, codes.NotFound}, updateErrorCodes(t, err))
+	require.Len(t, u.GetTableEntries(p4constants.TablePreQosPipeTunnelPeers), 1)
+
+	// updates are applied independently otherwise
+	err = write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR,
+		tableUpdate(p4.Update_INSERT, newTestTunnelPeerEntry(t, 3)),
+		tableUpdate(p4.Update_DELETE, newTestTunnelPeerEntry(t, 4)))
+	require.Equal(t, []codes.Code{codes.OK, codes.NotFound}, updateErrorCodes(t, err))
+	require.Len(t, u.GetTableEntries(p4constants.TablePreQosPipeTunnelPeers), 2)
+
+	// the action must be allowed for the table
+	invalid := newTestTunnelPeerEntry(t, 5)
+	invalid.Action.GetAction().ActionId = p4constants.ActionPreQosPipeSetAppId
+	err = write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR, tableUpdate(p4.Update_INSERT, invalid))
+	require.Equal(t, []codes.Code{codes.InvalidArgument}, updateErrorCodes(t, err))
+
+	resp, err := client.Read(context.Background(), &p4.ReadRequest{
+		DeviceId: DefaultDeviceID,
+		Entities: []*p4.Entity{{Entity: &p4.Entity_TableEntry{TableEntry: &p4.TableEntry{}}}},
+	})
+	require.NoError(t, err)
+
+	readResp, err := resp.Recv()
+	require.NoError(t, err)
+	require.Len(t, readResp.GetEntities(), 2)
+}
+
+func Test_FakeUP4_metersAndCounters(t *testing.T) {
+	u, client := startTestFakeUP4(t)
+
+	_, st := arbitrate(t, client, 1)
+	require.Equal(t, code.Code_OK, st)
+
+	config := &p4.MeterConfig{Cir: 100, Cburst: 10, Pir: 200, Pburst: 20}
+	meterUpdate := func(config *p4.MeterConfig) *p4.Update {
+		return &p4.Update{Type: p4.Update_MODIFY, Entity: &p4.Entity{Entity: &p4.Entity_MeterEntry{MeterEntry: &p4.MeterEntry{
+			MeterId: p4constants.MeterPreQosPipeAppMeter,
+			Index:   &p4.Index{Index: 3},
+			Config:  config,
+		}}}}
+	}
+
+	require.NoError(t, write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR, meterUpdate(config)))
+
+	entries := u.GetMeterEntries(p4constants.MeterPreQosPipeAppMeter)
+	require.Len(t, entries, 1)
+	require.Equal(t, int64(3), entries[0].GetIndex().GetIndex())
+	require.Equal(t, int64(200), entries[0].GetConfig().GetPir())
+
+	// meters are reset without config
+	require.NoError(t, write(client, 1, p4.WriteRequest_CONTINUE_ON_ERROR, meterUpdate(nil)))
+	require.Empty(t, u.GetMeterEntries(p4constants.MeterPreQosPipeAppMeter))
+
+	require.NoError(t, u.SetCounterData(p4constants.CounterPreQosPipePreQosCounter, 7,
+		&p4.CounterData{ByteCount: 1000, PacketCount: 10}))
+
+	resp, err := client.Read(context.Background(), &p4.ReadRequest{
+		DeviceId: DefaultDeviceID,
+		Entities: []*p4.Entity{{Entity: &p4.Entity_CounterEntry{CounterEntry: &p4.CounterEntry{
+			CounterId: p4constants.CounterPreQosPipePreQosCounter,
+			Index:     &p4.Index{Index: 7},
+		}}}},
+	})
+	require.NoError(t, err)
+
+	readResp, err := resp.Recv()
+	require.NoError(t, err)
+	require.Len(t, readResp.GetEntities(), 1)
+	require.Equal(t, int64(1000), readResp.GetEntities()[0].GetCounterEntry().GetData().GetByteCount())
+
+	require.Error(t, u.SetCounterData(p4constants.CounterPreQosPipePreQosCounter,
+		int64(p4constants.CounterSizePreQosPipePreQosCounter), &p4.CounterData{}))
+}
+
+func Test_FakeUP4_SendDigest(t *testing.T) {
+	u, client := startTestFakeUP4(t)
+
+	require.Error(t, u.SendDigest(1, []byte{0x0a, 0x00, 0x00, 0x01}), "no primary to send the digest to")
+
+	stream, st := arbitrate(t, client, 1)
+	require.Equal(t, code.Code_OK, st)
+
+	require.NoError(t, u.SendDigest(1, []byte{0x0a, 0x00, 0x00, 0x01}))
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x0a, 0x00, 0x00, 0x01}, resp.GetDigest().GetData()[0].GetBitstring())
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/omec-project/upf-epc/pfcpiface"
 	"github.com/wmnsk/go-pfcp/message"
 	"net"
-	"os"
 	"testing"
 	"time"
 
@@ -122,13 +121,13 @@ func TestUPFBasedUeIPAllocation(t *testing.T) {
 
 func TestDetectUP4Restart(t *testing.T) {
 	if !isDatapathUP4() {
-		t.Skipf("Skipping UP4-specific test for datapath: %s", os.Getenv(EnvDatapath))
+		t.Skipf("Skipping UP4-specific test for datapath: %s", testDatapath())
 	}
 
 	run := func(t *testing.T) {
 		// restart UP4, it will close P4Runtime channel between pfcpiface and mock-up4
-		MustStopMockUP4()
-		MustStartMockUP4()
+		MustStopUP4()
+		MustStartUP4()
 
 		// establish session, it forces pfcpiface to re-connect to UP4.
 		// Otherwise, we would need to wait about 2 minutes for pfcpiface to re-connect.
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package integration
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omec-project/pfcpsim/pkg/pfcpsim/session"
+	"github.com/omec-project/upf-epc/pfcpiface"
+	"github.com/omec-project/upf-epc/pfcpiface/bess_pb"
+	"github.com/stretchr/testify/require"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+const downlinkPDRID = 2
+
+func skipUnlessInProcessBESS(t *testing.T) {
+	if !isDatapathBESS() || isModeDocker() {
+		t.Skip("requires the sockets of the BESS fake, only reachable when the PFCP Agent runs in the test process")
+	}
+}
+
+func newBESSTestCase() testCase {
+	return testCase{
+		input: &pfcpSessionData{
+			sliceID:      1,
+			nbAddress:    nodeBAddress,
+			ueAddress:    ueAddress,
+			upfN3Address: upfN3Address,
+			sdfFilter:    "permit out udp from any 80-80 to assigned",
+			ulTEID:       15,
+			dlTEID:       16,
+			QFI:          0x9,
+		},
+	}
+}
+
+// downlinkFSEID returns the F-SEID allocated by the PFCP Agent for the session of the downlink PDR.
+func downlinkFSEID(t *testing.T) uint64 {
+	pdrs := bessFake.GetPdrTableEntries()[downlinkPDRID]
+	require.NotEmpty(t, pdrs, "missing downlink PDR")
+
+	return pdrs[0].FSEID()
+}
+
+// hasSample checks if metrics, in the Prometheus text format, contain a sample starting with prefix.
+func hasSample(metrics string, prefix string) bool {
+	for _, line := range strings.Split(metrics, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestBESSDownlinkDataNotification(t *testing.T) {
+	skipUnlessInProcessBESS(t)
+
+	setup(t, ConfigBESSNotifyAndFlowMeasure)
+	defer teardown(t)
+
+	tc := newBESSTestCase()
+	testUEAttach(t, fillExpected(&tc))
+
+	// start buffering
+	err := pfcpClient.ModifySession(tc.session, nil, []*ie.IE{
+		session.NewFARBuilder().
+			WithMethod(session.Update).WithID(2).
+			WithDstInterface(ie.DstInterfaceAccess).
+			WithAction(ActionBuffer | ActionNotify).WithTEID(0).
+			WithDownlinkIP(tc.input.nbAddress).BuildFAR(),
+	}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, bessFake.NotifyDownlinkData(downlinkFSEID(t)))
+
+	// pfcpsim ignores Session Report Requests, check that the PFCP Agent sent one instead
+	require.Eventually(t, func() bool {
+		return hasSample(scrapeMetrics(t), `pfcp_messages_total{direction="Outgoing",message_type="Session Report Request"`)
+	}, 10*time.Second, 500*time.Millisecond)
+
+	testUEDetach(t, fillExpected(&tc))
+}
+
+func TestBESSMetrics(t *testing.T) {
+	skipUnlessInProcessBESS(t)
+
+	setup(t, ConfigBESSNotifyAndFlowMeasure)
+	defer teardown(t)
+
+	tc := newBESSTestCase()
+	testUEAttach(t, fillExpected(&tc))
+
+	// both the access and core interfaces use the same port
+	bessFake.SetPortStats(BESSConfigDefault().AccessIface.IfName+"Fast",
+		&bess_pb.GetPortStatsResponse_Stat{Packets: 10, Bytes: 1000},
+		&bess_pb.GetPortStatsResponse_Stat{Packets: 20, Bytes: 2000})
+
+	fseid := downlinkFSEID(t)
+	stats := []*bess_pb.FlowMeasureReadResponse_Statistic{
+		{Fseid: fseid, Pdr: downlinkPDRID, TotalPackets: 10, TotalBytes: 1000},
+	}
+	bessFake.SetFlowMeasureStatistics(pfcpiface.PreQosFlowMeasure, stats)
+	bessFake.SetFlowMeasureStatistics(pfcpiface.PostDlQosFlowMeasure, stats)
+
+	// the session statistics are cleared once read, i.e. on each scrape
+	metrics := scrapeMetrics(t)
+	require.True(t, hasSample(metrics, `upf_packets_count{dir="rx",iface="Access"} 10`), metrics)
+	require.True(t, hasSample(metrics, `upf_packets_count{dir="tx",iface="Core"} 20`), metrics)
+	require.True(t, hasSample(metrics, fmt.Sprintf(`upf_session_rx_packets{fseid="%d",pdr="%d",`,
+		fseid, downlinkPDRID)), metrics)
+	require.True(t, hasSample(metrics, fmt.Sprintf(`upf_session_tx_bytes{fseid="%d",pdr="%d",`,
+		fseid, downlinkPDRID)), metrics)
+
+	testUEDetach(t, fillExpected(&tc))
+}
@@ -7,7 +7,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
-	"os"
 	"runtime"
 
 	"github.com/omec-project/upf-epc/pfcpiface"
@@ -18,6 +17,7 @@ const (
 	ConfigDefault = iota
 	ConfigUPFBasedIPAllocation
 	ConfigWipeOutOnUP4Restart
+	ConfigBESSNotifyAndFlowMeasure
 )
 
 const (
@@ -29,6 +29,8 @@ var baseConfig = pfcpiface.Conf{
 	ReadTimeout: 15,
 	RespTimeout: "2s",
 	LogLevel:    logrus.TraceLevel,
+	// default of LoadConfigFile, used for the PFCP requests and the UE rules pushed to the LBs
+	MaxReqRetries: 5,
 }
 
 func BESSConfigDefault() pfcpiface.Conf {
@@ -61,12 +63,25 @@ func BESSConfigUPFBasedIPAllocation() pfcpiface.Conf {
 	return config
 }
 
+// BESSConfigNotifyAndFlowMeasure enables the Downlink Data Notifications, end markers and
+// per session statistics, served by the BESS fake. Only supported in the native mode.
+func BESSConfigNotifyAndFlowMeasure() pfcpiface.Conf {
+	config := BESSConfigDefault()
+	config.EnableNotifyBess = true
+	config.NotifySockAddr = bessFakeNotifySockAddr
+	config.EnableEndMarker = true
+	config.EndMarkerSockAddr = bessFakeEndMarkerSockAddr
+	config.EnableFlowMeasure = true
+
+	return config
+}
+
 func UP4ConfigDefault() pfcpiface.Conf {
 	var up4Server string
-	switch os.Getenv(EnvMode) {
+	switch testMode() {
 	case ModeDocker:
 		up4Server = "mock-up4"
-	case ModeNative:
+	case ModeNative, ModeInProcess:
 		up4Server = "127.0.0.1"
 	}
 
@@ -124,12 +139,12 @@ func GetConfig(datapath string, configType uint32) pfcpiface.Conf {
 			return BESSConfigDefault()
 		case ConfigUPFBasedIPAllocation:
 			return BESSConfigUPFBasedIPAllocation()
+		case ConfigBESSNotifyAndFlowMeasure:
+			return BESSConfigNotifyAndFlowMeasure()
 		}
 	}
 
 	panic("Wrong datapath or config type provided")
-
-	return pfcpiface.Conf{}
 }
 
 func PushSliceMeterConfig(sliceConfig pfcpiface.NetworkSlice) error {
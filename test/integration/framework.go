@@ -8,6 +8,8 @@ import (
 	"errors"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -16,6 +18,8 @@ import (
 	"github.com/omec-project/upf-epc/internal/p4constants"
 	"github.com/omec-project/upf-epc/pfcpiface"
 	"github.com/omec-project/upf-epc/pkg/fake_bess"
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/omec-project/upf-epc/pkg/fake_up4"
 	"github.com/omec-project/upf-epc/test/integration/providers"
 	v1 "github.com/p4lang/p4runtime/go/p4/v1"
 	"github.com/prometheus/client_golang/prometheus"
@@ -42,6 +46,9 @@ const (
 
 	ModeDocker = "docker"
 	ModeNative = "native"
+	// ModeInProcess runs the PFCP Agent and fakes of the datapath and load balancers in the
+	// test process, without Docker. It is the default mode.
+	ModeInProcess = "inprocess"
 
 	defaultSliceID = 0
 
@@ -64,6 +71,14 @@ const (
 
 	p4InfoPath       = "../../conf/p4/bin/p4info.txt"
 	deviceConfigPath = "../../conf/p4/bin/bmv2.json"
+
+	// sockets of the BESS fake, reachable by the PFCP Agent in the native mode only
+	bessFakeNotifySockAddr    = "/tmp/fake-bess-notifycp"
+	bessFakeEndMarkerSockAddr = "/tmp/fake-bess-pfcpport"
+
+	metricsURL        = "http://127.0.0.1:8080/metrics"
+	lbRegistrationURL = "http://127.0.0.1:8080/v1/lb/registration"
+	lbUEsURL          = "http://127.0.0.1:8080/v1/lb/ues"
 )
 
 type UEState uint8
@@ -83,6 +98,14 @@ var (
 	pfcpAgent *pfcpiface.PFCPIface
 
 	bessFake *fake_bess.FakeBESS
+	// up4Fake replaces mock-up4 in the in-process mode
+	up4Fake *fake_up4.FakeUP4
+	// enterLBFake, exitLBFake and pfcpLBFake are the load balancers the PFCP Agent registers to,
+	// when running in the test process
+	enterLBFake *fake_lb.FakeLB
+	exitLBFake  *fake_lb.FakeLB
+	pfcpLBFake  *fake_lb.FakePFCPLB
+	lbServers   []*httptest.Server
 )
 
 type pfcpSessionData struct {
@@ -164,8 +187,10 @@ func init() {
 		ForceColors:   true,
 	})
 
-	providers.MustPullDockerImage(ImageNameMockUP4)
-	providers.MustCreateNetworkIfNotExists(DockerTestNetwork)
+	if needsDocker() {
+		providers.MustPullDockerImage(ImageNameMockUP4)
+		providers.MustCreateNetworkIfNotExists(DockerTestNetwork)
+	}
 }
 
 func (af appFilter) isEmpty() bool {
@@ -247,20 +272,47 @@ func waitForBESSFakeToStart() error {
 	return waitForPortOpen("tcp", "127.0.0.1", "10514")
 }
 
+// testMode returns the mode set by the MODE env variable, the in-process mode by default.
+func testMode() string {
+	if mode := os.Getenv(EnvMode); mode != "" {
+		return mode
+	}
+
+	return ModeInProcess
+}
+
+// testDatapath returns the datapath set by the DATAPATH env variable, BESS by default.
+func testDatapath() string {
+	if datapath := os.Getenv(EnvDatapath); datapath != "" {
+		return datapath
+	}
+
+	return DatapathBESS
+}
+
 func isModeNative() bool {
-	return os.Getenv(EnvMode) == ModeNative
+	return testMode() == ModeNative
 }
 
 func isModeDocker() bool {
-	return os.Getenv(EnvMode) == ModeDocker
+	return testMode() == ModeDocker
+}
+
+func isModeInProcess() bool {
+	return testMode() == ModeInProcess
 }
 
 func isDatapathUP4() bool {
-	return os.Getenv(EnvDatapath) == DatapathUP4
+	return testDatapath() == DatapathUP4
 }
 
 func isDatapathBESS() bool {
-	return os.Getenv(EnvDatapath) == DatapathBESS
+	return testDatapath() == DatapathBESS
+}
+
+// needsDocker returns true if the PFCP Agent or mock-up4 run in containers.
+func needsDocker() bool {
+	return isModeDocker() || (isModeNative() && isDatapathUP4())
 }
 
 func initForwardingPipelineConfig() {
@@ -319,9 +371,93 @@ func MustStopMockUP4() {
 	providers.MustStopDockerContainer(ContainerNameMockUP4)
 }
 
+// MustStartFakeUP4 starts an in-process fake of mock-up4, listening on the same port.
+func MustStartFakeUP4() {
+	var err error
+
+	up4Fake, err = fake_up4.NewFakeUP4(p4InfoPath)
+	if err != nil {
+		panic(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:50001")
+	if err != nil {
+		panic(err)
+	}
+
+	go func(fu *fake_up4.FakeUP4) {
+		if err := fu.Serve(listener); err != nil {
+			logrus.Errorln("fake UP4 stopped:", err)
+		}
+	}(up4Fake)
+
+	if err := waitForMockUP4ToStart(); err != nil {
+		panic(err)
+	}
+
+	initForwardingPipelineConfig()
+	mustInitCountersWithDummyValue()
+}
+
+// MustStopFakeUP4 stops the fake UP4, losing its state as mock-up4 does.
+func MustStopFakeUP4() {
+	if up4Fake != nil {
+		up4Fake.Stop()
+		up4Fake = nil
+	}
+}
+
+// MustStartUP4 starts mock-up4 or its fake in the in-process mode.
+func MustStartUP4() {
+	if isModeInProcess() {
+		MustStartFakeUP4()
+		return
+	}
+
+	MustStartMockUP4()
+}
+
+// MustStopUP4 stops mock-up4 or its fake in the in-process mode.
+func MustStopUP4() {
+	if isModeInProcess() {
+		MustStopFakeUP4()
+		return
+	}
+
+	MustStopMockUP4()
+}
+
+// startFakeLBs starts fake Enter-LB, Exit-LB and PFCP-LB HTTP servers on loopback, accepting
+// any registration, and returns the config pointing the PFCP Agent to them.
+func startFakeLBs() pfcpiface.LBConf {
+	enterLBFake = fake_lb.NewFakeLB()
+	exitLBFake = fake_lb.NewFakeLB()
+	pfcpLBFake = fake_lb.NewFakePFCPLB()
+
+	lbServers = []*httptest.Server{
+		httptest.NewServer(enterLBFake),
+		httptest.NewServer(exitLBFake),
+		httptest.NewServer(pfcpLBFake),
+	}
+
+	return pfcpiface.LBConf{
+		EnterLBURL: lbServers[0].URL,
+		ExitLBURL:  lbServers[1].URL,
+		PFCPLBURL:  lbServers[2].URL + "/",
+	}
+}
+
+func stopFakeLBs() {
+	for _, s := range lbServers {
+		s.Close()
+	}
+
+	lbServers = nil
+}
+
 func MustStartPFCPAgent() {
 	providers.MustRunDockerContainer(ContainerNamePFCPAgent, ImageNamePFCPAgent, "-config /config/upf.json",
-		[]string{"8805/udp", "8080/tcp"}, "/tmp:/config", DockerTestNetwork)
+		[]string{pfcpiface.PFCPPort + "/udp", "8080/tcp"}, "/tmp:/config", DockerTestNetwork)
 }
 
 func MustStopPFCPAgent() {
@@ -331,9 +467,12 @@ func MustStopPFCPAgent() {
 func setup(t *testing.T, configType uint32) {
 	// TODO: we currently need to reset the DefaultRegisterer between tests, as some leave the
 	// 		 the registry in a bad state. Use custom registries to avoid global state.
-	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	// the /metrics endpoint serves the metrics of the DefaultGatherer
+	prometheus.DefaultGatherer = registry
 
-	switch os.Getenv(EnvDatapath) {
+	switch testDatapath() {
 	case DatapathBESS:
 		bessFake = fake_bess.NewFakeBESS()
 		go func() {
@@ -344,25 +483,34 @@ func setup(t *testing.T, configType uint32) {
 
 		err := waitForBESSFakeToStart()
 		require.NoErrorf(t, err, "failed to start BESS fake: %v", err)
+
+		if configType == ConfigBESSNotifyAndFlowMeasure {
+			// the PFCP Agent connects to the sockets at startup
+			require.NoError(t, bessFake.ListenNotifySocket(bessFakeNotifySockAddr))
+			require.NoError(t, bessFake.ListenEndMarkerSocket(bessFakeEndMarkerSockAddr))
+		}
 	case DatapathUP4:
-		MustStartMockUP4()
+		MustStartUP4()
 	}
 
-	switch os.Getenv(EnvMode) {
+	switch testMode() {
 	case ModeDocker:
-		jsonConf, _ := json.Marshal(GetConfig(os.Getenv(EnvDatapath), configType))
+		jsonConf, _ := json.Marshal(GetConfig(testDatapath(), configType))
 		err := ioutil.WriteFile(ConfigPath, jsonConf, os.ModePerm)
 		require.NoError(t, err)
 		MustStartPFCPAgent()
-	case ModeNative:
-		pfcpAgent = pfcpiface.NewPFCPIface(GetConfig(os.Getenv(EnvDatapath), configType))
+	case ModeNative, ModeInProcess:
+		config := GetConfig(testDatapath(), configType)
+		config.LoadBalancers = startFakeLBs()
+
+		pfcpAgent = pfcpiface.NewPFCPIface(config)
 		go pfcpAgent.Run()
 	default:
 		t.Fatal("Unexpected test mode")
 	}
 
 	pfcpClient = pfcpsim.NewPFCPClient("127.0.0.1")
-	err := pfcpClient.ConnectN4("127.0.0.1")
+	err := pfcpClient.ConnectN4(net.JoinHostPort("127.0.0.1", pfcpiface.PFCPPort))
 	require.NoErrorf(t, err, "failed to connect to UPF")
 
 	// wait for PFCP Agent to initialize, blocking
@@ -376,11 +524,13 @@ func teardown(t *testing.T) {
 		require.NoError(t, err)
 	}
 
-	if pfcpClient != nil {
+	// pfcpsim keeps reading from the N4 socket once closed, spinning on the error and starving
+	// the PFCP Agent when it runs in the test process. Leave the socket open instead.
+	if pfcpClient != nil && isModeDocker() {
 		pfcpClient.DisconnectN4()
 	}
 
-	switch os.Getenv(EnvMode) {
+	switch testMode() {
 	case ModeDocker:
 		err := os.Remove(ConfigPath)
 		require.NoError(t, err)
@@ -390,22 +540,40 @@ func teardown(t *testing.T) {
 			MustStopPFCPAgent()
 			MustStopMockUP4()
 		}
-	case ModeNative:
+	case ModeNative, ModeInProcess:
 		pfcpAgent.Stop()
+		stopFakeLBs()
 	default:
 		t.Fatal("Unexpected test mode")
 	}
 
-	switch os.Getenv(EnvDatapath) {
+	switch testDatapath() {
 	case DatapathBESS:
 		if bessFake != nil {
 			bessFake.Stop()
 		}
+	case DatapathUP4:
+		if isModeInProcess() {
+			MustStopFakeUP4()
+		}
 	}
 }
 
+// scrapeMetrics returns the metrics exposed by the PFCP Agent, in the Prometheus text format.
+func scrapeMetrics(t *testing.T) string {
+	resp, err := http.Get(metricsURL)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
 func verifyEntries(t *testing.T, testdata *pfcpSessionData, expectedValues p4RtValues, ueState UEState) {
-	switch os.Getenv(EnvDatapath) {
+	switch testDatapath() {
 	case DatapathUP4:
 		verifyP4RuntimeEntries(t, testdata, expectedValues, ueState)
 	case DatapathBESS:
@@ -414,7 +582,7 @@ func verifyEntries(t *testing.T, testdata *pfcpSessionData, expectedValues p4RtV
 }
 
 func verifySliceMeter(t *testing.T, expectedValues p4RtValues) {
-	switch os.Getenv(EnvDatapath) {
+	switch testDatapath() {
 	case DatapathUP4:
 		verifyP4RuntimeSliceMeter(t, expectedValues)
 	case DatapathBESS:
@@ -423,7 +591,7 @@ func verifySliceMeter(t *testing.T, expectedValues p4RtValues) {
 }
 
 func verifyNoEntries(t *testing.T, expectedValues p4RtValues) {
-	switch os.Getenv(EnvDatapath) {
+	switch testDatapath() {
 	case DatapathUP4:
 		verifyNoP4RuntimeEntries(t, expectedValues)
 	case DatapathBESS:
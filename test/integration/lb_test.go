@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2022 Open Networking Foundation
+
+package integration
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/omec-project/upf-epc/pkg/fake_lb"
+	"github.com/stretchr/testify/require"
+)
+
+func skipIfModeDocker(t *testing.T) {
+	if isModeDocker() {
+		t.Skip("requires the fake load balancers, only used when the PFCP Agent doesn't run in a container")
+	}
+}
+
+// pushedUEAddresses returns the UE addresses pushed to lb, whatever the gateway.
+func pushedUEAddresses(lb *fake_lb.FakeLB) []string {
+	var addresses []string
+
+	for _, r := range lb.GetRules() {
+		addresses = append(addresses, r.IPs...)
+	}
+
+	return addresses
+}
+
+// lbRegistrationState returns the state of the registration of the PFCP Agent to the LBs.
+func lbRegistrationState(t *testing.T) string {
+	resp, err := http.Get(lbRegistrationURL)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	var status struct {
+		State string `json:"state"`
+	}
+
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+
+	return status.State
+}
+
+func TestLoadBalancerRegistrationAndUERules(t *testing.T) {
+	skipIfModeDocker(t)
+
+	setup(t, ConfigDefault)
+	defer teardown(t)
+
+	// the fake LBs don't send their gateway, as configuring it requires the access and core interfaces
+	require.Eventually(t, func() bool { return lbRegistrationState(t) == "lbs_registered" },
+		5*time.Second, 100*time.Millisecond)
+	require.Len(t, enterLBFake.GetRegistrations(), 1)
+	require.Len(t, exitLBFake.GetRegistrations(), 1)
+
+	tc := testCase{
+		input: &pfcpSessionData{
+			sliceID:      1,
+			nbAddress:    nodeBAddress,
+			ueAddress:    ueAddress,
+			upfN3Address: upfN3Address,
+			sdfFilter:    "permit out udp from any 80-80 to assigned",
+			ulTEID:       15,
+			dlTEID:       16,
+			QFI:          0x9,
+		},
+		expected: p4RtValues{
+			appFilter: appFilter{
+				proto:        0x11,
+				appIP:        net.ParseIP("0.0.0.0"),
+				appPrefixLen: 0,
+				appPort: portRange{
+					80, 80,
+				},
+			},
+			tc: 3,
+		},
+	}
+
+	testUEAttach(t, fillExpected(&tc))
+
+	// pfcpsim doesn't set the message priority, so the UE address is pushed once the session
+	// is established, and not again on modification
+	for _, lb := range []*fake_lb.FakeLB{enterLBFake, exitLBFake} {
+		lb := lb
+		require.Eventually(t, func() bool { return len(pushedUEAddresses(lb)) > 0 }, 5*time.Second, 100*time.Millisecond)
+	}
+
+	time.Sleep(3 * time.Second)
+	require.Equal(t, []string{ueAddress}, pushedUEAddresses(enterLBFake))
+	require.Equal(t, []string{ueAddress}, pushedUEAddresses(exitLBFake))
+
+	// the LBs can pull the UE addresses after a restart
+	resp, err := http.Get(lbUEsURL)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	var rules fake_lb.RuleRequest
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rules))
+	require.Equal(t, []string{ueAddress}, rules.IPs)
+
+	testUEDetach(t, fillExpected(&tc))
+}
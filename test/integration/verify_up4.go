@@ -490,8 +490,12 @@ func verifyNoP4RuntimeEntries(t *testing.T, expectedValues p4RtValues) {
 	}
 	require.Equal(t, 0, nrOfConfiguredMeters, "application meter should not have any cells configured")
 
+	// the tunnel peer is kept in UP4 once idle, to be reused by new sessions towards the same base station
+	tunnelPeers, _ := p4rtClient.ReadTableEntryWildcard(tablesNames[p4constants.TablePreQosPipeTunnelPeers])
+	require.LessOrEqual(t, len(tunnelPeers), 1, "PreQosPipe.tunnel_peers should contain at most 1 idle entry")
+
 	// 2 interfaces entries
-	expectedAllEntries := 2
+	expectedAllEntries := 2 + len(tunnelPeers)
 
 	allInstalledEntries, _ := p4rtClient.ReadTableEntryWildcard("")
 	// table entries for interfaces table are not removed by pfcpiface
@@ -500,7 +504,6 @@ func verifyNoP4RuntimeEntries(t *testing.T, expectedValues p4RtValues) {
 
 	tables := []string{
 		tablesNames[p4constants.TablePreQosPipeApplications],
-		tablesNames[p4constants.TablePreQosPipeTunnelPeers],
 		tablesNames[p4constants.TablePreQosPipeSessionsUplink],
 		tablesNames[p4constants.TablePreQosPipeSessionsDownlink],
 		tablesNames[p4constants.TablePreQosPipeTerminationsUplink],